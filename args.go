@@ -0,0 +1,218 @@
+package db
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrUnsupportedArg is returned when a query argument cannot be converted to
+// a value that database drivers accept.
+var ErrUnsupportedArg = errors.New("unsupported query argument")
+
+// WithArgCoercion will make the wrapper expand slice arguments with [In] and
+// convert arguments with [CoerceArgs] before they are passed to the driver.
+func WithArgCoercion() Option { return func(d *dbOptions) { d.coerceArgs = true } }
+
+// In expands slice arguments into a list of placeholders so that they can be
+// used with an IN clause. Both "?" and "$1" style placeholders are supported.
+//
+//	query, args, err := db.In("select * from users where id in (?)", []int{1, 2, 3})
+//	// select * from users where id in (?, ?, ?)
+func In(query string, args ...any) (string, []any, error) {
+	lists := make([][]any, len(args))
+	expand := false
+	for i, a := range args {
+		if l, ok := asList(a); ok {
+			if len(l) == 0 {
+				return "", nil, errors.Errorf("empty slice passed as argument %d", i+1)
+			}
+			lists[i] = l
+			expand = true
+		}
+	}
+	if !expand {
+		return query, args, nil
+	}
+
+	// The new position of each original argument.
+	positions := make([]int, len(args))
+	flat := make([]any, 0, len(args))
+	for i, a := range args {
+		positions[i] = len(flat) + 1
+		if lists[i] != nil {
+			flat = append(flat, lists[i]...)
+		} else {
+			flat = append(flat, a)
+		}
+	}
+
+	var (
+		b    strings.Builder
+		next int
+	)
+	b.Grow(len(query))
+	for i := 0; i < len(query); {
+		if end, ok := skipQuoted(query, i); ok {
+			b.WriteString(query[i:end])
+			i = end
+			continue
+		}
+		switch c := query[i]; {
+		case c == '?':
+			if next >= len(args) {
+				return "", nil, errors.New("more placeholders than arguments")
+			}
+			writeList(&b, "?", 0, len(lists[next]))
+			next++
+			i++
+		case c == '$' && i+1 < len(query) && isDigit(query[i+1]):
+			n, end := readNumber(query, i+1)
+			if n < 1 || n > len(args) {
+				return "", nil, errors.Errorf("placeholder $%d has no argument", n)
+			}
+			writeList(&b, "$", positions[n-1], len(lists[n-1]))
+			i = end
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.String(), flat, nil
+}
+
+func writeList(b *strings.Builder, prefix string, start, n int) {
+	if n == 0 {
+		n = 1
+	}
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(prefix)
+		if start > 0 {
+			b.WriteString(strconv.Itoa(start + i))
+		}
+	}
+}
+
+// asList returns the elements of v if it is a slice or array that should be
+// expanded by [In].
+func asList(v any) ([]any, bool) {
+	switch v.(type) {
+	case nil, []byte, driver.Valuer:
+		return nil, false
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return nil, false
+		}
+	default:
+		return nil, false
+	}
+	l := make([]any, rv.Len())
+	for i := range l {
+		l[i] = rv.Index(i).Interface()
+	}
+	return l, true
+}
+
+// CoerceArgs converts query arguments into values that database drivers
+// accept. Named string, integer, float and bool types are converted to their
+// underlying types, byte arrays like a [16]byte UUID become byte slices,
+// pointers are dereferenced, and [time.Duration] values are formatted as
+// "HH:MM:SS[.ffffff]" which both postgres intervals and mysql times accept.
+// Arguments that implement [driver.Valuer] are left alone.
+func CoerceArgs(args []any) ([]any, error) {
+	out := make([]any, len(args))
+	for i, a := range args {
+		v, err := coerceArg(a)
+		if err != nil {
+			return nil, errors.Wrapf(err, "argument %d", i+1)
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+func coerceArg(v any) (any, error) {
+	switch a := v.(type) {
+	case nil, driver.Valuer, int64, float64, bool, []byte, string, time.Time:
+		return v, nil
+	case time.Duration:
+		return formatDuration(a), nil
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Pointer:
+		if rv.IsNil() {
+			return nil, nil
+		}
+		return coerceArg(rv.Elem().Interface())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u := rv.Uint()
+		if u > math.MaxInt64 {
+			return nil, errors.Wrapf(ErrUnsupportedArg, "%T value %d overflows int64", v, u)
+		}
+		return int64(u), nil
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	case reflect.Bool:
+		return rv.Bool(), nil
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return rv.Bytes(), nil
+		}
+	case reflect.Array:
+		// Fixed size byte arrays like UUIDs.
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, rv.Len())
+			reflect.Copy(reflect.ValueOf(b), rv)
+			return b, nil
+		}
+	}
+	return nil, errors.Wrapf(ErrUnsupportedArg, "cannot use type %T", v)
+}
+
+func formatDuration(d time.Duration) string {
+	var sign string
+	if d < 0 {
+		sign = "-"
+		d = -d
+	}
+	h := d / time.Hour
+	d -= h * time.Hour
+	m := d / time.Minute
+	d -= m * time.Minute
+	s := d / time.Second
+	d -= s * time.Second
+	if us := d / time.Microsecond; us > 0 {
+		return fmt.Sprintf("%s%02d:%02d:%02d.%06d", sign, h, m, s, us)
+	}
+	return fmt.Sprintf("%s%02d:%02d:%02d", sign, h, m, s)
+}
+
+// normalizeArgs applies [In] and [CoerceArgs] to a query and its arguments.
+func normalizeArgs(query string, args []any) (string, []any, error) {
+	query, args, err := In(query, args...)
+	if err != nil {
+		return "", nil, err
+	}
+	args, err = CoerceArgs(args)
+	if err != nil {
+		return "", nil, err
+	}
+	return query, args, nil
+}
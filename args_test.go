@@ -0,0 +1,136 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/pkg/errors"
+)
+
+func TestIn(t *testing.T) {
+	is := is.New(t)
+	type table struct {
+		query string
+		args  []any
+		exp   string
+		args2 []any
+	}
+	for _, tt := range []table{
+		{
+			query: "select * from t where id in (?)",
+			args:  []any{[]int{1, 2, 3}},
+			exp:   "select * from t where id in (?, ?, ?)",
+			args2: []any{1, 2, 3},
+		},
+		{
+			query: "select * from t where a = ? and id in (?) and b = '?'",
+			args:  []any{"x", []string{"a", "b"}},
+			exp:   "select * from t where a = ? and id in (?, ?) and b = '?'",
+			args2: []any{"x", "a", "b"},
+		},
+		{
+			query: "select * from t where id = any($2) and a = $1 -- $2\nand id in ($2)",
+			args:  []any{"x", [2]int64{5, 6}},
+			exp:   "select * from t where id = any($2, $3) and a = $1 -- $2\nand id in ($2, $3)",
+			args2: []any{"x", int64(5), int64(6)},
+		},
+		{
+			query: "select $$?$$, ? from t",
+			args:  []any{[]byte("raw")},
+			exp:   "select $$?$$, ? from t",
+			args2: []any{[]byte("raw")},
+		},
+	} {
+		q, args, err := In(tt.query, tt.args...)
+		is.NoErr(err)
+		is.Equal(q, tt.exp)
+		is.Equal(args, tt.args2)
+	}
+
+	_, _, err := In("select * from t where id in (?)", []int{})
+	is.True(err != nil)
+	_, _, err = In("select ?, ?", []int{1})
+	is.True(err != nil)
+	_, _, err = In("select $3", []int{1})
+	is.True(err != nil)
+}
+
+func TestCoerceArgs(t *testing.T) {
+	is := is.New(t)
+	type myString string
+	type myInt int32
+	type myUint uint
+	type myFloat float32
+	type myBool bool
+	type myBytes []byte
+	var (
+		s      = "x"
+		nilPtr *int
+	)
+	now := time.Now()
+	args, err := CoerceArgs([]any{
+		myString("a"), myInt(2), myUint(3), myFloat(1.5), myBool(true),
+		myBytes("b"), &s, nilPtr, now, nil, sql.NullInt64{},
+		time.Hour + 2*time.Minute + 3*time.Second,
+		-(time.Second + 500*time.Millisecond),
+	})
+	is.NoErr(err)
+	is.Equal(args, []any{
+		"a", int64(2), int64(3), 1.5, true,
+		[]byte("b"), "x", nil, now, nil, sql.NullInt64{},
+		"01:02:03",
+		"-00:00:01.500000",
+	})
+
+	type uuid [16]byte
+	id := uuid{1, 2, 15: 16}
+	args, err = CoerceArgs([]any{id, &id, [2]byte{'h', 'i'}})
+	is.NoErr(err)
+	is.Equal(args, []any{id[:], id[:], []byte("hi")})
+	_, err = CoerceArgs([]any{[2]int{1, 2}})
+	is.True(errors.Is(err, ErrUnsupportedArg))
+
+	_, err = CoerceArgs([]any{1, map[string]int{}})
+	is.True(errors.Is(err, ErrUnsupportedArg))
+	_, err = CoerceArgs([]any{uint64(1 << 63)})
+	is.True(errors.Is(err, ErrUnsupportedArg))
+}
+
+func TestWithArgCoercion(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	pool, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer pool.Close()
+	pool.SetMaxOpenConns(1)
+	type id int
+	d := New(pool, WithArgCoercion())
+	_, err = d.ExecContext(ctx, "create table t (id int, name text)")
+	is.NoErr(err)
+	_, err = d.ExecContext(ctx, "insert into t values (?, ?), (?, ?), (?, ?)", id(1), "a", id(2), "b", id(3), "c")
+	is.NoErr(err)
+
+	tx, err := d.BeginTx(ctx, nil)
+	is.NoErr(err)
+	_, err = tx.ExecContext(ctx, "delete from t where id in (?)", []id{3})
+	is.NoErr(err)
+	rows, err := tx.QueryContext(ctx, "select name from t where id in (?) order by id", []id{1, 2, 3})
+	is.NoErr(err)
+	var names []string
+	for rows.Next() {
+		var n string
+		is.NoErr(rows.Scan(&n))
+		names = append(names, n)
+	}
+	is.NoErr(rows.Close())
+	is.NoErr(tx.Commit())
+	is.Equal(names, []string{"a", "b"})
+
+	_, err = d.QueryContext(ctx, "select * from t where id = ?", struct{}{})
+	is.True(errors.Is(err, ErrUnsupportedArg))
+	_, err = d.ExecContext(ctx, "delete from t where id in (?)", []int{})
+	is.True(err != nil)
+}
@@ -0,0 +1,181 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// AuditEvent is a write recorded by [WithAudit]. It is either a single
+// statement run with ExecContext outside of a transaction or every
+// statement of a committed transaction.
+type AuditEvent struct {
+	Time time.Time
+	// Actor is the actor set on the context with [Actor].
+	Actor string
+	// Tenant is the tenant set on the context with [Tenant].
+	Tenant string
+	// Tx is true when the statements were committed in a transaction.
+	Tx         bool
+	Statements []AuditStatement
+}
+
+// AuditStatement is a statement in an [AuditEvent]. Only the statement's
+// [Fingerprint] is recorded so values are not leaked into the audit log.
+type AuditStatement struct {
+	Fingerprint string
+	// RowsAffected is -1 if the driver could not report it.
+	RowsAffected int64
+}
+
+// RowsAffected returns the number of rows affected by all the statements.
+func (ev *AuditEvent) RowsAffected() int64 {
+	var n int64
+	for _, s := range ev.Statements {
+		if s.RowsAffected > 0 {
+			n += s.RowsAffected
+		}
+	}
+	return n
+}
+
+// AuditSink stores audit events, i.e. in a table, a log or a message queue.
+type AuditSink interface {
+	Audit(ctx context.Context, ev AuditEvent) error
+}
+
+// AuditFunc is an [AuditSink] func.
+type AuditFunc func(ctx context.Context, ev AuditEvent) error
+
+func (fn AuditFunc) Audit(ctx context.Context, ev AuditEvent) error { return fn(ctx, ev) }
+
+// WithAudit sends an [AuditEvent] to the sink for every ExecContext call and
+// every committed transaction that ran ExecContext. Statements in a
+// transaction are only sent once it commits. Sink errors are logged and do
+// not fail the statement since it has already run.
+func WithAudit(sink AuditSink) Option { return func(o *dbOptions) { o.audit = sink } }
+
+type actorKey struct{}
+
+// Actor returns a context that records the actor, like a user id, in the
+// audit events of statements run with it. See [WithAudit].
+func Actor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom returns the actor set with [Actor].
+func ActorFrom(ctx context.Context) (string, bool) {
+	a, ok := ctx.Value(actorKey{}).(string)
+	return a, ok
+}
+
+// auditingKey marks statements run by an [AuditSink] so they are not
+// audited themselves.
+type auditingKey struct{}
+
+func (db *database) auditExec(ctx context.Context, query string, res sql.Result) {
+	if ctx.Value(auditingKey{}) != nil {
+		return
+	}
+	ev := newAuditEvent(ctx)
+	ev.Statements = []AuditStatement{auditStatement(query, res)}
+	db.sendAudit(ctx, ev)
+}
+
+func (db *database) sendAudit(ctx context.Context, ev AuditEvent) {
+	ev.Time = now()
+	if err := db.audit.Audit(context.WithoutCancel(ctx), ev); err != nil {
+		db.logger.Error("failed to record audit event",
+			slog.String("actor", ev.Actor),
+			slog.Bool("tx", ev.Tx),
+			slog.Any("error", err))
+	}
+}
+
+func newAuditEvent(ctx context.Context) AuditEvent {
+	var ev AuditEvent
+	ev.Actor, _ = ActorFrom(ctx)
+	ev.Tenant, _ = TenantFrom(ctx)
+	return ev
+}
+
+func auditStatement(query string, res sql.Result) AuditStatement {
+	n, err := res.RowsAffected()
+	if err != nil {
+		n = -1
+	}
+	return AuditStatement{Fingerprint: Fingerprint(query), RowsAffected: n}
+}
+
+// txAudit collects the statements of a transaction until it commits.
+type txAudit struct {
+	ctx   context.Context
+	event AuditEvent
+}
+
+func (a *txAudit) add(ctx context.Context, query string, res sql.Result) {
+	if ctx.Value(auditingKey{}) != nil {
+		return
+	}
+	if len(a.event.Actor) == 0 {
+		a.event.Actor, _ = ActorFrom(ctx)
+	}
+	a.event.Statements = append(a.event.Statements, auditStatement(query, res))
+}
+
+// AuditLogger returns an [AuditSink] that logs events at the info level.
+func AuditLogger(l *slog.Logger) AuditSink {
+	return AuditFunc(func(ctx context.Context, ev AuditEvent) error {
+		fingerprints := make([]string, len(ev.Statements))
+		for i, s := range ev.Statements {
+			fingerprints[i] = s.Fingerprint
+		}
+		l.LogAttrs(ctx, slog.LevelInfo, "audit",
+			slog.Time("time", ev.Time),
+			slog.String("actor", ev.Actor),
+			slog.String("tenant", ev.Tenant),
+			slog.Bool("tx", ev.Tx),
+			slog.Any("statements", fingerprints),
+			slog.Int64("rows_affected", ev.RowsAffected()))
+		return nil
+	})
+}
+
+// AuditTable returns an [AuditSink] that inserts a row for each statement
+// into a table created by [CreateAuditTable]. The database may be the one
+// that is audited, the inserts are not audited.
+func AuditTable(d DB, table string) AuditSink {
+	return AuditFunc(func(ctx context.Context, ev AuditEvent) error {
+		if len(ev.Statements) == 0 {
+			return nil
+		}
+		dialect := DialectOf(d)
+		rows := make([]string, len(ev.Statements))
+		args := make([]any, 0, len(ev.Statements)*6)
+		for i, s := range ev.Statements {
+			rows[i] = "(?, ?, ?, ?, ?, ?)"
+			args = append(args, ev.Time.UTC(), ev.Actor, ev.Tenant, ev.Tx, s.Fingerprint, s.RowsAffected)
+		}
+		query := Rebind(dialect.Type(), "INSERT INTO "+dialect.QuoteIdent(table)+
+			" (at, actor, tenant, in_tx, fingerprint, rows_affected) VALUES "+strings.Join(rows, ", "))
+		_, err := d.ExecContext(context.WithValue(ctx, auditingKey{}, true), query, args...)
+		return err
+	})
+}
+
+// CreateAuditTable creates the table used by [AuditTable] if it doesn't
+// exist.
+func CreateAuditTable(ctx context.Context, d DB, table string) error {
+	_, err := d.ExecContext(context.WithValue(ctx, auditingKey{}, true),
+		`CREATE TABLE IF NOT EXISTS `+DialectOf(d).QuoteIdent(table)+` (
+		at            TIMESTAMP NOT NULL,
+		actor         VARCHAR(255) NOT NULL,
+		tenant        VARCHAR(255) NOT NULL,
+		in_tx         BOOLEAN NOT NULL,
+		fingerprint   TEXT NOT NULL,
+		rows_affected BIGINT NOT NULL
+	)`)
+	return err
+}
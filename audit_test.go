@@ -0,0 +1,91 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/pkg/errors"
+)
+
+func TestWithAudit(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	pool, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer pool.Close()
+	pool.SetMaxOpenConns(1)
+	ts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now = func() time.Time { return ts }
+	defer func() { now = time.Now }()
+
+	var (
+		events []AuditEvent
+		table  AuditSink
+		logs   bytes.Buffer
+	)
+	logged := AuditLogger(slog.New(slog.NewTextHandler(&logs, nil)))
+	d := New(pool, WithDialect(DialectFor(SQLiteDBType)), WithAudit(AuditFunc(func(ctx context.Context, ev AuditEvent) error {
+		events = append(events, ev)
+		is.NoErr(logged.Audit(ctx, ev))
+		return table.Audit(ctx, ev)
+	})))
+	table = AuditTable(d, "audit_log")
+	is.NoErr(CreateAuditTable(ctx, d, "audit_log"))
+	is.Equal(len(events), 0)
+
+	_, err = d.ExecContext(ctx, "CREATE TABLE t (id INTEGER PRIMARY KEY, name TEXT)")
+	is.NoErr(err)
+	actx := Actor(ctx, "alice")
+	_, err = d.ExecContext(actx, "INSERT INTO t (name) VALUES ('secret'), ('b')")
+	is.NoErr(err)
+	is.Equal(len(events), 2)
+	is.Equal(events[1].Actor, "alice")
+	is.True(!events[1].Tx)
+	is.Equal(events[1].Statements, []AuditStatement{{Fingerprint: Fingerprint("INSERT INTO t (name) VALUES ('secret'), ('b')"), RowsAffected: 2}})
+	is.True(!strings.Contains(events[1].Statements[0].Fingerprint, "secret"))
+
+	// Rolled back transactions and queries are not audited.
+	tx, err := d.BeginTx(actx, nil)
+	is.NoErr(err)
+	_, err = tx.ExecContext(ctx, "DELETE FROM t")
+	is.NoErr(err)
+	is.NoErr(tx.Rollback())
+	rows, err := d.QueryContext(ctx, "SELECT 1")
+	is.NoErr(err)
+	is.NoErr(rows.Close())
+	is.Equal(len(events), 2)
+
+	tx, err = d.BeginTx(ctx, nil)
+	is.NoErr(err)
+	_, err = tx.ExecContext(Actor(ctx, "bob"), "UPDATE t SET name = 'c' WHERE id = 1")
+	is.NoErr(err)
+	_, err = tx.ExecContext(ctx, "DELETE FROM t")
+	is.NoErr(err)
+	is.NoErr(tx.Commit())
+	is.Equal(len(events), 3)
+	ev := events[2]
+	is.True(ev.Tx)
+	is.Equal(ev.Actor, "bob")
+	is.True(ev.Time.Equal(ts))
+	is.Equal(len(ev.Statements), 2)
+	is.Equal(ev.RowsAffected(), int64(3))
+
+	var n int
+	is.NoErr(Get(ctx, d, &n, "SELECT count(*) FROM audit_log WHERE in_tx"))
+	is.Equal(n, 2)
+	is.NoErr(Get(ctx, d, &n, "SELECT count(*) FROM audit_log WHERE actor = 'alice'"))
+	is.Equal(n, 1)
+	is.True(strings.Contains(logs.String(), "actor=bob"))
+
+	// Sink errors are logged and don't fail the statement.
+	table = AuditFunc(func(context.Context, AuditEvent) error { return errors.New("sink down") })
+	_, err = d.ExecContext(ctx, "DELETE FROM t")
+	is.NoErr(err)
+	is.NoErr(AuditTable(d, "audit_log").Audit(ctx, AuditEvent{}))
+}
@@ -0,0 +1,180 @@
+package db
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// BatchOpt is an option for [DeleteInBatches] and [UpdateInBatches].
+type BatchOpt func(*batchOpts)
+
+type batchOpts struct {
+	typ      Type
+	args     []any
+	pause    time.Duration
+	progress func(batch int, affected, total int64)
+}
+
+// WithBatchType sets the database type used to limit each batch. Defaults to
+// the type of the database's [Dialect].
+func WithBatchType(t Type) BatchOpt { return func(o *batchOpts) { o.typ = t } }
+
+// WithBatchArgs sets the arguments for the placeholders in the statement.
+func WithBatchArgs(args ...any) BatchOpt { return func(o *batchOpts) { o.args = args } }
+
+// WithBatchPause sets a pause between batches so that large deletes don't
+// starve other queries.
+func WithBatchPause(d time.Duration) BatchOpt { return func(o *batchOpts) { o.pause = d } }
+
+// WithBatchProgress sets a callback that is called after each batch with the
+// batch number, the rows affected by the batch, and the total rows affected.
+func WithBatchProgress(fn func(batch int, affected, total int64)) BatchOpt {
+	return func(o *batchOpts) { o.progress = fn }
+}
+
+// DeleteInBatches runs a "DELETE FROM table WHERE ..." statement repeatedly,
+// affecting at most batchSize rows each time, until no rows are affected.
+// Postgres does not support DELETE with LIMIT so the rows of each batch are
+// selected by ctid, and sqlite uses the rowid.
+func DeleteInBatches(ctx context.Context, db DB, query string, batchSize int, opts ...BatchOpt) (int64, error) {
+	return execInBatches(ctx, db, query, batchSize, opts)
+}
+
+// UpdateInBatches is the same as [DeleteInBatches] but for "UPDATE table SET
+// ... WHERE ..." statements. The WHERE clause must stop matching rows once
+// they are updated or the loop will never end.
+func UpdateInBatches(ctx context.Context, db DB, query string, batchSize int, opts ...BatchOpt) (int64, error) {
+	return execInBatches(ctx, db, query, batchSize, opts)
+}
+
+func execInBatches(ctx context.Context, db DB, query string, batchSize int, opts []BatchOpt) (int64, error) {
+	o := batchOpts{typ: DialectOf(db).Type()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if batchSize <= 0 {
+		return 0, errors.New("batch size must be greater than zero")
+	}
+	q, err := limitStatement(o.typ, query, batchSize)
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for batch := 1; ; batch++ {
+		res, err := db.ExecContext(ctx, q, o.args...)
+		if err != nil {
+			return total, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return total, err
+		}
+		total += n
+		if o.progress != nil {
+			o.progress(batch, n, total)
+		}
+		if n == 0 {
+			return total, nil
+		}
+		if o.pause > 0 {
+			select {
+			case <-ctx.Done():
+				return total, ctx.Err()
+			case <-time.After(o.pause):
+			}
+		}
+	}
+}
+
+// limitStatement rewrites a DELETE or UPDATE statement so that it affects at
+// most n rows.
+func limitStatement(t Type, query string, n int) (string, error) {
+	query = strings.TrimRight(strings.TrimSpace(query), ";")
+	fields := strings.Fields(query)
+	var ti int
+	switch {
+	case len(fields) >= 3 && strings.EqualFold(fields[0], "delete") && strings.EqualFold(fields[1], "from"):
+		ti = 2
+	case len(fields) >= 2 && strings.EqualFold(fields[0], "update"):
+		ti = 1
+	default:
+		return "", errors.New("batched statements must be a DELETE or UPDATE")
+	}
+	// The subquery selects from the table under the same alias so that the
+	// condition's references to the alias still resolve.
+	table := fields[ti]
+	if alias := tableAlias(fields[ti+1:]); len(alias) > 0 {
+		table += " AS " + alias
+	}
+	limit := strconv.Itoa(n)
+	var rowID string
+	switch t {
+	case MySQLDBType:
+		return query + " LIMIT " + limit, nil
+	case PostgresDBType:
+		rowID = "ctid"
+	case SQLiteDBType:
+		rowID = "rowid"
+	default:
+		return "", errors.Errorf("batched statements not supported for %q", t)
+	}
+	i := indexKeyword(query, "where")
+	if i < 0 {
+		return query + " WHERE " + rowID + " IN (SELECT " + rowID + " FROM " + table + " LIMIT " + limit + ")", nil
+	}
+	cond := strings.TrimSpace(query[i+len("where"):])
+	return query[:i] + "WHERE " + rowID + " IN (SELECT " + rowID + " FROM " + table +
+		" WHERE " + cond + " LIMIT " + limit + ")", nil
+}
+
+// tableAlias returns the alias that follows the table name of a DELETE or
+// UPDATE statement, given the words after the table name.
+func tableAlias(fields []string) string {
+	if len(fields) == 0 {
+		return ""
+	}
+	if strings.EqualFold(fields[0], "as") {
+		if len(fields) < 2 {
+			return ""
+		}
+		return fields[1]
+	}
+	switch strings.ToLower(fields[0]) {
+	case "where", "set", "using", "returning", "order", "limit":
+		return ""
+	}
+	return fields[0]
+}
+
+// indexKeyword finds the first case insensitive occurrence of a keyword that is
+// not inside parentheses, quotes or comments.
+func indexKeyword(query, keyword string) int {
+	depth := 0
+	for i := 0; i < len(query); {
+		if end, ok := skipQuoted(query, i); ok {
+			i = end
+			continue
+		}
+		switch c := query[i]; c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		default:
+			if depth == 0 && len(query)-i >= len(keyword) &&
+				strings.EqualFold(query[i:i+len(keyword)], keyword) &&
+				(i == 0 || !isIdentChar(query[i-1])) &&
+				(i+len(keyword) == len(query) || !isIdentChar(query[i+len(keyword)])) {
+				return i
+			}
+		}
+		i++
+	}
+	return -1
+}
+
+func isIdentChar(c byte) bool { return c == '_' || isLetter(c) || isDigit(c) }
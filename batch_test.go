@@ -0,0 +1,85 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestLimitStatement(t *testing.T) {
+	is := is.New(t)
+	type table struct {
+		typ   Type
+		query string
+		exp   string
+	}
+	for _, tt := range []table{
+		{PostgresDBType, "DELETE FROM events WHERE created_at < $1;",
+			"DELETE FROM events WHERE ctid IN (SELECT ctid FROM events WHERE created_at < $1 LIMIT 10)"},
+		{PostgresDBType, "delete from events",
+			"delete from events WHERE ctid IN (SELECT ctid FROM events LIMIT 10)"},
+		{SQLiteDBType, "update t set a = (select 1 where 'where' = 'x') where a is null",
+			"update t set a = (select 1 where 'where' = 'x') WHERE rowid IN (SELECT rowid FROM t WHERE a is null LIMIT 10)"},
+		{MySQLDBType, "delete from events where id > ?", "delete from events where id > ? LIMIT 10"},
+		{PostgresDBType, "DELETE FROM events AS e WHERE e.created_at < $1",
+			"DELETE FROM events AS e WHERE ctid IN (SELECT ctid FROM events AS e WHERE e.created_at < $1 LIMIT 10)"},
+		{SQLiteDBType, "update t x set a = 1 where x.a is null",
+			"update t x set a = 1 WHERE rowid IN (SELECT rowid FROM t AS x WHERE x.a is null LIMIT 10)"},
+	} {
+		q, err := limitStatement(tt.typ, tt.query, 10)
+		is.NoErr(err)
+		is.Equal(q, tt.exp)
+	}
+	_, err := limitStatement(PostgresDBType, "select 1", 10)
+	is.True(err != nil)
+	_, err = limitStatement("oracle", "delete from t", 10)
+	is.True(err != nil)
+}
+
+func TestDeleteInBatches(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	pool, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer pool.Close()
+	pool.SetMaxOpenConns(1)
+	d := Simple(pool)
+	_, err = d.ExecContext(ctx, "create table t (id int, done bool)")
+	is.NoErr(err)
+	for i := 0; i < 25; i++ {
+		_, err = d.ExecContext(ctx, "insert into t values (?, false)", i)
+		is.NoErr(err)
+	}
+
+	var batches []int64
+	n, err := UpdateInBatches(ctx, d, "update t set done = true where done = ? and id < ?", 4,
+		WithBatchType(SQLiteDBType),
+		WithBatchArgs(false, 10),
+		WithBatchProgress(func(batch int, affected, total int64) {
+			batches = append(batches, affected)
+		}))
+	is.NoErr(err)
+	is.Equal(n, int64(10))
+	is.Equal(batches, []int64{4, 4, 2, 0})
+
+	n, err = DeleteInBatches(ctx, d, "delete from t", 10,
+		WithBatchType(SQLiteDBType), WithBatchPause(time.Millisecond))
+	is.NoErr(err)
+	is.Equal(n, int64(25))
+
+	_, err = d.ExecContext(ctx, "insert into t values (1, false), (2, true)")
+	is.NoErr(err)
+	n, err = DeleteInBatches(ctx, d, "delete from t as x where x.done", 10, WithBatchType(SQLiteDBType))
+	is.NoErr(err)
+	is.Equal(n, int64(1))
+
+	_, err = DeleteInBatches(ctx, d, "delete from t", 0)
+	is.True(err != nil)
+	_, err = DeleteInBatches(ctx, d, "select 1", 1)
+	is.True(err != nil)
+	_, err = DeleteInBatches(ctx, d, "delete from nope", 1, WithBatchType(SQLiteDBType))
+	is.True(err != nil)
+}
@@ -0,0 +1,99 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+// ErrBatchAborted is the error of the statements in a [Batch] that were not
+// run because an earlier statement failed.
+var ErrBatchAborted = errors.New("batch aborted by an earlier statement")
+
+// BatchStmt is a statement queued in a [Batch].
+type BatchStmt struct {
+	Query string
+	Args  []any
+}
+
+// BatchResult is the result of a statement in a [Batch].
+type BatchResult struct {
+	Result sql.Result
+	Err    error
+}
+
+// BatchExecer is implemented by databases that can send every statement of a
+// [Batch] in a single round trip, like the pgx adapter which uses a pgx
+// batch. The statements must be run atomically and there must be a result
+// for each one.
+type BatchExecer interface {
+	ExecBatch(ctx context.Context, stmts []BatchStmt) ([]BatchResult, error)
+}
+
+// Batch is a list of statements that are run together by [Batch.Run].
+type Batch struct {
+	stmts []BatchStmt
+}
+
+// Queue adds a statement to the batch.
+func (b *Batch) Queue(query string, args ...any) {
+	b.stmts = append(b.stmts, BatchStmt{Query: query, Args: args})
+}
+
+// Len returns the number of queued statements.
+func (b *Batch) Len() int { return len(b.stmts) }
+
+// Statements returns the queued statements.
+func (b *Batch) Statements() []BatchStmt { return b.stmts }
+
+// Run runs every statement and returns their results in the order they were
+// queued. Databases that implement [BatchExecer] send the whole batch in one
+// round trip, others run the statements one by one in a transaction, or in
+// the transaction if d is a [Tx]. Either way a failed statement stops the
+// batch: its result holds the error, the statements after it get
+// [ErrBatchAborted] and the first error is returned. Outside of a
+// transaction nothing is committed when a statement fails.
+func (b *Batch) Run(ctx context.Context, d DB) ([]BatchResult, error) {
+	if len(b.stmts) == 0 {
+		return nil, nil
+	}
+	if e, ok := d.(BatchExecer); ok {
+		return e.ExecBatch(ctx, b.stmts)
+	}
+	if tx, ok := d.(Tx); ok {
+		return b.exec(ctx, tx)
+	}
+	tx, err := Begin(ctx, nil, d)
+	if err != nil {
+		return nil, err
+	}
+	var results []BatchResult
+	err = TxDo(ctx, tx, func(tx Tx) (err error) {
+		results, err = b.exec(ctx, tx)
+		return err
+	})
+	if err != nil && results != nil && results[len(results)-1].Err == nil {
+		// The commit failed so none of the statements took effect.
+		for i := range results {
+			results[i] = BatchResult{Err: err}
+		}
+	}
+	return results, err
+}
+
+func (b *Batch) exec(ctx context.Context, tx Tx) ([]BatchResult, error) {
+	results := make([]BatchResult, len(b.stmts))
+	for i, s := range b.stmts {
+		res, err := tx.ExecContext(ctx, s.Query, s.Args...)
+		if err != nil {
+			results[i].Err = err
+			for j := i + 1; j < len(results); j++ {
+				results[j].Err = ErrBatchAborted
+			}
+			return results, err
+		}
+		results[i].Result = res
+	}
+	return results, nil
+}
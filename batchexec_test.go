@@ -0,0 +1,61 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/pkg/errors"
+)
+
+func TestBatch(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	d := newRepoDB(t)
+	_, err := d.ExecContext(ctx, "CREATE TABLE t (id int PRIMARY KEY, name text)")
+	is.NoErr(err)
+
+	var b Batch
+	results, err := b.Run(ctx, d)
+	is.NoErr(err)
+	is.Equal(len(results), 0)
+
+	b.Queue("INSERT INTO t VALUES (?, ?)", 1, "a")
+	b.Queue("INSERT INTO t VALUES (?, ?), (?, ?)", 2, "b", 3, "c")
+	b.Queue("UPDATE t SET name = 'x' WHERE id > ?", 1)
+	is.Equal(b.Len(), 3)
+	results, err = b.Run(ctx, d)
+	is.NoErr(err)
+	is.Equal(len(results), 3)
+	for i, exp := range []int64{1, 2, 2} {
+		is.NoErr(results[i].Err)
+		n, err := results[i].Result.RowsAffected()
+		is.NoErr(err)
+		is.Equal(n, exp)
+	}
+
+	// The batch runs in a transaction so nothing is written when a
+	// statement fails.
+	var fail Batch
+	fail.Queue("INSERT INTO t VALUES (?, ?)", 4, "d")
+	fail.Queue("INSERT INTO t VALUES (?, ?)", 1, "a")
+	fail.Queue("INSERT INTO t VALUES (?, ?)", 5, "e")
+	results, err = fail.Run(ctx, d)
+	is.True(err != nil)
+	is.NoErr(results[0].Err)
+	is.True(results[1].Err != nil)
+	is.True(errors.Is(results[2].Err, ErrBatchAborted))
+	var n int
+	is.NoErr(Get(ctx, d, &n, "SELECT count(*) FROM t"))
+	is.Equal(n, 3)
+
+	tx, err := d.BeginTx(ctx, nil)
+	is.NoErr(err)
+	var more Batch
+	more.Queue("DELETE FROM t WHERE id = ?", 3)
+	_, err = more.Run(ctx, tx)
+	is.NoErr(err)
+	is.NoErr(tx.Rollback())
+	is.NoErr(Get(ctx, d, &n, "SELECT count(*) FROM t"))
+	is.Equal(n, 3)
+}
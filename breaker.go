@@ -0,0 +1,133 @@
+package db
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrCircuitOpen is returned by the wrapper from [New] when the circuit
+// breaker is open and statements are not being sent to the database.
+var ErrCircuitOpen = errors.New("database circuit breaker is open")
+
+// CircuitState is the state of a circuit breaker.
+type CircuitState int
+
+const (
+	// CircuitClosed means statements are sent to the database.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen means statements fail fast with [ErrCircuitOpen].
+	CircuitOpen
+	// CircuitHalfOpen means the database is being probed to see if it has
+	// recovered.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// WithCircuitBreaker opens a circuit breaker after threshold consecutive
+// connection failures. While open, statements fail with [ErrCircuitOpen]
+// without touching the database. After the cooldown the next statement
+// probes the database with PingContext and closes the circuit if it
+// succeeds.
+func WithCircuitBreaker(threshold int, cooldown time.Duration) Option {
+	return func(o *dbOptions) {
+		if threshold <= 0 {
+			threshold = 1
+		}
+		o.breaker = &breaker{threshold: threshold, cooldown: cooldown}
+	}
+}
+
+type breaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	state     CircuitState
+	openedAt  time.Time
+}
+
+// allow returns [ErrCircuitOpen] if statements should not be sent to the
+// database.
+func (b *breaker) allow(ctx context.Context, p Pingable) error {
+	b.mu.Lock()
+	switch b.state {
+	case CircuitClosed:
+		b.mu.Unlock()
+		return nil
+	case CircuitHalfOpen:
+		// Another caller is probing.
+		b.mu.Unlock()
+		return ErrCircuitOpen
+	}
+	if now().Sub(b.openedAt) < b.cooldown {
+		b.mu.Unlock()
+		return ErrCircuitOpen
+	}
+	b.state = CircuitHalfOpen
+	b.mu.Unlock()
+
+	err := p.PingContext(ctx)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err != nil {
+		b.state = CircuitOpen
+		b.openedAt = now()
+		return errors.WithMessage(ErrCircuitOpen, err.Error())
+	}
+	b.state = CircuitClosed
+	b.failures = 0
+	return nil
+}
+
+// record updates the breaker with the result of a statement.
+func (b *breaker) record(err error) {
+	if errors.Is(err, context.Canceled) {
+		// The caller gave up so there is nothing to say about the database.
+		return
+	}
+	if err != nil && !IsConnectionError(err) {
+		// The database answered so it is still up.
+		err = nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.state == CircuitClosed && b.failures >= b.threshold {
+		b.state = CircuitOpen
+		b.openedAt = now()
+	}
+}
+
+func (b *breaker) State() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// CircuitState returns the state of the circuit breaker. It is always
+// [CircuitClosed] when the wrapper has no circuit breaker.
+func (db *database) CircuitState() CircuitState {
+	if db.breaker == nil {
+		return CircuitClosed
+	}
+	return db.breaker.State()
+}
@@ -0,0 +1,63 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/pkg/errors"
+)
+
+func TestWithCircuitBreaker(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	pool, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer pool.Close()
+	d := New(pool, WithCircuitBreaker(2, time.Minute))
+	is.Equal(d.CircuitState(), CircuitClosed)
+
+	calls := 0
+	fail := func(context.Context, string, []any) error { calls++; return driver.ErrBadConn }
+	ok := func(context.Context, string, []any) error { calls++; return nil }
+
+	is.True(errors.Is(d.run(ctx, opQuery, "select 1", nil, fail), driver.ErrBadConn))
+	// other errors mean the database is up
+	is.True(d.run(ctx, opQuery, "select 1", nil, func(context.Context, string, []any) error {
+		return errors.New("syntax error")
+	}) != nil)
+	is.True(d.run(ctx, opQuery, "select 1", nil, fail) != nil)
+	is.Equal(d.CircuitState(), CircuitClosed)
+	is.True(d.run(ctx, opQuery, "select 1", nil, fail) != nil)
+	is.Equal(d.CircuitState(), CircuitOpen)
+
+	calls = 0
+	err = d.run(ctx, opQuery, "select 1", nil, ok)
+	is.True(errors.Is(err, ErrCircuitOpen))
+	var qe *QueryError
+	is.True(errors.As(err, &qe))
+	is.Equal(calls, 0)
+
+	// half open probe fails then succeeds
+	reset := withNow(time.Now().Add(2 * time.Minute))
+	defer reset()
+	down := pingFunc(func(context.Context) error { return driver.ErrBadConn })
+	is.True(errors.Is(d.breaker.allow(ctx, down), ErrCircuitOpen))
+	is.Equal(d.CircuitState(), CircuitOpen)
+	is.True(errors.Is(d.run(ctx, opQuery, "select 1", nil, ok), ErrCircuitOpen))
+	is.Equal(calls, 0)
+
+	withNow(time.Now().Add(4 * time.Minute))
+	is.NoErr(d.run(ctx, opQuery, "select 1", nil, ok))
+	is.Equal(calls, 1)
+	is.Equal(d.CircuitState(), CircuitClosed)
+
+	// canceled statements are ignored
+	d.breaker.record(context.Canceled)
+	is.Equal(d.breaker.failures, 0)
+	is.Equal(CircuitHalfOpen.String(), "half-open")
+	is.Equal(New(pool).CircuitState(), CircuitClosed)
+}
@@ -0,0 +1,204 @@
+package db
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNoConflictClause is returned by [BulkInsert] and [UpsertStmt] when the
+// database's [Dialect] can't express the ON CONFLICT clause that the options
+// need, i.e. on SQL Server or on mysql without conflict or update columns.
+var ErrNoConflictClause = errors.New("dialect cannot handle conflicting rows")
+
+// BulkOpt is an option for [BulkInsert].
+type BulkOpt func(*bulkOpts)
+
+type bulkOpts struct {
+	maxParams       int
+	maxRows         int
+	ignoreConflicts bool
+	conflictCols    []string
+	// updateCols are updated when a row conflicts. Nil means every inserted
+	// column that is not a conflict column.
+	updateCols []string
+	upsert     bool
+}
+
+// WithBulkMaxParams sets the maximum number of placeholders in each INSERT
+// statement. Defaults to the limit of the database's [Dialect].
+func WithBulkMaxParams(n int) BulkOpt { return func(o *bulkOpts) { o.maxParams = n } }
+
+// WithBulkMaxRows sets the maximum number of rows in each INSERT statement.
+func WithBulkMaxRows(n int) BulkOpt { return func(o *bulkOpts) { o.maxRows = n } }
+
+// WithIgnoreConflicts skips rows that conflict with existing rows using
+// "ON CONFLICT DO NOTHING". The conflict columns are optional on postgres
+// and sqlite. On mysql "INSERT IGNORE" is used when there are no conflict
+// columns.
+func WithIgnoreConflicts(conflictCols ...string) BulkOpt {
+	return func(o *bulkOpts) {
+		o.ignoreConflicts = true
+		o.conflictCols = conflictCols
+	}
+}
+
+// WithUpsert updates existing rows that conflict on the conflict columns.
+// If no update columns are given then every inserted column except the
+// conflict columns is updated. See [Upsert].
+func WithUpsert(conflictCols, updateCols []string) BulkOpt {
+	return func(o *bulkOpts) {
+		o.upsert = true
+		o.conflictCols = conflictCols
+		o.updateCols = updateCols
+	}
+}
+
+// BulkInsert inserts many rows using multi-row INSERT statements. The rows
+// are split into as many statements as needed to stay under the database's
+// placeholder limit and all of them are run in one transaction. The number
+// of rows inserted is returned.
+func BulkInsert(ctx context.Context, db DB, table string, cols []string, rows [][]any, opts ...BulkOpt) (int64, error) {
+	return bulkInsert(ctx, db, DialectOf(db), table, cols, rows, opts)
+}
+
+func bulkInsert(ctx context.Context, db DB, dialect Dialect, table string, cols []string, rows [][]any, opts []BulkOpt) (int64, error) {
+	if len(cols) == 0 {
+		return 0, errors.New("bulk insert needs at least one column")
+	}
+	for i, r := range rows {
+		if len(r) != len(cols) {
+			return 0, errors.Errorf("row %d has %d values, expected %d", i, len(r), len(cols))
+		}
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+	o := bulkOpts{maxParams: dialect.MaxParams()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if _, err := conflictClause(dialect, cols, &o); err != nil {
+		return 0, err
+	}
+	perStmt := o.maxParams / len(cols)
+	if o.maxRows > 0 && o.maxRows < perStmt {
+		perStmt = o.maxRows
+	}
+	if perStmt <= 0 {
+		return 0, errors.Errorf("%d columns do not fit in %d placeholders", len(cols), o.maxParams)
+	}
+
+	var total int64
+	insert := func(tx DB) error {
+		for start := 0; start < len(rows); start += perStmt {
+			chunk := rows[start:min(start+perStmt, len(rows))]
+			query, args, err := bulkInsertQuery(dialect, table, cols, chunk, &o)
+			if err != nil {
+				return err
+			}
+			res, err := tx.ExecContext(ctx, query, args...)
+			if err != nil {
+				return err
+			}
+			n, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+			total += n
+		}
+		return nil
+	}
+	if tx, ok := db.(Tx); ok {
+		return total, insert(tx)
+	}
+	tx, err := Begin(ctx, nil, db)
+	if err != nil {
+		return 0, err
+	}
+	err = TxDo(ctx, tx, func(tx Tx) error { return insert(tx) })
+	if err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+func bulkInsertQuery(d Dialect, table string, cols []string, rows [][]any, o *bulkOpts) (string, []any, error) {
+	conflict, err := conflictClause(d, cols, o)
+	if err != nil {
+		return "", nil, err
+	}
+	var b strings.Builder
+	args := make([]any, 0, len(rows)*len(cols))
+	if mysqlIgnore(d, o) {
+		b.WriteString("INSERT IGNORE INTO ")
+	} else {
+		b.WriteString("INSERT INTO ")
+	}
+	b.WriteString(d.QuoteIdent(table))
+	b.WriteString(" (")
+	b.WriteString(strings.Join(quoteAll(d, cols), ", "))
+	b.WriteString(") VALUES ")
+	n := 0
+	for i, r := range rows {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteByte('(')
+		for j, v := range r {
+			if j > 0 {
+				b.WriteString(", ")
+			}
+			n++
+			b.WriteString(d.Placeholder(n))
+			args = append(args, v)
+		}
+		b.WriteByte(')')
+	}
+	if len(conflict) > 0 {
+		b.WriteByte(' ')
+		b.WriteString(conflict)
+	}
+	return b.String(), args, nil
+}
+
+// mysqlIgnore is true when conflicts are ignored with "INSERT IGNORE".
+func mysqlIgnore(d Dialect, o *bulkOpts) bool {
+	return o.ignoreConflicts && len(o.conflictCols) == 0 && d.Type() == MySQLDBType
+}
+
+// conflictClause returns the clause that handles conflicting rows. It is an
+// error if the options need one and the dialect can't express it, so that an
+// upsert never runs as a plain INSERT.
+func conflictClause(d Dialect, cols []string, o *bulkOpts) (string, error) {
+	var clause string
+	switch {
+	case o.upsert:
+		update := o.updateCols
+		if update == nil {
+			update = without(cols, o.conflictCols)
+		}
+		clause = d.OnConflict(o.conflictCols, update)
+	case o.ignoreConflicts && !mysqlIgnore(d, o):
+		clause = d.OnConflict(o.conflictCols, nil)
+	default:
+		return "", nil
+	}
+	if len(clause) == 0 {
+		return "", errors.Wrapf(ErrNoConflictClause, "%s with conflict columns %q", d.Type(), o.conflictCols)
+	}
+	return clause, nil
+}
+
+// without returns the strings in list that are not in remove.
+func without(list, remove []string) []string {
+	res := make([]string, 0, len(list))
+	for _, s := range list {
+		if !slices.Contains(remove, s) {
+			res = append(res, s)
+		}
+	}
+	return res
+}
@@ -0,0 +1,70 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestBulkInsert(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	pool, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer pool.Close()
+	pool.SetMaxOpenConns(1)
+	d := New(pool, WithDialect(DialectFor(SQLiteDBType)))
+	_, err = d.ExecContext(ctx, "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)")
+	is.NoErr(err)
+
+	rows := make([][]any, 0, 10)
+	for i := range 10 {
+		rows = append(rows, []any{i, "user"})
+	}
+	n, err := BulkInsert(ctx, d, "users", []string{"id", "name"}, rows, WithBulkMaxParams(6))
+	is.NoErr(err)
+	is.Equal(n, int64(10))
+
+	// Conflicts roll back the whole insert.
+	_, err = BulkInsert(ctx, d, "users", []string{"id", "name"}, [][]any{{100, "a"}, {1, "b"}}, WithBulkMaxRows(1))
+	is.True(IsUniqueViolation(err))
+	var count int
+	is.NoErr(pool.QueryRow("SELECT count(*) FROM users").Scan(&count))
+	is.Equal(count, 10)
+
+	n, err = BulkInsert(ctx, d, "users", []string{"id", "name"}, [][]any{{100, "a"}, {1, "b"}}, WithIgnoreConflicts("id"))
+	is.NoErr(err)
+	is.Equal(n, int64(1))
+
+	tx, err := d.BeginTx(ctx, nil)
+	is.NoErr(err)
+	n, err = BulkInsert(ctx, tx, "users", []string{"id", "name"}, [][]any{{200, "c"}})
+	is.NoErr(err)
+	is.Equal(n, int64(1))
+	is.NoErr(tx.Rollback())
+
+	n, err = BulkInsert(ctx, d, "users", []string{"id"}, nil)
+	is.NoErr(err)
+	is.Equal(n, int64(0))
+	_, err = BulkInsert(ctx, d, "users", []string{"id"}, [][]any{{1, 2}})
+	is.True(err != nil)
+	_, err = BulkInsert(ctx, d, "users", nil, nil)
+	is.True(err != nil)
+	_, err = BulkInsert(ctx, d, "users", []string{"id", "name"}, rows, WithBulkMaxParams(1))
+	is.True(err != nil)
+}
+
+func TestBulkInsertQuery(t *testing.T) {
+	is := is.New(t)
+	rows := [][]any{{1, "a"}, {2, "b"}}
+	q, args, err := bulkInsertQuery(DialectFor(PostgresDBType), "users", []string{"id", "name"}, rows, &bulkOpts{ignoreConflicts: true})
+	is.NoErr(err)
+	is.Equal(q, `INSERT INTO "users" ("id", "name") VALUES ($1, $2), ($3, $4) ON CONFLICT DO NOTHING`)
+	is.Equal(args, []any{1, "a", 2, "b"})
+	q, _, _ = bulkInsertQuery(DialectFor(MySQLDBType), "users", []string{"id", "name"}, rows, &bulkOpts{ignoreConflicts: true})
+	is.Equal(q, "INSERT IGNORE INTO `users` (`id`, `name`) VALUES (?, ?), (?, ?)")
+	q, _, _ = bulkInsertQuery(DialectFor(MySQLDBType), "users", []string{"id"}, [][]any{{1}}, &bulkOpts{ignoreConflicts: true, conflictCols: []string{"id"}})
+	is.Equal(q, "INSERT INTO `users` (`id`) VALUES (?) ON DUPLICATE KEY UPDATE `id` = `id`")
+}
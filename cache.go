@@ -0,0 +1,310 @@
+package db
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/gob"
+	"encoding/hex"
+	stderrors "errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrCacheMiss is returned by a [CacheStore] when it does not have a key.
+var ErrCacheMiss = errors.New("cache miss")
+
+// CacheStore holds the results cached by [WithCache]. It must be safe for
+// concurrent use. [LRUCache] is an in-memory store and a redis store only
+// needs GET, returning [ErrCacheMiss] for nil replies, and SET with an
+// expiry.
+type CacheStore interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Set stores the value for ttl, or until it is evicted when ttl is zero.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+// CacheKeyFunc returns the key a query's results are cached under.
+type CacheKeyFunc func(query string, args []any) string
+
+// DefaultCacheKey hashes the query and the type and value of each argument.
+func DefaultCacheKey(query string, args []any) string {
+	h := sha256.New()
+	h.Write([]byte(query))
+	for _, a := range args {
+		if v, ok := a.(driver.Valuer); ok {
+			if val, err := v.Value(); err == nil {
+				a = val
+			}
+		}
+		fmt.Fprintf(h, "\x00%T:%v", a, a)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// WithCache caches the results of queries run with a context from
+// [CacheQuery] for ttl. Results are dropped when a statement run by the
+// wrapper writes to one of the query's tables, or when they are invalidated
+// with [database.InvalidateCache]. The keyFn defaults to
+// [DefaultCacheKey].
+//
+// Writes by other programs are only seen once the ttl has passed.
+func WithCache(store CacheStore, ttl time.Duration, keyFn CacheKeyFunc) Option {
+	return func(o *dbOptions) {
+		if keyFn == nil {
+			keyFn = DefaultCacheKey
+		}
+		o.cache = &queryCache{store: store, ttl: ttl, key: keyFn}
+	}
+}
+
+type cacheTablesKey struct{}
+
+// CacheQuery returns a context that has queries cached when the database was
+// created with [WithCache]. The tables are the ones the query reads from.
+// They should not be schema qualified.
+func CacheQuery(ctx context.Context, tables ...string) context.Context {
+	norm := make([]string, len(tables))
+	for i, t := range tables {
+		norm[i] = strings.ToLower(t)
+	}
+	return context.WithValue(ctx, cacheTablesKey{}, norm)
+}
+
+func cacheTables(ctx context.Context) ([]string, bool) {
+	tables, ok := ctx.Value(cacheTablesKey{}).([]string)
+	return tables, ok
+}
+
+func init() {
+	// Driver values are sent through gob as interfaces.
+	gob.Register(time.Time{})
+}
+
+type queryCache struct {
+	store        CacheStore
+	ttl          time.Duration
+	key          CacheKeyFunc
+	hits, misses atomic.Uint64
+}
+
+// cacheEntry is the encoded form of a cached result.
+type cacheEntry struct {
+	Columns []string
+	Rows    [][]any
+}
+
+// generationKey is the key holding the current generation of a table. Each
+// cache key includes the generations of the query's tables so changing a
+// generation drops every result that read the table.
+func generationKey(table string) string { return "db:gen:" + table }
+
+// storeKey returns the key that a query's results are stored under.
+func (c *queryCache) storeKey(ctx context.Context, tenant, query string, args []any, tables []string) (string, error) {
+	var b strings.Builder
+	b.WriteString("db:q:")
+	b.WriteString(c.key(query, args))
+	if len(tenant) > 0 {
+		b.WriteString(":t=")
+		b.WriteString(tenant)
+	}
+	for _, t := range tables {
+		gen, err := c.store.Get(ctx, generationKey(t))
+		if errors.Is(err, ErrCacheMiss) {
+			// Start a new generation rather than assuming one, the old
+			// generation could have been evicted while its results were not.
+			gen = newGeneration()
+			err = c.store.Set(ctx, generationKey(t), gen, 0)
+		}
+		if err != nil {
+			return "", err
+		}
+		b.WriteByte(':')
+		b.Write(gen)
+	}
+	return b.String(), nil
+}
+
+func (c *queryCache) get(ctx context.Context, key string) (*Rowset, error) {
+	b, err := c.store.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	var e cacheEntry
+	if err = gob.NewDecoder(bytes.NewReader(b)).Decode(&e); err != nil {
+		return nil, err
+	}
+	return NewRowset(e.Columns, e.Rows), nil
+}
+
+func (c *queryCache) set(ctx context.Context, key string, rs *Rowset) error {
+	var b bytes.Buffer
+	err := gob.NewEncoder(&b).Encode(cacheEntry{Columns: rs.cols, Rows: rs.vals})
+	if err != nil {
+		return err
+	}
+	return c.store.Set(ctx, key, b.Bytes(), c.ttl)
+}
+
+// invalidate starts a new generation for each table.
+func (c *queryCache) invalidate(ctx context.Context, tables []string) error {
+	var errs []error
+	for _, t := range tables {
+		err := c.store.Set(ctx, generationKey(strings.ToLower(t)), newGeneration(), 0)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return stderrors.Join(errs...)
+}
+
+func newGeneration() []byte {
+	var b [8]byte
+	rand.Read(b[:])
+	return []byte(hex.EncodeToString(b[:]))
+}
+
+// cachedQuery runs a query through the cache. Errors from the store are
+// logged and the query goes to the database.
+func (db *database) cachedQuery(ctx context.Context, query string, args []any, tables []string) (Rows, error) {
+	tenant, err := db.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	key, err := db.cache.storeKey(ctx, tenant, query, args, tables)
+	if err != nil {
+		db.logger.Warn("query cache unavailable", slog.Any("error", err))
+		return db.query(ctx, query, args)
+	}
+	rs, err := db.cache.get(ctx, key)
+	if err == nil {
+		db.cache.hits.Add(1)
+		return db.cachedRows(rs), nil
+	}
+	db.cache.misses.Add(1)
+	if !errors.Is(err, ErrCacheMiss) {
+		db.logger.Warn("failed to read query cache", slog.Any("error", err))
+	}
+	rows, err := db.query(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	rs, err = Materialize(rows)
+	if err != nil {
+		return nil, err
+	}
+	if err = db.cache.set(ctx, key, rs); err != nil {
+		db.logger.Warn("failed to write query cache", slog.Any("error", err))
+	}
+	return db.cachedRows(rs), nil
+}
+
+// cachedRows applies the row wrappers that [database.query] would have
+// applied to the rows of the database.
+func (db *database) cachedRows(rs *Rowset) Rows {
+	if db.utc {
+		return &utcRows{rs}
+	}
+	return rs
+}
+
+// InvalidateCache drops the cached results of queries that read from any of
+// the tables. It does nothing if the database was not created with
+// [WithCache].
+func (db *database) InvalidateCache(ctx context.Context, tables ...string) error {
+	if db.cache == nil {
+		return nil
+	}
+	return db.cache.invalidate(ctx, tables)
+}
+
+// invalidateWrites drops the cached results of the tables a statement wrote
+// to.
+func (db *database) invalidateWrites(ctx context.Context, tables []string) {
+	if db.cache == nil || len(tables) == 0 {
+		return
+	}
+	if err := db.cache.invalidate(ctx, tables); err != nil {
+		db.logger.Warn("failed to invalidate query cache", slog.Any("error", err))
+	}
+}
+
+// LRUCache is an in-memory [CacheStore] that evicts the least recently used
+// key once it is full.
+type LRUCache struct {
+	mu    sync.Mutex
+	size  int
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+type lruItem struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+var _ CacheStore = (*LRUCache)(nil)
+
+// NewLRUCache creates an [LRUCache] that holds at most size keys.
+func NewLRUCache(size int) *LRUCache {
+	if size <= 0 {
+		size = 1
+	}
+	return &LRUCache{size: size, ll: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (c *LRUCache) Get(_ context.Context, key string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return nil, ErrCacheMiss
+	}
+	it := el.Value.(*lruItem)
+	if !it.expires.IsZero() && !now().Before(it.expires) {
+		c.ll.Remove(el)
+		delete(c.items, key)
+		return nil, ErrCacheMiss
+	}
+	c.ll.MoveToFront(el)
+	return it.value, nil
+}
+
+func (c *LRUCache) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var expires time.Time
+	if ttl > 0 {
+		expires = now().Add(ttl)
+	}
+	if el, ok := c.items[key]; ok {
+		it := el.Value.(*lruItem)
+		it.value, it.expires = value, expires
+		c.ll.MoveToFront(el)
+		return nil
+	}
+	c.items[key] = c.ll.PushFront(&lruItem{key: key, value: value, expires: expires})
+	for c.ll.Len() > c.size {
+		el := c.ll.Back()
+		c.ll.Remove(el)
+		delete(c.items, el.Value.(*lruItem).key)
+	}
+	return nil
+}
+
+// Len returns the number of keys in the cache.
+func (c *LRUCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
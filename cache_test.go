@@ -0,0 +1,176 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestWithCache(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	pool, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	pool.SetMaxOpenConns(1)
+	store := NewLRUCache(100)
+	d := New(pool, WithDialect(DialectFor(SQLiteDBType)), WithCache(store, time.Minute, nil))
+	defer d.Close()
+	_, err = d.ExecContext(ctx, `CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT, created DATETIME)`)
+	is.NoErr(err)
+	_, err = d.ExecContext(ctx, `INSERT INTO users (name, created) VALUES ('a', ?)`, time.Unix(10, 0).UTC())
+	is.NoErr(err)
+
+	names := func(ctx context.Context) []string {
+		rows, err := d.QueryContext(ctx, "SELECT id, name, created FROM users WHERE id > ? ORDER BY id", 0)
+		is.NoErr(err)
+		defer rows.Close()
+		var res []string
+		for rows.Next() {
+			var (
+				id      int
+				name    string
+				created time.Time
+			)
+			is.NoErr(rows.Scan(&id, &name, &created))
+			is.True(created.Equal(time.Unix(10, 0)))
+			res = append(res, name)
+		}
+		is.NoErr(rows.Err())
+		return res
+	}
+	cached := CacheQuery(ctx, "Users")
+	is.Equal(names(cached), []string{"a"})
+	is.Equal(d.cache.misses.Load(), uint64(1))
+	is.Equal(names(cached), []string{"a"})
+	is.Equal(d.cache.hits.Load(), uint64(1))
+
+	// Writes behind the wrapper's back are not seen.
+	_, err = pool.ExecContext(ctx, `INSERT INTO users (name, created) VALUES ('b', ?)`, time.Unix(10, 0).UTC())
+	is.NoErr(err)
+	is.Equal(names(cached), []string{"a"})
+	is.Equal(names(ctx), []string{"a", "b"})
+	is.NoErr(d.InvalidateCache(ctx, "users"))
+	is.Equal(names(cached), []string{"a", "b"})
+
+	// Writes through the wrapper drop the results.
+	_, err = d.ExecContext(ctx, `UPDATE "users" SET name = 'c' WHERE name = 'b'`)
+	is.NoErr(err)
+	is.Equal(names(cached), []string{"a", "c"})
+
+	tx, err := d.BeginTx(ctx, nil)
+	is.NoErr(err)
+	_, err = tx.ExecContext(ctx, `DELETE FROM main.users WHERE name = 'c'`)
+	is.NoErr(err)
+	is.Equal(names(cached), []string{"a", "c"})
+	is.NoErr(tx.Commit())
+	is.Equal(names(cached), []string{"a"})
+
+	// The generations are lost when the store is cleared.
+	hits := d.cache.hits.Load()
+	d.cache.store = NewLRUCache(10)
+	is.Equal(names(cached), []string{"a"})
+	is.Equal(d.cache.hits.Load(), hits)
+
+	_, err = d.QueryContext(cached, "SELECT nope FROM users")
+	is.True(err != nil)
+	is.NoErr(New(pool).InvalidateCache(ctx, "users"))
+}
+
+func TestLRUCache(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	defer func() { now = time.Now }()
+	start := time.Now()
+	now = func() time.Time { return start }
+	c := NewLRUCache(2)
+	is.NoErr(c.Set(ctx, "a", []byte("1"), 0))
+	is.NoErr(c.Set(ctx, "b", []byte("2"), time.Second))
+	v, err := c.Get(ctx, "a")
+	is.NoErr(err)
+	is.Equal(string(v), "1")
+	is.NoErr(c.Set(ctx, "c", []byte("3"), 0))
+	_, err = c.Get(ctx, "b")
+	is.Equal(err, ErrCacheMiss)
+	is.NoErr(c.Set(ctx, "c", []byte("4"), time.Second))
+	v, err = c.Get(ctx, "c")
+	is.NoErr(err)
+	is.Equal(string(v), "4")
+	now = func() time.Time { return start.Add(time.Second) }
+	_, err = c.Get(ctx, "c")
+	is.Equal(err, ErrCacheMiss)
+	is.Equal(c.Len(), 1)
+	is.Equal(NewLRUCache(0).size, 1)
+}
+
+func TestWrittenTables(t *testing.T) {
+	is := is.New(t)
+	for _, tt := range []struct {
+		query string
+		want  []string
+	}{
+		{"SELECT * FROM users FOR UPDATE", nil},
+		{"INSERT INTO users (id) VALUES (1) ON CONFLICT (id) DO UPDATE SET id = 2", []string{"users"}},
+		{"INSERT IGNORE INTO `Users` VALUES (1) ON DUPLICATE KEY UPDATE id = 2", []string{"users"}},
+		{`UPDATE ONLY public."Users" SET name = 'update x'`, []string{"public.users", "users"}},
+		{"DELETE FROM [dbo].[users] -- delete from other", []string{"dbo.users", "users"}},
+		{"TRUNCATE TABLE a, b; REPLACE INTO c VALUES (1)", []string{"a", "b", "c"}},
+		{"WITH d AS (DELETE FROM e RETURNING *) INSERT INTO f SELECT * FROM d", []string{"e", "f"}},
+		{`UPDATE "unterminated`, nil},
+		{"DELETE FROM (", nil},
+	} {
+		is.Equal(writtenTables(tt.query), tt.want)
+	}
+}
+
+func TestWithCache_QueryWrites(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	// Times are read in the Berlin time zone.
+	pool, err := sql.Open("sqlite3", "file::memory:?_loc=Europe%2FBerlin")
+	is.NoErr(err)
+	pool.SetMaxOpenConns(1)
+	d := New(pool, WithDialect(DialectFor(SQLiteDBType)), WithCache(NewLRUCache(100), time.Minute, nil), WithUTC())
+	defer d.Close()
+	_, err = d.ExecContext(ctx, `CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT, created DATETIME)`)
+	is.NoErr(err)
+	cached := CacheQuery(ctx, "users")
+	list := func() (names []string) {
+		rows, err := d.QueryContext(cached, "SELECT name, created FROM users ORDER BY id")
+		is.NoErr(err)
+		defer rows.Close()
+		for rows.Next() {
+			var (
+				name    string
+				created time.Time
+			)
+			is.NoErr(rows.Scan(&name, &created))
+			is.Equal(created.Location(), time.UTC)
+			names = append(names, name)
+		}
+		is.NoErr(rows.Err())
+		return names
+	}
+	insert := func(q interface {
+		QueryContext(context.Context, string, ...any) (Rows, error)
+	}, name string) {
+		rows, err := q.QueryContext(ctx, `INSERT INTO users (name, created) VALUES (?, ?) RETURNING id`, name, time.Unix(10, 0))
+		is.NoErr(err)
+		var id int
+		is.NoErr(ScanOne(rows, &id))
+	}
+	is.Equal(len(list()), 0)
+
+	insert(d, "a")
+	is.Equal(list(), []string{"a"})
+	is.Equal(list(), []string{"a"}) // from the cache
+
+	tx, err := d.BeginTx(ctx, nil)
+	is.NoErr(err)
+	insert(tx, "b")
+	is.NoErr(tx.Commit())
+	is.Equal(list(), []string{"a", "b"})
+	is.True(d.cache.hits.Load() > 0)
+}
@@ -0,0 +1,60 @@
+package db
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// cancelQuery cancels the active statements of every other connection with
+// the same application_name that have been running for longer than $1
+// milliseconds.
+const cancelQuery = `SELECT count(*) FROM (
+	SELECT pg_cancel_backend(pid) FROM pg_stat_activity
+	WHERE application_name = current_setting('application_name')
+	  AND pid <> pg_backend_pid()
+	  AND state = 'active'
+	  AND now() - query_start > $1 * interval '1 millisecond'
+) AS canceled`
+
+// CancelRunningQueries asks postgres to cancel the statements of this
+// application that have been running for longer than olderThan and returns
+// how many were canceled. Statements are matched by application_name, see
+// [Config.AppName], so other programs sharing the database are left alone.
+// This is meant as a break glass tool for incidents.
+func (db *database) CancelRunningQueries(ctx context.Context, olderThan time.Duration) (int, error) {
+	if t := db.dialect.Type(); !t.postgresWire() || t == CockroachDBType {
+		return 0, errors.Errorf("canceling queries is not supported for %q", t)
+	}
+	conn, err := db.DB.Conn(ctx)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	defer conn.Close()
+	var app string
+	if err = conn.QueryRowContext(ctx, "SELECT current_setting('application_name')").Scan(&app); err != nil {
+		return 0, errors.WithStack(err)
+	}
+	if len(app) == 0 {
+		return 0, errors.New("cannot cancel queries without an application_name")
+	}
+	var n int
+	err = conn.QueryRowContext(ctx, cancelQuery, olderThan.Milliseconds()).Scan(&n)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	db.logger.Warn("canceled running queries",
+		slog.String("application_name", app),
+		slog.Duration("older_than", olderThan),
+		slog.Int("count", n))
+	return n, nil
+}
+
+// canceled is true when a statement failed because its context was done.
+func canceled(ctx context.Context, err error) bool {
+	return err != nil && (ctx.Err() != nil ||
+		errors.Is(err, context.Canceled) ||
+		errors.Is(err, context.DeadlineExceeded))
+}
@@ -0,0 +1,63 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/pkg/errors"
+)
+
+func TestCancelRunningQueries(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	rec := &recorder{results: map[string]driver.Value{
+		"SELECT current_setting('application_name')": "api",
+		cancelQuery: int64(2),
+	}}
+	pool := sql.OpenDB(rec)
+	defer pool.Close()
+	d := New(pool)
+	n, err := d.CancelRunningQueries(ctx, time.Minute)
+	is.NoErr(err)
+	is.Equal(n, 2)
+	is.Equal(rec.take(), []string{"SELECT current_setting('application_name')", cancelQuery})
+
+	rec.results["SELECT current_setting('application_name')"] = ""
+	_, err = d.CancelRunningQueries(ctx, time.Minute)
+	is.True(err != nil)
+	delete(rec.results, cancelQuery)
+	rec.results["SELECT current_setting('application_name')"] = "api"
+	_, err = d.CancelRunningQueries(ctx, time.Minute)
+	is.True(errors.Is(err, sql.ErrNoRows))
+	rec.fail = "SELECT current_setting"
+	_, err = d.CancelRunningQueries(ctx, time.Minute)
+	is.True(err != nil)
+
+	_, err = New(pool, WithDialect(DialectFor(MySQLDBType))).CancelRunningQueries(ctx, time.Minute)
+	is.True(err != nil)
+	canceledCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = d.CancelRunningQueries(canceledCtx, time.Minute)
+	is.True(errors.Is(err, context.Canceled))
+}
+
+func TestSnapshot_Canceled(t *testing.T) {
+	is := is.New(t)
+	pool, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer pool.Close()
+	d := New(pool)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = d.ExecContext(ctx, "SELECT 1")
+	is.True(errors.Is(err, context.Canceled))
+	_, err = d.ExecContext(context.Background(), "not sql")
+	is.True(err != nil)
+	s := d.Snapshot()
+	is.Equal(s.Canceled, uint64(1))
+	is.Equal(s.Errors, uint64(2))
+}
@@ -0,0 +1,545 @@
+// Package cdc streams row changes out of postgres with logical replication
+// and the built in pgoutput plugin.
+//
+// The tables to stream are chosen with a publication, which has to be
+// created before subscribing:
+//
+//	CREATE PUBLICATION app_changes FOR TABLE users, orders;
+//
+// Changes are read from a replication slot, which keeps the server from
+// removing WAL that has not been acked yet, so changes are delivered at
+// least once across restarts. Slots that are no longer read from fill up
+// the server's disk and should be dropped.
+package cdc
+
+import (
+	"context"
+	"encoding/binary"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/pkg/errors"
+)
+
+// Op is the kind of change made to a row.
+type Op string
+
+// Change operations.
+const (
+	Insert   Op = "insert"
+	Update   Op = "update"
+	Delete   Op = "delete"
+	Truncate Op = "truncate"
+)
+
+// Change is a change to one row, or a whole table for [Truncate].
+type Change struct {
+	Op     Op
+	Schema string
+	Table  string
+	// New is the row after an insert or update. Large TOASTed values that
+	// were not changed by an update are left out.
+	New map[string]any
+	// Old is the row before an update or delete. It only has the columns of
+	// the table's replica identity, usually the primary key, unless the
+	// table's REPLICA IDENTITY is FULL. It is nil for updates that did not
+	// change the key.
+	Old map[string]any
+
+	types          *pgtype.Map
+	rel            *relation
+	newRow, oldRow tuple
+	keyOnly        bool
+}
+
+// Scan decodes the new row, or the old row of a delete, into a pointer to a
+// struct. Columns are matched to fields with the "db" tag or the lower case
+// field name. Columns without a field are skipped.
+func (c *Change) Scan(dest any) error {
+	row, keyOnly := c.newRow, false
+	if row == nil {
+		row, keyOnly = c.oldRow, c.keyOnly
+	}
+	rv := reflect.ValueOf(dest)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.Errorf("cdc: destination must be a pointer to a struct, got %T", dest)
+	}
+	fields := make(map[string][]int)
+	structFields(rv.Elem().Type(), nil, fields)
+	for i, col := range row {
+		if col.kind == 'u' || i >= len(c.rel.columns) || keyOnly && !c.rel.columns[i].key {
+			continue
+		}
+		rc := c.rel.columns[i]
+		index, ok := fields[rc.name]
+		if !ok {
+			continue
+		}
+		fv := rv.Elem().FieldByIndex(index)
+		if err := c.types.Scan(rc.oid, formatCode(col), col.data, fv.Addr().Interface()); err != nil {
+			return errors.Wrapf(err, "cdc: failed to scan column %q", rc.name)
+		}
+	}
+	return nil
+}
+
+// structFields maps column names to the fields of a struct, flattening
+// embedded structs.
+func structFields(t reflect.Type, index []int, fields map[string][]int) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, hasTag := f.Tag.Lookup("db")
+		if tag == "-" {
+			continue
+		}
+		idx := append(append([]int(nil), index...), i)
+		if f.Anonymous && !hasTag && f.Type.Kind() == reflect.Struct {
+			structFields(f.Type, idx, fields)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if len(name) == 0 {
+			name = strings.ToLower(f.Name)
+		}
+		if _, ok := fields[name]; !ok {
+			fields[name] = idx
+		}
+	}
+}
+
+// Transaction is a committed transaction and the changes made in it to the
+// subscribed tables.
+type Transaction struct {
+	XID uint32
+	// LSN is the position of the transaction's commit record.
+	LSN        LSN
+	CommitTime time.Time
+	Changes    []Change
+
+	end LSN
+	sub *Subscriber
+}
+
+// Ack tells the server that the transaction was handled so it is not sent
+// again after a restart. The position is reported on the next status
+// update. Acking a transaction also acks every transaction before it.
+func (t *Transaction) Ack() { t.sub.ack(t.end) }
+
+// Option configures a [Subscriber].
+type Option func(*options)
+
+type options struct {
+	tables         map[string]bool
+	statusInterval time.Duration
+	startLSN       LSN
+}
+
+// WithTables only delivers changes to the given tables. Tables may be
+// qualified with a schema. The tables in the publication are delivered by
+// default.
+func WithTables(tables ...string) Option {
+	return func(o *options) {
+		for _, t := range tables {
+			o.tables[t] = true
+		}
+	}
+}
+
+// WithStatusInterval sets how often the acked position is reported to the
+// server. Defaults to 10s.
+func WithStatusInterval(d time.Duration) Option {
+	return func(o *options) { o.statusInterval = d }
+}
+
+// WithStartLSN sets the position to start streaming from. By default
+// streaming resumes after the last position acked on the slot.
+func WithStartLSN(lsn LSN) Option { return func(o *options) { o.startLSN = lsn } }
+
+// replConn is a replication connection.
+type replConn interface {
+	// exec runs a simple query and returns the rows of the last result.
+	exec(ctx context.Context, sql string) ([][][]byte, error)
+	receive(ctx context.Context) (pgproto3.BackendMessage, error)
+	send(msg pgproto3.FrontendMessage) error
+	close(ctx context.Context) error
+}
+
+type pgConn struct{ *pgconn.PgConn }
+
+func (c pgConn) exec(ctx context.Context, sql string) ([][][]byte, error) {
+	res, err := c.Exec(ctx, sql).ReadAll()
+	if err != nil || len(res) == 0 {
+		return nil, err
+	}
+	return res[len(res)-1].Rows, nil
+}
+
+func (c pgConn) receive(ctx context.Context) (pgproto3.BackendMessage, error) {
+	msg, err := c.ReceiveMessage(ctx)
+	if pgconn.Timeout(err) {
+		// The connection is still usable after a deadline.
+		return nil, context.DeadlineExceeded
+	}
+	return msg, err
+}
+
+func (c pgConn) send(msg pgproto3.FrontendMessage) error {
+	c.Frontend().Send(msg)
+	return c.Frontend().Flush()
+}
+
+func (c pgConn) close(ctx context.Context) error { return c.Close(ctx) }
+
+// Subscriber reads transactions from a replication slot. Only [Transaction.Ack]
+// is safe to call from other goroutines.
+type Subscriber struct {
+	conn        replConn
+	slot        string
+	publication string
+	opts        options
+	types       *pgtype.Map
+	relations   map[uint32]*relation
+	started     bool
+	nextStatus  time.Time
+	tx          *Transaction // being received
+
+	mu        sync.Mutex
+	received  LSN
+	delivered LSN
+	acked     LSN
+}
+
+var slotName = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// Connect opens a replication connection for a slot and publication. The
+// connection string is any string accepted by [pgconn.ParseConfig]. The slot
+// has to exist, see [Subscriber.CreateSlot], and streaming begins with
+// [Subscriber.Start].
+func Connect(ctx context.Context, connString, slot, publication string, opts ...Option) (*Subscriber, error) {
+	if !slotName.MatchString(slot) {
+		return nil, errors.Errorf("cdc: invalid slot name %q", slot)
+	}
+	cfg, err := pgconn.ParseConfig(connString)
+	if err != nil {
+		return nil, err
+	}
+	cfg.RuntimeParams["replication"] = "database"
+	conn, err := pgconn.ConnectConfig(ctx, cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "cdc: failed to connect")
+	}
+	return newSubscriber(pgConn{conn}, slot, publication, opts), nil
+}
+
+func newSubscriber(conn replConn, slot, publication string, opts []Option) *Subscriber {
+	o := options{tables: make(map[string]bool), statusInterval: 10 * time.Second}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Subscriber{
+		conn:        conn,
+		slot:        slot,
+		publication: publication,
+		opts:        o,
+		types:       pgtype.NewMap(),
+		relations:   make(map[uint32]*relation),
+	}
+}
+
+// CreateSlot creates the replication slot if it doesn't exist and reports
+// whether it was created. Temporary slots are dropped when the connection
+// is closed.
+func (s *Subscriber) CreateSlot(ctx context.Context, temporary bool) (bool, error) {
+	rows, err := s.conn.exec(ctx, "SELECT 1 FROM pg_replication_slots WHERE slot_name = '"+s.slot+"'")
+	if err != nil {
+		return false, errors.Wrap(err, "cdc: failed to look up slot")
+	}
+	if len(rows) > 0 {
+		return false, nil
+	}
+	query := "CREATE_REPLICATION_SLOT " + s.slot
+	if temporary {
+		query += " TEMPORARY"
+	}
+	if _, err = s.conn.exec(ctx, query+" LOGICAL pgoutput"); err != nil {
+		return false, errors.Wrap(err, "cdc: failed to create slot")
+	}
+	return true, nil
+}
+
+// DropSlot drops the replication slot. It cannot be called after
+// [Subscriber.Start].
+func (s *Subscriber) DropSlot(ctx context.Context) error {
+	_, err := s.conn.exec(ctx, "DROP_REPLICATION_SLOT "+s.slot+" WAIT")
+	return errors.Wrap(err, "cdc: failed to drop slot")
+}
+
+// Start starts streaming changes.
+func (s *Subscriber) Start(ctx context.Context) error {
+	err := s.conn.send(&pgproto3.Query{String: "START_REPLICATION SLOT " + s.slot +
+		" LOGICAL " + s.opts.startLSN.String() +
+		" (proto_version '1', publication_names '" + strings.ReplaceAll(s.publication, "'", "''") + "')"})
+	if err != nil {
+		return errors.Wrap(err, "cdc: failed to start replication")
+	}
+	for {
+		msg, err := s.conn.receive(ctx)
+		if err != nil {
+			return errors.Wrap(err, "cdc: failed to start replication")
+		}
+		switch msg := msg.(type) {
+		case *pgproto3.CopyBothResponse:
+			s.started = true
+			s.nextStatus = time.Now().Add(s.opts.statusInterval)
+			return nil
+		case *pgproto3.ErrorResponse:
+			return errors.Wrap(pgconn.ErrorResponseToPgError(msg), "cdc: failed to start replication")
+		}
+	}
+}
+
+// Receive waits for the next committed transaction that changed one of the
+// subscribed tables. Transactions must be acked with [Transaction.Ack] once
+// they are handled. The acked position is reported to the server while
+// waiting.
+func (s *Subscriber) Receive(ctx context.Context) (*Transaction, error) {
+	if !s.started {
+		return nil, errors.New("cdc: replication has not been started")
+	}
+	for {
+		if !time.Now().Before(s.nextStatus) {
+			if err := s.sendStatus(); err != nil {
+				return nil, err
+			}
+		}
+		rctx, cancel := context.WithDeadline(ctx, s.nextStatus)
+		msg, err := s.conn.receive(rctx)
+		cancel()
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+				continue
+			}
+			return nil, err
+		}
+		switch msg := msg.(type) {
+		case *pgproto3.CopyData:
+			tx, err := s.handle(msg.Data)
+			if err != nil || tx != nil {
+				return tx, err
+			}
+		case *pgproto3.ErrorResponse:
+			return nil, pgconn.ErrorResponseToPgError(msg)
+		case *pgproto3.CopyDone:
+			return nil, errors.New("cdc: replication stream ended")
+		}
+	}
+}
+
+// Close reports the acked position and closes the connection.
+func (s *Subscriber) Close(ctx context.Context) error {
+	var err error
+	if s.started {
+		err = s.sendStatus()
+	}
+	if cerr := s.conn.close(ctx); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// handle handles a message sent in the replication stream.
+func (s *Subscriber) handle(data []byte) (*Transaction, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	r := reader{b: data[1:]}
+	switch data[0] {
+	case 'k': // keepalive
+		walEnd := LSN(r.uint64())
+		r.uint64() // server time
+		reply := r.byte() == 1
+		if r.err != nil {
+			return nil, errors.Wrap(r.err, "cdc: failed to decode keepalive")
+		}
+		s.advance(walEnd)
+		if reply {
+			s.nextStatus = time.Time{}
+		}
+	case 'w': // wal data
+		start := LSN(r.uint64())
+		r.uint64() // wal end
+		r.uint64() // server time
+		if r.err != nil {
+			return nil, errors.Wrap(r.err, "cdc: failed to decode wal data")
+		}
+		msg, err := parseMessage(r.b)
+		if err != nil {
+			return nil, errors.Wrap(err, "cdc")
+		}
+		s.advance(start)
+		return s.apply(msg)
+	}
+	return nil, nil
+}
+
+func (s *Subscriber) apply(msg any) (*Transaction, error) {
+	switch m := msg.(type) {
+	case *relation:
+		s.relations[m.id] = m
+	case *beginMsg:
+		s.tx = &Transaction{XID: m.xid, LSN: m.finalLSN, CommitTime: m.commitTime, sub: s}
+	case *rowMsg:
+		rel, err := s.relation(m.relation)
+		if err != nil || !s.subscribed(rel) {
+			return nil, err
+		}
+		c := Change{
+			Op: m.op, Schema: rel.schema, Table: rel.name,
+			types: s.types, rel: rel, newRow: m.new, oldRow: m.old, keyOnly: m.keyOnly,
+		}
+		if c.New, err = s.decode(rel, m.new, false); err != nil {
+			return nil, err
+		}
+		if c.Old, err = s.decode(rel, m.old, m.keyOnly); err != nil {
+			return nil, err
+		}
+		s.tx.Changes = append(s.tx.Changes, c)
+	case *truncateMsg:
+		for _, id := range m.relations {
+			rel, err := s.relation(id)
+			if err != nil {
+				return nil, err
+			}
+			if s.subscribed(rel) {
+				s.tx.Changes = append(s.tx.Changes, Change{
+					Op: Truncate, Schema: rel.schema, Table: rel.name, types: s.types, rel: rel,
+				})
+			}
+		}
+	case *commitMsg:
+		tx := s.tx
+		s.tx = nil
+		if tx == nil {
+			return nil, errors.New("cdc: commit without a transaction")
+		}
+		tx.end = m.endLSN
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if len(tx.Changes) == 0 {
+			// Nothing to deliver so let the slot move past it, unless an
+			// earlier transaction is still waiting for an ack.
+			if s.acked >= s.delivered {
+				s.acked, s.delivered = tx.end, tx.end
+			}
+			return nil, nil
+		}
+		s.delivered = tx.end
+		return tx, nil
+	}
+	return nil, nil
+}
+
+func (s *Subscriber) relation(id uint32) (*relation, error) {
+	if s.tx == nil {
+		return nil, errors.New("cdc: change outside of a transaction")
+	}
+	rel, ok := s.relations[id]
+	if !ok {
+		return nil, errors.Errorf("cdc: unknown relation %d", id)
+	}
+	return rel, nil
+}
+
+func (s *Subscriber) subscribed(rel *relation) bool {
+	return len(s.opts.tables) == 0 || s.opts.tables[rel.name] || s.opts.tables[rel.schema+"."+rel.name]
+}
+
+// decode decodes a tuple into Go values using the column types.
+func (s *Subscriber) decode(rel *relation, t tuple, keyOnly bool) (map[string]any, error) {
+	if t == nil {
+		return nil, nil
+	}
+	row := make(map[string]any, len(t))
+	for i, col := range t {
+		if i >= len(rel.columns) {
+			break
+		}
+		rc := rel.columns[i]
+		if keyOnly && !rc.key {
+			continue
+		}
+		switch col.kind {
+		case 'u':
+			continue
+		case 'n':
+			row[rc.name] = nil
+			continue
+		}
+		typ, ok := s.types.TypeForOID(rc.oid)
+		if !ok {
+			if col.kind == 't' {
+				row[rc.name] = string(col.data)
+			} else {
+				row[rc.name] = col.data
+			}
+			continue
+		}
+		v, err := typ.Codec.DecodeValue(s.types, rc.oid, formatCode(col), col.data)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cdc: failed to decode %s.%s", rel.name, rc.name)
+		}
+		row[rc.name] = v
+	}
+	return row, nil
+}
+
+func formatCode(c tupleColumn) int16 {
+	if c.kind == 'b' {
+		return pgtype.BinaryFormatCode
+	}
+	return pgtype.TextFormatCode
+}
+
+func (s *Subscriber) advance(lsn LSN) {
+	s.mu.Lock()
+	s.received = max(s.received, lsn)
+	s.mu.Unlock()
+}
+
+func (s *Subscriber) ack(lsn LSN) {
+	s.mu.Lock()
+	s.acked = max(s.acked, lsn)
+	s.mu.Unlock()
+}
+
+// sendStatus sends a standby status update with the acked position. When
+// every delivered transaction has been acked the slot is moved up to the
+// last position received so idle slots don't hold on to WAL.
+func (s *Subscriber) sendStatus() error {
+	s.mu.Lock()
+	write, flush := s.received, s.acked
+	if s.tx == nil && s.acked >= s.delivered {
+		flush = max(flush, s.received)
+	}
+	s.mu.Unlock()
+	b := make([]byte, 34)
+	b[0] = 'r'
+	binary.BigEndian.PutUint64(b[1:], uint64(write))
+	binary.BigEndian.PutUint64(b[9:], uint64(flush))
+	binary.BigEndian.PutUint64(b[17:], uint64(flush))
+	binary.BigEndian.PutUint64(b[25:], uint64(pgMicros(time.Now())))
+	if err := s.conn.send(&pgproto3.CopyData{Data: b}); err != nil {
+		return errors.Wrap(err, "cdc: failed to send status update")
+	}
+	s.nextStatus = time.Now().Add(s.opts.statusInterval)
+	return nil
+}
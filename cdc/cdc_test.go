@@ -0,0 +1,250 @@
+package cdc
+
+import (
+	"context"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/matryer/is"
+	"github.com/pkg/errors"
+)
+
+type fakeConn struct {
+	msgs   []pgproto3.BackendMessage
+	sent   []pgproto3.FrontendMessage
+	execs  []string
+	rows   [][][]byte
+	err    error
+	closed bool
+}
+
+func (c *fakeConn) exec(_ context.Context, sql string) ([][][]byte, error) {
+	c.execs = append(c.execs, sql)
+	return c.rows, c.err
+}
+
+func (c *fakeConn) receive(ctx context.Context) (pgproto3.BackendMessage, error) {
+	if len(c.msgs) == 0 {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	msg := c.msgs[0]
+	c.msgs = c.msgs[1:]
+	return msg, nil
+}
+
+func (c *fakeConn) send(msg pgproto3.FrontendMessage) error {
+	c.sent = append(c.sent, msg)
+	return nil
+}
+
+func (c *fakeConn) close(context.Context) error {
+	c.closed = true
+	return nil
+}
+
+// msg builds protocol messages.
+type msg []byte
+
+func (m msg) byte(b byte) msg   { return append(m, b) }
+func (m msg) str(s string) msg  { return append(append(m, s...), 0) }
+func (m msg) u16(n uint16) msg  { return binary.BigEndian.AppendUint16(m, n) }
+func (m msg) u32(n uint32) msg  { return binary.BigEndian.AppendUint32(m, n) }
+func (m msg) u64(n uint64) msg  { return binary.BigEndian.AppendUint64(m, n) }
+func (m msg) text(s string) msg { return append(m.byte('t').u32(uint32(len(s))), s...) }
+func (m msg) wal(lsn uint64) *pgproto3.CopyData {
+	return &pgproto3.CopyData{Data: append(msg{'w'}.u64(lsn).u64(lsn).u64(0), m...)}
+}
+
+func relationMsg(id uint32, schema, name string) msg {
+	return msg{'R'}.u32(id).str(schema).str(name).byte('d').u16(3).
+		byte(1).str("id").u32(23).u32(0).
+		byte(0).str("name").u32(25).u32(0).
+		byte(0).str("bio").u32(25).u32(0)
+}
+
+func begin(xid uint32) msg { return msg{'B'}.u64(90).u64(0).u32(xid) }
+
+func commit(end uint64) msg { return msg{'C'}.byte(0).u64(end - 10).u64(end).u64(0) }
+
+type user struct {
+	ID   int
+	Name string `db:"name"`
+	Bio  *string
+}
+
+func TestSubscriber(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	conn := &fakeConn{msgs: []pgproto3.BackendMessage{
+		&pgproto3.NoticeResponse{},
+		&pgproto3.CopyBothResponse{},
+		msg(relationMsg(1, "public", "users")).wal(100),
+		msg(relationMsg(2, "public", "other")).wal(100),
+		begin(7).wal(100),
+		msg{'I'}.u32(1).byte('N').u16(3).text("1").text("a").byte('n').wal(101),
+		msg{'U'}.u32(1).byte('K').u16(3).text("1").byte('n').byte('n').
+			byte('N').u16(3).text("2").text("").byte('u').wal(102),
+		msg{'D'}.u32(1).byte('K').u16(3).text("2").byte('n').byte('n').wal(103),
+		msg{'I'}.u32(2).byte('N').u16(3).text("9").text("x").byte('n').wal(104),
+		msg{'T'}.u32(2).byte(0).u32(1).u32(2).wal(105),
+		msg{'O'}.u64(0).str("origin").wal(105),
+		commit(120).wal(110),
+		// Only changes other tables.
+		begin(8).wal(130),
+		msg{'I'}.u32(2).byte('N').u16(3).text("9").text("x").byte('n').wal(131),
+		commit(140).wal(135),
+		&pgproto3.CopyData{Data: msg{'k'}.u64(150).u64(0).byte(1)},
+		&pgproto3.CopyDone{},
+	}}
+	s := newSubscriber(conn, "slot", "pub'", []Option{WithTables("public.users"), WithStartLSN(0x100000002)})
+	_, err := s.Receive(ctx)
+	is.True(err != nil) // not started
+	is.NoErr(s.Start(ctx))
+	is.Equal(conn.sent[0].(*pgproto3.Query).String,
+		"START_REPLICATION SLOT slot LOGICAL 1/2 (proto_version '1', publication_names 'pub''')")
+
+	tx, err := s.Receive(ctx)
+	is.NoErr(err)
+	is.Equal(tx.XID, uint32(7))
+	is.Equal(tx.LSN, LSN(90))
+	is.Equal(len(tx.Changes), 4)
+	ins, upd, del := tx.Changes[0], tx.Changes[1], tx.Changes[2]
+	is.Equal(tx.Changes[3].Op, Truncate)
+	is.Equal(tx.Changes[3].Table, "users")
+	is.Equal(ins.Op, Insert)
+	is.Equal(ins.Table, "users")
+	is.Equal(ins.New, map[string]any{"id": int32(1), "name": "a", "bio": nil})
+	is.Equal(ins.Old, nil)
+	is.Equal(upd.Op, Update)
+	is.Equal(upd.Old, map[string]any{"id": int32(1)})
+	is.Equal(upd.New, map[string]any{"id": int32(2), "name": ""})
+	is.Equal(del.Op, Delete)
+	is.Equal(del.New, nil)
+	is.Equal(del.Old, map[string]any{"id": int32(2)})
+
+	var u user
+	is.NoErr(ins.Scan(&u))
+	is.Equal(u, user{ID: 1, Name: "a"})
+	bio := "kept"
+	u.Bio = &bio
+	is.NoErr(upd.Scan(&u))
+	is.Equal(u.ID, 2)
+	is.Equal(u.Name, "")
+	is.Equal(*u.Bio, "kept") // unchanged toast value
+	is.NoErr(del.Scan(&u))
+	is.True(ins.Scan(u) != nil)
+
+	// The status update after the next transaction is skipped reports the
+	// delivered but unacked transaction.
+	_, err = s.Receive(ctx)
+	is.True(err != nil)
+	is.Equal(len(conn.sent), 2)
+	status := conn.sent[1].(*pgproto3.CopyData).Data
+	is.Equal(status[0], byte('r'))
+	is.Equal(binary.BigEndian.Uint64(status[1:]), uint64(150))
+	is.Equal(binary.BigEndian.Uint64(status[9:]), uint64(0))
+
+	tx.Ack()
+	is.NoErr(s.Close(ctx))
+	is.True(conn.closed)
+	status = conn.sent[2].(*pgproto3.CopyData).Data
+	is.Equal(binary.BigEndian.Uint64(status[9:]), uint64(150))
+}
+
+func TestSubscriber_Slots(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	conn := &fakeConn{}
+	s := newSubscriber(conn, "slot", "pub", nil)
+	created, err := s.CreateSlot(ctx, true)
+	is.NoErr(err)
+	is.True(created)
+	is.Equal(conn.execs, []string{
+		"SELECT 1 FROM pg_replication_slots WHERE slot_name = 'slot'",
+		"CREATE_REPLICATION_SLOT slot TEMPORARY LOGICAL pgoutput",
+	})
+	conn.rows = [][][]byte{{[]byte("1")}}
+	created, err = s.CreateSlot(ctx, false)
+	is.NoErr(err)
+	is.True(!created)
+	is.NoErr(s.DropSlot(ctx))
+	is.Equal(conn.execs[3], "DROP_REPLICATION_SLOT slot WAIT")
+	conn.err = errors.New("down")
+	_, err = s.CreateSlot(ctx, false)
+	is.True(err != nil)
+
+	_, err = Connect(ctx, "postgres://localhost", "Bad-Name", "pub")
+	is.True(err != nil)
+}
+
+func TestSubscriber_Errors(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	start := func(msgs ...pgproto3.BackendMessage) *Subscriber {
+		conn := &fakeConn{msgs: append([]pgproto3.BackendMessage{&pgproto3.CopyBothResponse{}}, msgs...)}
+		s := newSubscriber(conn, "slot", "pub", []Option{WithStatusInterval(time.Hour)})
+		is.NoErr(s.Start(ctx))
+		return s
+	}
+	for _, m := range []pgproto3.BackendMessage{
+		&pgproto3.ErrorResponse{Message: "boom"},
+		msg{'I'}.u32(1).byte('N').u16(0).wal(1),
+		commit(5).wal(2),
+		&pgproto3.CopyData{Data: msg{'k'}.u32(1)},
+		&pgproto3.CopyData{Data: msg{'w'}.u32(1)},
+		msg{'I'}.u32(1).byte('X').wal(1),
+	} {
+		_, err := start(m).Receive(ctx)
+		is.True(err != nil)
+	}
+	s := start(begin(1).wal(1), msg{'I'}.u32(1).byte('N').u16(0).wal(1))
+	_, err := s.Receive(ctx)
+	is.True(err != nil) // unknown relation
+
+	s = newSubscriber(&fakeConn{msgs: []pgproto3.BackendMessage{&pgproto3.ErrorResponse{}}}, "slot", "pub", nil)
+	is.True(s.Start(ctx) != nil)
+	s = newSubscriber(&fakeConn{}, "slot", "pub", nil)
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	is.True(s.Start(cctx) != nil)
+}
+
+func TestParseMessage(t *testing.T) {
+	is := is.New(t)
+	for _, m := range []msg{
+		{},
+		{'B'},
+		msg{'R'}.u32(1).str("s"),
+		msg{'U'}.u32(1).byte('X'),
+		msg{'D'}.u32(1).byte('N'),
+		msg{'T'}.u32(2).byte(0).u32(1),
+		msg{'I'}.u32(1).byte('N').u16(1).byte('t').u32(10),
+		msg{'I'}.u32(1).byte('N').u16(1).byte('?'),
+	} {
+		_, err := parseMessage(m)
+		is.True(err != nil)
+	}
+	m, err := parseMessage(msg{'Y'})
+	is.NoErr(err)
+	is.Equal(m, nil)
+	m, err = parseMessage(msg{'U'}.u32(1).byte('N').u16(1).byte('b').u32(1).byte(1))
+	is.NoErr(err)
+	is.Equal(m.(*rowMsg).new, tuple{{kind: 'b', data: []byte{1}}})
+}
+
+func TestLSN(t *testing.T) {
+	is := is.New(t)
+	lsn, err := ParseLSN("16/B374D848")
+	is.NoErr(err)
+	is.Equal(lsn, LSN(0x16B374D848))
+	is.Equal(lsn.String(), "16/B374D848")
+	for _, s := range []string{"", "1", "x/1", "1/x"} {
+		_, err = ParseLSN(s)
+		is.True(err != nil)
+	}
+	ts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	is.True(pgTime(pgMicros(ts)).Equal(ts))
+}
@@ -0,0 +1,249 @@
+package cdc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// LSN is a position in the postgres write-ahead log.
+type LSN uint64
+
+// String formats the LSN the way postgres does, i.e. "16/B374D848".
+func (l LSN) String() string { return fmt.Sprintf("%X/%X", uint32(l>>32), uint32(l)) }
+
+// ParseLSN parses an LSN formatted like "16/B374D848".
+func ParseLSN(s string) (LSN, error) {
+	hi, lo, ok := strings.Cut(s, "/")
+	if !ok {
+		return 0, errors.Errorf("invalid lsn %q", s)
+	}
+	h, err := strconv.ParseUint(hi, 16, 32)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid lsn %q", s)
+	}
+	l, err := strconv.ParseUint(lo, 16, 32)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid lsn %q", s)
+	}
+	return LSN(h<<32 | l), nil
+}
+
+// postgresEpoch is the zero time of timestamps in the replication protocol.
+var postgresEpoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func pgTime(micros int64) time.Time {
+	return postgresEpoch.Add(time.Duration(micros) * time.Microsecond)
+}
+
+func pgMicros(t time.Time) int64 { return t.Sub(postgresEpoch).Microseconds() }
+
+// The pgoutput messages this package uses. See "Logical Replication Message
+// Formats" in the postgres docs.
+type (
+	beginMsg struct {
+		finalLSN   LSN
+		commitTime time.Time
+		xid        uint32
+	}
+	commitMsg struct {
+		commitLSN, endLSN LSN
+		commitTime        time.Time
+	}
+	relation struct {
+		id      uint32
+		schema  string
+		name    string
+		columns []relColumn
+	}
+	relColumn struct {
+		name string
+		key  bool
+		oid  uint32
+	}
+	rowMsg struct {
+		op       Op
+		relation uint32
+		old, new tuple
+		// keyOnly is set when old only has the replica identity columns.
+		keyOnly bool
+	}
+	truncateMsg struct {
+		relations []uint32
+	}
+)
+
+// tuple is the data of a row. A nil tuple was not sent.
+type tuple []tupleColumn
+
+type tupleColumn struct {
+	// kind is 'n' for NULL, 'u' for an unchanged TOAST value that was not
+	// sent, 't' for text and 'b' for binary data.
+	kind byte
+	data []byte
+}
+
+// reader decodes the big-endian fields of a message.
+type reader struct {
+	b   []byte
+	err error
+}
+
+func (r *reader) take(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || len(r.b) < n {
+		r.err = errors.New("message is too short")
+		return nil
+	}
+	b := r.b[:n]
+	r.b = r.b[n:]
+	return b
+}
+
+func (r *reader) byte() byte {
+	if b := r.take(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *reader) uint16() uint16 {
+	if b := r.take(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *reader) uint32() uint32 {
+	if b := r.take(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *reader) uint64() uint64 {
+	if b := r.take(8); b != nil {
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (r *reader) string() string {
+	if r.err != nil {
+		return ""
+	}
+	i := bytes.IndexByte(r.b, 0)
+	if i < 0 {
+		r.err = errors.New("unterminated string")
+		return ""
+	}
+	s := string(r.b[:i])
+	r.b = r.b[i+1:]
+	return s
+}
+
+func (r *reader) tuple() tuple {
+	n := int(r.uint16())
+	t := make(tuple, 0, n)
+	for i := 0; i < n && r.err == nil; i++ {
+		c := tupleColumn{kind: r.byte()}
+		switch c.kind {
+		case 'n', 'u':
+		case 't', 'b':
+			// Copy the data because the message buffer is reused and an
+			// empty value must not be mistaken for NULL.
+			b := r.take(int(int32(r.uint32())))
+			c.data = append(make([]byte, 0, len(b)), b...)
+		default:
+			r.err = errors.Errorf("unknown tuple column kind %q", c.kind)
+		}
+		t = append(t, c)
+	}
+	return t
+}
+
+// parseMessage decodes a pgoutput message. Messages that are not needed to
+// build transactions, like origin and type messages, return nil.
+func parseMessage(data []byte) (any, error) {
+	if len(data) == 0 {
+		return nil, errors.New("empty message")
+	}
+	r := reader{b: data[1:]}
+	var msg any
+	switch data[0] {
+	case 'B':
+		msg = &beginMsg{
+			finalLSN:   LSN(r.uint64()),
+			commitTime: pgTime(int64(r.uint64())),
+			xid:        r.uint32(),
+		}
+	case 'C':
+		r.byte() // flags
+		msg = &commitMsg{
+			commitLSN:  LSN(r.uint64()),
+			endLSN:     LSN(r.uint64()),
+			commitTime: pgTime(int64(r.uint64())),
+		}
+	case 'R':
+		rel := &relation{id: r.uint32(), schema: r.string(), name: r.string()}
+		r.byte() // replica identity
+		n := int(r.uint16())
+		for i := 0; i < n && r.err == nil; i++ {
+			flags := r.byte()
+			c := relColumn{key: flags&1 != 0, name: r.string(), oid: r.uint32()}
+			r.uint32() // type modifier
+			rel.columns = append(rel.columns, c)
+		}
+		msg = rel
+	case 'I':
+		m := &rowMsg{op: Insert, relation: r.uint32()}
+		if r.byte() != 'N' && r.err == nil {
+			r.err = errors.New("insert has no new tuple")
+		}
+		m.new = r.tuple()
+		msg = m
+	case 'U':
+		m := &rowMsg{op: Update, relation: r.uint32()}
+		kind := r.byte()
+		if kind == 'K' || kind == 'O' {
+			m.keyOnly = kind == 'K'
+			m.old = r.tuple()
+			kind = r.byte()
+		}
+		if kind != 'N' && r.err == nil {
+			r.err = errors.New("update has no new tuple")
+		}
+		m.new = r.tuple()
+		msg = m
+	case 'D':
+		m := &rowMsg{op: Delete, relation: r.uint32()}
+		kind := r.byte()
+		if kind != 'K' && kind != 'O' && r.err == nil {
+			r.err = errors.New("delete has no old tuple")
+		}
+		m.keyOnly = kind == 'K'
+		m.old = r.tuple()
+		msg = m
+	case 'T':
+		n := int(r.uint32())
+		r.byte() // options
+		m := &truncateMsg{}
+		for i := 0; i < n && r.err == nil; i++ {
+			m.relations = append(m.relations, r.uint32())
+		}
+		msg = m
+	default:
+		return nil, nil
+	}
+	if r.err != nil {
+		return nil, errors.Wrapf(r.err, "failed to decode %q message", data[0])
+	}
+	return msg, nil
+}
@@ -0,0 +1,216 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ChecksumOpt is an option for [Checksum] and [CompareTable].
+type ChecksumOpt func(*checksumOpts)
+
+type checksumOpts struct {
+	chunkSize int
+}
+
+// WithChecksumChunkSize sets the number of rows hashed by each query.
+// Defaults to 10000.
+func WithChecksumChunkSize(n int) ChecksumOpt {
+	return func(o *checksumOpts) {
+		if n > 0 {
+			o.chunkSize = n
+		}
+	}
+}
+
+// ChunkChecksum is the checksum of a chunk of rows.
+type ChunkChecksum struct {
+	Rows int64
+	// LastKey is the key of the last row in the chunk.
+	LastKey any
+	Hash    string
+}
+
+// ChecksumMismatch is returned by [CompareTable] when two tables hold
+// different data.
+type ChecksumMismatch struct {
+	Table string
+	// After is the key of the row before the first chunk that differs, nil
+	// if it is the first chunk.
+	After any
+	A, B  ChunkChecksum
+}
+
+func (m *ChecksumMismatch) Error() string {
+	if m.After == nil {
+		return fmt.Sprintf("table %q differs in the first chunk", m.Table)
+	}
+	return fmt.Sprintf("table %q differs after key %v", m.Table, m.After)
+}
+
+// Checksum hashes the columns of every row in a table. The first column
+// must be a unique key and the table is hashed in chunks ordered by it, each
+// in a single query so the rows stay in the database. On postgres and mysql
+// the rows are hashed by the database. Sqlite has no hash functions so the
+// rows of a chunk are joined into one value by the database and hashed
+// here.
+//
+// Checksums are only comparable between databases of the same [Type]
+// because each formats values differently.
+func Checksum(ctx context.Context, db DB, table string, cols []string, opts ...ChecksumOpt) (string, error) {
+	c, err := newChunker(db, table, cols, opts)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	for {
+		chunk, ok, err := c.next(ctx)
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			return hex.EncodeToString(h.Sum(nil)), nil
+		}
+		fmt.Fprintf(h, "%d:%s\n", chunk.Rows, chunk.Hash)
+	}
+}
+
+// CompareTable compares the checksums of a table in two databases chunk by
+// chunk, i.e. before switching over to a migrated database. It returns a
+// [*ChecksumMismatch] for the first chunk that is different.
+func CompareTable(ctx context.Context, a, b DB, table string, cols []string, opts ...ChecksumOpt) error {
+	ca, err := newChunker(a, table, cols, opts)
+	if err != nil {
+		return err
+	}
+	cb, err := newChunker(b, table, cols, opts)
+	if err != nil {
+		return err
+	}
+	var after any
+	for {
+		x, okA, err := ca.next(ctx)
+		if err != nil {
+			return errors.Wrap(err, "first database")
+		}
+		y, okB, err := cb.next(ctx)
+		if err != nil {
+			return errors.Wrap(err, "second database")
+		}
+		if x.Rows != y.Rows || x.Hash != y.Hash {
+			return &ChecksumMismatch{Table: table, After: after, A: x, B: y}
+		}
+		if !okA && !okB {
+			return nil
+		}
+		after = x.LastKey
+	}
+}
+
+// chunker reads the checksums of a table's chunks in order.
+type chunker struct {
+	db DB
+	// first selects the first chunk and rest the chunks after the last key.
+	first, rest string
+	typ         Type
+	size        int
+	last        any
+	started     bool
+	finished    bool
+}
+
+func newChunker(db DB, table string, cols []string, opts []ChecksumOpt) (*chunker, error) {
+	o := checksumOpts{chunkSize: 10000}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if len(cols) == 0 {
+		return nil, errors.New("checksum needs at least one column")
+	}
+	d := DialectOf(db)
+	quoted := make([]string, len(cols))
+	for i, c := range cols {
+		quoted[i] = d.QuoteIdent(c)
+	}
+	key := quoted[0]
+	var row, agg string
+	switch t := d.Type(); {
+	case t.postgresWire():
+		row = "md5(ROW(" + strings.Join(quoted, ", ") + ")::text)"
+		agg = "md5(string_agg(h, '' ORDER BY k))"
+	case t == MySQLDBType:
+		for i, c := range quoted {
+			quoted[i] = "QUOTE(" + c + ")"
+		}
+		row = "MD5(CONCAT_WS(',', " + strings.Join(quoted, ", ") + "))"
+		// The rows are unique so xor-ing their hashes doesn't depend on
+		// order and doesn't cancel out.
+		agg = "CONCAT(BIT_XOR(CAST(CONV(SUBSTRING(h, 1, 16), 16, 10) AS UNSIGNED)), '-', " +
+			"BIT_XOR(CAST(CONV(SUBSTRING(h, 17, 16), 16, 10) AS UNSIGNED)))"
+	case t == SQLiteDBType:
+		for i, c := range quoted {
+			quoted[i] = "quote(" + c + ")"
+		}
+		row = strings.Join(quoted, " || ',' || ")
+		agg = "group_concat(h, char(10))"
+	default:
+		return nil, errors.Errorf("checksums are not supported for %q", t)
+	}
+	query := func(where string) string {
+		return "SELECT count(*), max(k), " + agg + " FROM (SELECT " + key + " AS k, " + row +
+			" AS h FROM " + d.QuoteIdent(table) + where + " ORDER BY " + key + " " +
+			d.Limit(o.chunkSize, 0) + ") chunk"
+	}
+	return &chunker{
+		db:    db,
+		first: query(""),
+		rest:  query(" WHERE " + key + " > " + d.Placeholder(1)),
+		typ:   d.Type(),
+		size:  o.chunkSize,
+	}, nil
+}
+
+// next returns the next chunk. It returns false once there are no rows
+// left.
+func (c *chunker) next(ctx context.Context) (ChunkChecksum, bool, error) {
+	if c.finished {
+		return ChunkChecksum{}, false, nil
+	}
+	query, args := c.first, []any(nil)
+	if c.started {
+		query, args = c.rest, []any{c.last}
+	}
+	rows, err := c.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return ChunkChecksum{}, false, err
+	}
+	var (
+		chunk ChunkChecksum
+		hash  sql.NullString
+	)
+	err = ScanOne(rows, &chunk.Rows, &chunk.LastKey, &hash)
+	if err != nil && !IsNotFound(err) {
+		return ChunkChecksum{}, false, err
+	}
+	if chunk.Rows == 0 {
+		c.finished = true
+		return ChunkChecksum{}, false, nil
+	}
+	chunk.Hash = hash.String
+	if c.typ == SQLiteDBType {
+		sum := sha256.Sum256([]byte(hash.String))
+		chunk.Hash = hex.EncodeToString(sum[:])
+	}
+	if b, ok := chunk.LastKey.([]byte); ok {
+		// Compare the next chunk's keys as text, not binary data.
+		chunk.LastKey = string(b)
+	}
+	c.last, c.started = chunk.LastKey, true
+	c.finished = chunk.Rows < int64(c.size)
+	return chunk, true, nil
+}
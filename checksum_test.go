@@ -0,0 +1,91 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/pkg/errors"
+)
+
+func TestChecksum(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	open := func() DB {
+		pool, err := sql.Open("sqlite3", ":memory:")
+		is.NoErr(err)
+		t.Cleanup(func() { pool.Close() })
+		pool.SetMaxOpenConns(1)
+		d := New(pool, WithDialect(DialectFor(SQLiteDBType)))
+		is.NoErr(ExecScript(ctx, d, `
+CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT, score REAL);
+INSERT INTO users VALUES (1, 'a', 1.5), (2, 'b', NULL), (3, 'c,d', 2), (4, NULL, 0), (5, 'e', 1);`))
+		return d
+	}
+	a, b := open(), open()
+	cols := []string{"id", "name", "score"}
+	chunks := WithChecksumChunkSize(2)
+
+	sumA, err := Checksum(ctx, a, "users", cols, chunks)
+	is.NoErr(err)
+	sumB, err := Checksum(ctx, b, "users", cols, chunks)
+	is.NoErr(err)
+	is.Equal(sumA, sumB)
+	whole, err := Checksum(ctx, a, "users", cols)
+	is.NoErr(err)
+	is.True(whole != sumA) // chunking is part of the checksum
+	is.NoErr(CompareTable(ctx, a, b, "users", cols, chunks))
+
+	_, err = b.ExecContext(ctx, "UPDATE users SET name = NULL WHERE id = 3")
+	is.NoErr(err)
+	sumB, err = Checksum(ctx, b, "users", cols, chunks)
+	is.NoErr(err)
+	is.True(sumA != sumB)
+	err = CompareTable(ctx, a, b, "users", cols, chunks)
+	var m *ChecksumMismatch
+	is.True(errors.As(err, &m))
+	is.Equal(m.After, int64(2))
+	is.Equal(m.Error(), `table "users" differs after key 2`)
+	// Only the changed column is checked.
+	is.NoErr(CompareTable(ctx, a, b, "users", []string{"id", "score"}, chunks))
+
+	_, err = b.ExecContext(ctx, "DELETE FROM users WHERE id = 1")
+	is.NoErr(err)
+	err = CompareTable(ctx, a, b, "users", cols, chunks)
+	is.True(errors.As(err, &m))
+	is.Equal(m.After, nil)
+	is.Equal(m.Error(), `table "users" differs in the first chunk`)
+	_, err = b.ExecContext(ctx, "DELETE FROM users")
+	is.NoErr(err)
+	err = CompareTable(ctx, a, b, "users", cols, chunks)
+	is.True(errors.As(err, &m))
+	is.Equal(m.B.Rows, int64(0))
+
+	_, err = Checksum(ctx, a, "users", nil)
+	is.True(err != nil)
+	_, err = Checksum(ctx, a, "missing", cols)
+	is.True(err != nil)
+	is.True(CompareTable(ctx, a, b, "missing", cols) != nil)
+	is.True(CompareTable(ctx, a, b, "users", nil) != nil)
+	is.True(CompareTable(ctx, a, New(nil, WithDialect(DialectFor(ClickHouseDBType))), "users", cols) != nil)
+}
+
+func TestChecksum_Postgres(t *testing.T) {
+	is := is.New(t)
+	rec := &recorder{}
+	pool := sql.OpenDB(rec)
+	defer pool.Close()
+	d := New(pool)
+	_, err := Checksum(context.Background(), d, "users", []string{"id", "name"})
+	is.NoErr(err)
+	is.Equal(rec.take(), []string{`SELECT count(*), max(k), md5(string_agg(h, '' ORDER BY k)) FROM ` +
+		`(SELECT "id" AS k, md5(ROW("id", "name")::text) AS h FROM "users" ORDER BY "id" LIMIT 10000) chunk`})
+
+	d = New(pool, WithDialect(DialectFor(MySQLDBType)))
+	c, err := newChunker(d, "users", []string{"id", "name"}, nil)
+	is.NoErr(err)
+	is.Equal(c.rest, "SELECT count(*), max(k), CONCAT(BIT_XOR(CAST(CONV(SUBSTRING(h, 1, 16), 16, 10) AS UNSIGNED)), '-', "+
+		"BIT_XOR(CAST(CONV(SUBSTRING(h, 17, 16), 16, 10) AS UNSIGNED))) FROM (SELECT `id` AS k, "+
+		"MD5(CONCAT_WS(',', QUOTE(`id`), QUOTE(`name`))) AS h FROM `users` WHERE `id` > ? ORDER BY `id` LIMIT 10000) chunk")
+}
@@ -0,0 +1,200 @@
+package db
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultMaxParams is the limit on the number of placeholders used in a
+// single statement by the postgres wire protocol.
+//
+// Deprecated: the limit now comes from the database's [Dialect], see
+// [Dialect.MaxParams].
+const DefaultMaxParams = 65535
+
+// ChunkOpt is an option for [QueryChunked] and [ExecChunked].
+type ChunkOpt func(*chunkOpts)
+
+type chunkOpts struct {
+	size        int
+	maxParams   int
+	parallelism int
+}
+
+// WithChunkSize sets the maximum number of list elements sent with each
+// statement.
+func WithChunkSize(n int) ChunkOpt { return func(o *chunkOpts) { o.size = n } }
+
+// WithMaxParams sets the maximum number of placeholders a statement may have.
+// Defaults to the limit of the database's [Dialect]. Handles without a
+// Dialect method are treated as postgres by [DialectOf], so set this when
+// chunking statements for other databases through them.
+func WithMaxParams(n int) ChunkOpt { return func(o *chunkOpts) { o.maxParams = n } }
+
+// WithParallelism sets the number of chunks that will be run concurrently.
+func WithParallelism(n int) ChunkOpt { return func(o *chunkOpts) { o.parallelism = n } }
+
+// QueryChunked runs a query that has a slice argument for an IN clause. If the
+// slice would expand to more placeholders than allowed, the query is run once
+// for each chunk of the slice. The fn callback is called for each row of every
+// chunk but never concurrently.
+func QueryChunked(
+	ctx context.Context,
+	db DB,
+	query string,
+	args []any,
+	fn func(Scanner) error,
+	opts ...ChunkOpt,
+) error {
+	var mu sync.Mutex
+	return runChunks(ctx, DialectOf(db), query, args, opts, func(ctx context.Context, query string, args []any) (err error) {
+		rows, err := db.QueryContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if e := rows.Close(); e != nil && err == nil {
+				err = e
+			}
+		}()
+		for rows.Next() {
+			mu.Lock()
+			err = fn(rows)
+			mu.Unlock()
+			if err != nil {
+				return err
+			}
+		}
+		return rows.Err()
+	})
+}
+
+// ExecChunked runs a statement that has a slice argument for an IN clause,
+// splitting it into chunks in the same way as [QueryChunked]. The total
+// number of rows affected is returned.
+func ExecChunked(
+	ctx context.Context,
+	db DB,
+	query string,
+	args []any,
+	opts ...ChunkOpt,
+) (int64, error) {
+	var (
+		mu    sync.Mutex
+		total int64
+	)
+	err := runChunks(ctx, DialectOf(db), query, args, opts, func(ctx context.Context, query string, args []any) error {
+		res, err := db.ExecContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		total += n
+		mu.Unlock()
+		return nil
+	})
+	return total, err
+}
+
+func runChunks(
+	ctx context.Context,
+	dialect Dialect,
+	query string,
+	args []any,
+	opts []ChunkOpt,
+	run func(ctx context.Context, query string, args []any) error,
+) error {
+	o := chunkOpts{maxParams: dialect.MaxParams(), parallelism: 1}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	// Find the longest list which is the one that gets chunked.
+	var (
+		index  = -1
+		list   []any
+		params int
+	)
+	for i, a := range args {
+		l, ok := asList(a)
+		if !ok {
+			params++
+			continue
+		}
+		params += len(l)
+		if len(l) > len(list) {
+			index, list = i, l
+		}
+	}
+	if index < 0 {
+		return errors.New("no slice argument to chunk")
+	}
+	size := o.maxParams - (params - len(list))
+	if o.size > 0 && o.size < size {
+		size = o.size
+	}
+	if size <= 0 {
+		return errors.Errorf("too many arguments to fit in %d placeholders", o.maxParams)
+	}
+
+	type chunk struct {
+		query string
+		args  []any
+	}
+	chunks := make([]chunk, 0, len(list)/size+1)
+	for start := 0; start < len(list); start += size {
+		end := min(start+size, len(list))
+		a := make([]any, len(args))
+		copy(a, args)
+		a[index] = list[start:end]
+		q, a, err := In(query, a...)
+		if err != nil {
+			return err
+		}
+		chunks = append(chunks, chunk{q, a})
+	}
+
+	if o.parallelism <= 1 || len(chunks) == 1 {
+		for _, c := range chunks {
+			if err := run(ctx, c.query, c.args); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+		sem      = make(chan struct{}, o.parallelism)
+	)
+	for _, c := range chunks {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(c chunk) {
+			defer func() { <-sem; wg.Done() }()
+			if err := run(ctx, c.query, c.args); err != nil {
+				once.Do(func() { firstErr = err; cancel() })
+			}
+		}(c)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
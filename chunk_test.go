@@ -0,0 +1,63 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestQueryChunked(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	pool, err := sql.Open("sqlite3", "file:chunked?mode=memory&cache=shared")
+	is.NoErr(err)
+	defer pool.Close()
+	d := Simple(pool)
+	_, err = d.ExecContext(ctx, "create table t (id int, kind text)")
+	is.NoErr(err)
+	ids := make([]int, 100)
+	for i := range ids {
+		ids[i] = i
+		_, err = d.ExecContext(ctx, "insert into t values (?, ?)", i, "a")
+		is.NoErr(err)
+	}
+
+	for _, opts := range [][]ChunkOpt{
+		{WithChunkSize(7)},
+		{WithMaxParams(11), WithParallelism(4)},
+		{WithParallelism(3), WithChunkSize(1000)},
+	} {
+		var got []int
+		err = QueryChunked(ctx, d, "select id from t where kind = ? and id in (?)", []any{"a", ids}, func(s Scanner) error {
+			var id int
+			if err := s.Scan(&id); err != nil {
+				return err
+			}
+			got = append(got, id)
+			return nil
+		}, opts...)
+		is.NoErr(err)
+		sort.Ints(got)
+		is.Equal(got, ids)
+	}
+
+	n, err := ExecChunked(ctx, d, "update t set kind = ? where id in (?)", []any{"b", ids[:50]}, WithChunkSize(9), WithParallelism(2))
+	is.NoErr(err)
+	is.Equal(n, int64(50))
+
+	errStop := sql.ErrNoRows
+	err = QueryChunked(ctx, d, "select id from t where id in (?)", []any{ids}, func(Scanner) error {
+		return errStop
+	}, WithChunkSize(3), WithParallelism(2))
+	is.Equal(err, errStop)
+
+	_, err = ExecChunked(ctx, d, "delete from t where id = ?", []any{1})
+	is.True(err != nil)
+	_, err = ExecChunked(ctx, d, "delete from t where kind = ? and id in (?)", []any{"a", ids}, WithMaxParams(1))
+	is.True(err != nil)
+	_, err = ExecChunked(ctx, d, "delete from nope where id in (?)", []any{ids}, WithChunkSize(10))
+	is.True(err != nil)
+}
@@ -0,0 +1,202 @@
+package db
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrCommitUnknown is returned when the connection was lost while committing
+// a transaction and it could not be determined if the commit was applied.
+var ErrCommitUnknown = errors.New("transaction commit outcome unknown")
+
+// CommitVerifier finds out if a transaction was committed when the connection
+// breaks during the commit. A random token is written to the verifier's table
+// as the last statement in the transaction. If the commit fails with a
+// connection error, the token is looked up using another connection to see
+// if the transaction was applied.
+type CommitVerifier struct {
+	// DB is used to look up tokens after a failed commit. It must not be
+	// the transaction being committed. Lookups use a context from
+	// [AfterWrite] so they skip replicas. It also terminates the session of
+	// the failed commit, which needs the same user as the transaction or
+	// the pg_signal_backend role on postgres and CONNECTION_ADMIN on mysql.
+	DB DB
+	// Table stores the tokens. See [CommitVerifier.CreateTable].
+	Table string
+}
+
+// WithCommitVerifier makes [TxDo] and [WithTx] commit using the
+// [CommitVerifier].
+func WithCommitVerifier(cv *CommitVerifier) TxOpt {
+	return func(o *txOpts) { o.verifier = cv }
+}
+
+type committer interface {
+	ExecContext(context.Context, string, ...any) (sql.Result, error)
+	Commit() error
+}
+
+// CreateTable creates the token table if it does not exist.
+func (cv *CommitVerifier) CreateTable(ctx context.Context) error {
+	_, err := cv.DB.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+cv.Table+" ("+
+		"token VARCHAR(64) PRIMARY KEY, "+
+		"created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP)")
+	return errors.WithStack(err)
+}
+
+// Prune deletes tokens older than the given age.
+func (cv *CommitVerifier) Prune(ctx context.Context, age time.Duration) (int64, error) {
+	res, err := cv.DB.ExecContext(ctx,
+		"DELETE FROM "+cv.Table+" WHERE created_at < "+DialectOf(cv.DB).Placeholder(1),
+		now().Add(-age).UTC())
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	return res.RowsAffected()
+}
+
+// Commit writes a token in the transaction and commits it. If the commit
+// fails because the connection broke, the token is looked up on the primary
+// to decide if the commit went through. A missing token only means that the
+// transaction was not committed once the old session is gone, so it is
+// terminated and waited for first on postgres and mysql. [ErrCommitUnknown]
+// is returned when the session can't be ruled out or the lookup fails.
+func (cv *CommitVerifier) Commit(ctx context.Context, tx committer) error {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return errors.WithStack(err)
+	}
+	token := hex.EncodeToString(b[:])
+	dialect := DialectOf(cv.DB)
+	_, err := tx.ExecContext(ctx,
+		"INSERT INTO "+cv.Table+" (token) VALUES ("+dialect.Placeholder(1)+")", token)
+	if err != nil {
+		return errors.Wrap(err, "could not write commit token")
+	}
+	var (
+		session    int64
+		sessionErr error
+	)
+	sq, ok := sessionQueries[dialect.Type()]
+	switch {
+	case ok:
+		session, sessionErr = sessionID(ctx, tx, sq.id)
+	case dialect.Type() != SQLiteDBType:
+		// sqlite is embedded so there is no session that outlives the commit.
+		sessionErr = errors.Errorf("cannot end %s sessions", dialect.Type())
+	}
+	commitErr := tx.Commit()
+	if commitErr == nil || dialect.ClassifyError(commitErr) != ErrorKindConnection {
+		return commitErr
+	}
+	// Replicas may not have the token yet.
+	ctx = AfterWrite(ctx)
+	if ok && sessionErr == nil {
+		sessionErr = cv.endSession(ctx, sq, session)
+	}
+	if err = sessionErr; err != nil {
+		return fmt.Errorf("%w: commit failed with %q and the old session could not be ended: %w", ErrCommitUnknown, commitErr, err)
+	}
+	rows, err := cv.DB.QueryContext(ctx,
+		"SELECT 1 FROM "+cv.Table+" WHERE token = "+dialect.Placeholder(1), token)
+	if err == nil {
+		var one int
+		err = ScanOne(rows, &one)
+	}
+	switch {
+	case err == nil:
+		// The token was written so the transaction was committed.
+		return nil
+	case errors.Is(err, sql.ErrNoRows):
+		return commitErr
+	default:
+		return errors.Wrapf(ErrCommitUnknown, "commit failed with %q and verification failed with %q", commitErr, err)
+	}
+}
+
+// sessionQuery looks up, terminates and checks for a server session.
+type sessionQuery struct {
+	// id returns the id of the current session.
+	id string
+	// kill is a format string for the statement that terminates a session.
+	kill string
+	// alive returns a row while the session with the id in the first
+	// argument exists.
+	alive string
+}
+
+var sessionQueries = map[Type]sessionQuery{
+	PostgresDBType: {
+		id:    "SELECT pg_backend_pid()",
+		kill:  "SELECT pg_terminate_backend(%d)",
+		alive: "SELECT 1 FROM pg_stat_activity WHERE pid = $1",
+	},
+	MySQLDBType: {
+		id:    "SELECT CONNECTION_ID()",
+		kill:  "KILL %d",
+		alive: "SELECT 1 FROM information_schema.processlist WHERE id = ?",
+	},
+}
+
+// endSessionTimeout bounds how long [CommitVerifier.Commit] waits for the
+// old session to end when the context has no deadline.
+const endSessionTimeout = 10 * time.Second
+
+// endSession terminates the session and waits until it is gone so that it
+// can't commit after the token was looked up.
+func (cv *CommitVerifier) endSession(ctx context.Context, sq sessionQuery, id int64) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, endSessionTimeout)
+		defer cancel()
+	}
+	// The session may already be gone, which is checked below.
+	_, _ = cv.DB.ExecContext(ctx, fmt.Sprintf(sq.kill, id))
+	for wait := 10 * time.Millisecond; ; wait = min(2*wait, time.Second) {
+		rows, err := cv.DB.QueryContext(ctx, sq.alive, id)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		var one int
+		err = ScanOne(rows, &one)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil
+		case err != nil:
+			return err
+		}
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return errors.Wrapf(ctx.Err(), "session %d did not end", id)
+		case <-t.C:
+		}
+	}
+}
+
+// sessionID runs the query in the transaction and scans its result.
+func sessionID(ctx context.Context, tx committer, query string) (int64, error) {
+	var id int64
+	switch q := tx.(type) {
+	case interface {
+		QueryContext(context.Context, string, ...any) (Rows, error)
+	}:
+		rows, err := q.QueryContext(ctx, query)
+		if err != nil {
+			return 0, err
+		}
+		return id, ScanOne(rows, &id)
+	case interface {
+		QueryRowContext(context.Context, string, ...any) *sql.Row
+	}:
+		return id, q.QueryRowContext(ctx, query).Scan(&id)
+	}
+	return 0, errors.Errorf("cannot query %T", tx)
+}
@@ -0,0 +1,119 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/pkg/errors"
+)
+
+// lostAckTx simulates a connection that breaks while committing.
+type lostAckTx struct {
+	Tx
+	apply bool
+}
+
+func (t *lostAckTx) Commit() error {
+	var err error
+	if t.apply {
+		err = t.Tx.Commit()
+	} else {
+		err = t.Tx.Rollback()
+	}
+	if err != nil {
+		return err
+	}
+	return driver.ErrBadConn
+}
+
+func TestCommitVerifier(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	pool, err := sql.Open("sqlite3", "file:commitverifier?mode=memory&cache=shared")
+	is.NoErr(err)
+	defer pool.Close()
+	d := New(pool, WithDialect(DialectFor(SQLiteDBType)))
+	cv := &CommitVerifier{DB: d, Table: "tx_tokens"}
+	is.NoErr(cv.CreateTable(ctx))
+	_, err = d.ExecContext(ctx, "create table t (a int)")
+	is.NoErr(err)
+
+	run := func(apply bool) error {
+		tx, err := d.BeginTx(ctx, nil)
+		is.NoErr(err)
+		return TxDo(ctx, &lostAckTx{Tx: tx, apply: apply}, func(tx Tx) error {
+			_, err := tx.ExecContext(ctx, "insert into t values (1)")
+			return err
+		}, WithCommitVerifier(cv))
+	}
+	is.NoErr(run(true))
+	err = run(false)
+	is.True(errors.Is(err, driver.ErrBadConn))
+
+	err = WithTx(ctx, pool, nil, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, "insert into t values (2)")
+		return err
+	}, WithCommitVerifier(cv))
+	is.NoErr(err)
+
+	var n int
+	is.NoErr(pool.QueryRow("select count(*) from t").Scan(&n))
+	is.Equal(n, 2)
+
+	defer withNow(time.Now().Add(time.Hour))()
+	pruned, err := cv.Prune(ctx, time.Minute)
+	is.NoErr(err)
+	is.Equal(pruned, int64(2))
+
+	// The lookup fails so the outcome is unknown.
+	closed, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	is.NoErr(closed.Close())
+	unknown := &CommitVerifier{DB: New(closed, WithDialect(DialectFor(SQLiteDBType))), Table: "tx_tokens"}
+	tx, err := d.BeginTx(ctx, nil)
+	is.NoErr(err)
+	err = unknown.Commit(ctx, &lostAckTx{Tx: tx, apply: true})
+	is.True(errors.Is(err, ErrCommitUnknown))
+
+	// The token is only missing for sure once the old session has ended.
+	_, err = d.ExecContext(ctx, "create table sessions (id int)")
+	is.NoErr(err)
+	sessionQueries[SQLiteDBType] = sessionQuery{
+		id:    "SELECT 7",
+		kill:  "DELETE FROM sessions WHERE id = %d AND 0",
+		alive: "SELECT 1 FROM sessions WHERE id = ?",
+	}
+	defer delete(sessionQueries, SQLiteDBType)
+	_, err = d.ExecContext(ctx, "insert into sessions values (7)")
+	is.NoErr(err)
+	tctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	tx, err = d.BeginTx(ctx, nil)
+	is.NoErr(err)
+	err = cv.Commit(tctx, &lostAckTx{Tx: tx})
+	is.True(errors.Is(err, ErrCommitUnknown))
+	is.True(errors.Is(err, context.DeadlineExceeded))
+
+	sessionQueries[SQLiteDBType] = sessionQuery{
+		id:    "SELECT 7",
+		kill:  "DELETE FROM sessions WHERE id = %d",
+		alive: "SELECT 1 FROM sessions WHERE id = ?",
+	}
+	tx, err = d.BeginTx(ctx, nil)
+	is.NoErr(err)
+	err = cv.Commit(ctx, &lostAckTx{Tx: tx})
+	is.True(errors.Is(err, driver.ErrBadConn))
+	is.True(!errors.Is(err, ErrCommitUnknown))
+
+	// Errors that are not connection errors are returned as is.
+	tx, err = d.BeginTx(ctx, nil)
+	is.NoErr(err)
+	is.NoErr(tx.Rollback())
+	err = cv.Commit(ctx, tx)
+	is.True(err != nil)
+	is.True(!errors.Is(err, ErrCommitUnknown))
+}
@@ -0,0 +1,214 @@
+package db
+
+import (
+	"bytes"
+	"compress/gzip"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// Compression formats written after the [CompressionMagic] prefix of a
+// [Compressed] value.
+const (
+	CompressionNone byte = 0x00
+	CompressionGzip byte = 0x01
+	// CompressionZstd is reserved for zstd. There is no built in zstd
+	// implementation, use [RegisterCompressor] to provide one.
+	CompressionZstd byte = 0x02
+
+	// maxCompressionFormat is the largest format byte.
+	maxCompressionFormat byte = 0x0f
+)
+
+// CompressionMagic starts every value written by [Compressed]. Values
+// without it are assumed to be uncompressed data written before the column
+// was wrapped. 0xff never starts valid UTF-8 or JSON, so text values written
+// before can't be mistaken for it.
+const CompressionMagic = "\xffDBZ"
+
+// DefaultCompressionThreshold is the encoded size in bytes at which
+// [Compressed] values start being compressed unless changed with
+// [SetCompression].
+const DefaultCompressionThreshold = 1024
+
+type compressionSettings struct {
+	format    byte
+	threshold int
+}
+
+var compression atomic.Pointer[compressionSettings]
+
+func init() {
+	compression.Store(&compressionSettings{format: CompressionGzip, threshold: DefaultCompressionThreshold})
+}
+
+// SetCompression sets the format used to compress [Compressed] values and
+// the encoded size in bytes at which they start being compressed. It
+// defaults to gzip and [DefaultCompressionThreshold] and is safe to call
+// while values are being written.
+func SetCompression(format byte, threshold int) {
+	if format == CompressionNone || format > maxCompressionFormat {
+		panic(fmt.Sprintf("db: invalid compression format %#x", format))
+	}
+	compression.Store(&compressionSettings{format: format, threshold: threshold})
+}
+
+// Compression returns the format and threshold set with [SetCompression].
+func Compression() (format byte, threshold int) {
+	s := compression.Load()
+	return s.format, s.threshold
+}
+
+// Compressor compresses and decompresses column values.
+type Compressor interface {
+	Compress([]byte) ([]byte, error)
+	Decompress([]byte) ([]byte, error)
+}
+
+var (
+	compressorsMu sync.RWMutex
+	compressors   = map[byte]Compressor{CompressionGzip: gzipCompressor{}}
+)
+
+// RegisterCompressor registers a [Compressor] for a format header byte.
+func RegisterCompressor(format byte, c Compressor) {
+	if format == CompressionNone || format > maxCompressionFormat {
+		panic(fmt.Sprintf("db: invalid compression format %#x", format))
+	}
+	compressorsMu.Lock()
+	compressors[format] = c
+	compressorsMu.Unlock()
+}
+
+func getCompressor(format byte) (Compressor, error) {
+	compressorsMu.RLock()
+	c, ok := compressors[format]
+	compressorsMu.RUnlock()
+	if !ok {
+		return nil, errors.Errorf("no compressor registered for format %#x", format)
+	}
+	return c, nil
+}
+
+// Compressed is a column type that compresses its value when writing to the
+// database and decompresses it when scanning. Strings and byte slices are
+// stored as is, everything else is encoded as JSON. The stored value starts
+// with [CompressionMagic] and a byte that identifies the compression format
+// so values under the threshold of [SetCompression] are stored uncompressed
+// and the format can be changed without rewriting old rows.
+//
+// The stored values are binary, so only bytea and blob columns are
+// supported. Text and json columns reject them.
+type Compressed[T any] struct {
+	V T
+}
+
+// Value implements [driver.Valuer].
+func (c Compressed[T]) Value() (driver.Value, error) {
+	raw, err := encodeValue(&c.V)
+	if err != nil {
+		return nil, err
+	}
+	settings := compression.Load()
+	if len(raw) < settings.threshold {
+		return append(compressionHeader(CompressionNone), raw...), nil
+	}
+	comp, err := getCompressor(settings.format)
+	if err != nil {
+		return nil, err
+	}
+	b, err := comp.Compress(raw)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not compress value")
+	}
+	return append(compressionHeader(settings.format), b...), nil
+}
+
+func compressionHeader(format byte) []byte {
+	return append([]byte(CompressionMagic), format)
+}
+
+// Scan implements [database/sql.Scanner].
+func (c *Compressed[T]) Scan(src any) error {
+	var b []byte
+	switch v := src.(type) {
+	case nil:
+		var zero T
+		c.V = zero
+		return nil
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return errors.Errorf("cannot scan %T into %T", src, c)
+	}
+	if len(b) > len(CompressionMagic) && string(b[:len(CompressionMagic)]) == CompressionMagic {
+		format := b[len(CompressionMagic)]
+		b = b[len(CompressionMagic)+1:]
+		if format != CompressionNone {
+			comp, err := getCompressor(format)
+			if err != nil {
+				return err
+			}
+			if b, err = comp.Decompress(b); err != nil {
+				return errors.Wrap(err, "could not decompress value")
+			}
+		}
+	}
+	return decodeValue(b, &c.V)
+}
+
+func encodeValue[T any](v *T) ([]byte, error) {
+	rv := reflect.ValueOf(v).Elem()
+	switch {
+	case rv.Kind() == reflect.String:
+		return []byte(rv.String()), nil
+	case rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8:
+		return bytes.Clone(rv.Bytes()), nil
+	}
+	return json.Marshal(v)
+}
+
+func decodeValue[T any](b []byte, v *T) error {
+	rv := reflect.ValueOf(v).Elem()
+	switch {
+	case rv.Kind() == reflect.String:
+		rv.SetString(string(b))
+		return nil
+	case rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8:
+		rv.Set(reflect.ValueOf(bytes.Clone(b)).Convert(rv.Type()))
+		return nil
+	}
+	return json.Unmarshal(b, v)
+}
+
+type gzipCompressor struct{}
+
+func (gzipCompressor) Compress(b []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(b); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gzipCompressor) Decompress(b []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
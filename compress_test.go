@@ -0,0 +1,94 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestCompressed(t *testing.T) {
+	is := is.New(t)
+	type payload struct {
+		Name string `json:"name"`
+		Tags []string
+	}
+	long := strings.Repeat("compress me ", 200)
+
+	v, err := Compressed[string]{V: "short"}.Value()
+	is.NoErr(err)
+	is.Equal(v, []byte(CompressionMagic+"\x00short"))
+	v, err = Compressed[string]{V: long}.Value()
+	is.NoErr(err)
+	b := v.([]byte)
+	is.Equal(string(b[:len(CompressionMagic)]), CompressionMagic)
+	is.Equal(b[len(CompressionMagic)], CompressionGzip)
+	is.True(len(b) < len(long))
+
+	var s Compressed[string]
+	is.NoErr(s.Scan(b))
+	is.Equal(s.V, long)
+	// Values written before the column was compressed are read as is.
+	is.NoErr(s.Scan("plain text"))
+	is.Equal(s.V, "plain text")
+	is.NoErr(s.Scan([]byte{0x01, 'a'}))
+	is.Equal(s.V, "\x01a")
+	is.NoErr(s.Scan(nil))
+	is.Equal(s.V, "")
+	is.True(s.Scan(1) != nil)
+	is.True(s.Scan(append(compressionHeader(CompressionZstd), 1, 2)) != nil)
+	is.True(s.Scan(append(compressionHeader(CompressionGzip), 1, 2)) != nil)
+
+	ctx := context.Background()
+	pool, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer pool.Close()
+	pool.SetMaxOpenConns(1)
+	_, err = pool.ExecContext(ctx, "create table t (p blob, b blob)")
+	is.NoErr(err)
+	in := payload{Name: long, Tags: []string{"a"}}
+	_, err = pool.ExecContext(ctx, "insert into t values (?, ?)",
+		Compressed[payload]{V: in}, Compressed[[]byte]{V: []byte("raw")})
+	is.NoErr(err)
+	var (
+		out Compressed[payload]
+		raw Compressed[[]byte]
+	)
+	is.NoErr(pool.QueryRowContext(ctx, "select p, b from t").Scan(&out, &raw))
+	is.Equal(out.V, in)
+	is.Equal(raw.V, []byte("raw"))
+}
+
+type xorCompressor struct{}
+
+func (xorCompressor) Compress(b []byte) ([]byte, error)   { return bytes.Map(xor, b), nil }
+func (xorCompressor) Decompress(b []byte) ([]byte, error) { return bytes.Map(xor, b), nil }
+func xor(r rune) rune                                     { return r ^ 1 }
+
+func TestRegisterCompressor(t *testing.T) {
+	is := is.New(t)
+	RegisterCompressor(0x0e, xorCompressor{})
+	defer SetCompression(Compression())
+	SetCompression(0x0e, 0)
+	format, threshold := Compression()
+	is.Equal(format, byte(0x0e))
+	is.Equal(threshold, 0)
+	v, err := Compressed[string]{V: "abc"}.Value()
+	is.NoErr(err)
+	is.Equal(v, append(compressionHeader(0x0e), 'a'^1, 'b'^1, 'c'^1))
+	var s Compressed[string]
+	is.NoErr(s.Scan(v))
+	is.Equal(s.V, "abc")
+
+	is.True(panics(func() { RegisterCompressor(CompressionNone, xorCompressor{}) }))
+	is.True(panics(func() { SetCompression(0x10, 0) }))
+}
+
+func panics(fn func()) (panicked bool) {
+	defer func() { panicked = recover() != nil }()
+	fn()
+	return false
+}
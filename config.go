@@ -1,12 +1,16 @@
 package db
 
 import (
+	"crypto/tls"
+	"database/sql"
+	stderrors "errors"
 	"net"
 	"net/url"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -14,44 +18,181 @@ import (
 type Type string
 
 const (
-	PostgresDBType Type = "postgres"
-	MySQLDBType    Type = "mysql"
+	PostgresDBType   Type = "postgres"
+	MySQLDBType      Type = "mysql"
+	SQLiteDBType     Type = "sqlite3"
+	SQLServerDBType  Type = "sqlserver"
+	ClickHouseDBType Type = "clickhouse"
+	// CockroachDBType uses the postgres wire protocol and driver.
+	CockroachDBType Type = "cockroachdb"
 )
 
+func (t Type) envPrefix() string { return strings.ToUpper(string(t)) + "_" }
+
+// postgresWire is true for databases that speak the postgres protocol.
+func (t Type) postgresWire() bool { return t == PostgresDBType || t == CockroachDBType }
+
+func (t Type) defaultPort() string {
+	switch t {
+	case PostgresDBType:
+		return "5432"
+	case MySQLDBType:
+		return "3306"
+	case SQLServerDBType:
+		return "1433"
+	case ClickHouseDBType:
+		return "9000"
+	case CockroachDBType:
+		return "26257"
+	}
+	return ""
+}
+
 // Config holds database connection config info.
 type Config struct {
-	Type     Type
-	Host     string
-	Port     string
-	User     string
-	Password string
-	DBName   string
+	Type Type `json:"type,omitempty" yaml:"type,omitempty" toml:"type,omitempty"`
+	// Host is a host name, an ip address or the path of a unix socket. For
+	// postgres a socket path is the directory holding the socket and for
+	// mysql it is the socket itself.
+	Host string `json:"host,omitempty" yaml:"host,omitempty" toml:"host,omitempty"`
+	// Hosts lists the servers of a cluster as "host" or "host:port". A
+	// comma separated Host is split into Hosts. Connections go to the first
+	// server that accepts them, see TargetSessionAttrs.
+	Hosts    []string `json:"hosts,omitempty" yaml:"hosts,omitempty" toml:"hosts,omitempty"`
+	Port     string   `json:"port,omitempty" yaml:"port,omitempty" toml:"port,omitempty"`
+	User     string   `json:"user,omitempty" yaml:"user,omitempty" toml:"user,omitempty"`
+	Password string   `json:"password,omitempty" yaml:"password,omitempty" toml:"password,omitempty"`
+	DBName   string   `json:"dbname,omitempty" yaml:"dbname,omitempty" toml:"dbname,omitempty"`
+	// Schema is the default schema of unqualified table names. On postgres
+	// it sets the search_path, which may list several comma separated
+	// schemas. Behind a Pooler it is set after connecting instead. Mysql
+	// schemas are databases so it is used as the database when DBName is
+	// empty.
+	Schema string `json:"schema,omitempty" yaml:"schema,omitempty" toml:"schema,omitempty"`
 	// Query options
-	SSLMode        string
-	SSLCA          string
-	SSLCert        string
-	SSLKey         string
-	SSLSNI         string
-	ConnectTimeout uint64
+	SSLMode        string `json:"sslmode,omitempty" yaml:"sslmode,omitempty" toml:"sslmode,omitempty"`
+	SSLCA          string `json:"sslca,omitempty" yaml:"sslca,omitempty" toml:"sslca,omitempty"`
+	SSLCert        string `json:"sslcert,omitempty" yaml:"sslcert,omitempty" toml:"sslcert,omitempty"`
+	SSLKey         string `json:"sslkey,omitempty" yaml:"sslkey,omitempty" toml:"sslkey,omitempty"`
+	SSLSNI         string `json:"sslsni,omitempty" yaml:"sslsni,omitempty" toml:"sslsni,omitempty"`
+	ConnectTimeout uint64 `json:"connect_timeout,omitempty" yaml:"connect_timeout,omitempty" toml:"connect_timeout,omitempty"`
+	// Session timeouts applied to every connection. On postgres they are
+	// sent as statement_timeout, lock_timeout and
+	// idle_in_transaction_session_timeout in the "options" startup
+	// parameter, ahead of any options in Params. Poolers reject it so
+	// behind a Pooler they are set after connecting instead. On mysql
+	// StatementTimeout sets max_execution_time, which only applies to
+	// SELECT statements, and LockTimeout sets innodb_lock_wait_timeout in
+	// whole seconds.
+	// IdleInTxTimeout is only supported by postgres.
+	StatementTimeout time.Duration `json:"statement_timeout,omitempty" yaml:"statement_timeout,omitempty" toml:"statement_timeout,omitempty"`
+	LockTimeout      time.Duration `json:"lock_timeout,omitempty" yaml:"lock_timeout,omitempty" toml:"lock_timeout,omitempty"`
+	IdleInTxTimeout  time.Duration `json:"idle_in_tx_timeout,omitempty" yaml:"idle_in_tx_timeout,omitempty" toml:"idle_in_tx_timeout,omitempty"`
+	// In-memory PEM encoded ssl material. These take precedence over the
+	// SSLCA, SSLCert and SSLKey file paths.
+	SSLCAPEM   string `json:"sslca_pem,omitempty" yaml:"sslca_pem,omitempty" toml:"sslca_pem,omitempty"`
+	SSLCertPEM string `json:"ssl_cert_pem,omitempty" yaml:"ssl_cert_pem,omitempty" toml:"ssl_cert_pem,omitempty"`
+	SSLKeyPEM  string `json:"ssl_key_pem,omitempty" yaml:"ssl_key_pem,omitempty" toml:"ssl_key_pem,omitempty"`
+	// TargetSessionAttrs is "read-write" (the default) to skip read-only
+	// servers when there are multiple hosts or "any" to use the first that
+	// is up.
+	TargetSessionAttrs string `json:"target_session_attrs,omitempty" yaml:"target_session_attrs,omitempty" toml:"target_session_attrs,omitempty"`
+	// AppName identifies the program in the server's connection list. It
+	// is sent as application_name on postgres and as the program_name
+	// connection attribute on mysql, where it cannot contain a comma or a
+	// colon. Pools opened by [Config.Open] use the name of the binary when
+	// it is empty.
+	AppName string `json:"app_name,omitempty" yaml:"app_name,omitempty" toml:"app_name,omitempty"`
+	// Params are extra driver options added to the query string of the URI
+	// and DSN, i.e. "application_name" or "search_path" for postgres and
+	// "collation" for mysql. They take precedence over the options built
+	// from the other fields.
+	Params map[string]string `json:"params,omitempty" yaml:"params,omitempty" toml:"params,omitempty"`
+	// Pooler names the server side connection pooler that sits in front of
+	// the database, i.e. [PgBouncer]. Poolers reject startup parameters
+	// that set session settings, so they are applied with set_config on
+	// each new connection instead, see [Config.PoolerSettings]. In
+	// transaction pooling mode these stick to the server connection, so
+	// every client of the pooler should use the same settings or they
+	// should be set with ALTER ROLE ... SET. Statements are not prepared by
+	// name.
+	Pooler string `json:"pooler,omitempty" yaml:"pooler,omitempty" toml:"pooler,omitempty"`
+	// TLS is used for encrypted connections instead of a config built from
+	// the ssl options. Mysql needs a registration func, see
+	// [RegisterTLSFunc].
+	TLS *tls.Config `json:"-" yaml:"-" toml:"-"`
+	// UTC makes the mysql driver parse DATE and DATETIME values into
+	// [time.Time] in the UTC location. [Config.Validate] rejects it
+	// together with a Location other than UTC.
+	UTC bool `json:"utc,omitempty" yaml:"utc,omitempty" toml:"utc,omitempty"`
+	// Options of the mysql driver. Charset is the connection's character
+	// set and Collation its collation, i.e. "utf8mb4_unicode_ci". ParseTime
+	// scans DATE and DATETIME values into [time.Time] in the Location time
+	// zone, which defaults to UTC. MultiStatements allows several
+	// statements in one query and InterpolateParams replaces placeholders
+	// on the client instead of preparing statements.
+	Charset           string `json:"charset,omitempty" yaml:"charset,omitempty" toml:"charset,omitempty"`
+	Collation         string `json:"collation,omitempty" yaml:"collation,omitempty" toml:"collation,omitempty"`
+	ParseTime         bool   `json:"parse_time,omitempty" yaml:"parse_time,omitempty" toml:"parse_time,omitempty"`
+	Location          string `json:"loc,omitempty" yaml:"loc,omitempty" toml:"loc,omitempty"`
+	MultiStatements   bool   `json:"multi_statements,omitempty" yaml:"multi_statements,omitempty" toml:"multi_statements,omitempty"`
+	InterpolateParams bool   `json:"interpolate_params,omitempty" yaml:"interpolate_params,omitempty" toml:"interpolate_params,omitempty"`
+	// Dialer opens the network connections to the database, or to the ssh
+	// host if SSHHost is set.
+	Dialer DialFunc `json:"-" yaml:"-" toml:"-"`
+	// SSHHost is an optional "host[:port]" of an ssh server that
+	// connections are tunneled through. The key defaults to
+	// ~/.ssh/id_ed25519 and the server's key is checked against
+	// ~/.ssh/known_hosts. Tunnels need github.com/harrybrwn/db/sshtunnel
+	// to be imported.
+	SSHHost       string `json:"ssh_host,omitempty" yaml:"ssh_host,omitempty" toml:"ssh_host,omitempty"`
+	SSHUser       string `json:"ssh_user,omitempty" yaml:"ssh_user,omitempty" toml:"ssh_user,omitempty"`
+	SSHKeyFile    string `json:"ssh_key_file,omitempty" yaml:"ssh_key_file,omitempty" toml:"ssh_key_file,omitempty"`
+	SSHKnownHosts string `json:"ssh_known_hosts,omitempty" yaml:"ssh_known_hosts,omitempty" toml:"ssh_known_hosts,omitempty"`
+	// EnvPrefix replaces the prefix of the environment variables read by
+	// [Config.Init] and [Config.EnvOverride], which is the upper case Type
+	// by default. With "BILLING_DB" the host is read from BILLING_DB_HOST
+	// and the type from BILLING_DB_TYPE so that two databases of the same
+	// type can be configured in one process.
+	EnvPrefix string `json:"env_prefix,omitempty" yaml:"env_prefix,omitempty" toml:"env_prefix,omitempty"`
 }
 
-func (db *Config) Init() {
+func (db *Config) Init() { db.init("") }
+
+// InitWithPrefix is the same as [Config.Init] but the environment variables
+// are prefixed, i.e. "BILLING_" reads BILLING_DATABASE_TYPE and
+// BILLING_POSTGRES_HOST. It is ignored when EnvPrefix is set.
+func (db *Config) InitWithPrefix(prefix string) { db.init(prefix) }
+
+// InitStrict is the same as [Config.Init] but returns an error for
+// environment variables that are set to invalid values, like a connect
+// timeout that is not a number or an unknown database type, instead of
+// ignoring them. Every invalid variable is reported and the config is
+// filled in either way.
+func (db *Config) InitStrict() error { return db.init("") }
+
+// init fills in empty fields from environment variables. Keys are prefixed
+// with namePre followed by the upper case [Type]. It returns the errors for
+// variables with invalid values.
+func (db *Config) init(namePre string) error {
+	var errs []error
 	if len(db.Type) == 0 {
-		db.Type = Type(getEnv("DATABASE_TYPE", string(PostgresDBType)))
-	}
-	var defPort string
-	switch db.Type {
-	case PostgresDBType:
-		defPort = "5432"
-	case MySQLDBType:
-		defPort = "3306"
+		key := db.typeEnvKey(namePre)
+		db.Type = Type(getEnv(key, string(PostgresDBType)))
+		if !knownType(db.Type) {
+			errs = append(errs, errors.Errorf("invalid %s: unknown database type %q", key, db.Type))
+		}
 	}
-	keyPre := strings.ToUpper(string(db.Type)) + "_"
+	defPort := db.Type.defaultPort()
+	keyPre := db.envKeyPrefix(namePre)
 	if len(db.Host) == 0 {
 		db.Host = getEnv(keyPre+"HOST", "localhost")
 	}
 	if len(db.Port) == 0 {
 		db.Port = getEnv(keyPre+"PORT", defPort)
+		if len(db.Port) > 0 && !validPort(db.Port) {
+			errs = append(errs, errors.Errorf("invalid %sPORT: %q is not a port number", keyPre, db.Port))
+		}
 	}
 	if len(db.User) == 0 {
 		db.User = getEnv(keyPre + "USER")
@@ -62,39 +203,166 @@ func (db *Config) Init() {
 	if len(db.DBName) == 0 {
 		db.DBName = getEnv(keyPre + "DB")
 	}
+	if len(db.Schema) == 0 {
+		db.Schema = getEnv(keyPre + "SCHEMA")
+	}
 	if len(db.SSLMode) == 0 {
 		db.SSLMode = getEnv(keyPre + "SSLMODE")
 	}
 	if db.ConnectTimeout == 0 {
-		db.ConnectTimeout, _ = getEnvUint(keyPre + "CONNECT_TIMEOUT")
+		var err error
+		db.ConnectTimeout, err = getEnvUint(keyPre + "CONNECT_TIMEOUT")
+		if err != nil && !errors.Is(err, errEnvNotFound) {
+			errs = append(errs, errors.Wrapf(err, "invalid %sCONNECT_TIMEOUT", keyPre))
+		}
+	}
+	for _, f := range db.timeoutFields() {
+		if *f.d == 0 {
+			var err error
+			*f.d, err = getEnvDuration(keyPre + f.key)
+			if err != nil && !errors.Is(err, errEnvNotFound) {
+				errs = append(errs, errors.Wrapf(err, "invalid %s%s", keyPre, f.key))
+			}
+		}
+	}
+	if len(db.SSLCAPEM) == 0 {
+		db.SSLCAPEM = getEnv(keyPre + "SSLCA_PEM")
 	}
+	if len(db.SSLCertPEM) == 0 {
+		db.SSLCertPEM = getEnv(keyPre + "SSL_CERT_PEM")
+	}
+	if len(db.SSLKeyPEM) == 0 {
+		db.SSLKeyPEM = getEnv(keyPre + "SSL_KEY_PEM")
+	}
+	if len(db.TargetSessionAttrs) == 0 {
+		db.TargetSessionAttrs = getEnv(keyPre + "TARGET_SESSION_ATTRS")
+	}
+	if len(db.AppName) == 0 {
+		db.AppName = getEnv(keyPre + "APP_NAME")
+	}
+	if len(db.Pooler) == 0 {
+		db.Pooler = getEnv(keyPre + "POOLER")
+	}
+	if len(db.Charset) == 0 {
+		db.Charset = getEnv(keyPre + "CHARSET")
+	}
+	if len(db.Collation) == 0 {
+		db.Collation = getEnv(keyPre + "COLLATION")
+	}
+	if len(db.Location) == 0 {
+		db.Location = getEnv(keyPre + "LOC")
+	}
+	for _, f := range db.boolFields() {
+		if !*f.b {
+			var err error
+			*f.b, err = getEnvBool(keyPre + f.key)
+			if err != nil && !errors.Is(err, errEnvNotFound) {
+				errs = append(errs, errors.Wrapf(err, "invalid %s%s", keyPre, f.key))
+			}
+		}
+	}
+	if db.Params == nil {
+		var err error
+		if db.Params, err = getEnvParams(keyPre + "OPTIONS"); err != nil {
+			errs = append(errs, errors.Wrapf(err, "invalid %sOPTIONS", keyPre))
+		}
+	}
+	if len(db.SSHHost) == 0 {
+		db.SSHHost = getEnv(keyPre + "SSH_HOST")
+	}
+	if len(db.SSHUser) == 0 {
+		db.SSHUser = getEnv(keyPre + "SSH_USER")
+	}
+	if len(db.SSHKeyFile) == 0 {
+		db.SSHKeyFile = getEnv(keyPre + "SSH_KEY_FILE")
+	}
+	if len(db.SSHKnownHosts) == 0 {
+		db.SSHKnownHosts = getEnv(keyPre + "SSH_KNOWN_HOSTS")
+	}
+	return stderrors.Join(errs...)
 }
 
-func (db *Config) EnvOverride() {
-	keyPre := strings.ToUpper(string(db.Type)) + "_"
-	var defPort string
-	switch db.Type {
-	case PostgresDBType:
-		defPort = "5432"
-	case MySQLDBType:
-		defPort = "3306"
-	}
+func (db *Config) EnvOverride() { db.envOverride("") }
+
+func (db *Config) envOverride(namePre string) {
+	keyPre := db.envKeyPrefix(namePre)
+	defPort := db.Type.defaultPort()
 	db.Host = getEnv(keyPre+"HOST", db.Host, "localhost")
 	db.Port = getEnv(keyPre+"PORT", db.Port, defPort)
 	db.User = getEnv(keyPre+"USER", db.User)
 	db.Password = getEnv(keyPre+"PASSWORD", db.Password)
 	db.DBName = getEnv(keyPre+"DB", db.DBName)
+	db.Schema = getEnv(keyPre+"SCHEMA", db.Schema)
 	db.SSLMode = getEnv(keyPre+"SSLMODE", db.SSLMode)
 	db.ConnectTimeout, _ = getEnvUint(keyPre+"CONNECT_TIMEOUT", db.ConnectTimeout)
+	for _, f := range db.timeoutFields() {
+		*f.d, _ = getEnvDuration(keyPre+f.key, *f.d)
+	}
 	db.SSLCA = getEnv(keyPre+"SSLCA", db.SSLCA)
 	db.SSLKey = getEnv(keyPre+"SSL_KEY", db.SSLKey)
 	db.SSLCert = getEnv(keyPre+"SSL_CERT", db.SSLCert)
 	db.SSLSNI = getEnv(keyPre+"SSL_SNI", db.SSLSNI)
+	db.SSLCAPEM = getEnv(keyPre+"SSLCA_PEM", db.SSLCAPEM)
+	db.SSLCertPEM = getEnv(keyPre+"SSL_CERT_PEM", db.SSLCertPEM)
+	db.SSLKeyPEM = getEnv(keyPre+"SSL_KEY_PEM", db.SSLKeyPEM)
+	db.TargetSessionAttrs = getEnv(keyPre+"TARGET_SESSION_ATTRS", db.TargetSessionAttrs)
+	db.AppName = getEnv(keyPre+"APP_NAME", db.AppName)
+	db.Pooler = getEnv(keyPre+"POOLER", db.Pooler)
+	db.Charset = getEnv(keyPre+"CHARSET", db.Charset)
+	db.Collation = getEnv(keyPre+"COLLATION", db.Collation)
+	db.Location = getEnv(keyPre+"LOC", db.Location)
+	for _, f := range db.boolFields() {
+		if b, err := getEnvBool(keyPre + f.key); err == nil {
+			*f.b = b
+		}
+	}
+	params, _ := getEnvParams(keyPre + "OPTIONS")
+	for k, v := range params {
+		if db.Params == nil {
+			db.Params = make(map[string]string)
+		}
+		db.Params[k] = v
+	}
+	db.SSHHost = getEnv(keyPre+"SSH_HOST", db.SSHHost)
+	db.SSHUser = getEnv(keyPre+"SSH_USER", db.SSHUser)
+	db.SSHKeyFile = getEnv(keyPre+"SSH_KEY_FILE", db.SSHKeyFile)
+	db.SSHKnownHosts = getEnv(keyPre+"SSH_KNOWN_HOSTS", db.SSHKnownHosts)
+}
+
+// typeEnvKey returns the environment variable that holds the [Type].
+func (db *Config) typeEnvKey(namePre string) string {
+	if len(db.EnvPrefix) > 0 {
+		return db.envKeyPrefix("") + "TYPE"
+	}
+	return namePre + "DATABASE_TYPE"
+}
+
+// envKeyPrefix returns the prefix of the config's environment variables,
+// either EnvPrefix or namePre followed by the upper case [Type].
+func (db *Config) envKeyPrefix(namePre string) string {
+	if len(db.EnvPrefix) > 0 {
+		return strings.TrimSuffix(db.EnvPrefix, "_") + "_"
+	}
+	return namePre + db.Type.envPrefix()
+}
+
+// isSocket is true when the host is a unix socket path.
+func (db *Config) isSocket() bool { return strings.HasPrefix(db.Host, "/") }
+
+// netAddr returns the network and address of the database server.
+func (db *Config) netAddr() (network, addr string) {
+	if db.isSocket() {
+		return "unix", db.Host
+	}
+	if hosts := db.hosts(); len(hosts) > 0 {
+		return "tcp", hosts[0]
+	}
+	return "tcp", net.JoinHostPort(db.Host, db.Port)
 }
 
 func (db *Config) URI() *url.URL {
 	u := url.URL{
-		Scheme: string(db.Type),
+		Scheme: db.Type.DriverName(),
 		Host:   net.JoinHostPort(db.Host, db.Port),
 		Path:   filepath.Join("/", db.DBName),
 	}
@@ -102,27 +370,80 @@ func (db *Config) URI() *url.URL {
 		u.User = url.UserPassword(db.User, db.Password)
 	}
 	q := make(url.Values)
-	switch db.Type {
-	case PostgresDBType:
+	if db.isSocket() {
+		u.Host = ""
+	}
+	hosts := db.hosts()
+	if len(hosts) > 0 {
+		u.Host = strings.Join(hosts, ",")
+	}
+	switch {
+	case db.Type.postgresWire():
+		if db.isSocket() {
+			// The port is part of the socket's file name.
+			q.Set("host", db.Host)
+			if len(db.Port) > 0 {
+				q.Set("port", db.Port)
+			}
+		}
 		if db.ConnectTimeout > 0 {
 			q.Set("connect_timeout", strconv.FormatUint(db.ConnectTimeout, 10))
 		}
 		if len(db.SSLMode) > 0 {
 			q.Set("sslmode", db.SSLMode)
 		}
-		if len(db.SSLCA) > 0 {
-			q.Set("sslrootcert", db.SSLCA)
-		}
-		if len(db.SSLCert) > 0 {
-			q.Set("sslcert", db.SSLCert)
-		}
-		if len(db.SSLKey) > 0 {
-			q.Set("sslkey", db.SSLKey)
+		if db.hasInlinePEM() {
+			// lib/pq treats the ssl options as file contents when sslinline
+			// is set.
+			q.Set("sslinline", "true")
+			if len(db.SSLCAPEM) > 0 {
+				q.Set("sslrootcert", db.SSLCAPEM)
+			}
+			if len(db.SSLCertPEM) > 0 {
+				q.Set("sslcert", db.SSLCertPEM)
+			}
+			if len(db.SSLKeyPEM) > 0 {
+				q.Set("sslkey", db.SSLKeyPEM)
+			}
+		} else {
+			if len(db.SSLCA) > 0 {
+				q.Set("sslrootcert", db.SSLCA)
+			}
+			if len(db.SSLCert) > 0 {
+				q.Set("sslcert", db.SSLCert)
+			}
+			if len(db.SSLKey) > 0 {
+				q.Set("sslkey", db.SSLKey)
+			}
 		}
 		if len(db.SSLSNI) > 0 {
 			q.Set("sslsni", db.SSLSNI)
 		}
-	case MySQLDBType:
+		if len(db.AppName) > 0 {
+			q.Set("application_name", db.AppName)
+		}
+		if len(db.Schema) > 0 {
+			q.Set("search_path", db.Schema)
+		}
+		if opts := db.pgTimeoutOptions(); len(opts) > 0 {
+			q.Set("options", opts)
+		}
+		if len(hosts) > 0 {
+			attrs := db.TargetSessionAttrs
+			if len(attrs) == 0 {
+				attrs = "read-write"
+			}
+			q.Set("target_session_attrs", attrs)
+		}
+		if db.behindPooler() {
+			// lib/pq sends statements with arguments in a single round
+			// trip using the unnamed statement.
+			q.Set("binary_parameters", "yes")
+		}
+	case db.Type == MySQLDBType:
+		if db.isSocket() {
+			q.Set("socket", db.Host)
+		}
 		if db.ConnectTimeout > 0 {
 			q.Set("connect-timeout", strconv.FormatUint(db.ConnectTimeout, 10))
 		}
@@ -138,6 +459,60 @@ func (db *Config) URI() *url.URL {
 		if len(db.SSLKey) > 0 {
 			q.Set("ssl-key", db.SSLKey)
 		}
+	case db.Type == SQLServerDBType:
+		// The path names an instance, not a database.
+		u.Path = ""
+		if len(db.DBName) > 0 {
+			q.Set("database", db.DBName)
+		}
+		if db.ConnectTimeout > 0 {
+			q.Set("connection timeout", strconv.FormatUint(db.ConnectTimeout, 10))
+		}
+		switch mode := strings.ToLower(db.SSLMode); mode {
+		case "":
+		case "disable", "disabled", "off", "false":
+			q.Set("encrypt", "disable")
+		case "require", "required", "prefer", "preferred":
+			q.Set("encrypt", "true")
+			q.Set("TrustServerCertificate", "true")
+		case "strict":
+			q.Set("encrypt", "strict")
+		default:
+			q.Set("encrypt", "true")
+		}
+		if len(db.SSLCA) > 0 {
+			q.Set("certificate", db.SSLCA)
+		}
+		if len(db.SSLSNI) > 0 {
+			q.Set("hostNameInCertificate", db.SSLSNI)
+		}
+		if len(db.AppName) > 0 {
+			q.Set("app name", db.AppName)
+		}
+	case db.Type == ClickHouseDBType:
+		if db.ConnectTimeout > 0 {
+			q.Set("dial_timeout", strconv.FormatUint(db.ConnectTimeout, 10)+"s")
+		}
+		switch mode := strings.ToLower(db.SSLMode); mode {
+		case "":
+		case "disable", "disabled", "off", "false":
+			q.Set("secure", "false")
+		case "require", "required", "prefer", "preferred":
+			q.Set("secure", "true")
+			q.Set("skip_verify", "true")
+		default:
+			q.Set("secure", "true")
+		}
+	}
+	for k, v := range db.Params {
+		if opts := q.Get(k); k == "options" && len(opts) > 0 {
+			// Later settings win so Params still take precedence.
+			v = opts + " " + v
+		}
+		q.Set(k, v)
+	}
+	if db.behindPooler() {
+		poolerParams(q)
 	}
 	if len(q) > 0 {
 		u.RawQuery = q.Encode()
@@ -145,6 +520,163 @@ func (db *Config) URI() *url.URL {
 	return &u
 }
 
+// DSN returns the data source name that should be given to the database
+// driver when opening a connection pool.
+func (db *Config) DSN() string {
+	switch db.Type {
+	case MySQLDBType:
+		return db.mysqlDSN()
+	case SQLiteDBType:
+		return db.DBName
+	default:
+		return db.URI().String()
+	}
+}
+
+// mysqlDSN builds a data source name in the format expected by
+// github.com/go-sql-driver/mysql. The driver only takes one host so only the
+// first is used, [Config.Open] fails over to the others.
+func (db *Config) mysqlDSN() string {
+	var b strings.Builder
+	if len(db.User) > 0 {
+		b.WriteString(db.User)
+		if len(db.Password) > 0 {
+			b.WriteByte(':')
+			b.WriteString(db.Password)
+		}
+		b.WriteByte('@')
+	}
+	network, addr := db.netAddr()
+	b.WriteString(network)
+	b.WriteByte('(')
+	b.WriteString(addr)
+	b.WriteString(")/")
+	b.WriteString(db.mysqlDBName())
+	q := make(url.Values)
+	if db.ConnectTimeout > 0 {
+		q.Set("timeout", strconv.FormatUint(db.ConnectTimeout, 10)+"s")
+	}
+	if name := db.tlsName(); db.mysqlCustomTLS() && tlsRegistered(name) {
+		q.Set("tls", name)
+	} else if tls := mysqlTLSParam(db.SSLMode); len(tls) > 0 {
+		q.Set("tls", tls)
+	}
+	if db.UTC {
+		q.Set("parseTime", "true")
+		q.Set("loc", "UTC")
+	}
+	if db.ParseTime {
+		q.Set("parseTime", "true")
+	}
+	if len(db.Location) > 0 {
+		q.Set("loc", db.Location)
+	}
+	if len(db.Charset) > 0 {
+		q.Set("charset", db.Charset)
+	}
+	if len(db.Collation) > 0 {
+		q.Set("collation", db.Collation)
+	}
+	if db.MultiStatements {
+		q.Set("multiStatements", "true")
+	}
+	if db.InterpolateParams {
+		q.Set("interpolateParams", "true")
+	}
+	if len(db.AppName) > 0 {
+		q.Set("connectionAttributes", "program_name:"+mysqlAttrReplacer.Replace(db.AppName))
+	}
+	db.mysqlTimeoutParams(q)
+	for k, v := range db.Params {
+		q.Set(k, v)
+	}
+	if len(q) > 0 {
+		b.WriteByte('?')
+		b.WriteString(q.Encode())
+	}
+	return b.String()
+}
+
+// mysqlDBName is the database selected by a mysql connection.
+func (db *Config) mysqlDBName() string {
+	if len(db.DBName) == 0 {
+		return db.Schema
+	}
+	return db.DBName
+}
+
+// mysqlTLSParam converts an sslmode value into the value of the mysql driver's
+// "tls" parameter.
+func mysqlTLSParam(mode string) string {
+	switch strings.ToLower(mode) {
+	case "":
+		return ""
+	case "disable", "disabled", "off", "false":
+		return "false"
+	case "require", "required":
+		return "skip-verify"
+	case "prefer", "preferred":
+		return "preferred"
+	default:
+		return "true"
+	}
+}
+
+// Open checks that a driver is registered for the configured [Type] and then
+// opens a connection pool using [Config.DSN].
+func (db *Config) Open() (*sql.DB, error) {
+	db = db.withAppName()
+	if err := checkDriver(db.Type); err != nil {
+		return nil, err
+	}
+	if err := db.registerTLS(); err != nil {
+		return nil, err
+	}
+	setup := db.poolerSetup()
+	if !db.customDial() && setup == nil {
+		return sql.Open(db.Type.DriverName(), db.DSN())
+	}
+	c, err := db.connector()
+	if err != nil {
+		return nil, err
+	}
+	if setup != nil {
+		c = WrapConnector(c, setup)
+	}
+	return sql.OpenDB(c), nil
+}
+
+// mysqlAttrReplacer removes the separators of the mysql driver's
+// connectionAttributes list, which has no escaping, from a value.
+var mysqlAttrReplacer = strings.NewReplacer(",", "_", ":", "_")
+
+// withAppName returns the config with AppName set to the name of the binary
+// if it is empty.
+func (db *Config) withAppName() *Config {
+	if len(db.AppName) > 0 || db.Type == SQLiteDBType {
+		return db
+	}
+	c := *db
+	c.AppName = filepath.Base(os.Args[0])
+	return &c
+}
+
+// getEnvParams parses an environment variable holding url query encoded
+// parameters like "application_name=api&search_path=app".
+// The parameters that could be parsed are returned along with any error.
+func getEnvParams(key string) (map[string]string, error) {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return nil, nil
+	}
+	q, err := url.ParseQuery(v)
+	params := make(map[string]string, len(q))
+	for k := range q {
+		params[k] = q.Get(k)
+	}
+	return params, err
+}
+
 var errEnvNotFound = errors.New("environment variable not found")
 
 func getEnv(key string, defaults ...string) string {
@@ -160,6 +692,58 @@ func getEnv(key string, defaults ...string) string {
 	return v
 }
 
+// timeoutFields maps the suffixes of the session timeout environment
+// variables to the fields they set.
+func (db *Config) timeoutFields() []envDuration {
+	return []envDuration{
+		{"STATEMENT_TIMEOUT", &db.StatementTimeout},
+		{"LOCK_TIMEOUT", &db.LockTimeout},
+		{"IDLE_IN_TX_TIMEOUT", &db.IdleInTxTimeout},
+	}
+}
+
+// boolFields maps the suffixes of the boolean environment variables to the
+// fields they set.
+func (db *Config) boolFields() []envBool {
+	return []envBool{
+		{"PARSE_TIME", &db.ParseTime},
+		{"MULTI_STATEMENTS", &db.MultiStatements},
+		{"INTERPOLATE_PARAMS", &db.InterpolateParams},
+	}
+}
+
+type envBool struct {
+	key string
+	b   *bool
+}
+
+type envDuration struct {
+	key string
+	d   *time.Duration
+}
+
+// getEnvDuration reads a duration parsed by [parseTimeout].
+func getEnvDuration(key string, defaults ...time.Duration) (time.Duration, error) {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		for _, val := range defaults {
+			if val > 0 {
+				return val, nil
+			}
+		}
+		return 0, errEnvNotFound
+	}
+	return parseTimeout(v)
+}
+
+func getEnvBool(key string) (bool, error) {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return false, errEnvNotFound
+	}
+	return strconv.ParseBool(v)
+}
+
 func getEnvUint(key string, defaults ...uint64) (uint64, error) {
 	v, ok := os.LookupEnv(key)
 	if !ok {
@@ -0,0 +1,126 @@
+package db
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// ConfigDecoder parses the contents of a config file into v, which is a
+// pointer to a map[string]any.
+type ConfigDecoder func(data []byte, v any) error
+
+var (
+	configDecodersMu sync.RWMutex
+	configDecoders   = map[string]ConfigDecoder{".json": json.Unmarshal}
+)
+
+// configDecoderImports maps file extensions to the package that registers
+// their [ConfigDecoder].
+var configDecoderImports = map[string]string{
+	".yaml": "github.com/harrybrwn/db/yamlconfig",
+	".yml":  "github.com/harrybrwn/db/yamlconfig",
+	".toml": "github.com/harrybrwn/db/tomlconfig",
+}
+
+// RegisterConfigDecoder sets the decoder [LoadConfig] uses for files with the
+// extension, i.e. ".yaml". JSON is always supported. YAML and TOML are
+// supported by importing github.com/harrybrwn/db/yamlconfig and
+// github.com/harrybrwn/db/tomlconfig so that programs only link the parsers
+// they use.
+func RegisterConfigDecoder(ext string, fn ConfigDecoder) {
+	configDecodersMu.Lock()
+	defer configDecodersMu.Unlock()
+	configDecoders[strings.ToLower(ext)] = fn
+}
+
+// LoadConfig reads a [Config] from a file in a format chosen by the file's
+// extension, see [RegisterConfigDecoder]. Keys are the lower case names in the Config's struct
+// tags, i.e. "host", "dbname" and "sslmode", and the port may be a number
+// or a string. Timeouts are durations like "30s" or a number of
+// milliseconds. Environment variables take precedence over the file the same
+// way as [Config.EnvOverride].
+func LoadConfig(path string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	ext := strings.ToLower(filepath.Ext(path))
+	configDecodersMu.RLock()
+	decode, ok := configDecoders[ext]
+	configDecodersMu.RUnlock()
+	if !ok {
+		if pkg, ok := configDecoderImports[ext]; ok {
+			return nil, errors.Errorf("no decoder for %q config files, add `import _ %q`", ext, pkg)
+		}
+		return nil, errors.Errorf("unknown config file format %q", ext)
+	}
+	var raw map[string]any
+	if err = decode(b, &raw); err != nil {
+		return nil, errors.Wrapf(err, "could not parse %s", path)
+	}
+	cfg, err := configFromMap(raw)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid config in %s", path)
+	}
+	if len(cfg.Type) == 0 {
+		cfg.Type = Type(getEnv(cfg.typeEnvKey(""), string(PostgresDBType)))
+	}
+	cfg.EnvOverride()
+	return cfg, nil
+}
+
+// configFromMap decodes a parsed config file. Scalars where strings are
+// expected, like a numeric port, are converted to strings and unknown keys
+// are rejected.
+func configFromMap(raw map[string]any) (*Config, error) {
+	for k, v := range raw {
+		switch strings.ToLower(k) {
+		case "port":
+			raw[k] = scalarString(v)
+		case "statement_timeout", "lock_timeout", "idle_in_tx_timeout":
+			d, err := parseTimeout(fmt.Sprint(v))
+			if err != nil {
+				return nil, errors.Wrap(err, k)
+			}
+			raw[k] = int64(d)
+		case "hosts":
+			if list, ok := v.([]any); ok {
+				for i := range list {
+					list[i] = scalarString(list[i])
+				}
+			}
+		case "params":
+			if m, ok := v.(map[string]any); ok {
+				for pk, pv := range m {
+					m[pk] = scalarString(pv)
+				}
+			}
+		}
+	}
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	var cfg Config
+	if err = dec.Decode(&cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func scalarString(v any) any {
+	switch v.(type) {
+	case nil, string, []any, map[string]any:
+		return v
+	}
+	return fmt.Sprint(v)
+}
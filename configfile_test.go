@@ -0,0 +1,63 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestLoadConfig(t *testing.T) {
+	is := is.New(t)
+	clearEnv()
+	dir := t.TempDir()
+	exp := Config{
+		Type:           PostgresDBType,
+		Host:           "db.internal",
+		Port:           "6432",
+		User:           "app",
+		DBName:         "billing",
+		SSLMode:        "verify-full",
+		ConnectTimeout: 5,
+		Params:         map[string]string{"search_path": "app", "statement_cache_capacity": "0"},
+	}
+	for name, content := range map[string]string{
+		"db.json": `{"type": "postgres", "host": "db.internal", "port": 6432, "user": "app", "dbname": "billing",
+			"sslmode": "verify-full", "connect_timeout": 5, "params": {"search_path": "app", "statement_cache_capacity": 0}}`,
+	} {
+		path := filepath.Join(dir, name)
+		is.NoErr(os.WriteFile(path, []byte(content), 0o600))
+		cfg, err := LoadConfig(path)
+		is.NoErr(err)
+		is.Equal(*cfg, exp)
+	}
+
+	// The environment takes precedence over the file.
+	t.Setenv("POSTGRES_HOST", "from-env")
+	cfg, err := LoadConfig(filepath.Join(dir, "db.json"))
+	is.NoErr(err)
+	is.Equal(cfg.Host, "from-env")
+	is.Equal(cfg.DBName, "billing")
+
+	for name, content := range map[string]string{
+		"bad.json":  `{"hostname": "x"}`,
+		"port.json": `{"port": [1]}`,
+		"db.ini":    "host=x",
+	} {
+		path := filepath.Join(dir, name)
+		is.NoErr(os.WriteFile(path, []byte(content), 0o600))
+		_, err = LoadConfig(path)
+		is.True(err != nil)
+	}
+	_, err = LoadConfig(filepath.Join(dir, "missing.json"))
+	is.True(err != nil)
+
+	// YAML and TOML need their packages to be imported.
+	path := filepath.Join(dir, "db.yaml")
+	is.NoErr(os.WriteFile(path, []byte("host: x\n"), 0o600))
+	_, err = LoadConfig(path)
+	is.True(err != nil)
+	is.True(strings.Contains(err.Error(), `import _ "github.com/harrybrwn/db/yamlconfig"`))
+}
@@ -0,0 +1,243 @@
+package db
+
+import (
+	"context"
+	stderrors "errors"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"os/signal"
+	"reflect"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ConfigChange is passed to the callbacks of a [Watcher] when the config
+// was reloaded and is different from the running config.
+type ConfigChange struct {
+	Old, New *Config
+	// Fields are the names of the [Config] fields that changed, i.e.
+	// "Password" or "SSLCert".
+	Fields []string
+}
+
+// Changed is true if any of the named fields changed.
+func (c *ConfigChange) Changed(fields ...string) bool {
+	for _, f := range fields {
+		if slices.Contains(c.Fields, f) {
+			return true
+		}
+	}
+	return false
+}
+
+// WatcherOpt is an option for [NewWatcher].
+type WatcherOpt func(*Watcher)
+
+// WithWatchFile reloads the config from a file with [LoadConfig] instead of
+// from the environment. The file is also reloaded when it changes.
+func WithWatchFile(path string) WatcherOpt { return func(w *Watcher) { w.path = path } }
+
+// WithWatchInterval sets how often the watched file is checked for changes.
+// Zero only reloads on signals.
+func WithWatchInterval(d time.Duration) WatcherOpt { return func(w *Watcher) { w.interval = d } }
+
+// WithWatchSignals sets the signals that trigger a reload. Defaults to
+// SIGHUP.
+func WithWatchSignals(sigs ...os.Signal) WatcherOpt { return func(w *Watcher) { w.signals = sigs } }
+
+// WithWatcherLogger sets the logger used by the [Watcher].
+func WithWatcherLogger(l *slog.Logger) WatcherOpt { return func(w *Watcher) { w.logger = l } }
+
+// Watcher reloads a [Config] without restarting the process. The config is
+// re-read on SIGHUP, or when the file changes if it came from a file, and
+// compared to the running config. If anything changed the registered
+// callbacks are called so they can swap credentials, rebuild the TLS config
+// or resize the pool.
+type Watcher struct {
+	path     string
+	interval time.Duration
+	signals  []os.Signal
+	logger   *slog.Logger
+
+	// base is the config given to NewWatcher. Reloads from the environment
+	// start from it so that fields set in code are kept.
+	base *Config
+
+	mu        sync.Mutex
+	cfg       *Config
+	callbacks []func(context.Context, ConfigChange) error
+	modTime   time.Time
+	size      int64
+}
+
+// NewWatcher creates a [Watcher] for the running config. Changes are read
+// from the environment unless [WithWatchFile] is used.
+func NewWatcher(cfg *Config, opts ...WatcherOpt) *Watcher {
+	w := &Watcher{
+		interval: 5 * time.Second,
+		signals:  []os.Signal{syscall.SIGHUP},
+		base:     cloneConfig(cfg),
+		cfg:      cloneConfig(cfg),
+	}
+	for _, o := range opts {
+		o(w)
+	}
+	if w.logger == nil {
+		w.logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	if len(w.path) > 0 {
+		w.modTime, w.size = fileVersion(w.path)
+	}
+	return w
+}
+
+// Config returns a copy of the running config.
+func (w *Watcher) Config() *Config {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return cloneConfig(w.cfg)
+}
+
+// OnChange registers a callback that is called with the changes after each
+// reload. Callbacks are called in the order they were registered.
+func (w *Watcher) OnChange(fn func(ctx context.Context, c ConfigChange) error) {
+	w.mu.Lock()
+	w.callbacks = append(w.callbacks, fn)
+	w.mu.Unlock()
+}
+
+// Reload re-reads the config and calls the callbacks if it changed. A config
+// that fails [Config.Validate] is not applied. The new config is kept even
+// if callbacks fail and their errors are returned.
+func (w *Watcher) Reload(ctx context.Context) error {
+	next, err := w.load()
+	if err != nil {
+		return err
+	}
+	if err = next.Validate(); err != nil {
+		return errors.Wrap(err, "reloaded config is invalid")
+	}
+	w.mu.Lock()
+	fields := diffConfig(w.cfg, next)
+	if len(fields) == 0 {
+		w.mu.Unlock()
+		return nil
+	}
+	change := ConfigChange{Old: w.cfg, New: cloneConfig(next), Fields: fields}
+	w.cfg = next
+	// The callbacks run without the lock so they can use the watcher.
+	callbacks := slices.Clone(w.callbacks)
+	w.mu.Unlock()
+
+	w.logger.Info("database config changed", "fields", fields)
+	var errs []error
+	for _, fn := range callbacks {
+		if err = fn(ctx, change); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return stderrors.Join(errs...)
+}
+
+// Run reloads the config on each signal and file change until the context
+// is canceled. Reload errors are logged.
+func (w *Watcher) Run(ctx context.Context) error {
+	sigs := make(chan os.Signal, 1)
+	if len(w.signals) > 0 {
+		signal.Notify(sigs, w.signals...)
+		defer signal.Stop(sigs)
+	}
+	var tick <-chan time.Time
+	if len(w.path) > 0 && w.interval > 0 {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case sig := <-sigs:
+			w.logger.Info("reloading database config", "signal", sig)
+		case <-tick:
+			mod, size := fileVersion(w.path)
+			w.mu.Lock()
+			changed := !mod.Equal(w.modTime) || size != w.size
+			w.modTime, w.size = mod, size
+			w.mu.Unlock()
+			if !changed {
+				continue
+			}
+			w.logger.Info("reloading database config", "file", w.path)
+		}
+		if err := w.Reload(ctx); err != nil {
+			w.logger.Error("failed to reload database config", "error", err)
+		}
+	}
+}
+
+func (w *Watcher) load() (*Config, error) {
+	if len(w.path) == 0 {
+		cfg := cloneConfig(w.base)
+		cfg.EnvOverride()
+		return cfg, nil
+	}
+	cfg, err := LoadConfig(w.path)
+	if err != nil {
+		return nil, err
+	}
+	// These can't be set in a file.
+	cfg.TLS, cfg.Dialer = w.base.TLS, w.base.Dialer
+	return cfg, nil
+}
+
+func fileVersion(path string) (time.Time, int64) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, -1
+	}
+	return info.ModTime(), info.Size()
+}
+
+// cloneConfig copies a config so that changes to its slices and maps are
+// not shared.
+func cloneConfig(cfg *Config) *Config {
+	c := *cfg
+	c.Hosts = slices.Clone(cfg.Hosts)
+	c.Params = maps.Clone(cfg.Params)
+	return &c
+}
+
+// diffConfig returns the names of the exported fields that are different.
+func diffConfig(a, b *Config) []string {
+	var fields []string
+	va, vb := reflect.ValueOf(a).Elem(), reflect.ValueOf(b).Elem()
+	t := va.Type()
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		x, y := va.Field(i), vb.Field(i)
+		var same bool
+		switch {
+		case f.Type.Kind() == reflect.Func:
+			same = x.Pointer() == y.Pointer()
+		case f.Type.Kind() == reflect.Map && x.Len() == 0 && y.Len() == 0,
+			f.Type.Kind() == reflect.Slice && x.Len() == 0 && y.Len() == 0:
+			same = true
+		default:
+			same = reflect.DeepEqual(x.Interface(), y.Interface())
+		}
+		if !same {
+			fields = append(fields, f.Name)
+		}
+	}
+	return fields
+}
@@ -0,0 +1,109 @@
+package db
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestWatcher_File(t *testing.T) {
+	is := is.New(t)
+	clearEnv()
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "db.json")
+	write := func(content string) {
+		t.Helper()
+		is.NoErr(os.WriteFile(path, []byte(content), 0o600))
+	}
+	write(`{"host": "db1", "dbname": "app", "password": "one"}`)
+	cfg, err := LoadConfig(path)
+	is.NoErr(err)
+
+	changes := make(chan ConfigChange, 4)
+	w := NewWatcher(cfg, WithWatchFile(path), WithWatchInterval(10*time.Millisecond), WithWatchSignals())
+	w.OnChange(func(_ context.Context, c ConfigChange) error {
+		changes <- c
+		return nil
+	})
+
+	is.NoErr(w.Reload(ctx))
+	is.Equal(len(changes), 0) // nothing changed
+
+	write(`{"host": "db1", "dbname": "app", "password": "two"}`)
+	is.NoErr(w.Reload(ctx))
+	c := <-changes
+	is.Equal(c.Fields, []string{"Password"})
+	is.True(c.Changed("Host", "Password"))
+	is.True(!c.Changed("Host"))
+	is.Equal(c.Old.Password, "one")
+	is.Equal(w.Config().Password, "two")
+
+	// Invalid configs are not applied.
+	write(`{"host": "db1", "dbname": "app", "port": 99999}`)
+	is.True(w.Reload(ctx) != nil)
+	is.Equal(w.Config().Password, "two")
+	is.Equal(len(changes), 0)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	done := make(chan error)
+	go func() { done <- w.Run(ctx) }()
+	write(`{"host": "db2", "dbname": "app", "password": "two"}`)
+	select {
+	case c = <-changes:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for reload")
+	}
+	is.Equal(c.Fields, []string{"Host"})
+	is.Equal(c.New.Host, "db2")
+	cancel()
+	is.NoErr(<-done)
+}
+
+func TestWatcher_Env(t *testing.T) {
+	is := is.New(t)
+	clearEnv()
+	cfg := &Config{Type: PostgresDBType, Host: "localhost", Port: "5432", DBName: "app", User: "app", Password: "one"}
+	w := NewWatcher(cfg)
+	var calls int
+	w.OnChange(func(_ context.Context, c ConfigChange) error {
+		calls++
+		is.Equal(c.Fields, []string{"User", "Password"})
+		return nil
+	})
+	is.NoErr(w.Reload(context.Background()))
+	is.Equal(calls, 0)
+
+	t.Setenv("POSTGRES_USER", "rotated")
+	t.Setenv("POSTGRES_PASSWORD", "two")
+	is.NoErr(w.Reload(context.Background()))
+	is.Equal(calls, 1)
+	is.Equal(w.Config().User, "rotated")
+	is.Equal(cfg.User, "app") // the original config is not modified
+}
+
+func TestWatcher_CallbackUsesWatcher(t *testing.T) {
+	is := is.New(t)
+	clearEnv()
+	w := NewWatcher(&Config{Type: PostgresDBType, Host: "localhost", Port: "5432", DBName: "app", Password: "one"})
+	var (
+		seen  string
+		calls int
+	)
+	w.OnChange(func(_ context.Context, c ConfigChange) error {
+		seen = w.Config().Password
+		w.OnChange(func(context.Context, ConfigChange) error { calls++; return nil })
+		return nil
+	})
+	t.Setenv("POSTGRES_PASSWORD", "two")
+	is.NoErr(w.Reload(context.Background()))
+	is.Equal(seen, "two")
+	is.Equal(calls, 0) // registered during the reload
+	t.Setenv("POSTGRES_PASSWORD", "three")
+	is.NoErr(w.Reload(context.Background()))
+	is.Equal(calls, 1)
+}
@@ -0,0 +1,103 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultConnectTimeout bounds the first ping of [Connect] when the context
+// has no deadline and the config has no ConnectTimeout.
+const DefaultConnectTimeout = 10 * time.Second
+
+type poolSettings struct {
+	maxOpen, maxIdle *int
+	maxLifetime      *time.Duration
+	maxIdleTime      *time.Duration
+}
+
+func (p *poolSettings) apply(pool *sql.DB) {
+	if p.maxOpen != nil {
+		pool.SetMaxOpenConns(*p.maxOpen)
+	}
+	if p.maxIdle != nil {
+		pool.SetMaxIdleConns(*p.maxIdle)
+	}
+	if p.maxLifetime != nil {
+		pool.SetConnMaxLifetime(*p.maxLifetime)
+	}
+	if p.maxIdleTime != nil {
+		pool.SetConnMaxIdleTime(*p.maxIdleTime)
+	}
+}
+
+// WithMaxOpenConns sets the maximum number of open connections of pools
+// opened by [Open] and [Connect], see [sql.DB.SetMaxOpenConns].
+func WithMaxOpenConns(n int) Option { return func(o *dbOptions) { o.pool.maxOpen = &n } }
+
+// WithMaxIdleConns sets the maximum number of idle connections of pools
+// opened by [Open] and [Connect], see [sql.DB.SetMaxIdleConns].
+func WithMaxIdleConns(n int) Option { return func(o *dbOptions) { o.pool.maxIdle = &n } }
+
+// WithConnMaxLifetime sets how long connections of pools opened by [Open]
+// and [Connect] are reused, see [sql.DB.SetConnMaxLifetime].
+func WithConnMaxLifetime(d time.Duration) Option {
+	return func(o *dbOptions) { o.pool.maxLifetime = &d }
+}
+
+// WithConnMaxIdleTime sets how long connections of pools opened by [Open]
+// and [Connect] may be idle, see [sql.DB.SetConnMaxIdleTime].
+func WithConnMaxIdleTime(d time.Duration) Option {
+	return func(o *dbOptions) { o.pool.maxIdleTime = &d }
+}
+
+// Connect opens a connection pool like [Open] and pings the database so that
+// a wrong address or password fails at startup instead of on the first
+// query. The ping is bounded by the context's deadline, the config's
+// ConnectTimeout or [DefaultConnectTimeout]. Errors name the database
+// without the password. See [ConnectLazy] to start without waiting for
+// the database.
+func Connect(ctx context.Context, cfg *Config, opts ...Option) (DB, error) {
+	d, err := Open(cfg, opts...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not open %s", cfg.target())
+	}
+	if err = ping(ctx, d, cfg); err != nil {
+		d.Close()
+		return nil, err
+	}
+	return d, nil
+}
+
+// ping pings the database once with the connect timeout.
+func ping(ctx context.Context, d Pingable, cfg *Config) error {
+	if _, ok := ctx.Deadline(); !ok {
+		timeout := DefaultConnectTimeout
+		if cfg.ConnectTimeout > 0 {
+			timeout = time.Duration(cfg.ConnectTimeout) * time.Second
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	if err := d.PingContext(ctx); err != nil {
+		return errors.Wrapf(err, "could not connect to %s", cfg.target())
+	}
+	return nil
+}
+
+// target describes the database for error messages, i.e.
+// "postgres://app@db:5432/billing". It never includes the password.
+func (db *Config) target() string {
+	if db.Type == SQLiteDBType {
+		return "sqlite3:" + db.DBName
+	}
+	_, addr := db.netAddr()
+	var user string
+	if len(db.User) > 0 {
+		user = db.User + "@"
+	}
+	return string(db.Type) + "://" + user + addr + "/" + db.DBName
+}
@@ -0,0 +1,36 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestConnect(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	cfg := &Config{Type: SQLiteDBType, DBName: ":memory:"}
+	d, err := Connect(ctx, cfg, WithMaxOpenConns(1), WithConnMaxIdleTime(time.Minute))
+	is.NoErr(err)
+	defer d.Close()
+	is.Equal(d.(*database).Stats().MaxOpenConnections, 1)
+	_, err = d.ExecContext(ctx, "SELECT 1")
+	is.NoErr(err)
+
+	// Nothing is listening on port 1.
+	cfg = &Config{Type: MySQLDBType, Host: "127.0.0.1", Port: "1", User: "app", Password: "secret", DBName: "billing"}
+	_, err = Connect(ctx, cfg)
+	is.True(err != nil)
+	is.True(strings.Contains(err.Error(), "could not connect to mysql://app@127.0.0.1:1/billing"))
+	is.True(!strings.Contains(err.Error(), "secret"))
+}
+
+func TestConfig_Target(t *testing.T) {
+	is := is.New(t)
+	is.Equal((&Config{Type: PostgresDBType, Host: "db", Port: "5432", DBName: "app"}).target(), "postgres://db:5432/app")
+	is.Equal((&Config{Type: PostgresDBType, Host: "/run/postgresql", User: "u", DBName: "app"}).target(), "postgres://u@/run/postgresql/app")
+	is.Equal((&Config{Type: SQLiteDBType, DBName: "data.db"}).target(), "sqlite3:data.db")
+}
@@ -0,0 +1,90 @@
+package db
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// Readiness reports whether a database opened by [ConnectLazy] has been
+// reached. It implements [expvar.Var] so it can be published as a gauge
+// that is 0 until the database is up and 1 after.
+type Readiness struct {
+	ready atomic.Bool
+	done  chan struct{}
+	once  sync.Once
+	err   error
+}
+
+func newReadiness() *Readiness { return &Readiness{done: make(chan struct{})} }
+
+// Ready is true once the database answered a ping.
+func (r *Readiness) Ready() bool { return r.ready.Load() }
+
+// Check returns nil once the database is ready and [ErrNotReady] before, so
+// it can back a readiness endpoint.
+func (r *Readiness) Check(context.Context) error {
+	if r.Ready() {
+		return nil
+	}
+	return ErrNotReady
+}
+
+// Wait blocks until the database is ready, the background wait gave up or
+// the context is canceled.
+func (r *Readiness) Wait(ctx context.Context) error {
+	select {
+	case <-r.done:
+		return r.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *Readiness) String() string {
+	if r.Ready() {
+		return "1"
+	}
+	return "0"
+}
+
+func (r *Readiness) finish(err error) {
+	r.once.Do(func() {
+		r.err = err
+		r.ready.Store(err == nil)
+		close(r.done)
+	})
+}
+
+// ConnectLazy opens a connection pool like [Connect] but does not wait for
+// the database. It is pinged in the background with [WaitFor] until it is
+// reachable, ctx is canceled or the database is closed, and the returned
+// [Readiness] flips once it is. Use it for services that should start and
+// report not ready instead of failing when the database is down. Only
+// configuration errors are returned.
+func ConnectLazy(ctx context.Context, cfg *Config, opts ...Option) (DB, *Readiness, error) {
+	d, err := Open(cfg, opts...)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "could not open %s", cfg.target())
+	}
+	r := newReadiness()
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-d.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	go func() {
+		defer cancel()
+		err := WaitFor(ctx, d, WithWaitLogger(d.logger))
+		if err != nil {
+			err = errors.Wrapf(err, "could not connect to %s", cfg.target())
+		}
+		r.finish(err)
+	}()
+	return d, r, nil
+}
@@ -0,0 +1,46 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestConnectLazy(t *testing.T) {
+	is := is.New(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	d, ready, err := ConnectLazy(ctx, &Config{Type: SQLiteDBType, DBName: ":memory:"})
+	is.NoErr(err)
+	defer d.Close()
+	is.NoErr(ready.Wait(ctx))
+	is.True(ready.Ready())
+	is.NoErr(ready.Check(ctx))
+	is.Equal(ready.String(), "1")
+
+	// The database is down so the wait only ends when the context does.
+	down, cancelDown := context.WithCancel(context.Background())
+	cfg := &Config{Type: MySQLDBType, Host: "127.0.0.1", Port: "1", DBName: "app"}
+	d, ready, err = ConnectLazy(down, cfg)
+	is.NoErr(err)
+	defer d.Close()
+	is.True(!ready.Ready())
+	is.True(errors.Is(ready.Check(ctx), ErrNotReady))
+	is.Equal(ready.String(), "0")
+	cancelDown()
+	is.True(errors.Is(ready.Wait(ctx), ErrDBTimeout))
+	is.True(!ready.Ready())
+
+	// Closing the database stops the wait.
+	d, ready, err = ConnectLazy(context.Background(), cfg)
+	is.NoErr(err)
+	is.NoErr(d.Close())
+	is.True(ready.Wait(ctx) != nil)
+	is.True(!ready.Ready())
+
+	_, _, err = ConnectLazy(ctx, &Config{Type: "nosuchdb"})
+	is.True(err != nil)
+}
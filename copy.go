@@ -0,0 +1,141 @@
+package db
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// RowSource is an iterator of rows for [CopyFrom].
+type RowSource interface {
+	// Next advances to the next row and returns false when there are no
+	// more rows.
+	Next() bool
+	// Values returns the values of the current row.
+	Values() ([]any, error)
+	// Err returns any error that stopped the iteration.
+	Err() error
+}
+
+// CopyFromRows returns a [RowSource] for rows that are in memory.
+func CopyFromRows(rows [][]any) RowSource { return &sliceSource{rows: rows, i: -1} }
+
+type sliceSource struct {
+	rows [][]any
+	i    int
+}
+
+func (s *sliceSource) Next() bool { s.i++; return s.i < len(s.rows) }
+
+func (s *sliceSource) Values() ([]any, error) { return s.rows[s.i], nil }
+
+func (s *sliceSource) Err() error { return nil }
+
+// copyBatchRows is the number of rows per INSERT when [CopyFrom] falls back
+// to [BulkInsert].
+const copyBatchRows = 1000
+
+// CopyFrom loads rows into a table using the postgres COPY protocol which
+// is much faster than INSERT statements. The driver must support "COPY ...
+// FROM STDIN" statements like lib/pq does. Other databases fall back to
+// [BulkInsert]. The load runs in one transaction and the number of rows
+// copied is returned.
+func CopyFrom(ctx context.Context, db DB, table string, cols []string, src RowSource) (int64, error) {
+	if len(cols) == 0 {
+		return 0, errors.New("copy needs at least one column")
+	}
+	var (
+		total int64
+		err   error
+	)
+	load := func(tx Tx) error {
+		if DialectOf(db).Type() == PostgresDBType {
+			total, err = copyIn(ctx, tx, table, cols, src)
+		} else {
+			total, err = copyInserts(ctx, tx, table, cols, src)
+		}
+		return err
+	}
+	if tx, ok := db.(Tx); ok {
+		err = load(tx)
+		return total, err
+	}
+	tx, err := Begin(ctx, nil, db)
+	if err != nil {
+		return 0, err
+	}
+	if err = TxDo(ctx, tx, load); err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+// copyInQuery builds the same statement as lib/pq's CopyIn.
+func copyInQuery(table string, cols []string) string {
+	d := DialectFor(PostgresDBType)
+	return "COPY " + d.QuoteIdent(table) + " (" + strings.Join(quoteAll(d, cols), ", ") + ") FROM STDIN"
+}
+
+func copyIn(ctx context.Context, tx Tx, table string, cols []string, src RowSource) (int64, error) {
+	p, ok := tx.(StmtPreparor)
+	if !ok {
+		return 0, errors.Errorf("cannot prepare a COPY statement with %T", tx)
+	}
+	stmt, err := p.PrepareContext(ctx, copyInQuery(table, cols))
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+	for src.Next() {
+		vals, err := src.Values()
+		if err != nil {
+			return 0, err
+		}
+		if len(vals) != len(cols) {
+			return 0, errors.Errorf("row has %d values, expected %d", len(vals), len(cols))
+		}
+		if _, err = stmt.ExecContext(ctx, vals...); err != nil {
+			return 0, err
+		}
+	}
+	if err = src.Err(); err != nil {
+		return 0, err
+	}
+	// An empty exec flushes the copy buffer.
+	res, err := stmt.ExecContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func copyInserts(ctx context.Context, tx Tx, table string, cols []string, src RowSource) (int64, error) {
+	var total int64
+	batch := make([][]any, 0, copyBatchRows)
+	flush := func() error {
+		n, err := BulkInsert(ctx, tx, table, cols, batch)
+		total += n
+		batch = batch[:0]
+		return err
+	}
+	for src.Next() {
+		vals, err := src.Values()
+		if err != nil {
+			return 0, err
+		}
+		batch = append(batch, vals)
+		if len(batch) == copyBatchRows {
+			if err = flush(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	if err := src.Err(); err != nil {
+		return 0, err
+	}
+	if err := flush(); err != nil {
+		return 0, err
+	}
+	return total, nil
+}
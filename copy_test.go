@@ -0,0 +1,56 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/pkg/errors"
+)
+
+type errSource struct{ n int }
+
+func (s *errSource) Next() bool             { s.n++; return s.n < 3 }
+func (s *errSource) Values() ([]any, error) { return []any{s.n}, nil }
+func (s *errSource) Err() error             { return errors.New("source failed") }
+
+func TestCopyFrom(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	pool, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer pool.Close()
+	pool.SetMaxOpenConns(1)
+	d := New(pool, WithDialect(DialectFor(SQLiteDBType)))
+	_, err = d.ExecContext(ctx, "CREATE TABLE points (x int, y int)")
+	is.NoErr(err)
+
+	rows := make([][]any, 2500)
+	for i := range rows {
+		rows[i] = []any{i, i * 2}
+	}
+	n, err := CopyFrom(ctx, d, "points", []string{"x", "y"}, CopyFromRows(rows))
+	is.NoErr(err)
+	is.Equal(n, int64(2500))
+
+	_, err = CopyFrom(ctx, d, "points", []string{"x"}, &errSource{})
+	is.True(err != nil)
+	var count int
+	is.NoErr(pool.QueryRow("SELECT count(*) FROM points").Scan(&count))
+	is.Equal(count, 2500)
+	_, err = CopyFrom(ctx, d, "points", nil, CopyFromRows(nil))
+	is.True(err != nil)
+
+	// sqlite doesn't understand COPY so this checks that the error is
+	// returned and the transaction is rolled back.
+	_, err = CopyFrom(ctx, New(pool), "points", []string{"x", "y"}, CopyFromRows(rows))
+	is.True(err != nil)
+	is.NoErr(pool.QueryRow("SELECT count(*) FROM points").Scan(&count))
+	is.Equal(count, 2500)
+}
+
+func TestCopyInQuery(t *testing.T) {
+	is := is.New(t)
+	is.Equal(copyInQuery("public.points", []string{"x", "y"}), `COPY "public"."points" ("x", "y") FROM STDIN`)
+}
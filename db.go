@@ -3,11 +3,12 @@ package db
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"io"
 	"log/slog"
+	"sync"
 	"time"
 
-	_ "github.com/lib/pq"
 	"github.com/pkg/errors"
 )
 
@@ -20,6 +21,9 @@ import (
 
 var (
 	ErrDBTimeout = errors.New("database ping timeout")
+	// ErrNotFound is returned when a query that expects a row returns none.
+	// It wraps [sql.ErrNoRows] so existing checks keep working.
+	ErrNotFound = fmt.Errorf("not found: %w", sql.ErrNoRows)
 )
 
 // DB is an abstract sql database type.
@@ -77,7 +81,19 @@ type StdDB interface {
 	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
 }
 
-// ScanOne will scan one row from a query and then close the Rows object.
+// Conn is an abstract type for a single database connection such as an
+// [sql.Conn].
+type Conn interface {
+	io.Closer
+	PingContext(context.Context) error
+	QueryContext(context.Context, string, ...any) (*sql.Rows, error)
+	ExecContext(context.Context, string, ...any) (sql.Result, error)
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+}
+
+// ScanOne will scan one row from a query and then close the Rows object. If
+// there are no rows then [ErrNotFound] is returned.
 func ScanOne(r Rows, dest ...any) (err error) {
 	if !r.Next() {
 		if err = r.Err(); err != nil {
@@ -85,7 +101,7 @@ func ScanOne(r Rows, dest ...any) (err error) {
 			return err
 		}
 		r.Close()
-		return sql.ErrNoRows
+		return ErrNotFound
 	}
 	if err = r.Scan(dest...); err != nil {
 		r.Close()
@@ -94,8 +110,38 @@ func ScanOne(r Rows, dest ...any) (err error) {
 	return r.Close()
 }
 
+// IsNotFound reports whether the error was caused by a query that returned no
+// rows.
+func IsNotFound(err error) bool {
+	return errors.Is(err, sql.ErrNoRows)
+}
+
 type dbOptions struct {
-	logger *slog.Logger
+	logger     *slog.Logger
+	dialect    Dialect
+	coerceArgs bool
+	retry      *RetryPolicy
+	role       *Role
+	setRole    string
+	breaker    *breaker
+	limiter    *limiter
+	waitHook   func(context.Context, time.Duration)
+	replicas   []*sql.DB
+
+	queryTimeout     time.Duration
+	statementTimeout bool
+	utc              bool
+	zeroTimeNull     bool
+	queryTags        bool
+	tagFuncs         []TagFunc
+	queryStats       *queryStats
+	leaks            *leakTracker
+	onConnect        []ConnectFunc
+	tenants          bool
+	cache            *queryCache
+	statsInterval    time.Duration
+	audit            AuditSink
+	pool             poolSettings
 }
 
 type Option func(*dbOptions)
@@ -106,41 +152,343 @@ func WithLogger(l *slog.Logger) Option { return func(d *dbOptions) { d.logger =
 // New will wrap an [sql.DB] and return a type that implements [DB]. Use this
 // function if you want fancy features like configuration and logging but if you
 // don't need those features then use [Simple].
+//
+// The pool is already open so options that configure opening it, like
+// [WithOnConnect] and [WithMaxOpenConns], have no effect here. Pass them to
+// [Open] instead.
 func New(pool *sql.DB, opts ...Option) *database {
-	options := dbOptions{}
+	return newDatabase(pool, applyOptions(opts))
+}
+
+// applyOptions applies the options in order. Options are only applied once
+// since some of them, like [WithQueryStats], create state.
+func applyOptions(opts []Option) dbOptions {
+	var options dbOptions
 	for _, o := range opts {
 		o(&options)
 	}
+	return options
+}
+
+// newDatabase wraps the pool with options that were already applied.
+func newDatabase(pool *sql.DB, options dbOptions) *database {
 	if options.logger == nil {
 		// TODO Create a silent log handler.
 		options.logger = slog.New(slog.NewTextHandler(io.Discard, nil))
 	}
+	if options.dialect == nil {
+		options.dialect = DialectFor(PostgresDBType)
+	}
 	d := &database{
-		DB:     pool,
-		logger: options.logger,
+		DB:         pool,
+		logger:     options.logger,
+		dialect:    options.dialect,
+		coerceArgs: options.coerceArgs,
+		retry:      options.retry,
+		role:       options.role,
+		setRole:    options.setRole,
+		breaker:    options.breaker,
+		limiter:    options.limiter,
+
+		queryTimeout:     options.queryTimeout,
+		statementTimeout: options.statementTimeout,
+		utc:              options.utc,
+		zeroTimeNull:     options.zeroTimeNull,
+		queryTags:        options.queryTags,
+		tagFuncs:         options.tagFuncs,
+		queryStats:       options.queryStats,
+		leaks:            options.leaks,
+		tenants:          options.tenants,
+		cache:            options.cache,
+		audit:            options.audit,
+	}
+	if d.limiter != nil {
+		d.limiter.hook = options.waitHook
+	}
+	if d.leaks != nil {
+		d.leaks.logger = d.logger
+	}
+	if len(options.replicas) > 0 {
+		d.replicas = &replicaSet{dbs: options.replicas}
+	}
+	d.stop = make(chan struct{})
+	if options.statsInterval > 0 {
+		go d.logStats(options.statsInterval, d.stop)
 	}
 	return d
 }
 
 type database struct {
 	*sql.DB
-	logger *slog.Logger
+	logger     *slog.Logger
+	dialect    Dialect
+	coerceArgs bool
+	retry      *RetryPolicy
+	role       *Role
+	setRole    string
+	breaker    *breaker
+	limiter    *limiter
+	replicas   *replicaSet
+	counters   counters
+
+	queryTimeout     time.Duration
+	statementTimeout bool
+	utc              bool
+	zeroTimeNull     bool
+	queryTags        bool
+	tagFuncs         []TagFunc
+	queryStats       *queryStats
+	leaks            *leakTracker
+	tenants          bool
+	cache            *queryCache
+	audit            AuditSink
+	drain            drainer
+	// stop is closed when the database is closed to end background work.
+	stop     chan struct{}
+	stopOnce sync.Once
 }
 
+// Dialect returns the [Dialect] of the database.
+func (db *database) Dialect() Dialect { return db.dialect }
+
 func (db *database) QueryContext(ctx context.Context, query string, v ...any) (Rows, error) {
-	rows, err := db.DB.QueryContext(ctx, query, v...)
+	leave, err := db.drain.enter()
+	if err != nil {
+		return nil, err
+	}
+	var rows Rows
+	if tables, ok := cacheTables(ctx); ok && db.cache != nil {
+		rows, err = db.cachedQuery(ctx, query, v, tables)
+	} else {
+		rows, err = db.query(ctx, query, v)
+	}
+	if err != nil {
+		leave()
+		return nil, err
+	}
+	release := leave
+	if db.cache != nil && classifyStatement(query)&^stmtRead != 0 {
+		// Writes like INSERT ... RETURNING are done once the rows are.
+		if tables := writtenTables(query); len(tables) > 0 {
+			var once sync.Once
+			release = func() {
+				leave()
+				once.Do(func() { db.invalidateWrites(context.WithoutCancel(ctx), tables) })
+			}
+		}
+	}
+	return &releaseRows{Rows: rows, release: release}, nil
+}
+
+func (db *database) query(ctx context.Context, query string, v []any) (Rows, error) {
+	ctx, done, err := db.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var rows Rows
+	err = db.run(ctx, opQuery, query, v, func(ctx context.Context, query string, args []any) (err error) {
+		tenant, err := db.tenant(ctx)
+		if err != nil {
+			return err
+		}
+		if len(tenant) > 0 && db.useConn() {
+			rows, err = db.tenantQuery(ctx, tenant, query, args)
+			return err
+		}
+		if db.implicitTx(ctx) {
+			rows, err = db.queryAsRole(ctx, query, args)
+			return err
+		}
+		rows, err = db.reader(ctx, query).QueryContext(ctx, query, args...)
+		return err
+	})
 	if err != nil {
-		db.logger.Debug(query, slog.Any("error", err))
+		if done != nil {
+			done()
+		}
+		return nil, err
+	}
+	if db.utc {
+		rows = &utcRows{rows}
+	}
+	if db.leaks != nil {
+		rows = db.leaks.track(rows)
 	}
-	return rows, err
+	if done == nil {
+		return rows, nil
+	}
+	return &releaseRows{Rows: rows, release: done}, nil
+}
+
+func (db *database) ExecContext(ctx context.Context, query string, v ...any) (sql.Result, error) {
+	leave, err := db.drain.enter()
+	if err != nil {
+		return nil, err
+	}
+	defer leave()
+	ctx, done, err := db.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if done != nil {
+		defer done()
+	}
+	var res sql.Result
+	err = db.run(ctx, opExec, query, v, func(ctx context.Context, query string, args []any) (err error) {
+		tenant, err := db.tenant(ctx)
+		if err != nil {
+			return err
+		}
+		if len(tenant) > 0 && db.useConn() {
+			res, err = db.tenantExec(ctx, tenant, query, args)
+			return err
+		}
+		if db.implicitTx(ctx) {
+			res, err = db.execAsRole(ctx, query, args)
+			return err
+		}
+		res, err = db.DB.ExecContext(ctx, query, args...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if db.cache != nil {
+		db.invalidateWrites(ctx, writtenTables(query))
+	}
+	if db.audit != nil {
+		db.auditExec(ctx, query, res)
+	}
+	return res, nil
 }
 
 func (db *database) BeginTx(ctx context.Context, opts *sql.TxOptions) (Tx, error) {
-	t, err := db.DB.BeginTx(ctx, opts)
+	leave, err := db.drain.enter()
 	if err != nil {
 		return nil, err
 	}
-	return &tx{Tx: t}, nil
+	var (
+		t       *sql.Tx
+		release func()
+	)
+	err = db.run(ctx, opBegin, "", nil, func(ctx context.Context, _ string, _ []any) (err error) {
+		tenant, err := db.tenant(ctx)
+		if err != nil {
+			return err
+		}
+		if len(tenant) > 0 && db.useConn() {
+			t, release, err = db.tenantBegin(ctx, tenant, opts)
+			return err
+		}
+		t, err = db.begin(ctx, opts)
+		return err
+	})
+	if err != nil {
+		leave()
+		return nil, err
+	}
+	done := leave
+	if release != nil {
+		done = func() { release(); leave() }
+	}
+	wrapped := &tx{Tx: t, db: db, release: done}
+	if db.audit != nil {
+		wrapped.audit = &txAudit{ctx: context.WithoutCancel(ctx), event: newAuditEvent(ctx)}
+		wrapped.audit.event.Tx = true
+	}
+	return wrapped, nil
+}
+
+// begin starts a transaction and runs any setup statements that the wrapper
+// has been configured with.
+func (db *database) begin(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	t, err := db.DB.BeginTx(ctx, db.role.txOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+	if len(db.setRole) > 0 {
+		_, err = t.ExecContext(ctx, "SET LOCAL ROLE "+db.dialect.QuoteIdent(db.setRole))
+		if err != nil {
+			t.Rollback()
+			return nil, errors.Wrap(err, "failed to set role")
+		}
+	}
+	if tenant, _ := db.tenant(ctx); len(tenant) > 0 {
+		_, err = t.ExecContext(ctx, "SET LOCAL search_path TO "+db.dialect.QuoteIdent(tenant))
+		if err != nil {
+			t.Rollback()
+			return nil, errors.Wrap(err, "failed to set tenant")
+		}
+	}
+	if q := db.statementTimeoutQuery(); len(q) > 0 {
+		if _, err = t.ExecContext(ctx, q); err != nil {
+			t.Rollback()
+			return nil, errors.Wrap(err, "failed to set statement timeout")
+		}
+	}
+	return t, nil
+}
+
+// queryFunc runs a statement after the wrapper has processed it.
+type queryFunc func(ctx context.Context, query string, args []any) error
+
+// run runs a database operation with all the features the wrapper has been
+// configured with.
+func (db *database) run(ctx context.Context, op, query string, args []any, fn queryFunc) error {
+	start := now()
+	db.counters.start()
+	raw := query
+	query, args, err := db.prepare(ctx, op, query, args)
+	if err == nil {
+		err = db.call(ctx, op, query, args, fn)
+	}
+	db.counters.done(op, err)
+	if canceled(ctx, err) {
+		db.counters.canceled.Add(1)
+	}
+	if db.queryStats != nil && len(raw) > 0 {
+		db.queryStats.record(raw, now().Sub(start), err)
+	}
+	if err != nil {
+		db.logger.Debug(query, slog.String("op", op), slog.Any("error", err))
+		return newQueryError(op, query, args, now().Sub(start), err)
+	}
+	return nil
+}
+
+// call runs the statement through the circuit breaker and retry policy.
+func (db *database) call(ctx context.Context, op, query string, args []any, fn queryFunc) (err error) {
+	if db.breaker != nil {
+		if err = db.breaker.allow(ctx, db.DB); err != nil {
+			return err
+		}
+		defer func() { db.breaker.record(err) }()
+	}
+	if db.retry == nil {
+		return fn(ctx, query, args)
+	}
+	return db.retry.retry(ctx, db.logger, op, query, func() error { return fn(ctx, query, args) })
+}
+
+// prepare checks the statement against the role and applies any argument
+// processing that the wrapper has been configured with.
+func (db *database) prepare(ctx context.Context, op, query string, args []any) (string, []any, error) {
+	if err := db.role.check(op, query); err != nil {
+		return query, args, err
+	}
+	if db.zeroTimeNull {
+		args = zeroTimesToNull(args)
+	}
+	if db.coerceArgs {
+		var err error
+		if query, args, err = normalizeArgs(query, args); err != nil {
+			return query, args, err
+		}
+	}
+	if db.queryTags && len(query) > 0 {
+		query = db.tagQuery(ctx, query)
+	}
+	return query, args, nil
 }
 
 // Simple creates a bare bones simple wrapper around a [sql.DB] that implements
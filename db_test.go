@@ -6,6 +6,7 @@ import (
 	"io"
 	"log/slog"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -63,6 +64,8 @@ func TestScanOne(t *testing.T) {
 		r.EXPECT().Close().Return(nil)
 		err := ScanOne(r)
 		is.True(errors.Is(err, sql.ErrNoRows))
+		is.True(errors.Is(err, ErrNotFound))
+		is.True(IsNotFound(err))
 	})
 
 	run("no next no rows error", func(t *testing.T, r *mockrows.MockRows) {
@@ -265,3 +268,214 @@ func TestWaitFor_Functional(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestTxPanics(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	pool, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer pool.Close()
+	pool.SetMaxOpenConns(1)
+	_, err = pool.Exec("create table t (a int)")
+	is.NoErr(err)
+	errTest := errors.New("test panic")
+	count := func() (n int) {
+		is.NoErr(pool.QueryRow("select count(*) from t").Scan(&n))
+		return n
+	}
+
+	func() {
+		defer func() { is.Equal(recover(), "oops") }()
+		_ = WithTx(ctx, pool, nil, func(tx *sql.Tx) error {
+			_, err := tx.Exec("insert into t values (1)")
+			is.NoErr(err)
+			panic("oops")
+		})
+	}()
+	is.Equal(count(), 0)
+
+	err = WithTx(ctx, pool, nil, func(tx *sql.Tx) error {
+		_, err := tx.Exec("insert into t values (1)")
+		is.NoErr(err)
+		panic(errTest)
+	}, WithPanicRecovery())
+	var pe *PanicError
+	is.True(errors.As(err, &pe))
+	is.True(errors.Is(err, errTest))
+	is.True(len(pe.Stack) > 0)
+	is.True(strings.Contains(pe.Error(), "test panic"))
+	is.Equal(count(), 0)
+
+	d := Simple(pool)
+	func() {
+		defer func() { is.Equal(recover(), "oops") }()
+		tx, err := d.BeginTx(ctx, nil)
+		is.NoErr(err)
+		_ = TxDo(ctx, tx, func(tx Tx) error {
+			_, err := tx.ExecContext(ctx, "insert into t values (1)")
+			is.NoErr(err)
+			panic("oops")
+		})
+	}()
+	is.Equal(count(), 0)
+	tx, err := d.BeginTx(ctx, nil)
+	is.NoErr(err)
+	err = TxDo(ctx, tx, func(tx Tx) error { panic(1) }, WithPanicRecovery())
+	is.True(errors.As(err, &pe))
+	is.Equal(pe.Value, 1)
+	is.True(pe.Unwrap() == nil)
+
+	tx, err = d.BeginTx(ctx, nil)
+	is.NoErr(err)
+	err = TxDo(ctx, tx, func(tx Tx) error {
+		_, err := tx.ExecContext(ctx, "insert into t values (1)")
+		return err
+	})
+	is.NoErr(err)
+	is.Equal(count(), 1)
+}
+
+func TestBegin(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	pool, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer pool.Close()
+
+	conn, err := pool.Conn(ctx)
+	is.NoErr(err)
+	defer conn.Close()
+	for _, d := range []any{pool, conn, StdDB(pool), Simple(pool), New(pool)} {
+		tx, err := Begin(ctx, nil, d)
+		is.NoErr(err)
+		_, err = Begin(ctx, nil, tx)
+		is.True(err != nil)
+		is.NoErr(tx.Rollback())
+	}
+	_, err = Begin(ctx, nil, "not a database")
+	is.True(err != nil)
+}
+
+func TestTxDoRetry(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	pool, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer pool.Close()
+	pool.SetMaxOpenConns(1)
+	_, err = pool.Exec("create table t (a int)")
+	is.NoErr(err)
+	d := New(pool, WithDialect(DialectFor(CockroachDBType)))
+	policy := WithTxRetry(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond})
+
+	var calls int
+	err = TxDoRetry(ctx, d, nil, func(tx Tx) error {
+		calls++
+		if _, err := tx.ExecContext(ctx, "insert into t values (?)", calls); err != nil {
+			return err
+		}
+		if calls < 3 {
+			return errors.New("pq: restart transaction: TransactionRetryWithProtoRefreshError")
+		}
+		return nil
+	}, policy)
+	is.NoErr(err)
+	is.Equal(calls, 3)
+	var n int
+	is.NoErr(pool.QueryRow("select count(*) from t").Scan(&n))
+	is.Equal(n, 1) // the failed attempts were rolled back
+
+	// Attempts run out.
+	calls = 0
+	err = TxDoRetry(ctx, d, nil, func(Tx) error {
+		calls++
+		return stateErr("40001")
+	}, policy)
+	is.True(IsSerializationFailure(err))
+	is.Equal(calls, 3)
+
+	// Other errors are not retried.
+	calls = 0
+	errTest := errors.New("test")
+	err = TxDoRetry(ctx, pool, nil, func(Tx) error {
+		calls++
+		return errTest
+	})
+	is.True(errors.Is(err, errTest))
+	is.Equal(calls, 1)
+	is.True(TxDoRetry(ctx, "not a database", nil, func(Tx) error { return nil }) != nil)
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	calls = 0
+	err = TxDoRetry(canceled, d, nil, func(Tx) error { calls++; return nil })
+	is.True(err != nil)
+	is.Equal(calls, 0)
+}
+
+func TestWithConn(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	pool, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer pool.Close()
+	err = WithConn(ctx, pool, func(conn *sql.Conn) error {
+		var c Conn = conn
+		if _, err := c.ExecContext(ctx, "create temp table tmp (a int)"); err != nil {
+			return err
+		}
+		_, err := c.ExecContext(ctx, "insert into tmp values (1)")
+		return err
+	})
+	is.NoErr(err)
+	errTest := errors.New("test")
+	err = WithConn(ctx, pool, func(conn *sql.Conn) error { return errTest })
+	is.Equal(err, errTest)
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	err = WithConn(canceled, pool, func(conn *sql.Conn) error { return nil })
+	is.True(errors.Is(err, context.Canceled))
+}
+
+func TestQueryError(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	pool, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer pool.Close()
+	pool.SetMaxOpenConns(1)
+	d := New(pool)
+
+	long := "select * from nope where " + strings.Repeat("a = 1 and ", 50) + "b = ?"
+	_, err = d.QueryContext(ctx, long, 1)
+	var qe *QueryError
+	is.True(errors.As(err, &qe))
+	is.Equal(qe.Op, "query")
+	is.Equal(qe.Args, 1)
+	is.Equal(len(qe.Query), maxErrorQueryLen+3)
+	is.True(strings.HasPrefix(err.Error(), `query "select * from nope where a = 1`))
+	is.True(strings.Contains(err.Error(), "no such table: nope"))
+	is.True(errors.Unwrap(err) != nil)
+
+	_, err = d.ExecContext(ctx, "insert into nope values (1)")
+	is.True(errors.As(err, &qe))
+	is.Equal(qe.Op, "exec")
+
+	tx, err := d.BeginTx(ctx, nil)
+	is.NoErr(err)
+	_, err = tx.ExecContext(ctx, "insert into nope values (1)")
+	is.True(errors.As(err, &qe))
+	is.NoErr(tx.Commit())
+	err = tx.Rollback()
+	is.True(errors.As(err, &qe))
+	is.Equal(qe.Op, "rollback")
+	is.True(errors.Is(err, sql.ErrTxDone))
+	is.Equal(err.Error(), "rollback failed after "+qe.Duration.String()+": sql: transaction has already been committed or rolled back")
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = d.BeginTx(canceled, nil)
+	is.True(errors.As(err, &qe))
+	is.Equal(qe.Op, "begin")
+	is.True(errors.Is(err, context.Canceled))
+}
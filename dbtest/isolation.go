@@ -0,0 +1,291 @@
+// Package dbtest has helpers for testing code against a real database.
+package dbtest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Step is one statement in an isolation [Scenario].
+type Step struct {
+	// Session is the transaction that runs the step, either 0 or 1.
+	Session int
+	// Label names the step in the [Result]. Results of SELECT statements are
+	// stored under the label.
+	Label string
+	// SQL is the statement to run. "COMMIT" and "ROLLBACK" end the session's
+	// transaction.
+	SQL  string
+	Args []any
+}
+
+// Scenario is a script of statements run by two concurrent transactions.
+// Steps run one at a time in order which acts as a barrier between the two
+// sessions. A step that is blocked by a lock is left running and the next
+// step starts after the [Scenario.StepTimeout].
+type Scenario struct {
+	Name string
+	// Setup statements are run before each isolation level.
+	Setup []string
+	// Teardown statements are run after each isolation level.
+	Teardown []string
+	Steps    []Step
+	// Anomaly inspects the result and describes the anomaly that was
+	// observed, or returns an empty string if there was none.
+	Anomaly func(r *Result) string
+	// StepTimeout is how long to wait for a step before considering it
+	// blocked. Defaults to 200ms.
+	StepTimeout time.Duration
+}
+
+// Result is the outcome of running a [Scenario] at one isolation level.
+type Result struct {
+	Level sql.IsolationLevel
+	// Rows holds the rows returned by labeled SELECT steps.
+	Rows map[string][][]any
+	// Errors holds the errors returned by labeled steps.
+	Errors map[string]error
+	// Blocked lists the labels of steps that had to wait on a lock.
+	Blocked []string
+	// Anomaly is the anomaly reported by the scenario.
+	Anomaly string
+	// Err is set when the isolation level could not be tested.
+	Err error
+}
+
+// Value returns the first column of the first row returned by a step.
+func (r *Result) Value(label string) any {
+	rows := r.Rows[label]
+	if len(rows) == 0 || len(rows[0]) == 0 {
+		return nil
+	}
+	return rows[0][0]
+}
+
+// DefaultLevels are the isolation levels tested by [IsolationMatrix] when no
+// levels are given.
+var DefaultLevels = []sql.IsolationLevel{
+	sql.LevelReadUncommitted,
+	sql.LevelReadCommitted,
+	sql.LevelRepeatableRead,
+	sql.LevelSerializable,
+}
+
+// IsolationMatrix runs the scenario under each isolation level and logs a
+// table of the anomalies that were observed.
+func IsolationMatrix(t testing.TB, db *sql.DB, s Scenario, levels ...sql.IsolationLevel) []Result {
+	t.Helper()
+	if len(levels) == 0 {
+		levels = DefaultLevels
+	}
+	if s.StepTimeout <= 0 {
+		s.StepTimeout = 200 * time.Millisecond
+	}
+	results := make([]Result, 0, len(levels))
+	var table strings.Builder
+	fmt.Fprintf(&table, "isolation matrix %q:\n", s.Name)
+	for _, level := range levels {
+		r := runScenario(db, &s, level)
+		switch {
+		case r.Err != nil:
+			fmt.Fprintf(&table, "  %-18s error: %v\n", level, r.Err)
+		case len(r.Anomaly) > 0:
+			fmt.Fprintf(&table, "  %-18s %s\n", level, r.Anomaly)
+		default:
+			fmt.Fprintf(&table, "  %-18s no anomaly\n", level)
+		}
+		results = append(results, r)
+	}
+	t.Log(table.String())
+	return results
+}
+
+type session struct {
+	tx    *sql.Tx
+	conn  *sql.Conn
+	steps chan Step
+	done  chan stepResult
+}
+
+type stepResult struct {
+	step Step
+	rows [][]any
+	err  error
+}
+
+func runScenario(db *sql.DB, s *Scenario, level sql.IsolationLevel) (r Result) {
+	ctx := context.Background()
+	// stepCtx is canceled to stop steps that are blocked forever.
+	stepCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	r = Result{Level: level, Rows: make(map[string][][]any), Errors: make(map[string]error)}
+	for _, stmt := range s.Setup {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			r.Err = fmt.Errorf("setup: %w", err)
+			return r
+		}
+	}
+	// stuck is set when a step could not be stopped. Its transaction still
+	// holds locks so the sessions are not closed and teardown is skipped
+	// instead of hanging.
+	var stuck bool
+	defer func() {
+		if stuck {
+			return
+		}
+		for _, stmt := range s.Teardown {
+			if _, err := db.ExecContext(ctx, stmt); err != nil && r.Err == nil {
+				r.Err = fmt.Errorf("teardown: %w", err)
+			}
+		}
+	}()
+
+	var sessions [2]*session
+	defer func() {
+		if stuck {
+			return
+		}
+		for _, sess := range sessions {
+			if sess != nil {
+				sess.tx.Rollback()
+				sess.conn.Close()
+			}
+		}
+	}()
+	for i := range sessions {
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			r.Err = err
+			return r
+		}
+		// Canceling stepCtx also rolls back the transaction which releases
+		// its locks.
+		tx, err := conn.BeginTx(stepCtx, &sql.TxOptions{Isolation: level})
+		if err != nil {
+			conn.Close()
+			r.Err = err
+			return r
+		}
+		// The steps are buffered so that queuing a step behind a blocked
+		// one never blocks the scenario.
+		sess := &session{tx: tx, conn: conn, steps: make(chan Step, len(s.Steps)), done: make(chan stepResult, len(s.Steps))}
+		sessions[i] = sess
+		go sess.run(stepCtx)
+	}
+
+	pending := 0
+	record := func(res stepResult) {
+		pending--
+		if len(res.step.Label) == 0 {
+			return
+		}
+		if res.err != nil {
+			r.Errors[res.step.Label] = res.err
+		} else if res.rows != nil {
+			r.Rows[res.step.Label] = res.rows
+		}
+	}
+	for _, step := range s.Steps {
+		if step.Session < 0 || step.Session > 1 {
+			r.Err = fmt.Errorf("step %q has invalid session %d", step.Label, step.Session)
+			break
+		}
+		sess := sessions[step.Session]
+		sess.steps <- step
+		pending++
+		select {
+		case res := <-sess.done:
+			record(res)
+		case <-time.After(s.StepTimeout):
+			r.Blocked = append(r.Blocked, step.Label)
+		}
+	}
+	for _, sess := range sessions {
+		close(sess.steps)
+	}
+	// Wait for blocked steps to finish. Steps that are still blocked after
+	// every step has run are waiting on a lock that will never be released
+	// so they are canceled and every transaction is rolled back. Their
+	// errors are recorded.
+	canceled := false
+	for pending > 0 {
+		select {
+		case res := <-sessions[0].done:
+			record(res)
+		case res := <-sessions[1].done:
+			record(res)
+		case <-time.After(s.StepTimeout):
+			if canceled {
+				stuck = true
+				r.Err = fmt.Errorf("%d blocked steps could not be canceled", pending)
+				return r
+			}
+			cancel()
+			canceled = true
+		}
+	}
+	if s.Anomaly != nil && r.Err == nil {
+		r.Anomaly = s.Anomaly(&r)
+	}
+	return r
+}
+
+func (s *session) run(ctx context.Context) {
+	for step := range s.steps {
+		res := stepResult{step: step}
+		switch strings.ToUpper(strings.TrimSpace(step.SQL)) {
+		case "COMMIT":
+			res.err = s.tx.Commit()
+		case "ROLLBACK":
+			res.err = s.tx.Rollback()
+		default:
+			res.rows, res.err = s.exec(ctx, step)
+		}
+		s.done <- res
+	}
+}
+
+func (s *session) exec(ctx context.Context, step Step) ([][]any, error) {
+	if !isSelect(step.SQL) {
+		_, err := s.tx.ExecContext(ctx, step.SQL, step.Args...)
+		return nil, err
+	}
+	rows, err := s.tx.QueryContext(ctx, step.SQL, step.Args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	out := [][]any{}
+	for rows.Next() {
+		row := make([]any, len(cols))
+		ptrs := make([]any, len(cols))
+		for i := range row {
+			ptrs[i] = &row[i]
+		}
+		if err = rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		out = append(out, row)
+	}
+	return out, rows.Err()
+}
+
+func isSelect(query string) bool {
+	f := strings.Fields(query)
+	if len(f) == 0 {
+		return false
+	}
+	switch strings.ToLower(f[0]) {
+	case "select", "with", "show", "values":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,121 @@
+package dbtest
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func openDB(t *testing.T) *sql.DB {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "test.db")
+	db, err := sql.Open("sqlite3", "file:"+file+"?_journal_mode=WAL&_busy_timeout=2000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestIsolationMatrix(t *testing.T) {
+	is := is.New(t)
+	db := openDB(t)
+	s := Scenario{
+		Name: "non-repeatable read",
+		Setup: []string{
+			`CREATE TABLE acct (id INTEGER PRIMARY KEY, balance INTEGER)`,
+			`INSERT INTO acct VALUES (1, 100)`,
+		},
+		Teardown: []string{`DROP TABLE acct`},
+		Steps: []Step{
+			{Session: 0, Label: "before", SQL: `SELECT balance FROM acct WHERE id = 1`},
+			{Session: 1, Label: "update", SQL: `UPDATE acct SET balance = ? WHERE id = 1`, Args: []any{50}},
+			{Session: 1, Label: "commit", SQL: "COMMIT"},
+			{Session: 0, Label: "after", SQL: `SELECT balance FROM acct WHERE id = 1`},
+			{Session: 0, SQL: "COMMIT"},
+		},
+		Anomaly: func(r *Result) string {
+			if r.Value("before") != r.Value("after") {
+				return fmt.Sprintf("non-repeatable read: %v != %v", r.Value("before"), r.Value("after"))
+			}
+			return ""
+		},
+	}
+	results := IsolationMatrix(t, db, s, sql.LevelDefault, sql.LevelSerializable)
+	is.Equal(len(results), 2)
+	for _, r := range results {
+		is.NoErr(r.Err)
+		is.Equal(len(r.Errors), 0)
+		is.Equal(len(r.Blocked), 0)
+		is.Equal(r.Value("before"), int64(100))
+		// sqlite transactions read from a snapshot
+		is.Equal(r.Anomaly, "")
+	}
+}
+
+func TestIsolationMatrix_Blocked(t *testing.T) {
+	is := is.New(t)
+	db := openDB(t)
+	s := Scenario{
+		Name: "write conflict",
+		Setup: []string{
+			`CREATE TABLE counter (n INTEGER)`,
+			`INSERT INTO counter VALUES (0)`,
+		},
+		Teardown: []string{`DROP TABLE counter`},
+		Steps: []Step{
+			{Session: 0, Label: "first", SQL: `UPDATE counter SET n = n + 1`},
+			{Session: 1, Label: "second", SQL: `UPDATE counter SET n = n + 1`},
+			{Session: 0, Label: "commit", SQL: "COMMIT"},
+			{Session: 1, SQL: "COMMIT"},
+		},
+		StepTimeout: 50 * time.Millisecond,
+	}
+	results := IsolationMatrix(t, db, s, sql.LevelDefault)
+	is.Equal(len(results), 1)
+	is.NoErr(results[0].Err)
+	is.Equal(results[0].Blocked, []string{"second"})
+}
+
+func TestIsolationMatrix_BadSession(t *testing.T) {
+	is := is.New(t)
+	db := openDB(t)
+	results := IsolationMatrix(t, db, Scenario{
+		Steps: []Step{{Session: 2, Label: "x", SQL: "SELECT 1"}},
+	}, sql.LevelDefault)
+	is.True(results[0].Err != nil)
+}
+
+func TestIsolationMatrix_Deadlock(t *testing.T) {
+	is := is.New(t)
+	file := filepath.Join(t.TempDir(), "test.db")
+	db, err := sql.Open("sqlite3", "file:"+file+"?_journal_mode=WAL&_busy_timeout=30000")
+	is.NoErr(err)
+	defer db.Close()
+	s := Scenario{
+		Name: "lock never released",
+		Setup: []string{
+			`CREATE TABLE counter (n INTEGER)`,
+			`INSERT INTO counter VALUES (0)`,
+		},
+		Teardown: []string{`DROP TABLE counter`},
+		Steps: []Step{
+			{Session: 0, Label: "first", SQL: `UPDATE counter SET n = n + 1`},
+			{Session: 1, Label: "second", SQL: `UPDATE counter SET n = n + 1`},
+			// Queued behind the blocked step.
+			{Session: 1, Label: "third", SQL: `UPDATE counter SET n = n + 1`},
+		},
+		StepTimeout: 50 * time.Millisecond,
+	}
+	start := time.Now()
+	results := IsolationMatrix(t, db, s, sql.LevelDefault)
+	is.True(time.Since(start) < 10*time.Second)
+	is.NoErr(results[0].Err)
+	is.Equal(results[0].Blocked, []string{"second", "third"})
+	is.True(results[0].Errors["second"] != nil)
+}
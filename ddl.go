@@ -0,0 +1,126 @@
+package db
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// TableExists reports whether a table or view exists. The name may be
+// qualified with a schema, or a database on mysql, otherwise the current one
+// is searched.
+func TableExists(ctx context.Context, db DB, name string) (bool, error) {
+	d := DialectOf(db)
+	if d.Type() == SQLiteDBType {
+		return exists(ctx, db,
+			"SELECT 1 FROM sqlite_master WHERE type IN ('table', 'view') AND name = ?", name)
+	}
+	schema, table, err := splitTableName(d, name)
+	if err != nil {
+		return false, err
+	}
+	return exists(ctx, db, "SELECT 1 FROM information_schema.tables WHERE table_schema = "+
+		schema+" AND table_name = "+d.Placeholder(1), table)
+}
+
+// ColumnExists reports whether a table has a column.
+func ColumnExists(ctx context.Context, db DB, table, column string) (bool, error) {
+	d := DialectOf(db)
+	if d.Type() == SQLiteDBType {
+		return exists(ctx, db, "SELECT 1 FROM pragma_table_info(?) WHERE name = ?", table, column)
+	}
+	schema, table, err := splitTableName(d, table)
+	if err != nil {
+		return false, err
+	}
+	return exists(ctx, db, "SELECT 1 FROM information_schema.columns WHERE table_schema = "+
+		schema+" AND table_name = "+d.Placeholder(1)+" AND column_name = "+d.Placeholder(2),
+		table, column)
+}
+
+// CreateTableIfNotExists runs a CREATE TABLE statement unless the table
+// already exists and reports whether the table was created. This works for
+// databases that don't support CREATE TABLE IF NOT EXISTS and when another
+// process creates the table at the same time.
+func CreateTableIfNotExists(ctx context.Context, db DB, ddl string) (bool, error) {
+	name, err := createTableName(ddl)
+	if err != nil {
+		return false, err
+	}
+	ok, err := TableExists(ctx, db, name)
+	if err != nil || ok {
+		return false, err
+	}
+	if _, err = db.ExecContext(ctx, ddl); err != nil {
+		// Lost a race with someone else creating it.
+		if ok, _ := TableExists(ctx, db, name); ok {
+			return false, nil
+		}
+		return false, errors.Wrapf(err, "failed to create table %q", name)
+	}
+	return true, nil
+}
+
+// splitTableName returns the expression for the table's schema and the
+// table's name.
+func splitTableName(d Dialect, name string) (schema, table string, err error) {
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		return "'" + strings.ReplaceAll(name[:i], "'", "''") + "'", name[i+1:], nil
+	}
+	switch t := d.Type(); {
+	case t.postgresWire():
+		return "current_schema()", name, nil
+	case t == MySQLDBType:
+		return "DATABASE()", name, nil
+	case t == SQLServerDBType:
+		return "SCHEMA_NAME()", name, nil
+	default:
+		return "", "", errors.Errorf("table introspection is not supported for %q", t)
+	}
+}
+
+// createTableName returns the name of the table created by a CREATE TABLE
+// statement.
+func createTableName(ddl string) (string, error) {
+	toks := sqlTokens(ddl)
+	i := 0
+	next := func(words ...string) bool {
+		for _, w := range words {
+			if i < len(toks) && toks[i] == w {
+				i++
+				return true
+			}
+		}
+		return false
+	}
+	if !next("create") {
+		return "", errors.New("not a CREATE TABLE statement")
+	}
+	for next("temp", "temporary", "unlogged", "global", "local") {
+	}
+	if !next("table") {
+		return "", errors.New("not a CREATE TABLE statement")
+	}
+	if next("if") && !(next("not") && next("exists")) {
+		return "", errors.New("not a CREATE TABLE statement")
+	}
+	name, _ := qualifiedName(toks, i)
+	if len(name) == 0 {
+		return "", errors.New("CREATE TABLE statement has no table name")
+	}
+	return name, nil
+}
+
+func exists(ctx context.Context, db DB, query string, args ...any) (bool, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	found := rows.Next()
+	if err = rows.Err(); err != nil {
+		return false, err
+	}
+	return found, rows.Close()
+}
@@ -0,0 +1,72 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestTableExists(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	pool, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer pool.Close()
+	pool.SetMaxOpenConns(1)
+	d := New(pool, WithDialect(DialectFor(SQLiteDBType)))
+
+	ok, err := TableExists(ctx, d, "users")
+	is.NoErr(err)
+	is.True(!ok)
+	created, err := CreateTableIfNotExists(ctx, d, `CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)`)
+	is.NoErr(err)
+	is.True(created)
+	created, err = CreateTableIfNotExists(ctx, d, `CREATE TABLE IF NOT EXISTS users (id INTEGER PRIMARY KEY)`)
+	is.NoErr(err)
+	is.True(!created)
+	ok, err = TableExists(ctx, d, "users")
+	is.NoErr(err)
+	is.True(ok)
+	ok, err = ColumnExists(ctx, d, "users", "name")
+	is.NoErr(err)
+	is.True(ok)
+	ok, err = ColumnExists(ctx, d, "users", "email")
+	is.NoErr(err)
+	is.True(!ok)
+
+	_, err = CreateTableIfNotExists(ctx, d, `CREATE TABLE posts (id INTEGER PRIMARY KEY`)
+	is.True(err != nil)
+	for _, ddl := range []string{"DROP TABLE x", "CREATE INDEX x ON y (z)", "CREATE TABLE IF x", "CREATE TABLE ("} {
+		_, err = CreateTableIfNotExists(ctx, d, ddl)
+		is.True(err != nil)
+	}
+	_, err = TableExists(ctx, New(pool, WithDialect(DialectFor(ClickHouseDBType))), "users")
+	is.True(err != nil)
+	_, err = ColumnExists(ctx, New(pool, WithDialect(DialectFor(ClickHouseDBType))), "users", "id")
+	is.True(err != nil)
+}
+
+func TestTableExists_InformationSchema(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	rec := &recorder{}
+	pool := sql.OpenDB(rec)
+	defer pool.Close()
+
+	_, err := TableExists(ctx, New(pool), "users")
+	is.NoErr(err)
+	_, err = TableExists(ctx, New(pool, WithDialect(DialectFor(MySQLDBType))), "app.users")
+	is.NoErr(err)
+	_, err = ColumnExists(ctx, New(pool, WithDialect(DialectFor(SQLServerDBType))), "users", "id")
+	is.NoErr(err)
+	is.Equal(rec.take(), []string{
+		"SELECT 1 FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = $1",
+		"SELECT 1 FROM information_schema.tables WHERE table_schema = 'app' AND table_name = ?",
+		"SELECT 1 FROM information_schema.columns WHERE table_schema = SCHEMA_NAME() AND table_name = @p1 AND column_name = @p2",
+	})
+	rec.fail = "SELECT"
+	_, err = TableExists(ctx, New(pool), "users")
+	is.True(err != nil)
+}
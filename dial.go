@@ -0,0 +1,120 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// DialFunc opens a network connection to the database server.
+type DialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// DialConnector builds a connector for a DSN that opens its network
+// connections with dial.
+type DialConnector func(dsn string, dial DialFunc) (driver.Connector, error)
+
+var (
+	dialConnectorsMu sync.RWMutex
+	dialConnectors   = map[Type]DialConnector{}
+)
+
+// RegisterDialConnector sets the func that builds connectors for configs of
+// the given type that have a [Config.Dialer] or an ssh tunnel. The driver
+// packages like github.com/harrybrwn/db/postgres register their connectors.
+func RegisterDialConnector(t Type, fn DialConnector) {
+	dialConnectorsMu.Lock()
+	defer dialConnectorsMu.Unlock()
+	dialConnectors[t] = fn
+}
+
+// SSHTunnelFunc opens an ssh tunnel for a config. It returns the func that
+// dials through the tunnel and the closer that shuts the tunnel down.
+type SSHTunnelFunc func(cfg *Config) (DialFunc, io.Closer, error)
+
+var (
+	sshTunnelMu sync.RWMutex
+	sshTunnel   SSHTunnelFunc
+)
+
+// RegisterSSHTunnel sets the func used to open the ssh tunnel for configs
+// with an SSHHost. It is done by importing github.com/harrybrwn/db/sshtunnel
+// so that programs that don't use ssh don't link an ssh client.
+func RegisterSSHTunnel(fn SSHTunnelFunc) {
+	sshTunnelMu.Lock()
+	defer sshTunnelMu.Unlock()
+	sshTunnel = fn
+}
+
+// dialFunc returns the dialer from the config. If an ssh host is set then
+// connections are tunneled through it using the Dialer to reach the ssh
+// server.
+func (db *Config) dialFunc() (DialFunc, io.Closer, error) {
+	if len(db.SSHHost) == 0 {
+		return db.Dialer, nil, nil
+	}
+	sshTunnelMu.RLock()
+	fn := sshTunnel
+	sshTunnelMu.RUnlock()
+	if fn == nil {
+		return nil, nil, errors.New("ssh tunnels need github.com/harrybrwn/db/sshtunnel to be imported")
+	}
+	return fn(db)
+}
+
+// customDial is true when connections can't be opened by the driver with
+// the DSN alone.
+func (db *Config) customDial() bool {
+	return db.Dialer != nil || len(db.SSHHost) > 0 || db.pgCustomTLS() || len(db.hosts()) > 0
+}
+
+// dialConnector returns a connector that connects with the config's dialer.
+// The driver's [DialConnector] must be registered.
+func (db *Config) dialConnector() (driver.Connector, error) {
+	dialConnectorsMu.RLock()
+	fn, ok := dialConnectors[db.Type]
+	dialConnectorsMu.RUnlock()
+	if !ok {
+		return nil, errors.Errorf("no dial connector for %q, see RegisterDialConnector", db.Type)
+	}
+	dial, closer, err := db.dialFunc()
+	if err != nil {
+		return nil, err
+	}
+	dsnCfg := *db
+	if db.pgCustomTLS() {
+		// The dialer negotiates tls so the driver must not.
+		dial = pgTLSDial(dial, db.TLS)
+		dsnCfg.SSLMode = "disable"
+	}
+	c, err := fn(dsnCfg.DSN(), dial)
+	if err != nil {
+		if closer != nil {
+			closer.Close()
+		}
+		return nil, err
+	}
+	return &closeConnector{Connector: c, closer: closer}, nil
+}
+
+// closeConnector closes the dialer's resources when the pool is closed.
+type closeConnector struct {
+	driver.Connector
+	closer io.Closer
+}
+
+func (c *closeConnector) Close() error {
+	var err error
+	if cl, ok := c.Connector.(io.Closer); ok {
+		err = cl.Close()
+	}
+	if c.closer != nil {
+		if e := c.closer.Close(); err == nil {
+			err = e
+		}
+	}
+	return err
+}
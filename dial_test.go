@@ -0,0 +1,88 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/pkg/errors"
+)
+
+type dialRecorder struct {
+	mu    sync.Mutex
+	addrs []string
+}
+
+func (d *dialRecorder) dial(_ context.Context, _, addr string) (net.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.addrs = append(d.addrs, addr)
+	return nil, errors.New("dial blocked")
+}
+
+func (d *dialRecorder) calls() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.addrs
+}
+
+// requireDialConnector skips the test when no dial connector is registered
+// for the type, which is the case for postgres when built with nopq.
+func requireDialConnector(t *testing.T, typ Type) {
+	t.Helper()
+	dialConnectorsMu.RLock()
+	_, ok := dialConnectors[typ]
+	dialConnectorsMu.RUnlock()
+	if !ok {
+		t.Skipf("no dial connector for %q", typ)
+	}
+}
+
+type dialConnector struct {
+	dial DialFunc
+	addr string
+}
+
+func (c *dialConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	_, err := c.dial(ctx, "tcp", c.addr)
+	return nil, err
+}
+
+func (c *dialConnector) Driver() driver.Driver { return nil }
+
+func TestConfig_Dialer(t *testing.T) {
+	is := is.New(t)
+	var rec dialRecorder
+	cfg := Config{Type: SQLServerDBType, Host: "db.internal", Port: "1433", User: "u", Dialer: rec.dial}
+	// Drivers must register a connector that takes the dialer.
+	_, err := cfg.dialConnector()
+	is.True(err != nil)
+	is.True(strings.Contains(err.Error(), "no dial connector"))
+
+	var dsn string
+	RegisterDialConnector(SQLServerDBType, func(d string, dial DialFunc) (driver.Connector, error) {
+		dsn = d
+		return &dialConnector{dial: dial, addr: "db.internal:1433"}, nil
+	})
+	defer func() {
+		dialConnectorsMu.Lock()
+		delete(dialConnectors, SQLServerDBType)
+		dialConnectorsMu.Unlock()
+	}()
+	c, err := cfg.dialConnector()
+	is.NoErr(err)
+	is.Equal(dsn, cfg.DSN())
+	_, err = c.Connect(context.Background())
+	is.True(err != nil)
+	is.Equal(rec.calls(), []string{"db.internal:1433"})
+
+	// ssh tunnels need the sshtunnel package.
+	cfg.SSHHost = "bastion"
+	_, err = cfg.dialConnector()
+	is.True(err != nil)
+	is.True(strings.Contains(err.Error(), "sshtunnel"))
+}
@@ -0,0 +1,337 @@
+package db
+
+import (
+	"database/sql/driver"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Dialect describes the SQL differences between database types so that
+// helpers can generate statements that are correct for the database they run
+// against.
+type Dialect interface {
+	// Type is the database type of the dialect.
+	Type() Type
+	// Placeholder returns the placeholder for the n'th query argument
+	// starting at 1.
+	Placeholder(n int) string
+	// QuoteIdent quotes an identifier. Dots separate the parts of qualified
+	// names like "schema.table".
+	QuoteIdent(name string) string
+	// OnConflict returns the clause appended to an INSERT statement that
+	// updates the updateCols when a row conflicts on the conflictCols. The
+	// row is left untouched if there are no updateCols. It is empty if the
+	// database can't express the clause.
+	OnConflict(conflictCols, updateCols []string) string
+	// Savepoint returns the statement that creates a savepoint.
+	Savepoint(name string) string
+	// RollbackToSavepoint returns the statement that rolls back to a
+	// savepoint.
+	RollbackToSavepoint(name string) string
+	// ReleaseSavepoint returns the statement that releases a savepoint.
+	ReleaseSavepoint(name string) string
+	// Limit returns a LIMIT/OFFSET clause. A negative limit means no limit.
+	Limit(limit, offset int) string
+	// MaxParams is the maximum number of placeholders allowed in a
+	// statement.
+	MaxParams() int
+	// ClassifyError returns the kind of error returned by the driver.
+	ClassifyError(err error) ErrorKind
+}
+
+// ErrorKind is a driver independent category of database error.
+type ErrorKind int
+
+const (
+	ErrorKindUnknown ErrorKind = iota
+	ErrorKindUniqueViolation
+	ErrorKindForeignKeyViolation
+	ErrorKindNotNullViolation
+	ErrorKindCheckViolation
+	ErrorKindSerializationFailure
+	ErrorKindDeadlock
+	ErrorKindConnection
+)
+
+var (
+	dialectsMu sync.RWMutex
+	dialects   = map[Type]Dialect{
+		PostgresDBType: postgresDialect{},
+		MySQLDBType:    mysqlDialect{},
+		SQLiteDBType:   sqliteDialect{},
+		// SQL Server and ClickHouse have no INSERT conflict clause so
+		// OnConflict returns an empty string for them.
+		SQLServerDBType:  sqlserverDialect{},
+		ClickHouseDBType: clickhouseDialect{},
+		CockroachDBType:  cockroachDialect{},
+	}
+)
+
+// RegisterDialect sets the [Dialect] used for a database type.
+func RegisterDialect(d Dialect) {
+	dialectsMu.Lock()
+	dialects[d.Type()] = d
+	dialectsMu.Unlock()
+}
+
+// DialectFor returns the [Dialect] for a database type. Unknown types get the
+// postgres dialect, so placeholders, quoting and limits silently follow
+// postgres for them.
+func DialectFor(t Type) Dialect {
+	dialectsMu.RLock()
+	d, ok := dialects[t]
+	dialectsMu.RUnlock()
+	if !ok {
+		return postgresDialect{}
+	}
+	return d
+}
+
+// Dialect returns the [Dialect] for the configured database type.
+func (db *Config) Dialect() Dialect { return DialectFor(db.Type) }
+
+// WithDialect sets the [Dialect] of the database wrapper returned by [New].
+// Defaults to postgres.
+func WithDialect(d Dialect) Option { return func(o *dbOptions) { o.dialect = d } }
+
+// DialectOf returns the [Dialect] of a database handle if it has one,
+// otherwise the postgres dialect is returned. Wrappers should have a
+// Dialect method that returns the wrapped database's dialect so that it is
+// not lost.
+func DialectOf(db any) Dialect {
+	if d, ok := db.(interface{ Dialect() Dialect }); ok {
+		if dialect := d.Dialect(); dialect != nil {
+			return dialect
+		}
+	}
+	return postgresDialect{}
+}
+
+// sqlStater is implemented by lib/pq and pgx errors.
+type sqlStater interface{ SQLState() string }
+
+// classifyConnError checks for errors that are caused by a broken connection.
+func classifyConnError(err error) ErrorKind {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, driver.ErrBadConn),
+		errors.Is(err, io.EOF),
+		errors.Is(err, io.ErrUnexpectedEOF),
+		errors.As(err, &netErr):
+		return ErrorKindConnection
+	}
+	return ErrorKindUnknown
+}
+
+func quoteIdent(name string, q byte) string {
+	parts := strings.Split(name, ".")
+	var b strings.Builder
+	for i, p := range parts {
+		if i > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteByte(q)
+		for j := 0; j < len(p); j++ {
+			if p[j] == q {
+				b.WriteByte(q)
+			}
+			b.WriteByte(p[j])
+		}
+		b.WriteByte(q)
+	}
+	return b.String()
+}
+
+func quoteAll(d Dialect, names []string) []string {
+	out := make([]string, len(names))
+	for i, n := range names {
+		out[i] = d.QuoteIdent(n)
+	}
+	return out
+}
+
+func savepoint(name string) string           { return "SAVEPOINT " + name }
+func rollbackToSavepoint(name string) string { return "ROLLBACK TO SAVEPOINT " + name }
+func releaseSavepoint(name string) string    { return "RELEASE SAVEPOINT " + name }
+
+func limitOffset(limit, offset int, noLimit string) string {
+	var b strings.Builder
+	switch {
+	case limit >= 0:
+		b.WriteString("LIMIT ")
+		b.WriteString(strconv.Itoa(limit))
+	case offset > 0 && len(noLimit) > 0:
+		b.WriteString("LIMIT ")
+		b.WriteString(noLimit)
+	}
+	if offset > 0 {
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString("OFFSET ")
+		b.WriteString(strconv.Itoa(offset))
+	}
+	return b.String()
+}
+
+// onConflictExcluded builds the postgres and sqlite ON CONFLICT clause.
+func onConflictExcluded(d Dialect, conflictCols, updateCols []string) string {
+	var b strings.Builder
+	b.WriteString("ON CONFLICT")
+	if len(conflictCols) > 0 {
+		b.WriteString(" (")
+		b.WriteString(strings.Join(quoteAll(d, conflictCols), ", "))
+		b.WriteByte(')')
+	}
+	if len(updateCols) == 0 {
+		b.WriteString(" DO NOTHING")
+		return b.String()
+	}
+	b.WriteString(" DO UPDATE SET ")
+	for i, c := range quoteAll(d, updateCols) {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(c)
+		b.WriteString(" = EXCLUDED.")
+		b.WriteString(c)
+	}
+	return b.String()
+}
+
+type postgresDialect struct{}
+
+func (postgresDialect) Type() Type                 { return PostgresDBType }
+func (postgresDialect) Placeholder(n int) string   { return "$" + strconv.Itoa(n) }
+func (postgresDialect) QuoteIdent(s string) string { return quoteIdent(s, '"') }
+func (d postgresDialect) OnConflict(conflictCols, updateCols []string) string {
+	return onConflictExcluded(d, conflictCols, updateCols)
+}
+func (postgresDialect) Savepoint(name string) string           { return savepoint(name) }
+func (postgresDialect) RollbackToSavepoint(name string) string { return rollbackToSavepoint(name) }
+func (postgresDialect) ReleaseSavepoint(name string) string    { return releaseSavepoint(name) }
+func (postgresDialect) Limit(limit, offset int) string         { return limitOffset(limit, offset, "") }
+func (postgresDialect) MaxParams() int                         { return 65535 }
+
+func (postgresDialect) ClassifyError(err error) ErrorKind { return ClassifyError(err) }
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) Type() Type                 { return MySQLDBType }
+func (mysqlDialect) Placeholder(int) string     { return "?" }
+func (mysqlDialect) QuoteIdent(s string) string { return quoteIdent(s, '`') }
+func (d mysqlDialect) OnConflict(conflictCols, updateCols []string) string {
+	var b strings.Builder
+	b.WriteString("ON DUPLICATE KEY UPDATE ")
+	if len(updateCols) == 0 {
+		// Assigning a column to itself is a no-op update.
+		if len(conflictCols) == 0 {
+			return ""
+		}
+		c := d.QuoteIdent(conflictCols[0])
+		b.WriteString(c + " = " + c)
+		return b.String()
+	}
+	for i, c := range quoteAll(d, updateCols) {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(c + " = VALUES(" + c + ")")
+	}
+	return b.String()
+}
+func (mysqlDialect) Savepoint(name string) string           { return savepoint(name) }
+func (mysqlDialect) RollbackToSavepoint(name string) string { return rollbackToSavepoint(name) }
+func (mysqlDialect) ReleaseSavepoint(name string) string    { return releaseSavepoint(name) }
+func (mysqlDialect) Limit(limit, offset int) string {
+	return limitOffset(limit, offset, "18446744073709551615")
+}
+func (mysqlDialect) MaxParams() int { return 65535 }
+
+func (mysqlDialect) ClassifyError(err error) ErrorKind { return ClassifyError(err) }
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Type() Type                 { return SQLiteDBType }
+func (sqliteDialect) Placeholder(int) string     { return "?" }
+func (sqliteDialect) QuoteIdent(s string) string { return quoteIdent(s, '"') }
+func (d sqliteDialect) OnConflict(conflictCols, updateCols []string) string {
+	return onConflictExcluded(d, conflictCols, updateCols)
+}
+func (sqliteDialect) Savepoint(name string) string           { return savepoint(name) }
+func (sqliteDialect) RollbackToSavepoint(name string) string { return rollbackToSavepoint(name) }
+func (sqliteDialect) ReleaseSavepoint(name string) string    { return releaseSavepoint(name) }
+func (sqliteDialect) Limit(limit, offset int) string         { return limitOffset(limit, offset, "-1") }
+func (sqliteDialect) MaxParams() int                         { return 32766 }
+
+func (sqliteDialect) ClassifyError(err error) ErrorKind { return ClassifyError(err) }
+
+type sqlserverDialect struct{}
+
+func (sqlserverDialect) Type() Type               { return SQLServerDBType }
+func (sqlserverDialect) Placeholder(n int) string { return "@p" + strconv.Itoa(n) }
+func (sqlserverDialect) QuoteIdent(s string) string {
+	parts := strings.Split(s, ".")
+	for i, p := range parts {
+		parts[i] = "[" + strings.ReplaceAll(p, "]", "]]") + "]"
+	}
+	return strings.Join(parts, ".")
+}
+func (sqlserverDialect) OnConflict([]string, []string) string { return "" }
+func (sqlserverDialect) Savepoint(name string) string         { return "SAVE TRANSACTION " + name }
+func (sqlserverDialect) RollbackToSavepoint(name string) string {
+	return "ROLLBACK TRANSACTION " + name
+}
+
+// ReleaseSavepoint returns an empty statement because SQL Server savepoints
+// are released when the transaction ends.
+func (sqlserverDialect) ReleaseSavepoint(string) string { return "" }
+
+// Limit returns an OFFSET FETCH clause, which SQL Server only allows after
+// an ORDER BY.
+func (sqlserverDialect) Limit(limit, offset int) string {
+	if limit < 0 && offset <= 0 {
+		return ""
+	}
+	s := "OFFSET " + strconv.Itoa(max(offset, 0)) + " ROWS"
+	if limit >= 0 {
+		s += " FETCH NEXT " + strconv.Itoa(limit) + " ROWS ONLY"
+	}
+	return s
+}
+func (sqlserverDialect) MaxParams() int { return 2100 }
+
+func (sqlserverDialect) ClassifyError(err error) ErrorKind { return ClassifyError(err) }
+
+type clickhouseDialect struct{}
+
+func (clickhouseDialect) Type() Type                             { return ClickHouseDBType }
+func (clickhouseDialect) Placeholder(int) string                 { return "?" }
+func (clickhouseDialect) QuoteIdent(s string) string             { return quoteIdent(s, '`') }
+func (clickhouseDialect) OnConflict([]string, []string) string   { return "" }
+func (clickhouseDialect) Savepoint(name string) string           { return savepoint(name) }
+func (clickhouseDialect) RollbackToSavepoint(name string) string { return rollbackToSavepoint(name) }
+func (clickhouseDialect) ReleaseSavepoint(name string) string    { return releaseSavepoint(name) }
+func (clickhouseDialect) Limit(limit, offset int) string         { return limitOffset(limit, offset, "") }
+func (clickhouseDialect) MaxParams() int                         { return 65535 }
+func (clickhouseDialect) ClassifyError(err error) ErrorKind      { return ClassifyError(err) }
+
+// cockroachDialect is the postgres dialect with CockroachDB's retry errors.
+type cockroachDialect struct{ postgresDialect }
+
+func (cockroachDialect) Type() Type { return CockroachDBType }
+
+// ClassifyError also treats "restart transaction" errors as serialization
+// failures since they are not always reported with SQLSTATE 40001.
+func (cockroachDialect) ClassifyError(err error) ErrorKind {
+	k := ClassifyError(err)
+	if k == ErrorKindUnknown && err != nil && strings.Contains(err.Error(), "restart transaction") {
+		return ErrorKindSerializationFailure
+	}
+	return k
+}
@@ -0,0 +1,117 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+type stateErr string
+
+func (e stateErr) Error() string    { return "sql state " + string(e) }
+func (e stateErr) SQLState() string { return string(e) }
+
+func TestDialects(t *testing.T) {
+	is := is.New(t)
+	pg := DialectFor(PostgresDBType)
+	my := DialectFor(MySQLDBType)
+	lite := (&Config{Type: SQLiteDBType}).Dialect()
+	is.Equal(DialectFor("unknown").Type(), PostgresDBType)
+
+	is.Equal(pg.Placeholder(3), "$3")
+	is.Equal(my.Placeholder(3), "?")
+	is.Equal(lite.Placeholder(3), "?")
+
+	is.Equal(pg.QuoteIdent(`public.my"table`), `"public"."my""table"`)
+	is.Equal(my.QuoteIdent("db.t`x"), "`db`.`t``x`")
+	is.Equal(lite.QuoteIdent("t"), `"t"`)
+
+	is.Equal(pg.OnConflict([]string{"id"}, []string{"name", "age"}),
+		`ON CONFLICT ("id") DO UPDATE SET "name" = EXCLUDED."name", "age" = EXCLUDED."age"`)
+	is.Equal(lite.OnConflict(nil, nil), `ON CONFLICT DO NOTHING`)
+	is.Equal(my.OnConflict([]string{"id"}, []string{"name"}), "ON DUPLICATE KEY UPDATE `name` = VALUES(`name`)")
+	is.Equal(my.OnConflict([]string{"id"}, nil), "ON DUPLICATE KEY UPDATE `id` = `id`")
+	is.Equal(my.OnConflict(nil, nil), "")
+
+	for _, d := range []Dialect{pg, my, lite} {
+		is.Equal(d.Savepoint("sp1"), "SAVEPOINT sp1")
+		is.Equal(d.RollbackToSavepoint("sp1"), "ROLLBACK TO SAVEPOINT sp1")
+		is.Equal(d.ReleaseSavepoint("sp1"), "RELEASE SAVEPOINT sp1")
+		is.Equal(d.Limit(10, 0), "LIMIT 10")
+		is.Equal(d.Limit(10, 5), "LIMIT 10 OFFSET 5")
+		is.Equal(d.Limit(-1, 0), "")
+		is.True(d.MaxParams() > 0)
+	}
+	is.Equal(pg.Limit(-1, 5), "OFFSET 5")
+	is.Equal(my.Limit(-1, 5), "LIMIT 18446744073709551615 OFFSET 5")
+	is.Equal(lite.Limit(-1, 5), "LIMIT -1 OFFSET 5")
+
+	ms := DialectFor(SQLServerDBType)
+	is.Equal(ms.Placeholder(2), "@p2")
+	is.Equal(ms.QuoteIdent("dbo.my]table"), "[dbo].[my]]table]")
+	is.Equal(ms.OnConflict([]string{"id"}, nil), "")
+	is.Equal(ms.Savepoint("sp1"), "SAVE TRANSACTION sp1")
+	is.Equal(ms.RollbackToSavepoint("sp1"), "ROLLBACK TRANSACTION sp1")
+	is.Equal(ms.ReleaseSavepoint("sp1"), "")
+	is.Equal(ms.Limit(10, 0), "OFFSET 0 ROWS FETCH NEXT 10 ROWS ONLY")
+	is.Equal(ms.Limit(-1, 5), "OFFSET 5 ROWS")
+	is.Equal(ms.Limit(-1, 0), "")
+	is.Equal(ms.MaxParams(), 2100)
+	ch := DialectFor(ClickHouseDBType)
+	is.Equal(ch.Placeholder(2), "?")
+	is.Equal(ch.QuoteIdent("db.t"), "`db`.`t`")
+	is.Equal(ch.OnConflict([]string{"id"}, nil), "")
+	is.Equal(ch.Limit(-1, 5), "OFFSET 5")
+	is.Equal(ch.Savepoint("sp1"), "SAVEPOINT sp1")
+	is.Equal(ch.RollbackToSavepoint("sp1"), "ROLLBACK TO SAVEPOINT sp1")
+	is.Equal(ch.ReleaseSavepoint("sp1"), "RELEASE SAVEPOINT sp1")
+	is.True(ch.MaxParams() > 0)
+	for _, d := range []Dialect{ms, ch} {
+		is.Equal(d.ClassifyError(stateErr("23505")), ErrorKindUniqueViolation)
+	}
+
+	for code, kind := range map[string]ErrorKind{
+		"23505": ErrorKindUniqueViolation,
+		"23503": ErrorKindForeignKeyViolation,
+		"23502": ErrorKindNotNullViolation,
+		"23514": ErrorKindCheckViolation,
+		"40001": ErrorKindSerializationFailure,
+		"40P01": ErrorKindDeadlock,
+		"08006": ErrorKindConnection,
+		"57P01": ErrorKindConnection,
+		"42601": ErrorKindUnknown,
+	} {
+		is.Equal(pg.ClassifyError(fmt.Errorf("wrapped: %w", stateErr(code))), kind)
+	}
+	is.Equal(pg.ClassifyError(driver.ErrBadConn), ErrorKindConnection)
+	is.Equal(my.ClassifyError(io.ErrUnexpectedEOF), ErrorKindConnection)
+	is.Equal(lite.ClassifyError(sql.ErrNoRows), ErrorKindUnknown)
+}
+
+type testDialect struct{ sqliteDialect }
+
+func (testDialect) Type() Type { return "test" }
+
+func TestDialectOf(t *testing.T) {
+	is := is.New(t)
+	RegisterDialect(testDialect{})
+	is.Equal(DialectFor("test"), testDialect{})
+
+	pool, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer pool.Close()
+	is.Equal(DialectOf(pool).Type(), PostgresDBType)
+	is.Equal(DialectOf(New(pool)).Type(), PostgresDBType)
+	d := New(pool, WithDialect(DialectFor(SQLiteDBType)))
+	is.Equal(DialectOf(d).Type(), SQLiteDBType)
+	tx, err := d.BeginTx(context.Background(), nil)
+	is.NoErr(err)
+	defer tx.Rollback()
+	is.Equal(DialectOf(tx).Type(), SQLiteDBType)
+	is.Equal(DialectOf(NewTx(nil)).Type(), PostgresDBType)
+}
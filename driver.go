@@ -0,0 +1,102 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// driverImports maps each database [Type] to the import path of the driver
+// that is expected to provide it.
+var driverImports = map[Type]string{
+	PostgresDBType:   "github.com/harrybrwn/db/postgres",
+	MySQLDBType:      "github.com/harrybrwn/db/mysql",
+	SQLiteDBType:     "github.com/harrybrwn/db/sqlite",
+	SQLServerDBType:  "github.com/microsoft/go-mssqldb",
+	ClickHouseDBType: "github.com/ClickHouse/clickhouse-go/v2",
+	CockroachDBType:  "github.com/harrybrwn/db/postgres",
+}
+
+// DriverName returns the name of the [database/sql] driver used for the
+// database type.
+func (t Type) DriverName() string {
+	if t == CockroachDBType {
+		return string(PostgresDBType)
+	}
+	return string(t)
+}
+
+// Drivers returns a sorted list of the [database/sql] drivers registered in
+// this program.
+func Drivers() []string { return sql.Drivers() }
+
+// ErrDriverNotRegistered is returned when there is no driver registered for a
+// database [Type].
+var ErrDriverNotRegistered = errors.New("database driver not registered")
+
+// checkDriver returns an error describing which import is missing if the
+// driver for the type has not been registered.
+func checkDriver(t Type) error {
+	name := t.DriverName()
+	drivers := Drivers()
+	if slices.Contains(drivers, name) {
+		return nil
+	}
+	var hint string
+	if pkg, ok := driverImports[t]; ok {
+		hint = fmt.Sprintf(" (add `import _ %q`)", pkg)
+	}
+	return fmt.Errorf(
+		"%w: %q for database type %q%s, registered drivers: [%s]",
+		ErrDriverNotRegistered, name, t, hint, strings.Join(drivers, ", "),
+	)
+}
+
+// Opener opens a database for a config. It is used for databases that are
+// not reached through a [database/sql] driver, or to replace the driver
+// that would be used, see [RegisterOpener].
+type Opener func(Config) (DB, error)
+
+var (
+	openersMu sync.RWMutex
+	openers   = map[Type]Opener{}
+)
+
+// RegisterOpener sets the func used by [Config.OpenDB] to open databases of
+// the given type. This lets drivers like pgx or proxies be plugged in
+// without using the [database/sql] driver registered for the type. A nil
+// func removes the opener.
+func RegisterOpener(t Type, fn Opener) {
+	openersMu.Lock()
+	defer openersMu.Unlock()
+	if fn == nil {
+		delete(openers, t)
+		return
+	}
+	openers[t] = fn
+}
+
+func openerFor(t Type) (Opener, bool) {
+	openersMu.RLock()
+	defer openersMu.RUnlock()
+	fn, ok := openers[t]
+	return fn, ok
+}
+
+// OpenDB opens the database with the [Opener] registered for the config's
+// type. Without one it opens a connection pool with [Config.Open] and wraps
+// it with [New] using the config's [Dialect].
+func (db *Config) OpenDB() (DB, error) {
+	if fn, ok := openerFor(db.Type); ok {
+		return fn(*db)
+	}
+	pool, err := db.Open()
+	if err != nil {
+		return nil, err
+	}
+	return New(pool, WithDialect(db.Dialect())), nil
+}
@@ -0,0 +1,21 @@
+//go:build !nopq
+
+package db
+
+// The lib/pq driver is registered by default so that [PostgresDBType] works
+// out of the box. Build with the "nopq" tag to leave it out of the binary and
+// import github.com/harrybrwn/db/postgres or another driver yourself.
+import (
+	"database/sql/driver"
+
+	"github.com/harrybrwn/db/internal/pqdial"
+)
+
+func init() {
+	RegisterDialConnector(PostgresDBType, pqDialConnector)
+	RegisterDialConnector(CockroachDBType, pqDialConnector)
+}
+
+func pqDialConnector(dsn string, dial DialFunc) (driver.Connector, error) {
+	return pqdial.Connector(dsn, dial)
+}
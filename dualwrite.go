@@ -0,0 +1,285 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	stderrors "errors"
+	"math/rand/v2"
+	"reflect"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// DualWriteMode sets how a [DualWriter] handles shadow database errors.
+type DualWriteMode uint8
+
+const (
+	// DualWriteBestEffort reports shadow errors to the error handler and
+	// never fails a statement because of the shadow database.
+	DualWriteBestEffort DualWriteMode = iota
+	// DualWriteStrict returns shadow errors to the caller.
+	DualWriteStrict
+)
+
+// ReadMismatch is a sampled read that returned different results from the
+// primary and shadow databases.
+type ReadMismatch struct {
+	Query string
+	// Primary and Shadow are the results of the query, Shadow is nil if
+	// the query failed on the shadow database.
+	Primary, Shadow *Rowset
+	// Err is the shadow database's error.
+	Err error
+}
+
+// DualWriterOpt is an option for [NewDualWriter].
+type DualWriterOpt func(*DualWriter)
+
+// WithDualWriteMode sets the [DualWriteMode]. Defaults to
+// [DualWriteBestEffort].
+func WithDualWriteMode(m DualWriteMode) DualWriterOpt {
+	return func(d *DualWriter) { d.mode = m }
+}
+
+// WithShadowErrorHandler sets a function that is called with the errors of
+// the shadow database in best effort mode.
+func WithShadowErrorHandler(fn func(ctx context.Context, query string, err error)) DualWriterOpt {
+	return func(d *DualWriter) { d.onError = fn }
+}
+
+// WithReadComparison runs a fraction of the reads made outside of a
+// transaction on the shadow database as well and calls fn when the results
+// differ. The rate is between 0 and 1. Both results are read into memory
+// before the primary's rows are returned so it should be kept low for large
+// queries.
+func WithReadComparison(rate float64, fn func(ctx context.Context, m ReadMismatch)) DualWriterOpt {
+	return func(d *DualWriter) { d.compareRate, d.onMismatch = rate, fn }
+}
+
+// DualWriter is a [DB] that sends writes to a primary and a shadow database
+// and reads from the primary. It is used to migrate to a new database
+// without downtime: writes are mirrored to the new database while the data
+// is copied, reads can be compared with [WithReadComparison] and then the
+// shadow becomes the primary.
+//
+// Statements are run on the primary first and only run on the shadow if
+// they succeed. Transactions are committed on the primary and then on the
+// shadow which is not atomic, a failed shadow commit leaves the databases
+// out of sync until they are reconciled, i.e. with [CompareTable].
+type DualWriter struct {
+	primary, shadow DB
+	mode            DualWriteMode
+	onError         func(ctx context.Context, query string, err error)
+	compareRate     float64
+	onMismatch      func(ctx context.Context, m ReadMismatch)
+	shadowErrors    atomic.Uint64
+}
+
+var _ DB = (*DualWriter)(nil)
+
+// NewDualWriter creates a [DualWriter]. Closing it closes both databases.
+func NewDualWriter(primary, shadow DB, opts ...DualWriterOpt) *DualWriter {
+	d := &DualWriter{primary: primary, shadow: shadow}
+	for _, o := range opts {
+		o(d)
+	}
+	return d
+}
+
+// Dialect returns the primary database's [Dialect].
+func (d *DualWriter) Dialect() Dialect { return DialectOf(d.primary) }
+
+// ShadowErrors returns the number of statements that failed on the shadow
+// database.
+func (d *DualWriter) ShadowErrors() uint64 { return d.shadowErrors.Load() }
+
+// Close closes both databases.
+func (d *DualWriter) Close() error {
+	return stderrors.Join(d.primary.Close(), d.shadow.Close())
+}
+
+// QueryContext runs reads on the primary. Queries that write, like an
+// INSERT with a RETURNING clause, are also run on the shadow.
+func (d *DualWriter) QueryContext(ctx context.Context, query string, args ...any) (Rows, error) {
+	if classifyStatement(query) != stmtRead {
+		rows, err := d.primary.QueryContext(ctx, query, args...)
+		if err != nil {
+			return nil, err
+		}
+		_, err = d.shadow.ExecContext(ctx, query, args...)
+		if err = d.shadowErr(ctx, query, err); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		return rows, nil
+	}
+	if d.onMismatch == nil || d.compareRate <= 0 || rand.Float64() >= d.compareRate {
+		return d.primary.QueryContext(ctx, query, args...)
+	}
+	return d.compareRead(ctx, query, args)
+}
+
+// ExecContext runs the statement on the primary and then the shadow.
+func (d *DualWriter) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	res, err := d.primary.ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	_, err = d.shadow.ExecContext(ctx, query, args...)
+	if err = d.shadowErr(ctx, query, err); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// BeginTx begins a transaction on both databases.
+func (d *DualWriter) BeginTx(ctx context.Context, opts *sql.TxOptions) (Tx, error) {
+	primary, err := d.primary.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	shadow, err := d.shadow.BeginTx(ctx, opts)
+	if err = d.shadowErr(ctx, "BEGIN", err); err != nil {
+		primary.Rollback()
+		return nil, err
+	}
+	return &dualTx{d: d, primary: primary, shadow: shadow}, nil
+}
+
+// shadowErr handles an error from the shadow database. It returns the error
+// in strict mode and reports it otherwise.
+func (d *DualWriter) shadowErr(ctx context.Context, query string, err error) error {
+	if err == nil {
+		return nil
+	}
+	d.shadowErrors.Add(1)
+	if d.mode == DualWriteStrict {
+		return errors.WithMessage(err, "shadow database")
+	}
+	if d.onError != nil {
+		d.onError(ctx, query, err)
+	}
+	return nil
+}
+
+func (d *DualWriter) compareRead(ctx context.Context, query string, args []any) (Rows, error) {
+	rows, err := d.primary.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	primary, err := Materialize(rows)
+	if err != nil {
+		return nil, err
+	}
+	m := ReadMismatch{Query: query, Primary: primary.Clone()}
+	rows, err = d.shadow.QueryContext(ctx, query, args...)
+	if err == nil {
+		m.Shadow, err = Materialize(rows)
+	}
+	if err != nil {
+		m.Shadow, m.Err = nil, err
+		d.onMismatch(ctx, m)
+	} else if !sameRows(primary, m.Shadow) {
+		d.onMismatch(ctx, m)
+	}
+	return primary, nil
+}
+
+// sameRows compares two result sets. Text is compared the same whether the
+// driver returned it as a string or bytes.
+func sameRows(a, b *Rowset) bool {
+	if !reflect.DeepEqual(a.cols, b.cols) || len(a.vals) != len(b.vals) {
+		return false
+	}
+	for i, row := range a.vals {
+		if len(row) != len(b.vals[i]) {
+			return false
+		}
+		for j, v := range row {
+			if !reflect.DeepEqual(normalizeValue(v), normalizeValue(b.vals[i][j])) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func normalizeValue(v any) any {
+	if b, ok := v.([]byte); ok {
+		return string(b)
+	}
+	return v
+}
+
+// dualTx is a transaction on both databases of a [DualWriter]. In best
+// effort mode the shadow transaction is dropped after its first error.
+type dualTx struct {
+	d       *DualWriter
+	primary Tx
+	shadow  Tx
+}
+
+func (tx *dualTx) Close() error { return nil }
+
+func (tx *dualTx) BeginTx(context.Context, *sql.TxOptions) (Tx, error) { return tx, nil }
+
+func (tx *dualTx) Dialect() Dialect { return tx.d.Dialect() }
+
+func (tx *dualTx) QueryContext(ctx context.Context, query string, args ...any) (Rows, error) {
+	rows, err := tx.primary.QueryContext(ctx, query, args...)
+	if err != nil || classifyStatement(query) == stmtRead {
+		return rows, err
+	}
+	if err = tx.shadowExec(ctx, query, args); err != nil {
+		rows.Close()
+		return nil, err
+	}
+	return rows, nil
+}
+
+func (tx *dualTx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	res, err := tx.primary.ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	if err = tx.shadowExec(ctx, query, args); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (tx *dualTx) shadowExec(ctx context.Context, query string, args []any) error {
+	if tx.shadow == nil {
+		return nil
+	}
+	_, err := tx.shadow.ExecContext(ctx, query, args...)
+	if err != nil && tx.d.mode != DualWriteStrict {
+		tx.shadow.Rollback()
+		tx.shadow = nil
+	}
+	return tx.d.shadowErr(ctx, query, err)
+}
+
+// Commit commits the primary transaction and then the shadow transaction.
+func (tx *dualTx) Commit() error {
+	if err := tx.primary.Commit(); err != nil {
+		if tx.shadow != nil {
+			tx.shadow.Rollback()
+		}
+		return err
+	}
+	if tx.shadow == nil {
+		return nil
+	}
+	return tx.d.shadowErr(context.Background(), "COMMIT", tx.shadow.Commit())
+}
+
+// Rollback rolls back both transactions.
+func (tx *dualTx) Rollback() error {
+	err := tx.primary.Rollback()
+	if tx.shadow == nil {
+		return err
+	}
+	return stderrors.Join(err, tx.d.shadowErr(context.Background(), "ROLLBACK", tx.shadow.Rollback()))
+}
@@ -0,0 +1,147 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func newDualWriterDBs(t *testing.T) (primary, shadow DB) {
+	t.Helper()
+	ctx := context.Background()
+	var dbs [2]DB
+	for i := range dbs {
+		pool, err := sql.Open("sqlite3", ":memory:")
+		if err != nil {
+			t.Fatal(err)
+		}
+		pool.SetMaxOpenConns(1)
+		dbs[i] = New(pool)
+		if _, err = dbs[i].ExecContext(ctx, "CREATE TABLE t (id int PRIMARY KEY, name text)"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dbs[0], dbs[1]
+}
+
+func countRows(t *testing.T, d DB) (n int) {
+	t.Helper()
+	rows, err := d.QueryContext(context.Background(), "SELECT count(*) FROM t")
+	if err == nil {
+		err = ScanOne(rows, &n)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestDualWriter(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	primary, shadow := newDualWriterDBs(t)
+	var shadowErrs []string
+	d := NewDualWriter(primary, shadow, WithShadowErrorHandler(func(_ context.Context, query string, err error) {
+		shadowErrs = append(shadowErrs, query)
+	}))
+	defer d.Close()
+
+	_, err := d.ExecContext(ctx, "INSERT INTO t VALUES (1, 'a')")
+	is.NoErr(err)
+	rows, err := d.QueryContext(ctx, "INSERT INTO t VALUES (2, 'b') RETURNING id")
+	is.NoErr(err)
+	var id int
+	is.NoErr(ScanOne(rows, &id))
+	is.Equal(id, 2)
+	is.Equal(countRows(t, primary), 2)
+	is.Equal(countRows(t, shadow), 2)
+
+	// A shadow failure is reported and doesn't fail the write.
+	_, err = shadow.ExecContext(ctx, "INSERT INTO t VALUES (3, 'x')")
+	is.NoErr(err)
+	_, err = d.ExecContext(ctx, "INSERT INTO t VALUES (3, 'c')")
+	is.NoErr(err)
+	is.Equal(shadowErrs, []string{"INSERT INTO t VALUES (3, 'c')"})
+	is.Equal(d.ShadowErrors(), uint64(1))
+
+	tx, err := d.BeginTx(ctx, nil)
+	is.NoErr(err)
+	_, err = tx.ExecContext(ctx, "INSERT INTO t VALUES (4, 'd')")
+	is.NoErr(err)
+	is.NoErr(tx.Commit())
+	is.Equal(countRows(t, primary), 4)
+	is.Equal(countRows(t, shadow), 4)
+
+	tx, err = d.BeginTx(ctx, nil)
+	is.NoErr(err)
+	_, err = tx.ExecContext(ctx, "INSERT INTO t VALUES (5, 'e')")
+	is.NoErr(err)
+	is.NoErr(tx.Rollback())
+	is.Equal(countRows(t, primary), 4)
+	is.Equal(countRows(t, shadow), 4)
+}
+
+func TestDualWriter_Strict(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	primary, shadow := newDualWriterDBs(t)
+	d := NewDualWriter(primary, shadow, WithDualWriteMode(DualWriteStrict))
+	defer d.Close()
+
+	_, err := shadow.ExecContext(ctx, "INSERT INTO t VALUES (1, 'x')")
+	is.NoErr(err)
+	_, err = d.ExecContext(ctx, "INSERT INTO t VALUES (1, 'a')")
+	is.True(err != nil)
+
+	tx, err := d.BeginTx(ctx, nil)
+	is.NoErr(err)
+	_, err = tx.ExecContext(ctx, "INSERT INTO t VALUES (2, 'b')")
+	is.NoErr(err)
+	_, err = tx.ExecContext(ctx, "INSERT INTO t VALUES (1, 'a')")
+	is.True(err != nil)
+	is.NoErr(tx.Rollback())
+	is.Equal(countRows(t, primary), 1)
+	is.Equal(countRows(t, shadow), 1)
+}
+
+func TestDualWriter_ReadComparison(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	primary, shadow := newDualWriterDBs(t)
+	var mismatches []ReadMismatch
+	d := NewDualWriter(primary, shadow, WithReadComparison(1, func(_ context.Context, m ReadMismatch) {
+		mismatches = append(mismatches, m)
+	}))
+	defer d.Close()
+
+	_, err := d.ExecContext(ctx, "INSERT INTO t VALUES (1, 'a'), (2, 'b')")
+	is.NoErr(err)
+	rows, err := d.QueryContext(ctx, "SELECT name FROM t ORDER BY id")
+	is.NoErr(err)
+	names, err := Materialize(rows)
+	is.NoErr(err)
+	is.Equal(names.Len(), 2)
+	is.Equal(len(mismatches), 0)
+
+	_, err = shadow.ExecContext(ctx, "UPDATE t SET name = 'x' WHERE id = 2")
+	is.NoErr(err)
+	rows, err = d.QueryContext(ctx, "SELECT name FROM t ORDER BY id")
+	is.NoErr(err)
+	var name string
+	is.True(rows.Next())
+	is.True(rows.Next())
+	is.NoErr(rows.Scan(&name))
+	is.Equal(name, "b") // reads come from the primary
+	is.Equal(len(mismatches), 1)
+	is.Equal(mismatches[0].Query, "SELECT name FROM t ORDER BY id")
+	is.Equal(mismatches[0].Shadow.Len(), 2)
+
+	_, err = shadow.ExecContext(ctx, "DROP TABLE t")
+	is.NoErr(err)
+	_, err = d.QueryContext(ctx, "SELECT name FROM t")
+	is.NoErr(err)
+	is.Equal(len(mismatches), 2)
+	is.True(mismatches[1].Err != nil)
+}
@@ -0,0 +1,246 @@
+package db
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DumpFormat is the output format of [Dump].
+type DumpFormat int
+
+const (
+	// DumpInsert writes one INSERT statement per row.
+	DumpInsert DumpFormat = iota
+	// DumpCSV writes a header row with the column names followed by the
+	// rows of each table. NULL is written as an empty field.
+	DumpCSV
+)
+
+// Transform changes a column value before it is dumped, i.e. to hide
+// personal information. The value is a driver value and nil for NULL.
+type Transform func(v any) (any, error)
+
+// NullTransform replaces every value with NULL.
+func NullTransform(any) (any, error) { return nil, nil }
+
+// HashTransform replaces values with the hex encoded sha256 of the salt and
+// the value so they stay unique and can still be joined on. NULL is kept.
+func HashTransform(salt string) Transform {
+	return func(v any) (any, error) {
+		if v == nil {
+			return nil, nil
+		}
+		h := sha256.New()
+		h.Write([]byte(salt))
+		switch v := v.(type) {
+		case []byte:
+			h.Write(v)
+		default:
+			fmt.Fprint(h, v)
+		}
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+}
+
+// DumpOpt is an option for [Dump].
+type DumpOpt func(*dumpOpts)
+
+type dumpOpts struct {
+	format     DumpFormat
+	tables     []string
+	transforms map[string]Transform
+}
+
+// WithDumpFormat sets the output format. Defaults to [DumpInsert].
+func WithDumpFormat(f DumpFormat) DumpOpt { return func(o *dumpOpts) { o.format = f } }
+
+// WithDumpTables sets the tables to dump in order. Defaults to every table
+// found by [Tables].
+func WithDumpTables(tables ...string) DumpOpt {
+	return func(o *dumpOpts) { o.tables = append(o.tables, tables...) }
+}
+
+// WithColumnTransform transforms a column's values. An empty table applies
+// the transform to the column in every table.
+func WithColumnTransform(table, column string, t Transform) DumpOpt {
+	return func(o *dumpOpts) { o.transforms[table+"."+column] = t }
+}
+
+// Dump streams the rows of tables to w as INSERT statements or CSV. Rows
+// are read one at a time so large tables are not held in memory.
+func Dump(ctx context.Context, db DB, w io.Writer, opts ...DumpOpt) error {
+	o := dumpOpts{transforms: make(map[string]Transform)}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if len(o.tables) == 0 {
+		tables, err := Tables(ctx, db)
+		if err != nil {
+			return err
+		}
+		for _, t := range tables {
+			o.tables = append(o.tables, t.Name)
+		}
+	}
+	bw := bufio.NewWriter(w)
+	d := DialectOf(db)
+	for _, table := range o.tables {
+		if err := dumpTable(ctx, db, d, bw, table, &o); err != nil {
+			return errors.Wrapf(err, "failed to dump %q", table)
+		}
+	}
+	return bw.Flush()
+}
+
+func dumpTable(ctx context.Context, db DB, d Dialect, w *bufio.Writer, table string, o *dumpOpts) error {
+	rows, err := db.QueryContext(ctx, "SELECT * FROM "+d.QuoteIdent(table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	c, ok := rows.(columner)
+	if !ok {
+		return errors.New("rows do not have column names")
+	}
+	cols, err := c.Columns()
+	if err != nil {
+		return err
+	}
+	transforms := make([]Transform, len(cols))
+	for i, col := range cols {
+		if t, ok := o.transforms[table+"."+col]; ok {
+			transforms[i] = t
+		} else {
+			transforms[i] = o.transforms["."+col]
+		}
+	}
+
+	var (
+		cw     *csv.Writer
+		prefix string
+		record = make([]string, len(cols))
+	)
+	switch o.format {
+	case DumpCSV:
+		cw = csv.NewWriter(w)
+		if err = cw.Write(cols); err != nil {
+			return err
+		}
+	case DumpInsert:
+		quoted := make([]string, len(cols))
+		for i, col := range cols {
+			quoted[i] = d.QuoteIdent(col)
+		}
+		prefix = "INSERT INTO " + d.QuoteIdent(table) + " (" + strings.Join(quoted, ", ") + ") VALUES ("
+	default:
+		return errors.Errorf("unknown dump format %d", o.format)
+	}
+
+	vals := make([]any, len(cols))
+	dest := make([]any, len(cols))
+	for i := range vals {
+		dest[i] = &vals[i]
+	}
+	for rows.Next() {
+		if err = rows.Scan(dest...); err != nil {
+			return err
+		}
+		for i, t := range transforms {
+			if t == nil {
+				continue
+			}
+			if vals[i], err = t(vals[i]); err != nil {
+				return errors.Wrapf(err, "failed to transform %q", cols[i])
+			}
+		}
+		if cw != nil {
+			for i, v := range vals {
+				record[i] = csvValue(v)
+			}
+			if err = cw.Write(record); err != nil {
+				return err
+			}
+			continue
+		}
+		w.WriteString(prefix)
+		for i, v := range vals {
+			if i > 0 {
+				w.WriteString(", ")
+			}
+			w.WriteString(sqlLiteral(d.Type(), v))
+		}
+		if _, err = w.WriteString(");\n"); err != nil {
+			return err
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	if cw != nil {
+		cw.Flush()
+		if err = cw.Error(); err != nil {
+			return err
+		}
+	}
+	return rows.Close()
+}
+
+func csvValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	}
+	return fmt.Sprint(v)
+}
+
+// sqlLiteral formats a driver value as an SQL literal.
+func sqlLiteral(t Type, v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case bool:
+		if t == SQLiteDBType {
+			if v {
+				return "1"
+			}
+			return "0"
+		}
+		return strings.ToUpper(strconv.FormatBool(v))
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case time.Time:
+		return "'" + v.Format("2006-01-02 15:04:05.999999999Z07:00") + "'"
+	case []byte:
+		if t.postgresWire() {
+			return `'\x` + hex.EncodeToString(v) + "'"
+		}
+		return "X'" + hex.EncodeToString(v) + "'"
+	case string:
+		return quoteString(t, v)
+	}
+	return quoteString(t, fmt.Sprint(v))
+}
+
+func quoteString(t Type, s string) string {
+	s = strings.ReplaceAll(s, "'", "''")
+	if t == MySQLDBType {
+		s = strings.ReplaceAll(s, `\`, `\\`)
+	}
+	return "'" + s + "'"
+}
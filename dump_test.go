@@ -0,0 +1,76 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/pkg/errors"
+)
+
+func TestDump(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	pool, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer pool.Close()
+	pool.SetMaxOpenConns(1)
+	d := New(pool, WithDialect(DialectFor(SQLiteDBType)))
+	is.NoErr(ExecScript(ctx, d, `
+CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT, phone TEXT, score REAL, key BLOB);
+INSERT INTO users VALUES (1, 'a@b.c', '555', 1.5, X'beef'), (2, 'o''neil@x.y', NULL, NULL, NULL);
+CREATE TABLE notes (id INTEGER PRIMARY KEY, email TEXT);
+INSERT INTO notes VALUES (1, 'a@b.c');`))
+
+	hash, _ := HashTransform("salt")("a@b.c")
+	var b bytes.Buffer
+	err = Dump(ctx, d, &b,
+		WithColumnTransform("", "email", HashTransform("salt")),
+		WithColumnTransform("users", "phone", NullTransform),
+	)
+	is.NoErr(err)
+	is.Equal(b.String(), `INSERT INTO "notes" ("id", "email") VALUES (1, '`+hash.(string)+`');
+INSERT INTO "users" ("id", "email", "phone", "score", "key") VALUES (1, '`+hash.(string)+`', NULL, 1.5, X'beef');
+INSERT INTO "users" ("id", "email", "phone", "score", "key") VALUES (2, '`+must(HashTransform("salt")("o'neil@x.y")).(string)+`', NULL, NULL, NULL);
+`)
+
+	b.Reset()
+	err = Dump(ctx, d, &b, WithDumpFormat(DumpCSV), WithDumpTables("users"))
+	is.NoErr(err)
+	is.Equal(b.String(), "id,email,phone,score,key\n1,a@b.c,555,1.5,\xbe\xef\n2,o'neil@x.y,,,\n")
+
+	fail := func(any) (any, error) { return nil, errors.New("nope") }
+	is.True(Dump(ctx, d, &b, WithColumnTransform("users", "id", fail)) != nil)
+	is.True(Dump(ctx, d, &b, WithDumpTables("missing")) != nil)
+	is.True(Dump(ctx, d, &b, WithDumpFormat(DumpFormat(9))) != nil)
+	is.True(Dump(ctx, New(pool, WithDialect(DialectFor(ClickHouseDBType))), &b) != nil)
+}
+
+func TestSQLLiteral(t *testing.T) {
+	is := is.New(t)
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	is.Equal(sqlLiteral(PostgresDBType, true), "TRUE")
+	is.Equal(sqlLiteral(SQLiteDBType, false), "0")
+	is.Equal(sqlLiteral(SQLiteDBType, true), "1")
+	is.Equal(sqlLiteral(PostgresDBType, []byte{1}), `'\x01'`)
+	is.Equal(sqlLiteral(MySQLDBType, `a\'b`), `'a\\''b'`)
+	is.Equal(sqlLiteral(PostgresDBType, ts), "'2024-01-02 03:04:05Z'")
+	is.Equal(sqlLiteral(PostgresDBType, 3), "'3'")
+	is.Equal(csvValue(ts), "2024-01-02T03:04:05Z")
+	v, err := HashTransform("")(nil)
+	is.NoErr(err)
+	is.Equal(v, nil)
+	v, err = HashTransform("")([]byte("x"))
+	is.NoErr(err)
+	is.Equal(v, must(HashTransform("")("x")))
+}
+
+func must[T any](v T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
@@ -0,0 +1,202 @@
+package db
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql/driver"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNoCipher is returned when an encrypted value is used before a
+// [Cipher] was set with [SetCipher].
+var ErrNoCipher = errors.New("no cipher set for encrypted columns")
+
+// Cipher encrypts and decrypts column values.
+type Cipher interface {
+	Encrypt(plaintext []byte) ([]byte, error)
+	Decrypt(ciphertext []byte) ([]byte, error)
+}
+
+var (
+	cipherMu     sync.RWMutex
+	columnCipher Cipher
+)
+
+// SetCipher sets the [Cipher] used by [EncryptedString] and
+// [EncryptedBytes].
+func SetCipher(c Cipher) {
+	cipherMu.Lock()
+	columnCipher = c
+	cipherMu.Unlock()
+}
+
+func getCipher() (Cipher, error) {
+	cipherMu.RLock()
+	c := columnCipher
+	cipherMu.RUnlock()
+	if c == nil {
+		return nil, ErrNoCipher
+	}
+	return c, nil
+}
+
+// EncryptedString is a string that is encrypted with the [Cipher] set by
+// [SetCipher] when it is written and decrypted when it is scanned. The
+// column has to hold binary data, i.e. bytea or blob. NULL is scanned as an
+// empty string.
+type EncryptedString string
+
+// Value implements [driver.Valuer].
+func (s EncryptedString) Value() (driver.Value, error) { return encrypt([]byte(s)) }
+
+// Scan implements [database/sql.Scanner].
+func (s *EncryptedString) Scan(src any) error {
+	b, err := decrypt(src)
+	if err != nil {
+		return err
+	}
+	*s = EncryptedString(b)
+	return nil
+}
+
+// EncryptedBytes is like [EncryptedString] for binary data. NULL is scanned
+// as nil.
+type EncryptedBytes []byte
+
+// Value implements [driver.Valuer].
+func (b EncryptedBytes) Value() (driver.Value, error) {
+	if b == nil {
+		return nil, nil
+	}
+	return encrypt(b)
+}
+
+// Scan implements [database/sql.Scanner].
+func (b *EncryptedBytes) Scan(src any) error {
+	plain, err := decrypt(src)
+	if err != nil {
+		return err
+	}
+	*b = plain
+	return nil
+}
+
+func encrypt(plain []byte) (driver.Value, error) {
+	c, err := getCipher()
+	if err != nil {
+		return nil, err
+	}
+	b, err := c.Encrypt(plain)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not encrypt value")
+	}
+	return b, nil
+}
+
+func decrypt(src any) ([]byte, error) {
+	var b []byte
+	switch v := src.(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return nil, errors.Errorf("cannot decrypt %T", src)
+	}
+	c, err := getCipher()
+	if err != nil {
+		return nil, err
+	}
+	plain, err := c.Decrypt(b)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not decrypt value")
+	}
+	return plain, nil
+}
+
+// aesGCMVersion is the first byte of values encrypted by [AESGCM].
+const aesGCMVersion byte = 0x01
+
+// AESGCM is a [Cipher] using AES-GCM with named keys. Values are encrypted
+// with the current key and store the key's id so that keys can be rotated
+// by adding a new current key and keeping the old ones until every value
+// has been re-encrypted.
+type AESGCM struct {
+	current string
+	keys    map[string]cipher.AEAD
+}
+
+var _ Cipher = (*AESGCM)(nil)
+
+// NewAESGCM creates an [AESGCM] cipher from keys by id. Keys must be 16, 24
+// or 32 bytes long and ids at most 255 bytes long. New values are encrypted
+// with the current key.
+func NewAESGCM(current string, keys map[string][]byte) (*AESGCM, error) {
+	if _, ok := keys[current]; !ok {
+		return nil, errors.Errorf("no key for the current key id %q", current)
+	}
+	c := &AESGCM{current: current, keys: make(map[string]cipher.AEAD, len(keys))}
+	for id, key := range keys {
+		if len(id) > 255 {
+			return nil, errors.Errorf("key id %q is too long", id)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid key %q", id)
+		}
+		if c.keys[id], err = cipher.NewGCM(block); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// Encrypt encrypts with the current key. The result is a version byte, the
+// key id's length and the key id, followed by the nonce and the sealed
+// data. The header is authenticated along with the data.
+func (c *AESGCM) Encrypt(plaintext []byte) ([]byte, error) {
+	aead := c.keys[c.current]
+	header := append([]byte{aesGCMVersion, byte(len(c.current))}, c.current...)
+	out := make([]byte, len(header)+aead.NonceSize(), len(header)+aead.NonceSize()+len(plaintext)+aead.Overhead())
+	copy(out, header)
+	if _, err := rand.Read(out[len(header):]); err != nil {
+		return nil, err
+	}
+	return aead.Seal(out, out[len(header):], plaintext, header), nil
+}
+
+// Decrypt decrypts a value encrypted with any of the keys.
+func (c *AESGCM) Decrypt(ciphertext []byte) ([]byte, error) {
+	id, err := c.KeyID(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	aead, ok := c.keys[id]
+	if !ok {
+		return nil, errors.Errorf("unknown key id %q", id)
+	}
+	header := ciphertext[:2+len(id)]
+	rest := ciphertext[len(header):]
+	if len(rest) < aead.NonceSize() {
+		return nil, errors.New("ciphertext is too short")
+	}
+	return aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], header)
+}
+
+// KeyID returns the id of the key a value was encrypted with. Values not
+// encrypted with the current key can be found this way and re-encrypted.
+func (c *AESGCM) KeyID(ciphertext []byte) (string, error) {
+	if len(ciphertext) < 2 || ciphertext[0] != aesGCMVersion {
+		return "", errors.New("not an AES-GCM value")
+	}
+	n := int(ciphertext[1])
+	if len(ciphertext) < 2+n {
+		return "", errors.New("ciphertext is too short")
+	}
+	return string(ciphertext[2 : 2+n]), nil
+}
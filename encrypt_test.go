@@ -0,0 +1,99 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestAESGCM(t *testing.T) {
+	is := is.New(t)
+	k1, k2 := bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 16)
+	old, err := NewAESGCM("k1", map[string][]byte{"k1": k1})
+	is.NoErr(err)
+	c, err := NewAESGCM("k2", map[string][]byte{"k1": k1, "k2": k2})
+	is.NoErr(err)
+
+	b, err := old.Encrypt([]byte("secret"))
+	is.NoErr(err)
+	is.True(!bytes.Contains(b, []byte("secret")))
+	id, err := c.KeyID(b)
+	is.NoErr(err)
+	is.Equal(id, "k1")
+	plain, err := c.Decrypt(b)
+	is.NoErr(err)
+	is.Equal(string(plain), "secret")
+
+	b2, err := c.Encrypt([]byte("secret"))
+	is.NoErr(err)
+	id, _ = c.KeyID(b2)
+	is.Equal(id, "k2")
+	_, err = old.Decrypt(b2)
+	is.True(err != nil) // unknown key
+
+	tampered := bytes.Clone(b)
+	tampered[len(tampered)-1] ^= 1
+	_, err = c.Decrypt(tampered)
+	is.True(err != nil)
+	// The key id is authenticated.
+	swapped := append([]byte{aesGCMVersion, 2, 'k', '2'}, b[4:]...)
+	_, err = c.Decrypt(swapped)
+	is.True(err != nil)
+	for _, v := range [][]byte{nil, {9, 0}, {aesGCMVersion, 5, 'k'}, {aesGCMVersion, 2, 'k', '1', 0}} {
+		_, err = c.Decrypt(v)
+		is.True(err != nil)
+	}
+
+	_, err = NewAESGCM("missing", map[string][]byte{"k1": k1})
+	is.True(err != nil)
+	_, err = NewAESGCM("k1", map[string][]byte{"k1": {1, 2, 3}})
+	is.True(err != nil)
+	_, err = NewAESGCM("k1", map[string][]byte{"k1": k1, string(make([]byte, 256)): k1})
+	is.True(err != nil)
+}
+
+func TestEncryptedColumns(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	SetCipher(nil)
+	_, err := EncryptedString("x").Value()
+	is.Equal(err, ErrNoCipher)
+	var s EncryptedString
+	is.Equal(s.Scan([]byte{1}), ErrNoCipher)
+
+	c, err := NewAESGCM("k", map[string][]byte{"k": bytes.Repeat([]byte{7}, 32)})
+	is.NoErr(err)
+	SetCipher(c)
+	defer SetCipher(nil)
+
+	pool, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer pool.Close()
+	d := New(pool, WithDialect(DialectFor(SQLiteDBType)))
+	_, err = d.ExecContext(ctx, "CREATE TABLE users (email BLOB, ssn BLOB)")
+	is.NoErr(err)
+	_, err = d.ExecContext(ctx, "INSERT INTO users VALUES (?, ?), (NULL, ?)",
+		EncryptedString("a@b.c"), EncryptedBytes("123"), EncryptedBytes(nil))
+	is.NoErr(err)
+
+	var raw []byte
+	is.NoErr(Get(ctx, d, &raw, "SELECT email FROM users WHERE email IS NOT NULL"))
+	is.True(!bytes.Contains(raw, []byte("a@b.c")))
+
+	var u struct {
+		Email EncryptedString `db:"email"`
+		SSN   EncryptedBytes  `db:"ssn"`
+	}
+	is.NoErr(Get(ctx, d, &u, "SELECT email, ssn FROM users WHERE email IS NOT NULL"))
+	is.Equal(string(u.Email), "a@b.c")
+	is.Equal(string(u.SSN), "123")
+	is.NoErr(Get(ctx, d, &u, "SELECT email, ssn FROM users WHERE email IS NULL"))
+	is.Equal(u.Email, EncryptedString(""))
+	is.Equal(u.SSN, EncryptedBytes(nil))
+
+	is.True(s.Scan(1) != nil)
+	is.True(s.Scan("garbage") != nil)
+}
@@ -0,0 +1,204 @@
+package db
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ClassifyError returns the kind of a database error. It understands errors
+// from lib/pq, pgx, go-sql-driver/mysql and go-sqlite3 without importing any
+// of them.
+func ClassifyError(err error) ErrorKind {
+	if err == nil {
+		return ErrorKindUnknown
+	}
+	var st sqlStater
+	if errors.As(err, &st) {
+		if k := classifySQLState(st.SQLState()); k != ErrorKindUnknown {
+			return k
+		}
+	}
+	if e, ok := findDriverError(err, mysqlDriverPkg); ok {
+		if k := classifyMySQL(e); k != ErrorKindUnknown {
+			return k
+		}
+	}
+	if e, ok := findDriverError(err, sqliteDriverPkg); ok {
+		if k := classifySQLite(e); k != ErrorKindUnknown {
+			return k
+		}
+	}
+	return classifyConnError(err)
+}
+
+// IsUniqueViolation returns true if the error was caused by a unique or
+// primary key constraint.
+func IsUniqueViolation(err error) bool { return ClassifyError(err) == ErrorKindUniqueViolation }
+
+// IsForeignKeyViolation returns true if the error was caused by a foreign key
+// constraint.
+func IsForeignKeyViolation(err error) bool {
+	return ClassifyError(err) == ErrorKindForeignKeyViolation
+}
+
+// IsNotNullViolation returns true if the error was caused by a not null
+// constraint.
+func IsNotNullViolation(err error) bool { return ClassifyError(err) == ErrorKindNotNullViolation }
+
+// IsCheckViolation returns true if the error was caused by a check
+// constraint.
+func IsCheckViolation(err error) bool { return ClassifyError(err) == ErrorKindCheckViolation }
+
+// IsSerializationFailure returns true if the transaction failed because it
+// could not be serialized with concurrent transactions and should be retried.
+func IsSerializationFailure(err error) bool {
+	return ClassifyError(err) == ErrorKindSerializationFailure
+}
+
+// IsDeadlock returns true if the error was caused by a deadlock.
+func IsDeadlock(err error) bool { return ClassifyError(err) == ErrorKindDeadlock }
+
+// IsConnectionError returns true if the error was caused by a broken or
+// refused connection.
+func IsConnectionError(err error) bool { return ClassifyError(err) == ErrorKindConnection }
+
+// ConstraintName returns the name of the constraint that caused the error or
+// an empty string if there is none.
+func ConstraintName(err error) string {
+	if err == nil {
+		return ""
+	}
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		v := structValue(e)
+		if !v.IsValid() {
+			continue
+		}
+		for _, name := range []string{"Constraint", "ConstraintName"} {
+			if f := v.FieldByName(name); f.IsValid() && f.Kind() == reflect.String && f.Len() > 0 {
+				return f.String()
+			}
+		}
+	}
+	if e, ok := findDriverError(err, mysqlDriverPkg); ok {
+		return mysqlConstraint(e.FieldByName("Message").String())
+	}
+	if _, ok := findDriverError(err, sqliteDriverPkg); ok {
+		if _, name, ok := strings.Cut(err.Error(), "constraint failed: "); ok {
+			return name
+		}
+	}
+	return ""
+}
+
+func classifySQLState(code string) ErrorKind {
+	switch {
+	case code == "23505":
+		return ErrorKindUniqueViolation
+	case code == "23503":
+		return ErrorKindForeignKeyViolation
+	case code == "23502":
+		return ErrorKindNotNullViolation
+	case code == "23514":
+		return ErrorKindCheckViolation
+	case code == "40001":
+		return ErrorKindSerializationFailure
+	case code == "40P01":
+		return ErrorKindDeadlock
+	case strings.HasPrefix(code, "08"), code == "57P01", code == "57P02", code == "57P03":
+		return ErrorKindConnection
+	}
+	return ErrorKindUnknown
+}
+
+// classifyMySQL classifies a github.com/go-sql-driver/mysql.MySQLError.
+func classifyMySQL(v reflect.Value) ErrorKind {
+	n := v.FieldByName("Number")
+	if !n.IsValid() || !n.CanUint() {
+		return ErrorKindUnknown
+	}
+	switch n.Uint() {
+	case 1062, 1586:
+		return ErrorKindUniqueViolation
+	case 1216, 1217, 1451, 1452:
+		return ErrorKindForeignKeyViolation
+	case 1048, 1364:
+		return ErrorKindNotNullViolation
+	case 3819:
+		return ErrorKindCheckViolation
+	case 1213:
+		return ErrorKindDeadlock
+	case 2006, 2013, 1053:
+		return ErrorKindConnection
+	}
+	return ErrorKindUnknown
+}
+
+// classifySQLite classifies a github.com/mattn/go-sqlite3.Error.
+func classifySQLite(v reflect.Value) ErrorKind {
+	ext := v.FieldByName("ExtendedCode")
+	if !ext.IsValid() || !ext.CanInt() {
+		return ErrorKindUnknown
+	}
+	switch ext.Int() {
+	case 2067, 1555: // SQLITE_CONSTRAINT_UNIQUE, SQLITE_CONSTRAINT_PRIMARYKEY
+		return ErrorKindUniqueViolation
+	case 787: // SQLITE_CONSTRAINT_FOREIGNKEY
+		return ErrorKindForeignKeyViolation
+	case 1299: // SQLITE_CONSTRAINT_NOTNULL
+		return ErrorKindNotNullViolation
+	case 275: // SQLITE_CONSTRAINT_CHECK
+		return ErrorKindCheckViolation
+	}
+	return ErrorKindUnknown
+}
+
+// mysqlConstraint pulls the constraint name out of a mysql error message.
+func mysqlConstraint(msg string) string {
+	if _, rest, ok := strings.Cut(msg, "CONSTRAINT `"); ok {
+		name, _, _ := strings.Cut(rest, "`")
+		return name
+	}
+	if _, rest, ok := strings.Cut(msg, "for key '"); ok {
+		name, _, _ := strings.Cut(rest, "'")
+		return name
+	}
+	if _, rest, ok := strings.Cut(msg, "Check constraint '"); ok {
+		name, _, _ := strings.Cut(rest, "'")
+		return name
+	}
+	return ""
+}
+
+// Import paths of the drivers whose error structs are read by reflection.
+const (
+	mysqlDriverPkg  = "github.com/go-sql-driver/mysql"
+	sqliteDriverPkg = "github.com/mattn/go-sqlite3"
+)
+
+// findDriverError looks through the error chain for an error struct defined
+// in the package with the import path pkg.
+func findDriverError(err error, pkg string) (reflect.Value, bool) {
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		v := structValue(e)
+		if v.IsValid() && v.Type().PkgPath() == pkg {
+			return v, true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+func structValue(err error) reflect.Value {
+	v := reflect.ValueOf(err)
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return reflect.Value{}
+	}
+	return v
+}
@@ -0,0 +1,69 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"github.com/matryer/is"
+)
+
+func TestClassifyError(t *testing.T) {
+	is := is.New(t)
+	is.Equal(ClassifyError(nil), ErrorKindUnknown)
+	is.Equal(ConstraintName(nil), "")
+
+	pqErr := &pq.Error{Code: "23505", Constraint: "users_email_key"}
+	err := fmt.Errorf("insert user: %w", pqErr)
+	is.True(IsUniqueViolation(err))
+	is.Equal(ConstraintName(err), "users_email_key")
+	is.True(IsForeignKeyViolation(&pq.Error{Code: "23503"}))
+	is.True(IsNotNullViolation(&pq.Error{Code: "23502"}))
+	is.True(IsCheckViolation(&pq.Error{Code: "23514"}))
+	is.True(IsSerializationFailure(&pq.Error{Code: "40001"}))
+	is.True(IsDeadlock(&pq.Error{Code: "40P01"}))
+	is.True(IsConnectionError(&pq.Error{Code: "08006"}))
+	is.True(!IsUniqueViolation(&pq.Error{Code: "42601"}))
+
+	myErr := &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'a@b.c' for key 'users.email'"}
+	is.True(IsUniqueViolation(fmt.Errorf("wrapped: %w", myErr)))
+	is.Equal(ConstraintName(myErr), "users.email")
+	myErr = &mysql.MySQLError{Number: 1452, Message: "Cannot add or update a child row: a foreign key constraint fails (`db`.`posts`, CONSTRAINT `posts_user_fk` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`))"}
+	is.True(IsForeignKeyViolation(myErr))
+	is.Equal(ConstraintName(myErr), "posts_user_fk")
+	myErr = &mysql.MySQLError{Number: 3819, Message: "Check constraint 'age_positive' is violated."}
+	is.True(IsCheckViolation(myErr))
+	is.Equal(ConstraintName(myErr), "age_positive")
+	is.True(IsNotNullViolation(&mysql.MySQLError{Number: 1048}))
+	is.True(IsDeadlock(&mysql.MySQLError{Number: 1213}))
+	is.True(IsConnectionError(&mysql.MySQLError{Number: 2013}))
+	is.Equal(ConstraintName(&mysql.MySQLError{Number: 1}), "")
+	is.Equal(ClassifyError(&mysql.MySQLError{Number: 1}), ErrorKindUnknown)
+
+	ctx := context.Background()
+	pool, err := sql.Open("sqlite3", ":memory:?_foreign_keys=on")
+	is.NoErr(err)
+	defer pool.Close()
+	pool.SetMaxOpenConns(1)
+	_, err = pool.ExecContext(ctx, `
+		create table users (id int primary key, email text unique, age int not null check (age > 0));
+		create table posts (user_id int references users (id));
+		insert into users values (1, 'a', 1);`)
+	is.NoErr(err)
+	_, err = pool.ExecContext(ctx, "insert into users values (2, 'a', 1)")
+	is.True(IsUniqueViolation(err))
+	is.Equal(ConstraintName(err), "users.email")
+	_, err = pool.ExecContext(ctx, "insert into users values (3, 'b', null)")
+	is.True(IsNotNullViolation(err))
+	_, err = pool.ExecContext(ctx, "insert into users values (3, 'b', -1)")
+	is.True(IsCheckViolation(err))
+	_, err = pool.ExecContext(ctx, "insert into posts values (9)")
+	is.True(IsForeignKeyViolation(err))
+	_, err = pool.ExecContext(ctx, "select * from nope")
+	is.Equal(ClassifyError(err), ErrorKindUnknown)
+	is.Equal(ConstraintName(err), "")
+	is.Equal(ConstraintName(sql.ErrNoRows), "")
+}
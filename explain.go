@@ -0,0 +1,248 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// SeqScanWarnRows is the estimated number of rows above which [Explain] adds
+// a warning for a sequential scan.
+const SeqScanWarnRows = 1000
+
+// Plan is a query plan returned by [Explain].
+type Plan struct {
+	// Root is the top node of the plan.
+	Root *PlanNode
+	// Raw is the plan as it was returned by the database. It is JSON for
+	// postgres and mysql.
+	Raw string
+	// Warnings describe the parts of the plan that are likely slow.
+	Warnings []string
+}
+
+// PlanNode is a step in a query plan.
+type PlanNode struct {
+	// Type is the kind of operation, i.e. "Seq Scan" or "Index Scan" on
+	// postgres and the access type on mysql.
+	Type string
+	// Relation is the table being read, if any.
+	Relation string
+	// Rows is the estimated number of rows. It is -1 when the database
+	// does not estimate rows.
+	Rows float64
+	// Cost is the estimated total cost, if the database reports one.
+	Cost float64
+	// SeqScan is true when the node reads every row of a table.
+	SeqScan  bool
+	Children []*PlanNode
+}
+
+// Walk calls fn for the node and each of its descendants.
+func (n *PlanNode) Walk(fn func(*PlanNode)) {
+	if n == nil {
+		return
+	}
+	fn(n)
+	for _, c := range n.Children {
+		c.Walk(fn)
+	}
+}
+
+// SeqScans returns the sequential scans estimated to read at least minRows
+// rows. Scans with unknown estimates are always included.
+func (p Plan) SeqScans(minRows float64) []*PlanNode {
+	var scans []*PlanNode
+	p.Root.Walk(func(n *PlanNode) {
+		if n.SeqScan && (n.Rows < 0 || n.Rows >= minRows) {
+			scans = append(scans, n)
+		}
+	})
+	return scans
+}
+
+// Explain returns the plan of a query without running it. It uses EXPLAIN
+// (FORMAT JSON) on postgres, EXPLAIN FORMAT=JSON on mysql and EXPLAIN QUERY
+// PLAN on sqlite. Sequential scans over [SeqScanWarnRows] rows are listed
+// in the plan's warnings.
+func Explain(ctx context.Context, db DB, query string, args ...any) (Plan, error) {
+	var (
+		plan *Plan
+		err  error
+	)
+	switch t := DialectOf(db).Type(); t {
+	case PostgresDBType:
+		plan, err = explainJSON(ctx, db, "EXPLAIN (FORMAT JSON) "+query, args, parsePostgresPlan)
+	case MySQLDBType:
+		plan, err = explainJSON(ctx, db, "EXPLAIN FORMAT=JSON "+query, args, parseMySQLPlan)
+	case SQLiteDBType:
+		plan, err = explainSQLite(ctx, db, query, args)
+	default:
+		return Plan{}, errors.Errorf("explain is not supported for %q", t)
+	}
+	if err != nil {
+		return Plan{}, err
+	}
+	for _, n := range plan.SeqScans(SeqScanWarnRows) {
+		if n.Rows < 0 {
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf("sequential scan on %s", n.Relation))
+		} else {
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf("sequential scan on %s over %.0f rows", n.Relation, n.Rows))
+		}
+	}
+	return *plan, nil
+}
+
+func explainJSON(ctx context.Context, db DB, query string, args []any, parse func([]byte) (*PlanNode, error)) (*Plan, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	var raw []byte
+	if err = ScanOne(rows, &raw); err != nil {
+		return nil, err
+	}
+	root, err := parse(raw)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse plan")
+	}
+	return &Plan{Root: root, Raw: string(raw)}, nil
+}
+
+type pgPlanNode struct {
+	NodeType string        `json:"Node Type"`
+	Relation string        `json:"Relation Name"`
+	Rows     float64       `json:"Plan Rows"`
+	Cost     float64       `json:"Total Cost"`
+	Plans    []*pgPlanNode `json:"Plans"`
+}
+
+func parsePostgresPlan(raw []byte) (*PlanNode, error) {
+	var plans []struct {
+		Plan *pgPlanNode `json:"Plan"`
+	}
+	if err := json.Unmarshal(raw, &plans); err != nil {
+		return nil, err
+	}
+	if len(plans) == 0 || plans[0].Plan == nil {
+		return nil, errors.New("empty plan")
+	}
+	var convert func(*pgPlanNode) *PlanNode
+	convert = func(p *pgPlanNode) *PlanNode {
+		n := &PlanNode{
+			Type:     p.NodeType,
+			Relation: p.Relation,
+			Rows:     p.Rows,
+			Cost:     p.Cost,
+			SeqScan:  p.NodeType == "Seq Scan",
+		}
+		for _, c := range p.Plans {
+			n.Children = append(n.Children, convert(c))
+		}
+		return n
+	}
+	return convert(plans[0].Plan), nil
+}
+
+func parseMySQLPlan(raw []byte) (*PlanNode, error) {
+	var plan struct {
+		QueryBlock map[string]any `json:"query_block"`
+	}
+	if err := json.Unmarshal(raw, &plan); err != nil {
+		return nil, err
+	}
+	if plan.QueryBlock == nil {
+		return nil, errors.New("empty plan")
+	}
+	root := &PlanNode{Type: "query_block", Rows: -1, Cost: mysqlCost(plan.QueryBlock)}
+	mysqlChildren(root, plan.QueryBlock)
+	return root, nil
+}
+
+// mysqlChildren adds every table access found under v to the parent node.
+// Other operations like "ordering_operation" become their own nodes.
+func mysqlChildren(parent *PlanNode, v any) {
+	switch v := v.(type) {
+	case []any:
+		for _, e := range v {
+			mysqlChildren(parent, e)
+		}
+	case map[string]any:
+		for key, val := range v {
+			obj, isObj := val.(map[string]any)
+			switch {
+			case key == "table" && isObj:
+				n := &PlanNode{Rows: -1, Cost: mysqlCost(obj)}
+				n.Relation, _ = obj["table_name"].(string)
+				n.Type, _ = obj["access_type"].(string)
+				n.SeqScan = n.Type == "ALL"
+				if rows, ok := obj["rows_examined_per_scan"].(float64); ok {
+					n.Rows = rows
+				}
+				mysqlChildren(n, obj)
+				parent.Children = append(parent.Children, n)
+			case isObj && strings.HasSuffix(key, "_operation"):
+				n := &PlanNode{Type: key, Rows: -1, Cost: mysqlCost(obj)}
+				mysqlChildren(n, obj)
+				parent.Children = append(parent.Children, n)
+			default:
+				mysqlChildren(parent, val)
+			}
+		}
+	}
+}
+
+func mysqlCost(obj map[string]any) float64 {
+	info, _ := obj["cost_info"].(map[string]any)
+	for _, k := range []string{"query_cost", "prefix_cost", "read_cost"} {
+		if s, ok := info[k].(string); ok {
+			var f float64
+			if _, err := fmt.Sscan(s, &f); err == nil {
+				return f
+			}
+		}
+	}
+	return 0
+}
+
+func explainSQLite(ctx context.Context, db DB, query string, args []any) (*Plan, error) {
+	rows, err := db.QueryContext(ctx, "EXPLAIN QUERY PLAN "+query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var (
+		root  = &PlanNode{Type: "QUERY PLAN", Rows: -1}
+		nodes = map[int]*PlanNode{0: root}
+		raw   strings.Builder
+	)
+	for rows.Next() {
+		var (
+			id, parent, notused int
+			detail              string
+		)
+		if err = rows.Scan(&id, &parent, &notused, &detail); err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&raw, "%d|%d|%s\n", id, parent, detail)
+		n := &PlanNode{Type: detail, Rows: -1}
+		if fields := strings.Fields(detail); len(fields) > 1 && (fields[0] == "SCAN" || fields[0] == "SEARCH") {
+			n.Relation = fields[1]
+			// "SCAN t USING INDEX" reads an index, not the table.
+			n.SeqScan = fields[0] == "SCAN" && !strings.Contains(detail, " USING ")
+		}
+		p, ok := nodes[parent]
+		if !ok {
+			p = root
+		}
+		p.Children = append(p.Children, n)
+		nodes[id] = n
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return &Plan{Root: root, Raw: raw.String()}, nil
+}
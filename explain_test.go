@@ -0,0 +1,120 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/matryer/is"
+	"go.uber.org/mock/gomock"
+
+	"github.com/harrybrwn/db/mockrows"
+)
+
+func TestExplain_SQLite(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	pool, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer pool.Close()
+	pool.SetMaxOpenConns(1)
+	d := New(pool, WithDialect(DialectFor(SQLiteDBType)))
+	_, err = d.ExecContext(ctx, "CREATE TABLE t (id INTEGER PRIMARY KEY, name TEXT, org INT); CREATE INDEX t_org ON t (org)")
+	is.NoErr(err)
+
+	plan, err := Explain(ctx, d, "SELECT * FROM t WHERE name = ?", "a")
+	is.NoErr(err)
+	is.Equal(len(plan.Root.Children), 1)
+	is.True(plan.Root.Children[0].SeqScan)
+	is.Equal(plan.Root.Children[0].Relation, "t")
+	is.Equal(plan.Warnings, []string{"sequential scan on t"})
+
+	plan, err = Explain(ctx, d, "SELECT * FROM t WHERE org = ?", 1)
+	is.NoErr(err)
+	is.Equal(len(plan.SeqScans(0)), 0)
+	is.Equal(len(plan.Warnings), 0)
+
+	_, err = Explain(ctx, d, "SELECT * FROM missing")
+	is.True(err != nil)
+	_, err = Explain(ctx, New(pool, WithDialect(oracleDialect{})), "SELECT 1")
+	is.True(err != nil)
+}
+
+type oracleDialect struct{ sqliteDialect }
+
+func (oracleDialect) Type() Type { return "oracle" }
+
+const pgPlanJSON = `[{"Plan": {"Node Type": "Hash Join", "Total Cost": 250.5, "Plan Rows": 5000,
+  "Plans": [
+    {"Node Type": "Seq Scan", "Relation Name": "users", "Plan Rows": 5000, "Total Cost": 100},
+    {"Node Type": "Hash", "Plan Rows": 10, "Plans": [
+      {"Node Type": "Seq Scan", "Relation Name": "orgs", "Plan Rows": 10, "Total Cost": 1}
+    ]}
+  ]}}]`
+
+const mysqlPlanJSON = `{"query_block": {"select_id": 1, "cost_info": {"query_cost": "1207.25"},
+  "ordering_operation": {"using_filesort": true,
+    "nested_loop": [
+      {"table": {"table_name": "users", "access_type": "ALL", "rows_examined_per_scan": 9000, "cost_info": {"read_cost": "10.5"}}},
+      {"table": {"table_name": "orgs", "access_type": "eq_ref", "rows_examined_per_scan": 1}}
+    ]}}}`
+
+func TestExplain_JSON(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	for _, tt := range []struct {
+		typ      Type
+		prefix   string
+		raw      string
+		warnings []string
+		seqScans int
+	}{
+		{PostgresDBType, "EXPLAIN (FORMAT JSON) ", pgPlanJSON, []string{"sequential scan on users over 5000 rows"}, 2},
+		{MySQLDBType, "EXPLAIN FORMAT=JSON ", mysqlPlanJSON, []string{"sequential scan on users over 9000 rows"}, 1},
+	} {
+		ctrl := gomock.NewController(t)
+		rows := mockrows.NewMockRows(ctrl)
+		rows.EXPECT().Next().Return(true)
+		rows.EXPECT().Scan(gomock.Any()).DoAndReturn(func(dest ...any) error {
+			*dest[0].(*[]byte) = []byte(tt.raw)
+			return nil
+		})
+		rows.EXPECT().Close().Return(nil)
+		d := &explainDB{rows: rows, dialect: DialectFor(tt.typ)}
+		plan, err := Explain(ctx, d, "SELECT * FROM users JOIN orgs ON orgs.id = users.org_id WHERE users.id > $1", 1)
+		is.NoErr(err)
+		is.Equal(d.query, tt.prefix+"SELECT * FROM users JOIN orgs ON orgs.id = users.org_id WHERE users.id > $1")
+		is.Equal(d.args, []any{1})
+		is.Equal(plan.Raw, tt.raw)
+		is.Equal(plan.Warnings, tt.warnings)
+		is.Equal(len(plan.SeqScans(0)), tt.seqScans)
+		is.True(plan.Root.Cost > 0)
+		ctrl.Finish()
+	}
+
+	root, err := parseMySQLPlan([]byte(mysqlPlanJSON))
+	is.NoErr(err)
+	is.Equal(root.Children[0].Type, "ordering_operation")
+	is.Equal(root.Children[0].Children[0].Cost, 10.5)
+	for _, raw := range []string{"[]", "{", `{"nope": 1}`} {
+		_, err = parsePostgresPlan([]byte(raw))
+		is.True(err != nil)
+		_, err = parseMySQLPlan([]byte(raw))
+		is.True(err != nil)
+	}
+}
+
+type explainDB struct {
+	DB
+	rows    Rows
+	dialect Dialect
+	query   string
+	args    []any
+}
+
+func (d *explainDB) Dialect() Dialect { return d.dialect }
+
+func (d *explainDB) QueryContext(_ context.Context, query string, args ...any) (Rows, error) {
+	d.query, d.args = query, args
+	return d.rows, nil
+}
@@ -0,0 +1,10 @@
+package db
+
+import "expvar"
+
+// PublishExpvar publishes a [Snapshot] of the database under name so the
+// pool and wrapper statistics show up in /debug/vars. Like
+// [expvar.Publish] it panics if the name is already in use.
+func PublishExpvar(name string, database *database) {
+	expvar.Publish(name, expvar.Func(func() any { return database.Snapshot() }))
+}
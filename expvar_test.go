@@ -0,0 +1,24 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"expvar"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestPublishExpvar(t *testing.T) {
+	is := is.New(t)
+	pool, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer pool.Close()
+	pool.SetMaxOpenConns(2)
+	d := New(pool)
+	PublishExpvar("test_db", d)
+	var s Snapshot
+	is.NoErr(json.Unmarshal([]byte(expvar.Get("test_db").String()), &s))
+	is.Equal(s.Pool.MaxOpen, 2)
+	is.Equal(s.Circuit, "closed")
+}
@@ -0,0 +1,152 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	stderrors "errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// ErrReadOnly is returned when a server only accepts read-only sessions but
+// a read-write session was required.
+var ErrReadOnly = errors.New("server is read-only")
+
+// hosts returns the "host:port" address of every configured server. It is
+// nil unless there is more than one host.
+func (db *Config) hosts() []string {
+	hosts := db.Hosts
+	if len(hosts) == 0 && strings.Contains(db.Host, ",") {
+		hosts = strings.Split(db.Host, ",")
+	}
+	if len(hosts) < 2 {
+		return nil
+	}
+	addrs := make([]string, 0, len(hosts))
+	for _, h := range hosts {
+		h = strings.TrimSpace(h)
+		if _, _, err := net.SplitHostPort(h); err != nil {
+			port := db.Port
+			if len(port) == 0 {
+				port = db.Type.defaultPort()
+			}
+			h = net.JoinHostPort(h, port)
+		}
+		addrs = append(addrs, h)
+	}
+	return addrs
+}
+
+// readWrite is true when sessions must be able to write.
+func (db *Config) readWrite() bool {
+	switch strings.ToLower(db.TargetSessionAttrs) {
+	case "", "read-write", "primary":
+		return true
+	}
+	return false
+}
+
+// failoverConnector returns a connector that tries each host in order until
+// one accepts a connection.
+func (db *Config) failoverConnector() (driver.Connector, error) {
+	fc := &failoverConnector{}
+	for _, addr := range db.hosts() {
+		host := *db
+		host.Hosts = nil
+		host.Host, host.Port, _ = net.SplitHostPort(addr)
+		c, err := host.connector()
+		if err != nil {
+			fc.Close()
+			return nil, err
+		}
+		fc.hosts = append(fc.hosts, addr)
+		fc.conns = append(fc.conns, c)
+	}
+	if db.readWrite() {
+		switch {
+		case db.Type.postgresWire():
+			fc.check = pgWritable
+		case db.Type == MySQLDBType:
+			fc.check = mysqlWritable
+		}
+	}
+	return fc, nil
+}
+
+// failoverConnector connects to the first host that is up and, if check is
+// set, passes the check. It starts with the last host that worked.
+type failoverConnector struct {
+	hosts []string
+	conns []driver.Connector
+	check ConnectFunc
+
+	mu   sync.Mutex
+	last int
+}
+
+func (c *failoverConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	c.mu.Lock()
+	start := c.last
+	c.mu.Unlock()
+	var errs []error
+	for i := range c.conns {
+		n := (start + i) % len(c.conns)
+		conn, err := c.conns[n].Connect(ctx)
+		if err == nil && c.check != nil {
+			if err = runConnectFuncs(ctx, conn, []ConnectFunc{c.check}); err != nil {
+				conn.Close()
+			}
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			errs = append(errs, errors.Wrap(err, c.hosts[n]))
+			continue
+		}
+		c.mu.Lock()
+		c.last = n
+		c.mu.Unlock()
+		return conn, nil
+	}
+	return nil, errors.Wrap(stderrors.Join(errs...), "no hosts available")
+}
+
+func (c *failoverConnector) Driver() driver.Driver { return c.conns[0].Driver() }
+
+func (c *failoverConnector) Close() error {
+	var errs []error
+	for _, conn := range c.conns {
+		if cl, ok := conn.(io.Closer); ok {
+			errs = append(errs, cl.Close())
+		}
+	}
+	return stderrors.Join(errs...)
+}
+
+func pgWritable(ctx context.Context, conn *sql.Conn) error {
+	var ro string
+	if err := conn.QueryRowContext(ctx, "SHOW transaction_read_only").Scan(&ro); err != nil {
+		return err
+	}
+	if ro != "off" {
+		return ErrReadOnly
+	}
+	return nil
+}
+
+func mysqlWritable(ctx context.Context, conn *sql.Conn) error {
+	var ro bool
+	if err := conn.QueryRowContext(ctx, "SELECT @@global.read_only").Scan(&ro); err != nil {
+		return err
+	}
+	if ro {
+		return ErrReadOnly
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+type downConnector struct{}
+
+func (*downConnector) Connect(context.Context) (driver.Conn, error) {
+	return nil, errors.New("connection refused")
+}
+func (*downConnector) Driver() driver.Driver { return nil }
+
+func TestConfig_Hosts(t *testing.T) {
+	is := is.New(t)
+	c := Config{Type: PostgresDBType, Host: "a, b:6543", Port: "5433", DBName: "db"}
+	is.Equal(c.hosts(), []string{"a:5433", "b:6543"})
+	is.Equal(c.URI().String(), "postgres://a:5433,b:6543/db?target_session_attrs=read-write")
+	c.TargetSessionAttrs = "any"
+	is.Equal(c.URI().Query().Get("target_session_attrs"), "any")
+	is.True(!c.readWrite())
+
+	c = Config{Type: MySQLDBType, Hosts: []string{"a", "b"}, User: "u"}
+	is.Equal(c.hosts(), []string{"a:3306", "b:3306"})
+	is.Equal(c.DSN(), "u@tcp(a:3306)/")
+	c.Hosts = []string{"a"}
+	is.True(c.hosts() == nil)
+}
+
+func TestFailoverConnector(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	primary, replica := &recorder{current: "primary"}, &recorder{current: "replica"}
+	fc := &failoverConnector{
+		hosts: []string{"down", "replica", "primary"},
+		conns: []driver.Connector{&downConnector{}, replica, primary},
+		check: func(ctx context.Context, conn *sql.Conn) error {
+			var name string
+			if err := conn.QueryRowContext(ctx, "SELECT DATABASE()").Scan(&name); err != nil {
+				return err
+			}
+			if name != "primary" {
+				return ErrReadOnly
+			}
+			return nil
+		},
+	}
+	conn, err := fc.Connect(ctx)
+	is.NoErr(err)
+	is.NoErr(conn.Close())
+	is.Equal(fc.last, 2)
+	is.Equal(len(replica.take()), 1)
+	is.Equal(len(primary.take()), 1)
+
+	// The last good host is tried first.
+	_, err = fc.Connect(ctx)
+	is.NoErr(err)
+	is.Equal(len(replica.take()), 0)
+
+	primary.current = "replica"
+	_, err = fc.Connect(ctx)
+	is.True(errors.Is(err, ErrReadOnly))
+	is.True(fc.Driver() == nil)
+	is.NoErr(fc.Close())
+
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = fc.Connect(ctx)
+	is.True(errors.Is(err, context.Canceled))
+}
+
+func TestConfig_OpenFailover(t *testing.T) {
+	is := is.New(t)
+	down, err := net.Listen("tcp", "127.0.0.1:0")
+	is.NoErr(err)
+	downAddr := down.Addr().String()
+	down.Close()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	is.NoErr(err)
+	defer ln.Close()
+	accepted := make(chan struct{}, 1)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+			select {
+			case accepted <- struct{}{}:
+			default:
+			}
+		}
+	}()
+
+	for _, typ := range []Type{PostgresDBType, MySQLDBType} {
+		c := Config{Type: typ, Hosts: []string{downAddr, ln.Addr().String()}, User: "u", SSLMode: "disable"}
+		pool, err := c.Open()
+		is.NoErr(err)
+		err = pool.Ping()
+		is.True(err != nil)
+		<-accepted
+		is.NoErr(pool.Close())
+	}
+}
@@ -0,0 +1,63 @@
+package db
+
+import (
+	"context"
+	stderrors "errors"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// QueryAll runs a query on each database concurrently and merges the
+// results in the order of the databases. If any of the queries fail, the
+// results from the databases that succeeded are returned along with the
+// errors joined together, each one annotated with the index of its database.
+func QueryAll[T any](ctx context.Context, dbs []DB, query string, scan func(Scanner) (T, error), args ...any) ([]T, error) {
+	var (
+		wg      sync.WaitGroup
+		results = make([][]T, len(dbs))
+		errs    = make([]error, len(dbs))
+	)
+	for i, d := range dbs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := queryAll(ctx, d, query, scan, args)
+			if err != nil {
+				errs[i] = errors.WithMessagef(err, "database %d", i)
+				return
+			}
+			results[i] = res
+		}()
+	}
+	wg.Wait()
+	var n int
+	for _, r := range results {
+		n += len(r)
+	}
+	merged := make([]T, 0, n)
+	for _, r := range results {
+		merged = append(merged, r...)
+	}
+	return merged, stderrors.Join(errs...)
+}
+
+func queryAll[T any](ctx context.Context, d DB, query string, scan func(Scanner) (T, error), args []any) (res []T, err error) {
+	rows, err := d.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if e := rows.Close(); e != nil && err == nil {
+			err = e
+		}
+	}()
+	for rows.Next() {
+		v, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, v)
+	}
+	return res, rows.Err()
+}
@@ -0,0 +1,46 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/pkg/errors"
+)
+
+func TestQueryAll(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	var dbs []DB
+	for _, values := range []string{"(1), (2)", "(3)", "(4), (5)"} {
+		pool, err := sql.Open("sqlite3", ":memory:")
+		is.NoErr(err)
+		defer pool.Close()
+		pool.SetMaxOpenConns(1)
+		d := New(pool)
+		_, err = d.ExecContext(ctx, "CREATE TABLE t (n int)")
+		is.NoErr(err)
+		_, err = d.ExecContext(ctx, "INSERT INTO t VALUES "+values)
+		is.NoErr(err)
+		dbs = append(dbs, d)
+	}
+	scan := func(s Scanner) (n int, err error) { return n, s.Scan(&n) }
+
+	res, err := QueryAll(ctx, dbs, "SELECT n FROM t WHERE n > ? ORDER BY n", scan, 1)
+	is.NoErr(err)
+	is.Equal(res, []int{2, 3, 4, 5})
+
+	_, err = dbs[1].ExecContext(ctx, "DROP TABLE t")
+	is.NoErr(err)
+	res, err = QueryAll(ctx, dbs, "SELECT n FROM t ORDER BY n", scan)
+	is.True(err != nil)
+	is.True(strings.HasPrefix(err.Error(), "database 1: "))
+	is.Equal(res, []int{1, 2, 4, 5})
+
+	errScan := errors.New("scan failed")
+	res, err = QueryAll(ctx, dbs[:1], "SELECT n FROM t", func(Scanner) (int, error) { return 0, errScan })
+	is.True(errors.Is(err, errScan))
+	is.Equal(len(res), 0)
+}
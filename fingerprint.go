@@ -0,0 +1,213 @@
+package db
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fingerprint normalizes a query so that statements that only differ by
+// their literal values, placeholders, comments, whitespace or the length of
+// IN lists have the same fingerprint.
+//
+//	Fingerprint("SELECT * FROM t WHERE id IN (1, 2, 3) AND name = 'x'")
+//	// select * from t where id in (...) and name = ?
+func Fingerprint(query string) string {
+	var b strings.Builder
+	b.Grow(len(query))
+	space := func() {
+		if b.Len() > 0 && !strings.HasSuffix(b.String(), " ") {
+			b.WriteByte(' ')
+		}
+	}
+	for i := 0; i < len(query); {
+		c := query[i]
+		if j, ok := skipQuoted(query, i); ok {
+			switch c {
+			case '"', '`':
+				// Quoted identifiers are kept as is.
+				b.WriteString(query[i:j])
+			case '\'', '$':
+				b.WriteByte('?')
+			default:
+				// Comments
+				space()
+			}
+			i = j
+			continue
+		}
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			space()
+			i++
+		case c == '$' && i+1 < len(query) && isDigit(query[i+1]):
+			_, i = readNumber(query, i+1)
+			b.WriteByte('?')
+		case c == ':' && i+1 < len(query) && isLetter(query[i+1]) && (i == 0 || query[i-1] != ':'):
+			// Named parameter
+			i++
+			for i < len(query) && isIdentChar(query[i]) {
+				i++
+			}
+			b.WriteByte('?')
+		case isDigit(c) && (i == 0 || !isIdentChar(query[i-1])):
+			for i < len(query) && (isDigit(query[i]) || query[i] == '.') {
+				i++
+			}
+			b.WriteByte('?')
+		case isLetter(c) || c == '_':
+			for i < len(query) && isIdentChar(query[i]) {
+				b.WriteByte(lower(query[i]))
+				i++
+			}
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	fp := strings.TrimRight(strings.TrimSpace(b.String()), "; ")
+	fp = inListRe.ReplaceAllString(fp, "in (...)")
+	fp = valuesRe.ReplaceAllString(fp, "$1, ...")
+	return fp
+}
+
+var (
+	inListRe = regexp.MustCompile(`in ?\( ?\?(?: ?, ?\?)* ?\)`)
+	valuesRe = regexp.MustCompile(`(values ?\([^()]*\))(?: ?, ?\([^()]*\))+`)
+)
+
+func lower(c byte) byte {
+	if c >= 'A' && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
+
+// StatementStats are the statistics for every statement with the same
+// [Fingerprint].
+type StatementStats struct {
+	Fingerprint string        `json:"fingerprint"`
+	Count       uint64        `json:"count"`
+	Errors      uint64        `json:"errors"`
+	Total       time.Duration `json:"total"`
+	Max         time.Duration `json:"max"`
+	// Latency percentiles over the most recent statements.
+	P50 time.Duration `json:"p50"`
+	P95 time.Duration `json:"p95"`
+	P99 time.Duration `json:"p99"`
+}
+
+// OtherFingerprint collects statements once the maximum number of
+// fingerprints set by [WithQueryStats] has been reached.
+const OtherFingerprint = "<other>"
+
+const (
+	defaultMaxFingerprints = 1000
+	latencySamples         = 256
+)
+
+// WithQueryStats keeps statistics for each query [Fingerprint]. At most
+// maxFingerprints are tracked, defaulting to 1000, and any statements after
+// that are counted under [OtherFingerprint]. Use [database.QueryStats] or
+// [database.WriteStats] to read them.
+func WithQueryStats(maxFingerprints int) Option {
+	return func(d *dbOptions) {
+		if maxFingerprints <= 0 {
+			maxFingerprints = defaultMaxFingerprints
+		}
+		d.queryStats = &queryStats{max: maxFingerprints}
+	}
+}
+
+type queryStats struct {
+	mu    sync.Mutex
+	max   int
+	stats map[string]*fingerprintStats
+	// fingerprints caches the fingerprint of each query string.
+	fingerprints map[string]string
+}
+
+type fingerprintStats struct {
+	StatementStats
+	samples [latencySamples]time.Duration
+	next    int
+}
+
+func (qs *queryStats) record(query string, d time.Duration, err error) {
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+	fp, ok := qs.fingerprints[query]
+	if !ok {
+		fp = Fingerprint(query)
+		if qs.fingerprints == nil {
+			qs.fingerprints = make(map[string]string)
+			qs.stats = make(map[string]*fingerprintStats)
+		}
+		if len(qs.fingerprints) < qs.max*4 {
+			qs.fingerprints[query] = fp
+		}
+	}
+	s, ok := qs.stats[fp]
+	if !ok {
+		if len(qs.stats) >= qs.max {
+			fp = OtherFingerprint
+			s = qs.stats[fp]
+		}
+		if s == nil {
+			s = &fingerprintStats{StatementStats: StatementStats{Fingerprint: fp}}
+			qs.stats[fp] = s
+		}
+	}
+	s.Count++
+	if err != nil {
+		s.Errors++
+	}
+	s.Total += d
+	s.Max = max(s.Max, d)
+	s.samples[s.next%latencySamples] = d
+	s.next++
+}
+
+func (qs *queryStats) snapshot() []StatementStats {
+	if qs == nil {
+		return nil
+	}
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+	out := make([]StatementStats, 0, len(qs.stats))
+	for _, s := range qs.stats {
+		st := s.StatementStats
+		samples := make([]time.Duration, min(s.next, latencySamples))
+		copy(samples, s.samples[:len(samples)])
+		sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+		st.P50 = percentile(samples, 0.50)
+		st.P95 = percentile(samples, 0.95)
+		st.P99 = percentile(samples, 0.99)
+		out = append(out, st)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Total != out[j].Total {
+			return out[i].Total > out[j].Total
+		}
+		return out[i].Fingerprint < out[j].Fingerprint
+	})
+	return out
+}
+
+// percentile returns the nearest rank percentile of sorted samples.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(float64(len(sorted))*p+0.5) - 1
+	return sorted[max(0, min(i, len(sorted)-1))]
+}
+
+// QueryStats returns the statistics of each query fingerprint sorted by the
+// total time spent running them. It returns nil unless the database was
+// created with [WithQueryStats].
+func (db *database) QueryStats() []StatementStats {
+	return db.queryStats.snapshot()
+}
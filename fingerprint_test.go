@@ -0,0 +1,89 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestFingerprint(t *testing.T) {
+	is := is.New(t)
+	for _, tt := range []struct{ in, want string }{
+		{"SELECT * FROM t WHERE id IN (1, 2, 3) AND name = 'x'", "select * from t where id in (...) and name = ?"},
+		{"select *\n  from t -- comment\n where id = $1;", "select * from t where id = ?"},
+		{"select * from t where id in ($1,$2) /* c */", "select * from t where id in (...)"},
+		{"SELECT \"Col1\", `x` FROM t2 WHERE a = :name AND b::text = ?", "select \"Col1\", `x` from t2 where a = ? and b::text = ?"},
+		{"insert into t (a, b) values (1, 'a'), (2, 'b'), (3, 'c')", "insert into t (a, b) values (?, ?), ..."},
+		{"select 1.5, $$body$$, -2", "select ?, ?, -?"},
+	} {
+		is.Equal(Fingerprint(tt.in), tt.want)
+	}
+	is.Equal(Fingerprint("select * from t where id = 1"), Fingerprint("SELECT * FROM t WHERE id = 99"))
+}
+
+func TestPercentile(t *testing.T) {
+	is := is.New(t)
+	is.Equal(percentile(nil, 0.5), time.Duration(0))
+	samples := make([]time.Duration, 100)
+	for i := range samples {
+		samples[i] = time.Duration(i + 1)
+	}
+	is.Equal(percentile(samples, 0.5), time.Duration(50))
+	is.Equal(percentile(samples, 0.95), time.Duration(95))
+	is.Equal(percentile(samples, 0.99), time.Duration(99))
+	is.Equal(percentile(samples[:1], 0.99), time.Duration(1))
+}
+
+func TestWithQueryStats(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	pool, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer pool.Close()
+	pool.SetMaxOpenConns(1)
+	is.Equal(New(pool).QueryStats(), nil)
+
+	d := New(pool, WithQueryStats(3))
+	_, err = d.ExecContext(ctx, "CREATE TABLE t (id INT)")
+	is.NoErr(err)
+	for i := range 5 {
+		_, err = d.ExecContext(ctx, "INSERT INTO t VALUES (?)", i)
+		is.NoErr(err)
+	}
+	tx, err := d.BeginTx(ctx, nil)
+	is.NoErr(err)
+	_, err = tx.ExecContext(ctx, "INSERT INTO t VALUES (10)")
+	is.NoErr(err)
+	is.NoErr(tx.Commit())
+	_, err = d.ExecContext(ctx, "INSERT INTO missing VALUES (1)")
+	is.True(err != nil)
+	// These are over the limit.
+	_, err = d.ExecContext(ctx, "DELETE FROM t WHERE id = 1")
+	is.NoErr(err)
+	_, err = d.ExecContext(ctx, "DELETE FROM t WHERE id = 2")
+	is.NoErr(err)
+
+	stats := map[string]StatementStats{}
+	for _, s := range d.QueryStats() {
+		stats[s.Fingerprint] = s
+	}
+	is.Equal(len(stats), 4)
+	is.Equal(stats["insert into t values (?)"].Count, uint64(6))
+	is.Equal(stats["insert into missing values (?)"].Errors, uint64(1))
+	is.Equal(stats[OtherFingerprint].Count, uint64(2))
+	is.True(stats["insert into t values (?)"].Total >= stats["insert into t values (?)"].Max)
+
+	var b bytes.Buffer
+	is.NoErr(d.WriteStats(&b, StatsOpenMetrics))
+	is.True(strings.Contains(b.String(), `db_query_duration_seconds_count{fingerprint="insert into t values (?)"} 6`))
+	is.True(strings.Contains(b.String(), `db_query_errors_total{fingerprint="insert into missing values (?)"} 1`))
+	b.Reset()
+	is.NoErr(d.WriteStats(&b, StatsText))
+	is.True(strings.Contains(b.String(), "query: count=6 errors=0"))
+	is.Equal(labelEscape("a\"b\\c\n"), `a\"b\\c\n`)
+}
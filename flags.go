@@ -0,0 +1,52 @@
+package db
+
+import (
+	"flag"
+	"time"
+)
+
+// FlagSet is the part of [flag.FlagSet] used to register config flags. It is
+// also implemented by github.com/spf13/pflag's FlagSet.
+type FlagSet interface {
+	StringVar(p *string, name, value, usage string)
+	Uint64Var(p *uint64, name string, value uint64, usage string)
+	BoolVar(p *bool, name string, value bool, usage string)
+	DurationVar(p *time.Duration, name string, value time.Duration, usage string)
+}
+
+var _ FlagSet = (*flag.FlagSet)(nil)
+
+// AddFlags registers flags for the connection options like --db-host and
+// --db-port. The config's current values are the defaults. Call
+// [Config.Init] after parsing the flags so that options that were not given
+// as flags are read from the environment.
+func (db *Config) AddFlags(fs *flag.FlagSet) { db.BindFlags(fs, "db-") }
+
+// BindFlags is like [Config.AddFlags] but the flag names start with prefix
+// instead of "db-" and the flag set can be a pflag FlagSet.
+func (db *Config) BindFlags(fs FlagSet, prefix string) {
+	fs.StringVar((*string)(&db.Type), prefix+"type", string(db.Type), "database type, i.e. postgres, mysql or sqlite3")
+	fs.StringVar(&db.Host, prefix+"host", db.Host, "database host, comma separated for a cluster")
+	fs.StringVar(&db.Port, prefix+"port", db.Port, "database port")
+	fs.StringVar(&db.User, prefix+"user", db.User, "database user")
+	fs.StringVar(&db.Password, prefix+"password", db.Password, "database password")
+	fs.StringVar(&db.DBName, prefix+"name", db.DBName, "database name")
+	fs.StringVar(&db.Schema, prefix+"schema", db.Schema, "default schema, the search_path on postgres")
+	fs.StringVar(&db.SSLMode, prefix+"sslmode", db.SSLMode, "database ssl mode")
+	fs.StringVar(&db.SSLCA, prefix+"sslca", db.SSLCA, "database ssl ca file")
+	fs.StringVar(&db.SSLCert, prefix+"sslcert", db.SSLCert, "database ssl client certificate file")
+	fs.StringVar(&db.SSLKey, prefix+"sslkey", db.SSLKey, "database ssl client key file")
+	fs.Uint64Var(&db.ConnectTimeout, prefix+"connect-timeout", db.ConnectTimeout, "database connect timeout in seconds")
+	fs.DurationVar(&db.StatementTimeout, prefix+"statement-timeout", db.StatementTimeout, "abort statements that run longer than this")
+	fs.DurationVar(&db.LockTimeout, prefix+"lock-timeout", db.LockTimeout, "abort statements that wait longer than this for a lock")
+	fs.DurationVar(&db.IdleInTxTimeout, prefix+"idle-in-tx-timeout", db.IdleInTxTimeout, "close sessions that are idle in a transaction for longer than this")
+	fs.StringVar(&db.AppName, prefix+"app-name", db.AppName, "application name sent to the database")
+	fs.StringVar(&db.Pooler, prefix+"pooler", db.Pooler, "connection pooler in front of the database, i.e. pgbouncer")
+	fs.BoolVar(&db.UTC, prefix+"utc", db.UTC, "parse mysql times in UTC")
+	fs.StringVar(&db.Charset, prefix+"charset", db.Charset, "mysql connection character set")
+	fs.StringVar(&db.Collation, prefix+"collation", db.Collation, "mysql connection collation")
+	fs.BoolVar(&db.ParseTime, prefix+"parse-time", db.ParseTime, "parse mysql DATE and DATETIME values into time.Time")
+	fs.StringVar(&db.Location, prefix+"loc", db.Location, "time zone of parsed mysql times")
+	fs.BoolVar(&db.MultiStatements, prefix+"multi-statements", db.MultiStatements, "allow multiple mysql statements in one query")
+	fs.BoolVar(&db.InterpolateParams, prefix+"interpolate-params", db.InterpolateParams, "interpolate mysql query arguments on the client")
+}
@@ -0,0 +1,37 @@
+package db
+
+import (
+	"flag"
+	"io"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestConfig_AddFlags(t *testing.T) {
+	is := is.New(t)
+	clearEnv()
+	t.Setenv("MYSQL_USER", "env-user")
+	t.Setenv("MYSQL_HOST", "env-host")
+
+	cfg := Config{Port: "3307"}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	cfg.AddFlags(fs)
+	is.Equal(fs.Lookup("db-port").DefValue, "3307")
+	is.NoErr(fs.Parse([]string{"--db-type", "mysql", "--db-host", "flag-host", "--db-name", "app", "--db-connect-timeout", "5", "--db-utc"}))
+	cfg.Init()
+	is.Equal(cfg.Type, MySQLDBType)
+	is.Equal(cfg.Host, "flag-host")
+	is.Equal(cfg.User, "env-user")
+	is.Equal(cfg.Port, "3307")
+	is.Equal(cfg.DBName, "app")
+	is.Equal(cfg.ConnectTimeout, uint64(5))
+	is.True(cfg.UTC)
+
+	var other Config
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	other.BindFlags(fs, "billing-db-")
+	is.NoErr(fs.Parse([]string{"--billing-db-host", "billing"}))
+	is.Equal(other.Host, "billing")
+}
@@ -0,0 +1,108 @@
+package db
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type columner interface {
+	Columns() ([]string, error)
+}
+
+// Get runs a query and scans the first row into dest. The destination may be
+// a [Scanable], a pointer to a struct whose fields are matched to columns
+// using the "db" tag, or a pointer to a single value. If the query returns no
+// rows then [ErrNotFound] is returned.
+func Get(ctx context.Context, d DB, dest any, query string, args ...any) error {
+	rows, err := d.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	switch v := dest.(type) {
+	case Scanable:
+		if !rows.Next() {
+			return noRows(rows)
+		}
+		if err = v.Scan(rows); err != nil {
+			rows.Close()
+			return err
+		}
+		return rows.Close()
+	}
+	rv := reflect.ValueOf(dest)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		rows.Close()
+		return errors.Errorf("db.Get: destination must be a non-nil pointer, got %T", dest)
+	}
+	elem := rv.Elem()
+	if elem.Kind() != reflect.Struct || !isStructDest(elem.Type()) {
+		return ScanOne(rows, dest)
+	}
+	c, ok := rows.(columner)
+	if !ok {
+		rows.Close()
+		return errors.Errorf("db.Get: cannot scan into %T, rows do not have column names", dest)
+	}
+	cols, err := c.Columns()
+	if err != nil {
+		rows.Close()
+		return err
+	}
+	fields := fieldsOf(elem.Type())
+	ptrs := make([]any, len(cols))
+	for i, col := range cols {
+		f, ok := fields.byName[col]
+		if !ok {
+			rows.Close()
+			return errors.Errorf("db.Get: no field for column %q in %T", col, dest)
+		}
+		fv, ok := allocFieldByIndex(elem, f.index)
+		if !ok {
+			rows.Close()
+			return errors.Errorf("db.Get: cannot set column %q in %T", col, dest)
+		}
+		ptrs[i] = fv.Addr().Interface()
+	}
+	return ScanOne(rows, ptrs...)
+}
+
+func noRows(rows Rows) error {
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return err
+	}
+	rows.Close()
+	return ErrNotFound
+}
+
+// isStructDest reports whether a struct should be mapped column by column
+// rather than scanned as a single value like [time.Time] or [sql.NullString].
+func isStructDest(t reflect.Type) bool {
+	return t != timeType && !reflect.PointerTo(t).Implements(sqlScannerType)
+}
+
+var (
+	sqlScannerType = reflect.TypeOf((*interface{ Scan(any) error })(nil)).Elem()
+	timeType       = reflect.TypeOf(time.Time{})
+)
+
+// allocFieldByIndex is like [fieldByIndex] but allocates nil embedded
+// pointers.
+func allocFieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, v.CanAddr()
+}
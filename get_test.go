@@ -0,0 +1,113 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/pkg/errors"
+)
+
+type getUser struct {
+	ID      int64
+	Name    string `db:"name"`
+	Created time.Time
+}
+
+type getAudit struct {
+	getUser
+	Note sql.NullString `db:"note"`
+}
+
+type scanableUser struct{ id int64 }
+
+func (u *scanableUser) Scan(s Scanner) error { return s.Scan(&u.id) }
+
+func TestGet(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	pool, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer pool.Close()
+	pool.SetMaxOpenConns(1)
+	d := New(pool)
+	_, err = d.ExecContext(ctx, `CREATE TABLE users (id INTEGER, name TEXT, created DATETIME, note TEXT)`)
+	is.NoErr(err)
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	_, err = d.ExecContext(ctx, `INSERT INTO users VALUES (1, 'alice', ?, 'hi')`, created)
+	is.NoErr(err)
+
+	var u getUser
+	is.NoErr(Get(ctx, d, &u, `SELECT id, name, created FROM users WHERE id = ?`, 1))
+	is.Equal(u.ID, int64(1))
+	is.Equal(u.Name, "alice")
+	is.True(u.Created.Equal(created))
+
+	var a getAudit
+	is.NoErr(Get(ctx, d, &a, `SELECT id, name, note FROM users`))
+	is.Equal(a.Name, "alice")
+	is.Equal(a.Note.String, "hi")
+
+	var name string
+	is.NoErr(Get(ctx, d, &name, `SELECT name FROM users`))
+	is.Equal(name, "alice")
+
+	var tm time.Time
+	is.NoErr(Get(ctx, d, &tm, `SELECT created FROM users`))
+	is.True(tm.Equal(created))
+
+	var su scanableUser
+	is.NoErr(Get(ctx, d, &su, `SELECT id FROM users`))
+	is.Equal(su.id, int64(1))
+
+	err = Get(ctx, d, &u, `SELECT id, name FROM users WHERE id = ?`, 2)
+	is.True(IsNotFound(err))
+	is.True(errors.Is(err, ErrNotFound))
+	is.True(errors.Is(err, sql.ErrNoRows))
+	err = Get(ctx, d, &su, `SELECT id FROM users WHERE id = 2`)
+	is.True(IsNotFound(err))
+
+	err = Get(ctx, d, &u, `SELECT id, note AS missing FROM users`)
+	is.True(err != nil)
+	is.True(!IsNotFound(err))
+	is.True(Get(ctx, d, u, `SELECT id FROM users`) != nil)
+	is.True(!IsNotFound(errors.New("connection refused")))
+}
+
+type fieldsBase struct {
+	ID   int64
+	Name string
+}
+
+type fieldsOther struct {
+	Name string
+}
+
+type fieldsShadow struct {
+	fieldsBase
+	*fieldsOther
+	ID string `db:"id"`
+}
+
+func TestFieldsOf_Shadowing(t *testing.T) {
+	is := is.New(t)
+	sf := fieldsOf(reflect.TypeOf(fieldsShadow{}))
+	// The outer ID hides the embedded one and the two embedded names are
+	// ambiguous so neither is used.
+	is.Equal(len(sf.list), 1)
+	is.Equal(sf.byName["id"].index, []int{2})
+	_, ok := sf.byName["name"]
+	is.True(!ok)
+
+	ctx := context.Background()
+	pool, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer pool.Close()
+	var v fieldsShadow
+	is.NoErr(Get(ctx, New(pool), &v, `SELECT 'outer' AS id`))
+	is.Equal(v.ID, "outer")
+	is.Equal(v.fieldsBase.ID, int64(0))
+}
@@ -0,0 +1,227 @@
+package db
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// State is the health of a database connection.
+type State int
+
+const (
+	StateUnknown State = iota
+	StateUp
+	StateDegraded
+	StateDown
+)
+
+func (s State) String() string {
+	switch s {
+	case StateUp:
+		return "up"
+	case StateDegraded:
+		return "degraded"
+	case StateDown:
+		return "down"
+	default:
+		return "unknown"
+	}
+}
+
+// EventType is the kind of change reported by a [Monitor].
+type EventType int
+
+const (
+	// EventUp is sent the first time the database is reachable and when it
+	// recovers from being degraded.
+	EventUp EventType = iota + 1
+	// EventDown is sent when the database stops responding.
+	EventDown
+	// EventDegraded is sent when the database responds slower than the
+	// degraded latency threshold.
+	EventDegraded
+	// EventReconnected is sent when the database is reachable again after
+	// being down.
+	EventReconnected
+)
+
+func (e EventType) String() string {
+	switch e {
+	case EventUp:
+		return "up"
+	case EventDown:
+		return "down"
+	case EventDegraded:
+		return "degraded"
+	case EventReconnected:
+		return "reconnected"
+	default:
+		return "unknown"
+	}
+}
+
+// Event is a change in the health of the database.
+type Event struct {
+	Type EventType
+	Time time.Time
+	// Err is the error that caused the database to go down.
+	Err error
+	// Latency is how long the health check took.
+	Latency time.Duration
+}
+
+// MonitorOpt is an option for [NewMonitor].
+type MonitorOpt func(*Monitor)
+
+// WithCheckInterval sets how often the [Monitor] pings the database.
+func WithCheckInterval(d time.Duration) MonitorOpt { return func(m *Monitor) { m.interval = d } }
+
+// WithCheckTimeout sets the timeout for each health check.
+func WithCheckTimeout(d time.Duration) MonitorOpt { return func(m *Monitor) { m.timeout = d } }
+
+// WithDegradedLatency sets the ping latency at which the database is
+// considered degraded. Zero disables the degraded state.
+func WithDegradedLatency(d time.Duration) MonitorOpt {
+	return func(m *Monitor) { m.degraded = d }
+}
+
+// WithMonitorLogger sets the logger used by the [Monitor].
+func WithMonitorLogger(l *slog.Logger) MonitorOpt { return func(m *Monitor) { m.logger = l } }
+
+// Monitor periodically checks the health of a database and sends an [Event]
+// to subscribers whenever the health changes.
+type Monitor struct {
+	db       Pingable
+	interval time.Duration
+	timeout  time.Duration
+	degraded time.Duration
+	logger   *slog.Logger
+
+	mu     sync.Mutex
+	state  State
+	last   Event
+	subs   map[int]chan Event
+	nextID int
+}
+
+// NewMonitor creates a new health [Monitor].
+func NewMonitor(db Pingable, opts ...MonitorOpt) *Monitor {
+	m := &Monitor{
+		db:       db,
+		interval: 5 * time.Second,
+		timeout:  2 * time.Second,
+		subs:     make(map[int]chan Event),
+	}
+	for _, o := range opts {
+		o(m)
+	}
+	if m.logger == nil {
+		m.logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	return m
+}
+
+// State returns the current health state.
+func (m *Monitor) State() State {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state
+}
+
+// LastEvent returns the last state change event.
+func (m *Monitor) LastEvent() Event {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.last
+}
+
+// Subscribe returns a channel that receives state change events and a
+// function that unsubscribes and closes the channel. Events are dropped if the
+// channel's buffer is full so slow subscribers never block the monitor.
+func (m *Monitor) Subscribe(buffer int) (<-chan Event, func()) {
+	ch := make(chan Event, buffer)
+	m.mu.Lock()
+	id := m.nextID
+	m.nextID++
+	m.subs[id] = ch
+	m.mu.Unlock()
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			m.mu.Lock()
+			delete(m.subs, id)
+			m.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// Run checks the database health every interval until the context is
+// canceled.
+func (m *Monitor) Run(ctx context.Context) error {
+	m.Check(ctx)
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			m.Check(ctx)
+		}
+	}
+}
+
+// Check runs one health check, notifies subscribers if the state changed and
+// returns the new state.
+func (m *Monitor) Check(ctx context.Context) State {
+	if m.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.timeout)
+		defer cancel()
+	}
+	start := now()
+	err := m.db.PingContext(ctx)
+	latency := now().Sub(start)
+
+	next := StateUp
+	switch {
+	case err != nil:
+		next = StateDown
+	case m.degraded > 0 && latency > m.degraded:
+		next = StateDegraded
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	prev := m.state
+	if next == prev {
+		return next
+	}
+	m.state = next
+	ev := Event{Time: now(), Err: err, Latency: latency}
+	switch {
+	case next == StateDown:
+		ev.Type = EventDown
+		m.logger.Warn("database is down", slog.Any("error", err))
+	case next == StateDegraded:
+		ev.Type = EventDegraded
+		m.logger.Warn("database is degraded", slog.Duration("latency", latency))
+	case prev == StateDown:
+		ev.Type = EventReconnected
+		m.logger.Info("database reconnected")
+	default:
+		ev.Type = EventUp
+	}
+	m.last = ev
+	for _, ch := range m.subs {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+	return next
+}
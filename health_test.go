@@ -0,0 +1,85 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+type pingFunc func(context.Context) error
+
+func (f pingFunc) Ping() error                           { return f(context.Background()) }
+func (f pingFunc) PingContext(ctx context.Context) error { return f(ctx) }
+
+func TestMonitor(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	errDown := errors.New("connection refused")
+	var (
+		results []error
+		delay   time.Duration
+	)
+	ping := pingFunc(func(context.Context) error {
+		err := results[0]
+		results = results[1:]
+		time.Sleep(delay)
+		return err
+	})
+	m := NewMonitor(ping, WithDegradedLatency(20*time.Millisecond), WithCheckTimeout(time.Second))
+	events, unsubscribe := m.Subscribe(10)
+	is.Equal(m.State(), StateUnknown)
+
+	results = []error{nil, nil, errDown, errDown, nil, nil, nil}
+	is.Equal(m.Check(ctx), StateUp)
+	is.Equal(m.Check(ctx), StateUp)
+	is.Equal(m.Check(ctx), StateDown)
+	is.Equal(m.Check(ctx), StateDown)
+	is.Equal(m.Check(ctx), StateUp)
+	delay = 30 * time.Millisecond
+	is.Equal(m.Check(ctx), StateDegraded)
+	delay = 0
+	is.Equal(m.Check(ctx), StateUp)
+	is.Equal(m.LastEvent().Type, EventUp)
+	unsubscribe()
+	unsubscribe()
+
+	var types []EventType
+	for ev := range events {
+		is.True(!ev.Time.IsZero())
+		if ev.Type == EventDown {
+			is.Equal(ev.Err, errDown)
+		}
+		types = append(types, ev.Type)
+	}
+	is.Equal(types, []EventType{EventUp, EventDown, EventReconnected, EventDegraded, EventUp})
+
+	for _, s := range []State{StateUnknown, StateUp, StateDegraded, StateDown} {
+		is.True(len(s.String()) > 0)
+	}
+	for _, e := range []EventType{0, EventUp, EventDown, EventDegraded, EventReconnected} {
+		is.True(len(e.String()) > 0)
+	}
+}
+
+func TestMonitor_Run(t *testing.T) {
+	is := is.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := make(chan struct{}, 10)
+	m := NewMonitor(pingFunc(func(context.Context) error {
+		select {
+		case calls <- struct{}{}:
+		default:
+		}
+		return nil
+	}), WithCheckInterval(time.Millisecond))
+	done := make(chan error)
+	go func() { done <- m.Run(ctx) }()
+	<-calls
+	<-calls
+	cancel()
+	is.NoErr(<-done)
+	is.Equal(m.State(), StateUp)
+}
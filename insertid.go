@@ -0,0 +1,56 @@
+package db
+
+import (
+	"context"
+	"strings"
+)
+
+// InsertReturningID runs an INSERT statement and returns the id of the new
+// row. On postgres and sqlite "RETURNING id" is appended to the statement
+// unless it already has a RETURNING clause. On mysql the id comes from
+// [sql.Result.LastInsertId].
+func InsertReturningID(ctx context.Context, db DB, query string, args ...any) (int64, error) {
+	if DialectOf(db).Type() == MySQLDBType {
+		res, err := db.ExecContext(ctx, query, args...)
+		if err != nil {
+			return 0, err
+		}
+		return res.LastInsertId()
+	}
+	if !hasKeyword(query, "returning") {
+		query = strings.TrimRight(query, "; \t\r\n") + " RETURNING id"
+	}
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	var id int64
+	if err = ScanOne(rows, &id); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// hasKeyword reports whether the query has the keyword outside of strings,
+// quoted identifiers, and comments.
+func hasKeyword(query, keyword string) bool {
+	for i := 0; i < len(query); {
+		if j, ok := skipQuoted(query, i); ok {
+			i = j
+			continue
+		}
+		if !isLetter(query[i]) && query[i] != '_' {
+			i++
+			continue
+		}
+		j := i
+		for j < len(query) && isIdentChar(query[j]) {
+			j++
+		}
+		if strings.EqualFold(query[i:j], keyword) {
+			return true
+		}
+		i = j
+	}
+	return false
+}
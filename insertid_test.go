@@ -0,0 +1,41 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestInsertReturningID(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	pool, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer pool.Close()
+	pool.SetMaxOpenConns(1)
+	d := New(pool)
+	_, err = d.ExecContext(ctx, "CREATE TABLE users (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT)")
+	is.NoErr(err)
+
+	id, err := InsertReturningID(ctx, d, "INSERT INTO users (name) VALUES (?);", "a")
+	is.NoErr(err)
+	is.Equal(id, int64(1))
+	id, err = InsertReturningID(ctx, d, "INSERT INTO users (name) VALUES ('returning') RETURNING id * 10")
+	is.NoErr(err)
+	is.Equal(id, int64(20))
+	id, err = InsertReturningID(ctx, New(pool, WithDialect(DialectFor(MySQLDBType))), "INSERT INTO users (name) VALUES (?)", "c")
+	is.NoErr(err)
+	is.Equal(id, int64(3))
+
+	_, err = InsertReturningID(ctx, d, "INSERT INTO missing (name) VALUES (?)", "a")
+	is.True(err != nil)
+	_, err = InsertReturningID(ctx, New(pool, WithDialect(DialectFor(MySQLDBType))), "INSERT INTO missing (name) VALUES (?)", "a")
+	is.True(err != nil)
+	_, err = InsertReturningID(ctx, d, "INSERT INTO users (name) SELECT name FROM users WHERE 0")
+	is.True(IsNotFound(err))
+
+	is.True(!hasKeyword(`INSERT INTO t ("returning") VALUES ('returning') -- returning`, "returning"))
+	is.True(hasKeyword(`insert into t values (1) returning *`, "RETURNING"))
+}
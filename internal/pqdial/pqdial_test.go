@@ -0,0 +1,28 @@
+package pqdial
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/pkg/errors"
+)
+
+func TestDialer(t *testing.T) {
+	is := is.New(t)
+	var deadline bool
+	d := dialer(func(ctx context.Context, network, addr string) (net.Conn, error) {
+		_, deadline = ctx.Deadline()
+		return nil, errors.New(network + " " + addr)
+	})
+	_, err := d.Dial("tcp", "a:1")
+	is.Equal(err.Error(), "tcp a:1")
+	is.True(!deadline)
+	_, err = d.DialTimeout("tcp", "a:1", time.Second)
+	is.True(err != nil)
+	is.True(deadline)
+	_, err = d.DialContext(context.Background(), "unix", "/s")
+	is.Equal(err.Error(), "unix /s")
+}
@@ -0,0 +1,176 @@
+package db
+
+import (
+	"context"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// TableInfo describes a table found by [Tables].
+type TableInfo struct {
+	Name    string
+	Columns []ColumnInfo
+	Indexes []IndexInfo
+}
+
+// ColumnInfo describes a column of a table. Type is the type as reported by
+// the database, i.e. "character varying" on postgres and "varchar(255)" on
+// mysql.
+type ColumnInfo struct {
+	Name     string
+	Type     string
+	Nullable bool
+}
+
+// IndexInfo describes an index and the columns it covers in order.
+type IndexInfo struct {
+	Name    string
+	Columns []string
+	Unique  bool
+}
+
+// Column returns the column with the given name.
+func (t *TableInfo) Column(name string) (ColumnInfo, bool) {
+	for _, c := range t.Columns {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return ColumnInfo{}, false
+}
+
+// introspectQueries are the queries that list the columns and indexes of
+// the tables in the current schema. Columns are returned as table, column,
+// type and nullable and indexes as table, index, unique and column, with
+// the index's columns in order.
+type introspectQueries struct{ columns, indexes string }
+
+var (
+	postgresIntrospect = introspectQueries{
+		columns: `SELECT c.table_name, c.column_name, c.data_type, c.is_nullable = 'YES'
+FROM information_schema.columns c
+JOIN information_schema.tables t ON t.table_schema = c.table_schema AND t.table_name = c.table_name
+WHERE c.table_schema = current_schema() AND t.table_type = 'BASE TABLE'
+ORDER BY c.table_name, c.ordinal_position`,
+		indexes: `SELECT t.relname, i.relname, ix.indisunique, a.attname
+FROM pg_index ix
+JOIN pg_class i ON i.oid = ix.indexrelid
+JOIN pg_class t ON t.oid = ix.indrelid
+JOIN pg_namespace n ON n.oid = t.relnamespace
+JOIN LATERAL unnest(ix.indkey) WITH ORDINALITY AS k(attnum, ord) ON true
+JOIN pg_attribute a ON a.attrelid = t.oid AND a.attnum = k.attnum
+WHERE n.nspname = current_schema()
+ORDER BY t.relname, i.relname, k.ord`,
+	}
+	mysqlIntrospect = introspectQueries{
+		columns: `SELECT c.table_name, c.column_name, c.column_type, c.is_nullable = 'YES'
+FROM information_schema.columns c
+JOIN information_schema.tables t ON t.table_schema = c.table_schema AND t.table_name = c.table_name
+WHERE c.table_schema = DATABASE() AND t.table_type = 'BASE TABLE'
+ORDER BY c.table_name, c.ordinal_position`,
+		indexes: `SELECT table_name, index_name, non_unique = 0, column_name
+FROM information_schema.statistics
+WHERE table_schema = DATABASE()
+ORDER BY table_name, index_name, seq_in_index`,
+	}
+	sqliteIntrospect = introspectQueries{
+		columns: `SELECT m.name, p.name, p.type, p."notnull" = 0
+FROM sqlite_master m JOIN pragma_table_info(m.name) p
+WHERE m.type = 'table' AND m.name NOT LIKE 'sqlite_%'
+ORDER BY m.name, p.cid`,
+		indexes: `SELECT m.name, l.name, l."unique", i.name
+FROM sqlite_master m
+JOIN pragma_index_list(m.name) l
+JOIN pragma_index_info(l.name) i
+WHERE m.type = 'table' AND m.name NOT LIKE 'sqlite_%'
+ORDER BY m.name, l.name, i.seqno`,
+	}
+)
+
+// Tables returns the tables in the current schema of postgres and
+// cockroachdb, the current database of mysql or the main database of
+// sqlite, sorted by name.
+func Tables(ctx context.Context, db DB) ([]TableInfo, error) {
+	var q introspectQueries
+	switch t := DialectOf(db).Type(); {
+	case t.postgresWire():
+		q = postgresIntrospect
+	case t == MySQLDBType:
+		q = mysqlIntrospect
+	case t == SQLiteDBType:
+		q = sqliteIntrospect
+	default:
+		return nil, errors.Errorf("introspection is not supported for %q", t)
+	}
+	tables := make(map[string]*TableInfo)
+	table := func(name string) *TableInfo {
+		t, ok := tables[name]
+		if !ok {
+			t = &TableInfo{Name: name}
+			tables[name] = t
+		}
+		return t
+	}
+	err := queryEach(ctx, db, q.columns, func(s Scanner) error {
+		var (
+			name string
+			c    ColumnInfo
+		)
+		if err := s.Scan(&name, &c.Name, &c.Type, &c.Nullable); err != nil {
+			return err
+		}
+		t := table(name)
+		t.Columns = append(t.Columns, c)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list columns")
+	}
+	err = queryEach(ctx, db, q.indexes, func(s Scanner) error {
+		var (
+			name, index, col string
+			unique           bool
+		)
+		if err := s.Scan(&name, &index, &unique, &col); err != nil {
+			return err
+		}
+		t, ok := tables[name]
+		if !ok {
+			return nil
+		}
+		if n := len(t.Indexes); n > 0 && t.Indexes[n-1].Name == index {
+			t.Indexes[n-1].Columns = append(t.Indexes[n-1].Columns, col)
+			return nil
+		}
+		t.Indexes = append(t.Indexes, IndexInfo{Name: index, Unique: unique, Columns: []string{col}})
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list indexes")
+	}
+	res := make([]TableInfo, 0, len(tables))
+	for _, t := range tables {
+		res = append(res, *t)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res, nil
+}
+
+// queryEach runs a query and calls fn for each row.
+func queryEach(ctx context.Context, db DB, query string, fn func(Scanner) error) error {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err = fn(rows); err != nil {
+			return err
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	return rows.Close()
+}
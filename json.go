@@ -0,0 +1,52 @@
+package db
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+// JSON stores a value as JSON in a json, jsonb, or text column.
+type JSON[T any] struct {
+	V T
+}
+
+// JSONFrom returns a [JSON] holding v.
+func JSONFrom[T any](v T) JSON[T] { return JSON[T]{V: v} }
+
+// Value implements [driver.Valuer]. The JSON is sent as a string so that it
+// works for both json and text columns.
+func (j JSON[T]) Value() (driver.Value, error) {
+	b, err := json.Marshal(j.V)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan implements [sql.Scanner]. Drivers return either []byte or string
+// for json columns and both are handled. A NULL column is scanned as the
+// zero value.
+func (j *JSON[T]) Scan(value any) error {
+	var b []byte
+	switch v := value.(type) {
+	case nil:
+		var zero T
+		j.V = zero
+		return nil
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return errors.Errorf("cannot scan %T into JSON", value)
+	}
+	return json.Unmarshal(b, &j.V)
+}
+
+// MarshalJSON implements [json.Marshaler].
+func (j JSON[T]) MarshalJSON() ([]byte, error) { return json.Marshal(j.V) }
+
+// UnmarshalJSON implements [json.Unmarshaler].
+func (j *JSON[T]) UnmarshalJSON(b []byte) error { return json.Unmarshal(b, &j.V) }
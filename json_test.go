@@ -0,0 +1,61 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestJSON(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	pool, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer pool.Close()
+	pool.SetMaxOpenConns(1)
+	d := New(pool)
+	_, err = d.ExecContext(ctx, "CREATE TABLE docs (body TEXT, raw BLOB)")
+	is.NoErr(err)
+	type settings struct {
+		Theme string   `json:"theme"`
+		Tags  []string `json:"tags"`
+	}
+	s := settings{Theme: "dark", Tags: []string{"a"}}
+	_, err = d.ExecContext(ctx, "INSERT INTO docs VALUES (?, ?), (NULL, NULL)", JSONFrom(s), []byte(`{"theme":"light"}`))
+	is.NoErr(err)
+
+	rows, err := d.QueryContext(ctx, "SELECT body, raw FROM docs ORDER BY rowid")
+	is.NoErr(err)
+	var got [][2]JSON[settings]
+	for rows.Next() {
+		var r [2]JSON[settings]
+		is.NoErr(rows.Scan(&r[0], &r[1]))
+		got = append(got, r)
+	}
+	is.NoErr(rows.Close())
+	is.Equal(got[0][0].V, s)
+	is.Equal(got[0][1].V.Theme, "light")
+	is.Equal(got[1][0].V, settings{})
+
+	var raw string
+	rows, err = d.QueryContext(ctx, "SELECT body FROM docs LIMIT 1")
+	is.NoErr(err)
+	is.NoErr(ScanOne(rows, &raw))
+	is.Equal(raw, `{"theme":"dark","tags":["a"]}`)
+
+	var j JSON[settings]
+	is.True(j.Scan(42) != nil)
+	is.True(j.Scan("{") != nil)
+	_, err = JSONFrom(make(chan int)).Value()
+	is.True(err != nil)
+
+	b, err := json.Marshal(struct{ S JSON[settings] }{JSONFrom(s)})
+	is.NoErr(err)
+	is.Equal(string(b), `{"S":{"theme":"dark","tags":["a"]}}`)
+	var back struct{ S JSON[settings] }
+	is.NoErr(json.Unmarshal(b, &back))
+	is.Equal(back.S.V, s)
+}
@@ -0,0 +1,219 @@
+// Package leader elects a single leader among many processes using a lease
+// row in the database.
+package leader
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/harrybrwn/db"
+)
+
+// DefaultTable is the name of the lease table.
+const DefaultTable = "leader_leases"
+
+// Option configures an [Elector].
+type Option func(*Elector)
+
+// WithTTL sets how long a lease lasts without being renewed. Defaults to
+// 15s.
+func WithTTL(d time.Duration) Option { return func(e *Elector) { e.ttl = d } }
+
+// WithRenewInterval sets how often the lease is renewed or an election is
+// attempted. Defaults to a third of the TTL.
+func WithRenewInterval(d time.Duration) Option { return func(e *Elector) { e.renew = d } }
+
+// WithID sets the identity of this candidate. Defaults to a random id.
+func WithID(id string) Option { return func(e *Elector) { e.id = id } }
+
+// WithTable sets the name of the lease table.
+func WithTable(name string) Option { return func(e *Elector) { e.table = name } }
+
+// WithDialect sets the dialect used to build queries. Defaults to the
+// dialect of the database.
+func WithDialect(d db.Dialect) Option { return func(e *Elector) { e.dialect = d } }
+
+// WithLogger sets the elector's logger.
+func WithLogger(l *slog.Logger) Option { return func(e *Elector) { e.logger = l } }
+
+// WithOnChange sets a function that is called when this candidate gains or
+// loses leadership.
+func WithOnChange(fn func(leader bool)) Option { return func(e *Elector) { e.onChange = fn } }
+
+// Elector campaigns for leadership of a named lease. The lease expires after
+// the TTL unless the leader renews it, so a leader that dies is replaced
+// within one TTL. Lease times come from the candidates' clocks which should
+// be kept in sync. A candidate stops reporting itself as the leader a tenth
+// of the TTL before its lease expires, even if a renewal is still running,
+// so that two candidates don't act as the leader at once.
+type Elector struct {
+	db       db.DB
+	name     string
+	id       string
+	table    string
+	ttl      time.Duration
+	renew    time.Duration
+	dialect  db.Dialect
+	logger   *slog.Logger
+	onChange func(bool)
+
+	leader atomic.Bool
+	// expires is when leadership ends in unix nanoseconds unless the lease
+	// is renewed before then.
+	expires atomic.Int64
+	mu      sync.Mutex
+	changes chan bool
+}
+
+// New creates an [Elector] for the named lease.
+func New(d db.DB, name string, opts ...Option) *Elector {
+	e := &Elector{
+		db:      d,
+		name:    name,
+		table:   DefaultTable,
+		ttl:     15 * time.Second,
+		changes: make(chan bool, 1),
+	}
+	for _, o := range opts {
+		o(e)
+	}
+	if len(e.id) == 0 {
+		var b [8]byte
+		rand.Read(b[:])
+		e.id = hex.EncodeToString(b[:])
+	}
+	if e.renew <= 0 {
+		e.renew = e.ttl / 3
+	}
+	if e.dialect == nil {
+		e.dialect = db.DialectOf(d)
+	}
+	if e.logger == nil {
+		e.logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	return e
+}
+
+// CreateTable creates the lease table if it doesn't exist.
+func (e *Elector) CreateTable(ctx context.Context) error {
+	_, err := e.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+e.dialect.QuoteIdent(e.table)+` (
+		name       VARCHAR(255) PRIMARY KEY,
+		holder     VARCHAR(255) NOT NULL,
+		expires_at TIMESTAMP NOT NULL
+	)`)
+	return err
+}
+
+// ID returns the identity of this candidate.
+func (e *Elector) ID() string { return e.id }
+
+// IsLeader reports whether this candidate currently holds the lease. It is
+// false once the lease is about to expire without having been renewed.
+func (e *Elector) IsLeader() bool {
+	return e.leader.Load() && now().UnixNano() < e.expires.Load()
+}
+
+// Changes returns a channel that receives the latest leadership state
+// whenever it changes. Only the most recent state is buffered.
+func (e *Elector) Changes() <-chan bool { return e.changes }
+
+// Run campaigns for leadership until the context is done. The lease is
+// released when Run returns.
+func (e *Elector) Run(ctx context.Context) error {
+	t := time.NewTicker(e.renew)
+	defer t.Stop()
+	for {
+		if err := e.Campaign(ctx); err != nil && ctx.Err() == nil {
+			e.logger.Warn("leader election failed", slog.String("lease", e.name), slog.Any("error", err))
+		}
+		select {
+		case <-ctx.Done():
+			if err := e.Resign(context.WithoutCancel(ctx)); err != nil {
+				e.logger.Warn("failed to resign leadership", slog.String("lease", e.name), slog.Any("error", err))
+			}
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+var now = time.Now
+
+func timestamp() time.Time { return now().UTC().Truncate(time.Microsecond) }
+
+// Campaign tries once to take or renew the lease. If it fails with an error
+// or does not finish before the lease would expire then leadership is lost.
+func (e *Elector) Campaign(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, e.ttl-e.margin())
+	defer cancel()
+	ts := timestamp()
+	leader, err := e.campaign(ctx, ts)
+	if leader {
+		e.expires.Store(ts.Add(e.ttl - e.margin()).UnixNano())
+	}
+	e.set(leader)
+	return err
+}
+
+// margin is how long before the lease expires that leadership ends, which
+// leaves room for clock drift between the candidates.
+func (e *Elector) margin() time.Duration { return e.ttl / 10 }
+
+func (e *Elector) campaign(ctx context.Context, ts time.Time) (bool, error) {
+	table := e.dialect.QuoteIdent(e.table)
+	res, err := e.db.ExecContext(ctx, e.rebind(`UPDATE `+table+
+		` SET holder = ?, expires_at = ? WHERE name = ? AND (holder = ? OR expires_at < ?)`),
+		e.id, ts.Add(e.ttl), e.name, e.id, ts)
+	if err != nil {
+		return false, err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return false, err
+	} else if n > 0 {
+		return true, nil
+	}
+	_, err = e.db.ExecContext(ctx, e.rebind(`INSERT INTO `+table+
+		` (name, holder, expires_at) VALUES (?, ?, ?)`), e.name, e.id, ts.Add(e.ttl))
+	switch {
+	case err == nil:
+		return true, nil
+	case db.IsUniqueViolation(err):
+		// Someone else holds the lease.
+		return false, nil
+	}
+	return false, err
+}
+
+// Resign gives up the lease if this candidate holds it so another candidate
+// can take over without waiting for it to expire.
+func (e *Elector) Resign(ctx context.Context) error {
+	defer e.set(false)
+	_, err := e.db.ExecContext(ctx, e.rebind(`DELETE FROM `+e.dialect.QuoteIdent(e.table)+
+		` WHERE name = ? AND holder = ?`), e.name, e.id)
+	return err
+}
+
+func (e *Elector) rebind(query string) string { return db.Rebind(e.dialect.Type(), query) }
+
+func (e *Elector) set(leader bool) {
+	if e.leader.Swap(leader) == leader {
+		return
+	}
+	e.logger.Info("leadership changed", slog.String("lease", e.name), slog.Bool("leader", leader))
+	e.mu.Lock()
+	select {
+	case <-e.changes:
+	default:
+	}
+	e.changes <- leader
+	e.mu.Unlock()
+	if e.onChange != nil {
+		e.onChange(leader)
+	}
+}
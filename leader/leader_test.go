@@ -0,0 +1,127 @@
+package leader
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/pkg/errors"
+
+	"github.com/harrybrwn/db"
+)
+
+func setup(t *testing.T) db.DB {
+	t.Helper()
+	pool, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.SetMaxOpenConns(1)
+	t.Cleanup(func() { pool.Close() })
+	return db.New(pool, db.WithDialect(db.DialectFor(db.SQLiteDBType)))
+}
+
+func withNow(tm time.Time) func() {
+	now = func() time.Time { return tm }
+	return func() { now = time.Now }
+}
+
+func TestElector(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	d := setup(t)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	defer withNow(start)()
+
+	var changes []bool
+	a := New(d, "cron", WithID("a"), WithTTL(time.Minute), WithOnChange(func(l bool) { changes = append(changes, l) }))
+	b := New(d, "cron", WithID("b"), WithTTL(time.Minute))
+	is.NoErr(a.CreateTable(ctx))
+	is.Equal(a.ID(), "a")
+	is.True(len(New(d, "x").ID()) > 0)
+
+	is.NoErr(a.Campaign(ctx))
+	is.True(a.IsLeader())
+	is.Equal(<-a.Changes(), true)
+	is.NoErr(b.Campaign(ctx))
+	is.True(!b.IsLeader())
+
+	// renew
+	withNow(start.Add(50 * time.Second))
+	is.NoErr(a.Campaign(ctx))
+	is.True(a.IsLeader())
+	withNow(start.Add(90 * time.Second))
+	is.NoErr(b.Campaign(ctx))
+	is.True(!b.IsLeader())
+
+	// a stops reporting itself as leader before the lease expires
+	withNow(start.Add(50*time.Second + 53*time.Second))
+	is.True(a.IsLeader())
+	withNow(start.Add(50*time.Second + 54*time.Second))
+	is.True(!a.IsLeader())
+
+	// a stops renewing and the lease expires
+	withNow(start.Add(3 * time.Minute))
+	is.NoErr(b.Campaign(ctx))
+	is.True(b.IsLeader())
+	is.NoErr(a.Campaign(ctx))
+	is.True(!a.IsLeader())
+	is.Equal(changes, []bool{true, false})
+
+	is.NoErr(b.Resign(ctx))
+	is.True(!b.IsLeader())
+	is.NoErr(a.Campaign(ctx))
+	is.True(a.IsLeader())
+}
+
+func TestElector_Run(t *testing.T) {
+	is := is.New(t)
+	d := setup(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	e := New(d, "job", WithTTL(time.Second), WithRenewInterval(time.Millisecond))
+	is.NoErr(e.CreateTable(ctx))
+	done := make(chan error)
+	go func() { done <- e.Run(ctx) }()
+	is.Equal(<-e.Changes(), true)
+	cancel()
+	is.True(errors.Is(<-done, context.Canceled))
+	is.True(!e.IsLeader())
+
+	// The lease was released.
+	other := New(d, "job")
+	is.NoErr(other.Campaign(context.Background()))
+	is.True(other.IsLeader())
+
+	// No table
+	e = New(d, "job", WithTable("missing"))
+	is.True(e.Campaign(context.Background()) != nil)
+	is.True(!e.IsLeader())
+}
+
+// hungDB blocks every statement until the context is done.
+type hungDB struct{ db.DB }
+
+func (hungDB) ExecContext(ctx context.Context, _ string, _ ...any) (sql.Result, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestElector_HungRenewal(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	d := setup(t)
+	e := New(d, "job", WithTTL(100*time.Millisecond))
+	is.NoErr(e.CreateTable(ctx))
+	is.NoErr(e.Campaign(ctx))
+	is.True(e.IsLeader())
+
+	e.db = hungDB{d}
+	start := time.Now()
+	err := e.Campaign(ctx)
+	is.True(errors.Is(err, context.DeadlineExceeded))
+	is.True(time.Since(start) < 100*time.Millisecond)
+	is.True(!e.IsLeader())
+}
@@ -0,0 +1,148 @@
+package db
+
+import (
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// WithLeakDetection tracks every [Rows] returned by the wrapper along with the
+// stack trace of the caller that created it. Rows that are still open after
+// the window are logged as leaked, and any rows still open when the database
+// is closed are logged as well. A window of zero only checks on close.
+//
+// Capturing stack traces is expensive so this is meant for debugging and
+// tests.
+func WithLeakDetection(window time.Duration) Option {
+	return func(d *dbOptions) {
+		if d.leaks == nil {
+			d.leaks = &leakTracker{}
+		}
+		d.leaks.window = window
+	}
+}
+
+// WithLeakPanic makes closing the database panic if there are open rows. It
+// enables leak detection if [WithLeakDetection] was not used.
+func WithLeakPanic() Option {
+	return func(d *dbOptions) {
+		if d.leaks == nil {
+			d.leaks = &leakTracker{}
+		}
+		d.leaks.panics = true
+	}
+}
+
+// LeakError describes rows that were not closed.
+type LeakError struct {
+	// Stacks are the stack traces of the callers that created each of the
+	// leaked rows.
+	Stacks []string
+}
+
+func (e *LeakError) Error() string {
+	return fmt.Sprintf("%d rows were not closed", len(e.Stacks))
+}
+
+type leakTracker struct {
+	window time.Duration
+	panics bool
+	logger *slog.Logger
+
+	mu   sync.Mutex
+	open map[*leakRows]struct{}
+}
+
+func (lt *leakTracker) track(rows Rows) Rows {
+	r := &leakRows{Rows: rows, tracker: lt, stack: debug.Stack(), created: now()}
+	lt.mu.Lock()
+	if lt.open == nil {
+		lt.open = make(map[*leakRows]struct{})
+	}
+	lt.open[r] = struct{}{}
+	lt.mu.Unlock()
+	if lt.window > 0 {
+		r.timer = time.AfterFunc(lt.window, func() {
+			lt.logger.Warn("rows not closed",
+				slog.Duration("window", lt.window),
+				slog.String("stack", string(r.stack)))
+		})
+	}
+	return r
+}
+
+func (lt *leakTracker) untrack(r *leakRows) {
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+	lt.mu.Lock()
+	delete(lt.open, r)
+	lt.mu.Unlock()
+}
+
+// check logs every open rows and returns a *LeakError if there are any.
+func (lt *leakTracker) check() error {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+	if len(lt.open) == 0 {
+		return nil
+	}
+	leak := &LeakError{}
+	for r := range lt.open {
+		lt.logger.Error("rows not closed before closing the database",
+			slog.Duration("age", now().Sub(r.created)),
+			slog.String("stack", string(r.stack)))
+		leak.Stacks = append(leak.Stacks, string(r.stack))
+	}
+	return leak
+}
+
+type leakRows struct {
+	Rows
+	tracker *leakTracker
+	stack   []byte
+	created time.Time
+	timer   *time.Timer
+	once    sync.Once
+}
+
+func (r *leakRows) done() { r.once.Do(func() { r.tracker.untrack(r) }) }
+
+func (r *leakRows) Next() bool {
+	if r.Rows.Next() {
+		return true
+	}
+	r.done()
+	return false
+}
+
+func (r *leakRows) Close() error {
+	r.done()
+	return r.Rows.Close()
+}
+
+func (r *leakRows) Columns() ([]string, error) {
+	c, ok := r.Rows.(columner)
+	if !ok {
+		return nil, errors.New("rows do not have column names")
+	}
+	return c.Columns()
+}
+
+// Close closes the database. When leak detection is enabled any open rows
+// are logged first and [WithLeakPanic] will panic with a *[LeakError].
+func (db *database) Close() error {
+	if db.leaks != nil {
+		if err := db.leaks.check(); err != nil && db.leaks.panics {
+			panic(err)
+		}
+	}
+	if db.stop != nil {
+		db.stopOnce.Do(func() { close(db.stop) })
+	}
+	return db.DB.Close()
+}
@@ -0,0 +1,96 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+type syncBuffer struct {
+	mu sync.Mutex
+	b  bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.b.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.b.String()
+}
+
+func TestWithLeakDetection(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	pool, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	var logs syncBuffer
+	d := New(pool,
+		WithLogger(slog.New(slog.NewTextHandler(&logs, nil))),
+		WithLeakDetection(10*time.Millisecond))
+
+	// Rows that are read to the end or closed are not leaks.
+	rows, err := d.QueryContext(ctx, "SELECT 1")
+	is.NoErr(err)
+	for rows.Next() {
+	}
+	is.NoErr(rows.Close())
+	rows, err = d.QueryContext(ctx, "SELECT 1")
+	is.NoErr(err)
+	is.NoErr(rows.Close())
+	tx, err := d.BeginTx(ctx, nil)
+	is.NoErr(err)
+	rows, err = tx.QueryContext(ctx, "SELECT 1")
+	is.NoErr(err)
+	var n int
+	is.NoErr(ScanOne(rows, &n))
+	is.NoErr(tx.Commit())
+
+	leaked, err := d.QueryContext(ctx, "SELECT 1")
+	is.NoErr(err)
+	time.Sleep(50 * time.Millisecond)
+	out := logs.String()
+	is.Equal(strings.Count(out, "rows not closed"), 1)
+	is.True(strings.Contains(out, "TestWithLeakDetection"))
+
+	cols, err := leaked.(columner).Columns()
+	is.NoErr(err)
+	is.Equal(cols, []string{"1"})
+
+	is.NoErr(d.Close())
+	is.True(strings.Contains(logs.String(), "rows not closed before closing the database"))
+}
+
+func TestWithLeakPanic(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	pool, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer pool.Close()
+	d := New(pool, WithLeakPanic())
+	rows, err := d.QueryContext(ctx, "SELECT 1")
+	is.NoErr(err)
+	func() {
+		defer func() {
+			r := recover()
+			le, ok := r.(*LeakError)
+			is.True(ok)
+			is.Equal(len(le.Stacks), 1)
+			is.Equal(le.Error(), "1 rows were not closed")
+		}()
+		d.Close()
+	}()
+	is.NoErr(rows.Close())
+	is.NoErr(d.Close())
+}
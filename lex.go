@@ -0,0 +1,311 @@
+package db
+
+import "strings"
+
+// skipQuoted checks if the query has a string literal, quoted identifier, or
+// comment starting at index i. If it does, the index of the first byte after
+// it is returned along with true.
+func skipQuoted(query string, i int) (int, bool) {
+	switch c := query[i]; c {
+	case '\'', '"', '`':
+		for j := i + 1; j < len(query); j++ {
+			if query[j] != c {
+				continue
+			}
+			// A doubled quote is an escaped quote.
+			if j+1 < len(query) && query[j+1] == c {
+				j++
+				continue
+			}
+			return j + 1, true
+		}
+		return len(query), true
+	case '-':
+		if i+1 < len(query) && query[i+1] == '-' {
+			for j := i + 2; j < len(query); j++ {
+				if query[j] == '\n' {
+					return j + 1, true
+				}
+			}
+			return len(query), true
+		}
+	case '/':
+		if i+1 < len(query) && query[i+1] == '*' {
+			for j := i + 2; j+1 < len(query); j++ {
+				if query[j] == '*' && query[j+1] == '/' {
+					return j + 2, true
+				}
+			}
+			return len(query), true
+		}
+	case '$':
+		tag, ok := dollarTag(query, i)
+		if !ok {
+			return i, false
+		}
+		for j := i + len(tag); j < len(query); j++ {
+			if query[j] == '$' && hasPrefixAt(query, j, tag) {
+				return j + len(tag), true
+			}
+		}
+		return len(query), true
+	}
+	return i, false
+}
+
+// dollarTag returns the postgres dollar quote tag (i.e. "$$" or "$body$")
+// starting at index i.
+func dollarTag(query string, i int) (string, bool) {
+	for j := i + 1; j < len(query); j++ {
+		c := query[j]
+		switch {
+		case c == '$':
+			return query[i : j+1], true
+		case c == '_' || isLetter(c):
+		case isDigit(c) && j > i+1:
+		default:
+			return "", false
+		}
+	}
+	return "", false
+}
+
+func hasPrefixAt(s string, i int, prefix string) bool {
+	return len(s)-i >= len(prefix) && s[i:i+len(prefix)] == prefix
+}
+
+func isDigit(c byte) bool  { return c >= '0' && c <= '9' }
+func isLetter(c byte) bool { return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') }
+
+// readNumber reads the decimal number starting at index i and returns the
+// index of the first byte after it.
+func readNumber(query string, i int) (n, end int) {
+	for end = i; end < len(query) && isDigit(query[end]); end++ {
+		n = n*10 + int(query[end]-'0')
+	}
+	return n, end
+}
+
+// stmtClass is a rough classification of what a statement does.
+type stmtClass uint8
+
+const (
+	stmtRead stmtClass = 1 << iota
+	stmtWrite
+	stmtDDL
+	stmtOther
+)
+
+// classifyStatement returns the classes of all the statements in a query
+// combined into a bit set. Statements are classified by their leading keyword and CTEs are
+// considered writes if they contain a data modifying keyword. EXPLAIN is a
+// read unless it has the ANALYZE option, which runs the explained statement
+// so it is classified like that statement.
+func classifyStatement(query string) stmtClass {
+	var class stmtClass
+	first, cte := "", false
+	// explain is true between EXPLAIN and the statement it explains.
+	explain, analyze := false, false
+	for i := 0; i < len(query); {
+		if j, ok := skipQuoted(query, i); ok {
+			i = j
+			continue
+		}
+		c := query[i]
+		switch {
+		case c == ';':
+			if explain {
+				class |= stmtRead
+			}
+			first, cte, explain, analyze = "", false, false, false
+			i++
+		case isLetter(c) || c == '_':
+			j := i
+			for j < len(query) && (isLetter(query[j]) || isDigit(query[j]) || query[j] == '_') {
+				j++
+			}
+			word := strings.ToLower(query[i:j])
+			i = j
+			switch {
+			case len(first) == 0 && word == "explain":
+				first, explain = word, true
+			case explain:
+				if word == "analyze" || word == "analyse" {
+					analyze = true
+					continue
+				}
+				wc := keywordClass(word)
+				if wc != stmtRead && wc != stmtWrite {
+					continue // an option like VERBOSE or FORMAT JSON
+				}
+				explain = false
+				if !analyze {
+					class |= stmtRead
+					continue
+				}
+				cte = word == "with"
+				class |= wc
+			case len(first) == 0:
+				first = word
+				cte = word == "with"
+				class |= keywordClass(word)
+			case cte && keywordClass(word) == stmtWrite:
+				class |= stmtWrite
+			}
+		default:
+			i++
+		}
+	}
+	if explain {
+		class |= stmtRead
+	}
+	return class
+}
+
+func keywordClass(word string) stmtClass {
+	switch word {
+	case "select", "with", "show", "explain", "values", "table", "describe", "desc":
+		return stmtRead
+	case "insert", "update", "delete", "merge", "replace", "upsert", "copy", "load", "call":
+		return stmtWrite
+	case "create", "alter", "drop", "truncate", "grant", "revoke", "comment",
+		"rename", "reindex", "vacuum", "cluster", "analyze", "refresh":
+		return stmtDDL
+	}
+	return stmtOther
+}
+
+// sqlTokens splits a query into lower case words, unquoted identifiers and
+// single punctuation characters. String literals and comments are dropped.
+func sqlTokens(query string) []string {
+	var toks []string
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == '"' || c == '`' || c == '[':
+			end := byte(c)
+			if c == '[' {
+				end = ']'
+			}
+			j := strings.IndexByte(query[i+1:], end)
+			if j < 0 {
+				return toks
+			}
+			toks = append(toks, strings.ToLower(query[i+1:i+1+j]))
+			i += j + 2
+		case isLetter(c) || c == '_':
+			j := i
+			for j < len(query) && (isLetter(query[j]) || isDigit(query[j]) || query[j] == '_' || query[j] == '$') {
+				j++
+			}
+			toks = append(toks, strings.ToLower(query[i:j]))
+			i = j
+		default:
+			if j, ok := skipQuoted(query, i); ok {
+				i = j
+				continue
+			}
+			if c > ' ' {
+				toks = append(toks, query[i:i+1])
+			}
+			i++
+		}
+	}
+	return toks
+}
+
+// isLockingRead reports whether a query takes row locks with FOR UPDATE,
+// FOR NO KEY UPDATE, FOR SHARE, FOR KEY SHARE or MySQL's LOCK IN SHARE MODE.
+// These need the primary even though they are classified as reads.
+func isLockingRead(query string) bool {
+	toks := sqlTokens(query)
+	for i := 0; i+1 < len(toks); i++ {
+		switch next := toks[i+1]; toks[i] {
+		case "for":
+			if next == "update" || next == "share" || next == "no" || next == "key" {
+				return true
+			}
+		case "lock":
+			if next == "in" && i+3 < len(toks) && toks[i+2] == "share" && toks[i+3] == "mode" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// writtenTables returns the tables that a query inserts into, updates,
+// deletes from or truncates. Names are lower case without quotes and a
+// schema qualified name is also returned without its schema.
+func writtenTables(query string) []string {
+	var (
+		tables []string
+		toks   = sqlTokens(query)
+	)
+	at := func(i int) string {
+		if i >= 0 && i < len(toks) {
+			return toks[i]
+		}
+		return ""
+	}
+	for i := 0; i < len(toks); i++ {
+		start, list := -1, false
+		switch toks[i] {
+		case "insert", "replace", "upsert", "merge":
+			j := i + 1
+			for at(j) == "ignore" || at(j) == "low_priority" || at(j) == "delayed" {
+				j++
+			}
+			if at(j) == "into" {
+				start = j + 1
+			}
+		case "update":
+			// Skip "DO UPDATE", "FOR UPDATE" and "KEY UPDATE".
+			if prev := at(i - 1); prev != "do" && prev != "for" && prev != "key" {
+				start = i + 1
+			}
+		case "delete":
+			if at(i+1) == "from" {
+				start = i + 2
+			}
+		case "truncate":
+			start, list = i+1, true
+		}
+		for start >= 0 && start < len(toks) {
+			for at(start) == "table" || at(start) == "only" || at(start) == "ignore" || at(start) == "low_priority" {
+				start++
+			}
+			name, end := qualifiedName(toks, start)
+			if len(name) == 0 {
+				break
+			}
+			tables = append(tables, name)
+			if dot := strings.LastIndexByte(name, '.'); dot >= 0 {
+				tables = append(tables, name[dot+1:])
+			}
+			if !list || at(end) != "," {
+				break
+			}
+			start = end + 1
+		}
+	}
+	return tables
+}
+
+// qualifiedName reads a dotted name starting at toks[i] and returns it with
+// the index of the token after it.
+func qualifiedName(toks []string, i int) (string, int) {
+	if i >= len(toks) || !isName(toks[i]) {
+		return "", i
+	}
+	name := toks[i]
+	for i+2 < len(toks) && toks[i+1] == "." && isName(toks[i+2]) {
+		name += "." + toks[i+2]
+		i += 2
+	}
+	return name, i + 1
+}
+
+func isName(tok string) bool {
+	return len(tok) > 1 || (len(tok) == 1 && (isLetter(tok[0]) || tok[0] == '_'))
+}
@@ -0,0 +1,81 @@
+package db
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// WithMaxConcurrency limits the number of queries and execs that the
+// wrapper from [New] runs at the same time. Callers over the limit wait until
+// a slot is free or their context is done. A query holds its slot until its
+// rows are closed or fully read.
+func WithMaxConcurrency(n int) Option {
+	return func(o *dbOptions) {
+		if n > 0 {
+			o.limiter = &limiter{sem: make(chan struct{}, n)}
+		}
+	}
+}
+
+// WithConcurrencyWaitHook sets a function that is called with the time each
+// statement spent waiting for a slot when using [WithMaxConcurrency].
+func WithConcurrencyWaitHook(fn func(ctx context.Context, wait time.Duration)) Option {
+	return func(o *dbOptions) { o.waitHook = fn }
+}
+
+type limiter struct {
+	sem  chan struct{}
+	hook func(context.Context, time.Duration)
+}
+
+// acquire waits for a slot and returns a function that releases it.
+func (l *limiter) acquire(ctx context.Context) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	start := now()
+	select {
+	case l.sem <- struct{}{}:
+	default:
+		select {
+		case l.sem <- struct{}{}:
+		case <-ctx.Done():
+			return nil, errors.Wrap(ctx.Err(), "waiting for database concurrency limit")
+		}
+	}
+	if l.hook != nil {
+		l.hook(ctx, now().Sub(start))
+	}
+	var once sync.Once
+	return func() { once.Do(func() { <-l.sem }) }, nil
+}
+
+// releaseRows calls release once the rows are done.
+type releaseRows struct {
+	Rows
+	release func()
+}
+
+func (r *releaseRows) Next() bool {
+	if r.Rows.Next() {
+		return true
+	}
+	r.release()
+	return false
+}
+
+func (r *releaseRows) Close() error {
+	defer r.release()
+	return r.Rows.Close()
+}
+
+func (r *releaseRows) Columns() ([]string, error) {
+	c, ok := r.Rows.(columner)
+	if !ok {
+		return nil, errors.New("rows do not have column names")
+	}
+	return c.Columns()
+}
@@ -0,0 +1,48 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/pkg/errors"
+)
+
+func TestWithMaxConcurrency(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	pool, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer pool.Close()
+	pool.SetMaxOpenConns(1)
+	var waits []time.Duration
+	d := New(pool, WithMaxConcurrency(1), WithConcurrencyWaitHook(func(_ context.Context, wait time.Duration) {
+		waits = append(waits, wait)
+	}))
+
+	rows, err := d.QueryContext(ctx, "select 1 union all select 2")
+	is.NoErr(err)
+	// The open rows hold the only slot.
+	tctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	_, err = d.ExecContext(tctx, "select 1")
+	cancel()
+	is.True(errors.Is(err, context.DeadlineExceeded))
+	for rows.Next() {
+	}
+	// Reading all the rows frees the slot.
+	_, err = d.ExecContext(ctx, "select 1")
+	is.NoErr(err)
+	is.NoErr(rows.Close())
+
+	var u struct{ N int }
+	is.NoErr(Get(ctx, d, &u, "select 3 as n"))
+	is.Equal(u.N, 3)
+	_, err = d.QueryContext(ctx, "not sql")
+	is.True(err != nil)
+	_, err = d.ExecContext(ctx, "select 1")
+	is.NoErr(err)
+	is.Equal(len(waits), 5)
+	is.Equal(len(d.limiter.sem), 0)
+}
@@ -0,0 +1,325 @@
+package db
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrInvalidListRequest is returned when a [ListRequest] cannot be parsed.
+var ErrInvalidListRequest = errors.New("invalid list request")
+
+// FilterOp is a comparison used by a [Filter].
+type FilterOp string
+
+const (
+	OpEq   FilterOp = "eq"
+	OpNe   FilterOp = "ne"
+	OpLt   FilterOp = "lt"
+	OpLte  FilterOp = "lte"
+	OpGt   FilterOp = "gt"
+	OpGte  FilterOp = "gte"
+	OpLike FilterOp = "like"
+	OpIn   FilterOp = "in"
+)
+
+var filterOpSQL = map[FilterOp]string{
+	OpEq:   "=",
+	OpNe:   "<>",
+	OpLt:   "<",
+	OpLte:  "<=",
+	OpGt:   ">",
+	OpGte:  ">=",
+	OpLike: "LIKE",
+	OpIn:   "IN",
+}
+
+// Filter is a condition on a column.
+type Filter struct {
+	// Field is the name used in the request.
+	Field string
+	// Column is the database column the field maps to.
+	Column string
+	Op     FilterOp
+	// Values holds one value for every operator except [OpIn].
+	Values []string
+}
+
+// SortField is a column to order by.
+type SortField struct {
+	Field  string
+	Column string
+	Desc   bool
+}
+
+// ListSpec is the safelist of fields a list endpoint accepts.
+type ListSpec struct {
+	// Filters maps request field names to database columns.
+	Filters map[string]string
+	// Sorts maps request field names to database columns.
+	Sorts map[string]string
+	// DefaultSort is used when the request has no sort, i.e. "-created_at".
+	DefaultSort string
+	// DefaultPageSize defaults to 20. It is capped by MaxPageSize.
+	DefaultPageSize int
+	// MaxPageSize defaults to 100.
+	MaxPageSize int
+}
+
+// ListRequest is a parsed and validated request for a page of a list.
+type ListRequest struct {
+	// Page starts at 1 and is ignored when a Cursor is used.
+	Page     int
+	PageSize int
+	// Cursor is an opaque value for keyset pagination made by
+	// [EncodeCursor].
+	Cursor string
+	// After holds the values of the sort columns of the last row of the
+	// previous page, decoded from Cursor. The page starts after that row.
+	After   []string
+	Filters []Filter
+	Sort    []SortField
+}
+
+// EncodeCursor returns the cursor of the page after a row given the row's
+// values of the request's sort columns in order. The sort should end with a
+// unique column like the primary key so that rows with equal values are
+// not skipped, and the sort columns should not be NULL.
+func EncodeCursor(values ...any) string {
+	strs := make([]string, len(values))
+	for i, v := range values {
+		switch v := v.(type) {
+		case time.Time:
+			strs[i] = v.Format(time.RFC3339Nano)
+		case []byte:
+			strs[i] = string(v)
+		default:
+			strs[i] = fmt.Sprint(v)
+		}
+	}
+	b, _ := json.Marshal(strs)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(cursor string) ([]string, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, err
+	}
+	var values []string
+	if err = json.Unmarshal(b, &values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// ParseListRequest parses a [ListRequest] from url query values. It accepts
+// "page", "page_size", "cursor", and "sort" which is a comma separated list
+// of fields that are prefixed with "-" for descending order. A cursor needs a
+// sort and must have a value for every sort field, see [EncodeCursor]. Every other key
+// is a filter in the form "field=value" or "field[op]=value". Fields that are
+// not in the spec are rejected.
+func ParseListRequest(v url.Values, spec ListSpec) (*ListRequest, error) {
+	if spec.DefaultPageSize <= 0 {
+		spec.DefaultPageSize = 20
+	}
+	if spec.MaxPageSize <= 0 {
+		spec.MaxPageSize = 100
+	}
+	r := ListRequest{Page: 1, PageSize: min(spec.DefaultPageSize, spec.MaxPageSize), Cursor: v.Get("cursor")}
+	var err error
+	if p := v.Get("page"); len(p) > 0 {
+		if r.Page, err = strconv.Atoi(p); err != nil || r.Page < 1 {
+			return nil, errors.Wrapf(ErrInvalidListRequest, "invalid page %q", p)
+		}
+	}
+	if p := v.Get("page_size"); len(p) > 0 {
+		if r.PageSize, err = strconv.Atoi(p); err != nil || r.PageSize < 1 {
+			return nil, errors.Wrapf(ErrInvalidListRequest, "invalid page_size %q", p)
+		}
+		r.PageSize = min(r.PageSize, spec.MaxPageSize)
+	}
+
+	sortParam := v.Get("sort")
+	if len(sortParam) == 0 {
+		sortParam = spec.DefaultSort
+	}
+	for _, s := range strings.Split(sortParam, ",") {
+		s = strings.TrimSpace(s)
+		if len(s) == 0 {
+			continue
+		}
+		sf := SortField{Field: s}
+		if f, ok := strings.CutPrefix(s, "-"); ok {
+			sf.Field, sf.Desc = f, true
+		}
+		col, ok := spec.Sorts[sf.Field]
+		if !ok {
+			return nil, errors.Wrapf(ErrInvalidListRequest, "cannot sort by %q", sf.Field)
+		}
+		sf.Column = col
+		r.Sort = append(r.Sort, sf)
+	}
+	if len(r.Cursor) > 0 {
+		if r.After, err = decodeCursor(r.Cursor); err != nil || len(r.After) != len(r.Sort) || len(r.Sort) == 0 {
+			return nil, errors.Wrap(ErrInvalidListRequest, "invalid cursor")
+		}
+	}
+
+	keys := make([]string, 0, len(v))
+	for k := range v {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		switch key {
+		case "page", "page_size", "cursor", "sort":
+			continue
+		}
+		f := Filter{Field: key, Op: OpEq}
+		if name, rest, ok := strings.Cut(key, "["); ok && strings.HasSuffix(rest, "]") {
+			f.Field, f.Op = name, FilterOp(strings.TrimSuffix(rest, "]"))
+		}
+		if _, ok := filterOpSQL[f.Op]; !ok {
+			return nil, errors.Wrapf(ErrInvalidListRequest, "unknown filter operator %q", f.Op)
+		}
+		col, ok := spec.Filters[f.Field]
+		if !ok {
+			return nil, errors.Wrapf(ErrInvalidListRequest, "cannot filter by %q", f.Field)
+		}
+		f.Column = col
+		for _, val := range v[key] {
+			if f.Op == OpIn {
+				f.Values = strings.Split(val, ",")
+			} else {
+				f.Values = []string{val}
+			}
+			r.Filters = append(r.Filters, f)
+		}
+	}
+	return &r, nil
+}
+
+// Offset returns the number of rows to skip for the page.
+func (r *ListRequest) Offset() int {
+	if len(r.Cursor) > 0 || r.Page < 1 {
+		return 0
+	}
+	return (r.Page - 1) * r.PageSize
+}
+
+// Where returns the filters and the cursor's keyset condition as a WHERE
+// clause and its arguments. The first placeholder is numbered argStart+1 so
+// the clause can follow other arguments. An empty string is returned when
+// there are no filters and no cursor.
+func (r *ListRequest) Where(d Dialect, argStart int) (string, []any) {
+	keyset := len(r.After) > 0 && len(r.After) == len(r.Sort)
+	if len(r.Filters) == 0 && !keyset {
+		return "", nil
+	}
+	var (
+		b    strings.Builder
+		args []any
+	)
+	arg := func(v any) string {
+		args = append(args, v)
+		return d.Placeholder(argStart + len(args))
+	}
+	b.WriteString("WHERE ")
+	for i, f := range r.Filters {
+		if i > 0 {
+			b.WriteString(" AND ")
+		}
+		b.WriteString(d.QuoteIdent(f.Column))
+		b.WriteByte(' ')
+		b.WriteString(filterOpSQL[f.Op])
+		b.WriteByte(' ')
+		if f.Op == OpIn {
+			b.WriteByte('(')
+		}
+		for j, val := range f.Values {
+			if j > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(arg(val))
+		}
+		if f.Op == OpIn {
+			b.WriteByte(')')
+		}
+	}
+	if keyset {
+		if len(r.Filters) > 0 {
+			b.WriteString(" AND ")
+		}
+		r.writeKeyset(&b, d, arg)
+	}
+	return b.String(), args
+}
+
+// writeKeyset writes the condition for rows that come after the cursor in
+// the sort order, i.e. "(a > $1 OR (a = $2 AND b < $3))" for "a,-b".
+func (r *ListRequest) writeKeyset(b *strings.Builder, d Dialect, arg func(any) string) {
+	b.WriteByte('(')
+	for i, s := range r.Sort {
+		if i > 0 {
+			b.WriteString(" OR (")
+		}
+		for j, prev := range r.Sort[:i] {
+			b.WriteString(d.QuoteIdent(prev.Column) + " = " + arg(r.After[j]) + " AND ")
+		}
+		op := " > "
+		if s.Desc {
+			op = " < "
+		}
+		b.WriteString(d.QuoteIdent(s.Column) + op + arg(r.After[i]))
+		if i > 0 {
+			b.WriteByte(')')
+		}
+	}
+	b.WriteByte(')')
+}
+
+// OrderBy returns the ORDER BY clause or an empty string if there is no
+// sort.
+func (r *ListRequest) OrderBy(d Dialect) string {
+	if len(r.Sort) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("ORDER BY ")
+	for i, s := range r.Sort {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(d.QuoteIdent(s.Column))
+		if s.Desc {
+			b.WriteString(" DESC")
+		} else {
+			b.WriteString(" ASC")
+		}
+	}
+	return b.String()
+}
+
+// Limit returns the LIMIT and OFFSET clause for the page.
+func (r *ListRequest) Limit(d Dialect) string { return d.Limit(r.PageSize, r.Offset()) }
+
+// Clauses returns the WHERE, ORDER BY and LIMIT clauses joined together so
+// they can be appended to a SELECT statement.
+func (r *ListRequest) Clauses(d Dialect, argStart int) (string, []any) {
+	where, args := r.Where(d, argStart)
+	parts := make([]string, 0, 3)
+	for _, p := range []string{where, r.OrderBy(d), r.Limit(d)} {
+		if len(p) > 0 {
+			parts = append(parts, p)
+		}
+	}
+	return strings.Join(parts, " "), args
+}
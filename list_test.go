@@ -0,0 +1,123 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"net/url"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/pkg/errors"
+)
+
+var userListSpec = ListSpec{
+	Filters:     map[string]string{"name": "name", "age": "age", "org": "org_id"},
+	Sorts:       map[string]string{"name": "name", "created": "created_at"},
+	DefaultSort: "-created",
+	MaxPageSize: 50,
+}
+
+func TestParseListRequest(t *testing.T) {
+	is := is.New(t)
+	v, err := url.ParseQuery("page=3&page_size=500&sort=name,-created&name[like]=j%25&age[gte]=18&org[in]=1,2")
+	is.NoErr(err)
+	r, err := ParseListRequest(v, userListSpec)
+	is.NoErr(err)
+	is.Equal(r.Page, 3)
+	is.Equal(r.PageSize, 50)
+	is.Equal(r.Offset(), 100)
+	is.Equal(r.Sort, []SortField{{"name", "name", false}, {"created", "created_at", true}})
+	is.Equal(len(r.Filters), 3)
+
+	// The default page size is capped too.
+	def, err := ParseListRequest(url.Values{}, ListSpec{DefaultPageSize: 500, MaxPageSize: 50})
+	is.NoErr(err)
+	is.Equal(def.PageSize, 50)
+	def, err = ParseListRequest(url.Values{}, ListSpec{DefaultPageSize: 500})
+	is.NoErr(err)
+	is.Equal(def.PageSize, 100)
+
+	clauses, args := r.Clauses(DialectFor(PostgresDBType), 1)
+	is.Equal(clauses, `WHERE "age" >= $2 AND "name" LIKE $3 AND "org_id" IN ($4, $5) ORDER BY "name" ASC, "created_at" DESC LIMIT 50 OFFSET 100`)
+	is.Equal(args, []any{"18", "j%", "1", "2"})
+
+	r, err = ParseListRequest(url.Values{"cursor": {EncodeCursor(7)}, "name": {"jim"}}, userListSpec)
+	is.NoErr(err)
+	is.Equal(r.PageSize, 20)
+	is.Equal(r.Offset(), 0)
+	is.Equal(r.Sort, []SortField{{"created", "created_at", true}})
+	is.Equal(r.After, []string{"7"})
+	clauses, args = r.Clauses(DialectFor(MySQLDBType), 0)
+	is.Equal(clauses, "WHERE `name` = ? AND (`created_at` < ?) ORDER BY `created_at` DESC LIMIT 20")
+	is.Equal(args, []any{"jim", "7"})
+
+	r, err = ParseListRequest(url.Values{"cursor": {EncodeCursor("jim", 3)}, "sort": {"name,-created"}}, userListSpec)
+	is.NoErr(err)
+	clauses, args = r.Clauses(DialectFor(PostgresDBType), 0)
+	is.Equal(clauses, `WHERE ("name" > $1 OR ("name" = $2 AND "created_at" < $3)) ORDER BY "name" ASC, "created_at" DESC LIMIT 20`)
+	is.Equal(args, []any{"jim", "jim", "3"})
+
+	_, err = ParseListRequest(url.Values{"cursor": {EncodeCursor(1)}}, ListSpec{})
+	is.True(errors.Is(err, ErrInvalidListRequest)) // a cursor needs a sort
+	r, err = ParseListRequest(url.Values{}, ListSpec{})
+	is.NoErr(err)
+	clauses, args = r.Clauses(DialectFor(PostgresDBType), 0)
+	is.Equal(clauses, "LIMIT 20")
+	is.Equal(len(args), 0)
+
+	for _, q := range []string{
+		"page=0", "page=x", "page_size=-1", "sort=password", "password=x", "name[regex]=x",
+		"cursor=abc", "cursor=" + EncodeCursor(1, 2),
+	} {
+		v, err := url.ParseQuery(q)
+		is.NoErr(err)
+		_, err = ParseListRequest(v, userListSpec)
+		is.True(errors.Is(err, ErrInvalidListRequest))
+	}
+}
+
+func TestListRequest_Query(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	pool, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer pool.Close()
+	pool.SetMaxOpenConns(1)
+	_, err = pool.ExecContext(ctx, `
+		create table users (name text, age int, org_id int, created_at int);
+		insert into users values ('a', 10, 1, 1), ('b', 20, 1, 2), ('c', 30, 2, 3), ('d', 40, 3, 4);`)
+	is.NoErr(err)
+	v, _ := url.ParseQuery("age[gt]=10&org[in]=1,2&page_size=1&page=2")
+	r, err := ParseListRequest(v, userListSpec)
+	is.NoErr(err)
+	clauses, args := r.Clauses(DialectFor(SQLiteDBType), 0)
+	var name string
+	is.NoErr(pool.QueryRowContext(ctx, "SELECT name FROM users "+clauses, args...).Scan(&name))
+	is.Equal(name, "b")
+
+	// Walk the pages with cursors.
+	var names []string
+	v = url.Values{"sort": {"-created"}, "page_size": {"3"}}
+	for {
+		r, err = ParseListRequest(v, userListSpec)
+		is.NoErr(err)
+		clauses, args = r.Clauses(DialectFor(SQLiteDBType), 0)
+		rows, err := pool.QueryContext(ctx, "SELECT name, created_at FROM users "+clauses, args...)
+		is.NoErr(err)
+		var (
+			n       int
+			created int
+		)
+		for rows.Next() {
+			is.NoErr(rows.Scan(&name, &created))
+			names = append(names, name)
+			n++
+		}
+		is.NoErr(rows.Err())
+		if n < r.PageSize {
+			break
+		}
+		v.Set("cursor", EncodeCursor(created))
+	}
+	is.Equal(names, []string{"d", "c", "b", "a"})
+}
@@ -0,0 +1,101 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrLockNotSupported is returned when the database does not have
+	// advisory locks.
+	ErrLockNotSupported = errors.New("advisory locks are not supported by this database")
+	// ErrLockNotAcquired is returned by [TryLock] when the lock is held by
+	// someone else.
+	ErrLockNotAcquired = errors.New("lock is held by another session")
+)
+
+// Conner is an abstract type that can check out a dedicated connection such
+// as a [sql.DB] or the wrapper returned by [New].
+type Conner interface {
+	Conn(ctx context.Context) (*sql.Conn, error)
+}
+
+// lockRetryInterval is how often [Lock] tries to take a held lock.
+var lockRetryInterval = 100 * time.Millisecond
+
+// Lock takes a named advisory lock that is shared by every client of the
+// database, blocking until it is acquired or the context is done. It uses
+// pg_advisory_lock on postgres and GET_LOCK on mysql. The lock is held by a
+// dedicated connection until unlock is called.
+func Lock(ctx context.Context, db Conner, key string) (unlock func() error, err error) {
+	for {
+		unlock, err = TryLock(ctx, db, key)
+		if !errors.Is(err, ErrLockNotAcquired) {
+			return unlock, err
+		}
+		t := time.NewTimer(lockRetryInterval)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil, errors.Wrapf(ctx.Err(), "failed to acquire lock %q", key)
+		case <-t.C:
+		}
+	}
+}
+
+// TryLock takes a named advisory lock without waiting. If the lock is held
+// then [ErrLockNotAcquired] is returned.
+func TryLock(ctx context.Context, db Conner, key string) (unlock func() error, err error) {
+	var lockQuery, unlockQuery string
+	var arg any
+	switch DialectOf(db).Type() {
+	case PostgresDBType:
+		lockQuery, unlockQuery = "SELECT pg_try_advisory_lock($1)", "SELECT pg_advisory_unlock($1)"
+		arg = lockID(key)
+	case MySQLDBType:
+		lockQuery, unlockQuery = "SELECT GET_LOCK(?, 0)", "SELECT RELEASE_LOCK(?)"
+		arg = key
+	default:
+		return nil, ErrLockNotSupported
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var ok sql.NullBool
+	if err = conn.QueryRowContext(ctx, lockQuery, arg).Scan(&ok); err != nil {
+		conn.Close()
+		return nil, errors.Wrapf(err, "failed to acquire lock %q", key)
+	}
+	if !ok.Valid || !ok.Bool {
+		conn.Close()
+		return nil, errors.Wrapf(ErrLockNotAcquired, "%q", key)
+	}
+	var once sync.Once
+	return func() (err error) {
+		once.Do(func() {
+			defer conn.Close()
+			var released sql.NullBool
+			err = conn.QueryRowContext(context.Background(), unlockQuery, arg).Scan(&released)
+			if err != nil {
+				err = errors.Wrapf(err, "failed to release lock %q", key)
+			} else if !released.Bool {
+				err = errors.Errorf("lock %q was not held", key)
+			}
+		})
+		return err
+	}, nil
+}
+
+// lockID hashes a lock name to the 64 bit key used by postgres advisory
+// locks.
+func lockID(key string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return int64(h.Sum64())
+}
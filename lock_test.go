@@ -0,0 +1,99 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/mattn/go-sqlite3"
+	"github.com/pkg/errors"
+)
+
+// fakeLocks emulates advisory locks with sqlite functions. Locks are owned
+// by connection.
+type fakeLocks struct {
+	mu   sync.Mutex
+	held map[any]*sqlite3.SQLiteConn
+}
+
+func (l *fakeLocks) register(c *sqlite3.SQLiteConn) error {
+	lock := func(key any) bool {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if owner, ok := l.held[key]; ok && owner != c {
+			return false
+		}
+		l.held[key] = c
+		return true
+	}
+	unlock := func(key any) bool {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if l.held[key] != c {
+			return false
+		}
+		delete(l.held, key)
+		return true
+	}
+	for name, fn := range map[string]any{
+		"pg_try_advisory_lock": lock,
+		"pg_advisory_unlock":   unlock,
+		"GET_LOCK":             func(key string, _ int) bool { return lock(key) },
+		"RELEASE_LOCK":         func(key string) bool { return unlock(key) },
+	} {
+		if err := c.RegisterFunc(name, fn, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var locks = fakeLocks{held: make(map[any]*sqlite3.SQLiteConn)}
+
+func init() {
+	sql.Register("sqlite3_locks", &sqlite3.SQLiteDriver{ConnectHook: locks.register})
+}
+
+func TestLock(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	defer func(d time.Duration) { lockRetryInterval = d }(lockRetryInterval)
+	lockRetryInterval = time.Millisecond
+	pool, err := sql.Open("sqlite3_locks", ":memory:")
+	is.NoErr(err)
+	defer pool.Close()
+
+	for _, d := range []Conner{pool, New(pool, WithDialect(DialectFor(MySQLDBType)))} {
+		unlock, err := Lock(ctx, d, "cron")
+		is.NoErr(err)
+		_, err = TryLock(ctx, d, "cron")
+		is.True(errors.Is(err, ErrLockNotAcquired))
+		other, err := TryLock(ctx, d, "other")
+		is.NoErr(err)
+		is.NoErr(other())
+
+		tctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		_, err = Lock(tctx, d, "cron")
+		cancel()
+		is.True(errors.Is(err, context.DeadlineExceeded))
+
+		done := make(chan error)
+		go func() {
+			unlock, err := Lock(ctx, d, "cron")
+			if err == nil {
+				err = unlock()
+			}
+			done <- err
+		}()
+		time.Sleep(5 * time.Millisecond)
+		is.NoErr(unlock())
+		is.NoErr(unlock()) // only released once
+		is.NoErr(<-done)
+	}
+	_, err = Lock(ctx, New(pool, WithDialect(DialectFor(SQLiteDBType))), "cron")
+	is.True(errors.Is(err, ErrLockNotSupported))
+	is.True(lockID("a") != lockID("b"))
+}
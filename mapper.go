@@ -0,0 +1,125 @@
+package db
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// field is a struct field that maps to a database column.
+type field struct {
+	name  string
+	index []int
+	// opts holds the options after the column name in the struct tag.
+	opts []string
+}
+
+type structFields struct {
+	list   []*field
+	byName map[string]*field
+}
+
+var fieldCache sync.Map // map[reflect.Type]*structFields
+
+// fieldsOf returns the column fields of a struct type. Columns are named
+// using the "db" struct tag, falling back to the lower case field name. Fields
+// tagged with `db:"-"` are ignored and embedded structs are flattened.
+func fieldsOf(t reflect.Type) *structFields {
+	if f, ok := fieldCache.Load(t); ok {
+		return f.(*structFields)
+	}
+	sf := &structFields{byName: make(map[string]*field)}
+	collectFields(t, sf)
+	f, _ := fieldCache.LoadOrStore(t, sf)
+	return f.(*structFields)
+}
+
+// collectFields finds the column fields of a struct type. Like
+// encoding/json, fields of embedded structs are visited breadth first so
+// that a shallower field hides deeper fields with the same column name, and
+// names that are used by more than one field at the shallowest depth are
+// dropped because they are ambiguous.
+func collectFields(t reflect.Type, sf *structFields) {
+	type embedded struct {
+		t     reflect.Type
+		index []int
+	}
+	var (
+		fields  []*field
+		depths  = make(map[string]int)
+		counts  = make(map[string]int)
+		current = []embedded{{t: t}}
+		visited = make(map[reflect.Type]bool)
+	)
+	for depth := 0; len(current) > 0; depth++ {
+		var next []embedded
+		for _, e := range current {
+			if visited[e.t] {
+				continue
+			}
+			visited[e.t] = true
+			for i := 0; i < e.t.NumField(); i++ {
+				f := e.t.Field(i)
+				tag, hasTag := f.Tag.Lookup("db")
+				if tag == "-" {
+					continue
+				}
+				idx := append(append([]int(nil), e.index...), i)
+				ft := f.Type
+				if f.Anonymous && !hasTag {
+					if ft.Kind() == reflect.Pointer {
+						ft = ft.Elem()
+					}
+					if ft.Kind() == reflect.Struct {
+						next = append(next, embedded{t: ft, index: idx})
+						continue
+					}
+				}
+				if !f.IsExported() {
+					continue
+				}
+				parts := strings.Split(tag, ",")
+				name := parts[0]
+				if len(name) == 0 {
+					name = strings.ToLower(f.Name)
+				}
+				if d, ok := depths[name]; ok && d < depth {
+					// Hidden by a shallower field.
+					continue
+				}
+				depths[name] = depth
+				counts[name]++
+				fields = append(fields, &field{name: name, index: idx, opts: parts[1:]})
+			}
+		}
+		current = next
+	}
+	slices.SortFunc(fields, func(a, b *field) int { return slices.Compare(a.index, b.index) })
+	for _, f := range fields {
+		if counts[f.name] > 1 {
+			continue
+		}
+		sf.list = append(sf.list, f)
+		sf.byName[f.name] = f
+	}
+}
+
+// fieldByIndex is like [reflect.Value.FieldByIndex] but returns false instead
+// of panicking when it encounters a nil embedded pointer.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// hasOpt reports whether the field's struct tag has an option, i.e. "pk" in
+// `db:"id,pk"`.
+func (f *field) hasOpt(opt string) bool { return slices.Contains(f.opts, opt) }
@@ -0,0 +1,114 @@
+package mysql
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	gomysql "github.com/go-sql-driver/mysql"
+
+	"github.com/harrybrwn/db"
+)
+
+// LoadOpt is an option for [LoadData].
+type LoadOpt func(*loadOpts)
+
+type loadOpts struct {
+	cols        []string
+	fieldsTerm  string
+	enclosedBy  string
+	linesTerm   string
+	ignoreLines int
+	// duplicates is "REPLACE", "IGNORE" or empty.
+	duplicates string
+}
+
+// WithLoadColumns sets the columns of the fields in each line. Defaults to
+// every column of the table in order.
+func WithLoadColumns(cols ...string) LoadOpt { return func(o *loadOpts) { o.cols = cols } }
+
+// WithCSV reads comma separated fields that may be enclosed in double quotes.
+// The default is mysql's tab separated format.
+func WithCSV() LoadOpt {
+	return func(o *loadOpts) { o.fieldsTerm, o.enclosedBy = ",", `"` }
+}
+
+// WithFieldsTerminatedBy sets the string between fields.
+func WithFieldsTerminatedBy(s string) LoadOpt { return func(o *loadOpts) { o.fieldsTerm = s } }
+
+// WithLinesTerminatedBy sets the string at the end of each line.
+func WithLinesTerminatedBy(s string) LoadOpt { return func(o *loadOpts) { o.linesTerm = s } }
+
+// WithIgnoreLines skips lines at the start of the data, i.e. a header.
+func WithIgnoreLines(n int) LoadOpt { return func(o *loadOpts) { o.ignoreLines = n } }
+
+// WithReplaceDuplicates replaces existing rows that have the same unique key
+// as a loaded row.
+func WithReplaceDuplicates() LoadOpt { return func(o *loadOpts) { o.duplicates = "REPLACE" } }
+
+// WithIgnoreDuplicates skips loaded rows that have the same unique key as
+// an existing row.
+func WithIgnoreDuplicates() LoadOpt { return func(o *loadOpts) { o.duplicates = "IGNORE" } }
+
+var readerID atomic.Uint64
+
+// LoadData loads rows into a table from a reader with "LOAD DATA LOCAL
+// INFILE", the mysql equivalent of [db.CopyFrom]. The reader is registered
+// with the driver for the duration of the statement so nothing is written to
+// disk. The server must have local_infile enabled. The number of rows loaded
+// is returned. If r is an [io.ReadCloser] then the driver closes it.
+func LoadData(ctx context.Context, d db.DB, table string, r io.Reader, opts ...LoadOpt) (int64, error) {
+	var o loadOpts
+	for _, opt := range opts {
+		opt(&o)
+	}
+	name := "db-load-" + strconv.FormatUint(readerID.Add(1), 10)
+	gomysql.RegisterReaderHandler(name, func() io.Reader { return r })
+	defer gomysql.DeregisterReaderHandler(name)
+	res, err := d.ExecContext(ctx, loadDataQuery(name, table, &o))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func loadDataQuery(reader, table string, o *loadOpts) string {
+	dialect := db.DialectFor(db.MySQLDBType)
+	var b strings.Builder
+	b.WriteString("LOAD DATA LOCAL INFILE ")
+	b.WriteString(quoteString("Reader::" + reader))
+	if len(o.duplicates) > 0 {
+		b.WriteString(" " + o.duplicates)
+	}
+	b.WriteString(" INTO TABLE " + dialect.QuoteIdent(table))
+	if len(o.fieldsTerm) > 0 || len(o.enclosedBy) > 0 {
+		b.WriteString(" FIELDS")
+		if len(o.fieldsTerm) > 0 {
+			b.WriteString(" TERMINATED BY " + quoteString(o.fieldsTerm))
+		}
+		if len(o.enclosedBy) > 0 {
+			b.WriteString(" OPTIONALLY ENCLOSED BY " + quoteString(o.enclosedBy))
+		}
+	}
+	if len(o.linesTerm) > 0 {
+		b.WriteString(" LINES TERMINATED BY " + quoteString(o.linesTerm))
+	}
+	if o.ignoreLines > 0 {
+		b.WriteString(" IGNORE " + strconv.Itoa(o.ignoreLines) + " LINES")
+	}
+	if len(o.cols) > 0 {
+		cols := make([]string, len(o.cols))
+		for i, c := range o.cols {
+			cols[i] = dialect.QuoteIdent(c)
+		}
+		b.WriteString(" (" + strings.Join(cols, ", ") + ")")
+	}
+	return b.String()
+}
+
+// quoteString quotes a mysql string literal.
+func quoteString(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`, "\n", `\n`, "\r", `\r`, "\t", `\t`).Replace(s) + "'"
+}
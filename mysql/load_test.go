@@ -0,0 +1,53 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+
+	"github.com/harrybrwn/db"
+)
+
+type execDB struct {
+	db.DB
+	queries []string
+}
+
+func (d *execDB) ExecContext(_ context.Context, query string, _ ...any) (sql.Result, error) {
+	d.queries = append(d.queries, query)
+	return driver.RowsAffected(2), nil
+}
+
+func TestLoadData(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	d := &execDB{}
+	n, err := LoadData(ctx, d, "users", strings.NewReader("1\ta\n2\tb\n"))
+	is.NoErr(err)
+	is.Equal(n, int64(2))
+	is.Equal(len(d.queries), 1)
+	is.True(strings.HasPrefix(d.queries[0], "LOAD DATA LOCAL INFILE 'Reader::db-load-"))
+	is.True(strings.HasSuffix(d.queries[0], "' INTO TABLE `users`"))
+}
+
+func TestLoadDataQuery(t *testing.T) {
+	is := is.New(t)
+	var o loadOpts
+	for _, opt := range []LoadOpt{
+		WithCSV(),
+		WithLinesTerminatedBy("\r\n"),
+		WithIgnoreLines(1),
+		WithReplaceDuplicates(),
+		WithLoadColumns("id", "name"),
+	} {
+		opt(&o)
+	}
+	is.Equal(loadDataQuery("r", "app.users", &o), "LOAD DATA LOCAL INFILE 'Reader::r' REPLACE INTO TABLE `app`.`users` "+
+		`FIELDS TERMINATED BY ',' OPTIONALLY ENCLOSED BY '"' LINES TERMINATED BY '\r\n' IGNORE 1 LINES `+
+		"(`id`, `name`)")
+	is.Equal(quoteString(`it's \`), `'it\'s \\'`)
+}
@@ -0,0 +1,64 @@
+// Package mysql registers the github.com/go-sql-driver/mysql driver for
+// [db.MySQLDBType] along with the tls config registration used by
+// [db.Config.TLS] and the ssl options, and the connector used by
+// [db.Config.Dialer] and ssh tunnels. It also has [LoadData] for loading
+// rows with LOAD DATA LOCAL INFILE.
+//
+//	import _ "github.com/harrybrwn/db/mysql"
+package mysql
+
+import (
+	"context"
+	"database/sql/driver"
+	"net"
+	"strconv"
+	"sync/atomic"
+
+	gomysql "github.com/go-sql-driver/mysql"
+
+	"github.com/harrybrwn/db"
+)
+
+func init() {
+	db.RegisterTLSFunc(db.MySQLDBType, gomysql.RegisterTLSConfig)
+	db.RegisterDialConnector(db.MySQLDBType, DialConnector)
+}
+
+// dialNets numbers the networks registered for dial funcs.
+var dialNets atomic.Uint64
+
+// DialConnector returns a go-sql-driver/mysql connector that opens its
+// connections with dial. The driver only takes dial funcs registered under
+// a network name, so each connector registers its own until it is closed.
+func DialConnector(dsn string, dial db.DialFunc) (driver.Connector, error) {
+	cfg, err := gomysql.ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if dial == nil {
+		var d net.Dialer
+		dial = d.DialContext
+	}
+	network := cfg.Net
+	cfg.Net = "db-dial-" + strconv.FormatUint(dialNets.Add(1), 10)
+	gomysql.RegisterDialContext(cfg.Net, func(ctx context.Context, addr string) (net.Conn, error) {
+		return dial(ctx, network, addr)
+	})
+	c, err := gomysql.NewConnector(cfg)
+	if err != nil {
+		gomysql.DeregisterDialContext(cfg.Net)
+		return nil, err
+	}
+	return &dialConnector{Connector: c, net: cfg.Net}, nil
+}
+
+// dialConnector removes its dial func when the pool is closed.
+type dialConnector struct {
+	driver.Connector
+	net string
+}
+
+func (c *dialConnector) Close() error {
+	gomysql.DeregisterDialContext(c.net)
+	return nil
+}
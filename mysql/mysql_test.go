@@ -0,0 +1,68 @@
+package mysql
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"strings"
+	"testing"
+
+	gomysql "github.com/go-sql-driver/mysql"
+	"github.com/matryer/is"
+	"github.com/pkg/errors"
+
+	"github.com/harrybrwn/db"
+)
+
+func TestTLS(t *testing.T) {
+	is := is.New(t)
+	cfg := db.Config{Type: db.MySQLDBType, Host: "db.internal", User: "u", TLS: &tls.Config{ServerName: "db.internal"}}
+	pool, err := cfg.Open()
+	is.NoErr(err)
+	is.NoErr(pool.Close())
+	mc, err := gomysql.ParseDSN(cfg.DSN())
+	is.NoErr(err)
+	is.True(mc.TLS != nil)
+	is.Equal(mc.TLS.ServerName, "db.internal")
+	is.True(strings.HasPrefix(mc.TLSConfig, "db-"))
+}
+
+func TestDialer(t *testing.T) {
+	is := is.New(t)
+	var addrs []string
+	cfg := db.Config{
+		Type: db.MySQLDBType,
+		Host: "db.internal",
+		Port: "3306",
+		User: "u",
+		Dialer: func(_ context.Context, network, addr string) (net.Conn, error) {
+			addrs = append(addrs, network+" "+addr)
+			return nil, errors.New("dial blocked")
+		},
+	}
+	pool, err := cfg.Open()
+	is.NoErr(err)
+	err = pool.Ping()
+	is.True(err != nil && strings.Contains(err.Error(), "dial blocked"))
+	is.Equal(addrs[0], "tcp db.internal:3306")
+	is.NoErr(pool.Close())
+	_, err = DialConnector("not a dsn", nil)
+	is.True(err != nil)
+}
+
+// lookalikeError has the fields of the driver's MySQLError but lives in a
+// package whose import path also ends in "mysql".
+type lookalikeError struct {
+	Number  uint16
+	Message string
+}
+
+func (e *lookalikeError) Error() string { return e.Message }
+
+func TestClassifyError_Lookalike(t *testing.T) {
+	is := is.New(t)
+	err := &lookalikeError{Number: 1062, Message: "Duplicate entry 'a' for key 'users.email'"}
+	is.Equal(db.ClassifyError(err), db.ErrorKindUnknown)
+	is.Equal(db.ConstraintName(err), "")
+	is.True(db.IsUniqueViolation(&gomysql.MySQLError{Number: 1062}))
+}
@@ -0,0 +1,135 @@
+package db
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Named converts a query with ":name" placeholders into one with "?"
+// placeholders and returns the arguments in the order they are used. The
+// argument can be a struct (or pointer to a struct) whose fields are named
+// with "db" tags, or a map with string keys.
+//
+//	query, args, err := db.Named(
+//		"select * from users where org_id = :org_id and name = :name",
+//		map[string]any{"org_id": 1, "name": "jim"},
+//	)
+func Named(query string, arg any) (string, []any, error) {
+	return NamedFor(MySQLDBType, query, arg)
+}
+
+// NamedFor is the same as [Named] but will use the placeholder style of the
+// database type, i.e. "$1" for postgres.
+func NamedFor(t Type, query string, arg any) (string, []any, error) {
+	d := DialectFor(t)
+	numbered := usesNumberedParams(d)
+	lookup, err := namedLookup(arg)
+	if err != nil {
+		return "", nil, err
+	}
+	var (
+		b       strings.Builder
+		args    []any
+		numbers = make(map[string]int)
+		// brackets is the depth of array subscripts like "arr[1:2]".
+		brackets int
+	)
+	b.Grow(len(query))
+	for i := 0; i < len(query); {
+		if end, ok := skipQuoted(query, i); ok {
+			b.WriteString(query[i:end])
+			i = end
+			continue
+		}
+		c := query[i]
+		switch {
+		case c == '[':
+			brackets++
+		case c == ']' && brackets > 0:
+			brackets--
+		}
+		if c != ':' {
+			b.WriteByte(c)
+			i++
+			continue
+		}
+		// Skip postgres casts like "id::text".
+		if i+1 < len(query) && query[i+1] == ':' {
+			b.WriteString("::")
+			i += 2
+			continue
+		}
+		// Skip postgres array slices like "arr[1:2]" and "arr[lo:hi]".
+		if brackets > 0 && i > 0 && isIdentChar(query[i-1]) {
+			b.WriteByte(c)
+			i++
+			continue
+		}
+		end := i + 1
+		for end < len(query) && isIdentChar(query[end]) {
+			end++
+		}
+		if end == i+1 {
+			b.WriteByte(c)
+			i++
+			continue
+		}
+		name := query[i+1 : end]
+		i = end
+		if n, ok := numbers[name]; ok && numbered {
+			b.WriteString(d.Placeholder(n))
+			continue
+		}
+		v, ok := lookup(name)
+		if !ok {
+			return "", nil, errors.Errorf("could not find name %q in argument", name)
+		}
+		args = append(args, v)
+		numbers[name] = len(args)
+		b.WriteString(d.Placeholder(len(args)))
+	}
+	return b.String(), args, nil
+}
+
+// usesNumberedParams reports whether the dialect's placeholders are numbered
+// and can be reused.
+func usesNumberedParams(d Dialect) bool { return d.Placeholder(1) != d.Placeholder(2) }
+
+func namedLookup(arg any) (func(string) (any, bool), error) {
+	v := reflect.ValueOf(arg)
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil, errors.New("named argument is nil")
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, errors.Errorf("named argument map must have string keys, got %s", v.Type())
+		}
+		return func(name string) (any, bool) {
+			val := v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key()))
+			if !val.IsValid() {
+				return nil, false
+			}
+			return val.Interface(), true
+		}, nil
+	case reflect.Struct:
+		fields := fieldsOf(v.Type())
+		return func(name string) (any, bool) {
+			f, ok := fields.byName[name]
+			if !ok {
+				return nil, false
+			}
+			fv, ok := fieldByIndex(v, f.index)
+			if !ok {
+				return nil, true
+			}
+			return fv.Interface(), true
+		}, nil
+	}
+	return nil, errors.Errorf("named argument must be a struct or map, got %T", arg)
+}
@@ -0,0 +1,89 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestNamed(t *testing.T) {
+	is := is.New(t)
+	type Base struct {
+		ID    int `db:"id"`
+		OrgID int `db:"org_id"`
+	}
+	type user struct {
+		Base
+		Name    string
+		Email   string `db:"email,omitempty"`
+		Ignored string `db:"-"`
+		private string
+	}
+	u := user{Base: Base{ID: 1, OrgID: 2}, Name: "jim", Email: "jim@example.com", private: "x"}
+
+	q, args, err := Named("select * from users where id = :id and org_id = :org_id and (name = :name or ':name' = '') and id::text = :id", u)
+	is.NoErr(err)
+	is.Equal(q, "select * from users where id = ? and org_id = ? and (name = ? or ':name' = '') and id::text = ?")
+	is.Equal(args, []any{1, 2, "jim", 1})
+
+	q, args, err = NamedFor(PostgresDBType, "insert into users (id, email, name) values (:id, :email, :name) returning :id", &u)
+	is.NoErr(err)
+	is.Equal(q, "insert into users (id, email, name) values ($1, $2, $3) returning $1")
+	is.Equal(args, []any{1, "jim@example.com", "jim"})
+
+	// Array slices are not named parameters but an index can be.
+	q, args, err = NamedFor(PostgresDBType, "select arr[1:2], arr[lo:hi], arr[:i], arr[:i:2] from t where id = :id", map[string]any{"i": 3, "id": 4})
+	is.NoErr(err)
+	is.Equal(q, "select arr[1:2], arr[lo:hi], arr[$1], arr[$1:2] from t where id = $2")
+	is.Equal(args, []any{3, 4})
+
+	q, args, err = Named("select :a, :b, : c", map[string]any{"a": 1, "b": "two"})
+	is.NoErr(err)
+	is.Equal(q, "select ?, ?, : c")
+	is.Equal(args, []any{1, "two"})
+
+	_, _, err = Named("select :ignored", u)
+	is.True(err != nil)
+	_, _, err = Named("select :private", u)
+	is.True(err != nil)
+	_, _, err = Named("select :a", map[int]any{})
+	is.True(err != nil)
+	_, _, err = Named("select :a", 1)
+	is.True(err != nil)
+	_, _, err = Named("select :a", (*user)(nil))
+	is.True(err != nil)
+
+	type withPtr struct {
+		*Base
+		Name string
+	}
+	_, args, err = Named("select :id, :name", withPtr{Name: "x"})
+	is.NoErr(err)
+	is.Equal(args, []any{nil, "x"})
+}
+
+func TestRebind(t *testing.T) {
+	is := is.New(t)
+	q := "select * from t where a = ? and b = '?' and c in (?, ?) -- ?"
+	is.Equal(Rebind(PostgresDBType, q), "select * from t where a = $1 and b = '?' and c in ($2, $3) -- ?")
+	is.Equal(Rebind(MySQLDBType, q), q)
+	is.Equal(Rebind(MySQLDBType, Rebind(PostgresDBType, q)), q)
+	is.Equal(Rebind(SQLiteDBType, "select $1, $$ $2 $$, $10"), "select ?, $$ $2 $$, ?")
+
+	query, args, err := RebindArgs(MySQLDBType, "select $2, $1 where a = $1", []any{"a", "b"})
+	is.NoErr(err)
+	is.Equal(query, "select ?, ? where a = ?")
+	is.Equal(args, []any{"b", "a", "a"})
+	query, args, err = RebindArgs(PostgresDBType, "select ?, ?", []any{1, 2})
+	is.NoErr(err)
+	is.Equal(query, "select $1, $2")
+	is.Equal(args, []any{1, 2})
+	query, args, err = RebindArgs(SQLiteDBType, "select ?", []any{1})
+	is.NoErr(err)
+	is.Equal(query, "select ?")
+	is.Equal(args, []any{1})
+	_, _, err = RebindArgs(SQLiteDBType, "select $3", []any{1})
+	is.True(err != nil)
+	_, _, err = RebindArgs(SQLiteDBType, "select $1, ?", []any{1})
+	is.True(err != nil)
+}
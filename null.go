@@ -0,0 +1,66 @@
+package db
+
+import (
+	"bytes"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+)
+
+// Null is a nullable column value. It scans like [sql.Null] and is encoded
+// as null in JSON when it is not valid.
+type Null[T any] struct {
+	V     T
+	Valid bool
+}
+
+// NullFrom returns a valid [Null] holding v.
+func NullFrom[T any](v T) Null[T] { return Null[T]{V: v, Valid: true} }
+
+// NullFromPtr returns a [Null] that is valid if p is not nil.
+func NullFromPtr[T any](p *T) Null[T] {
+	if p == nil {
+		return Null[T]{}
+	}
+	return NullFrom(*p)
+}
+
+// Ptr returns a pointer to the value or nil if it is not valid.
+func (n Null[T]) Ptr() *T {
+	if !n.Valid {
+		return nil
+	}
+	v := n.V
+	return &v
+}
+
+// Scan implements [sql.Scanner].
+func (n *Null[T]) Scan(value any) error {
+	return (*sql.Null[T])(n).Scan(value)
+}
+
+// Value implements [driver.Valuer].
+func (n Null[T]) Value() (driver.Value, error) {
+	return sql.Null[T](n).Value()
+}
+
+// MarshalJSON implements [json.Marshaler].
+func (n Null[T]) MarshalJSON() ([]byte, error) {
+	if !n.Valid {
+		return []byte("null"), nil
+	}
+	return json.Marshal(n.V)
+}
+
+// UnmarshalJSON implements [json.Unmarshaler].
+func (n *Null[T]) UnmarshalJSON(b []byte) error {
+	if bytes.Equal(bytes.TrimSpace(b), []byte("null")) {
+		*n = Null[T]{}
+		return nil
+	}
+	if err := json.Unmarshal(b, &n.V); err != nil {
+		return err
+	}
+	n.Valid = true
+	return nil
+}
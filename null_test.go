@@ -0,0 +1,68 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestNull(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	pool, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer pool.Close()
+	pool.SetMaxOpenConns(1)
+	d := New(pool)
+	_, err = d.ExecContext(ctx, "CREATE TABLE t (s TEXT, n INT, ts DATETIME)")
+	is.NoErr(err)
+	now := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	_, err = d.ExecContext(ctx, "INSERT INTO t VALUES (?, ?, ?), (?, ?, ?)",
+		NullFrom("a"), NullFrom(int64(1)), NullFrom(now),
+		Null[string]{}, Null[int64]{}, Null[time.Time]{})
+	is.NoErr(err)
+
+	rows, err := d.QueryContext(ctx, "SELECT s, n, ts FROM t ORDER BY rowid")
+	is.NoErr(err)
+	type row struct {
+		S  Null[string]
+		N  Null[int]
+		TS Null[time.Time]
+	}
+	var got []row
+	for rows.Next() {
+		var r row
+		is.NoErr(rows.Scan(&r.S, &r.N, &r.TS))
+		got = append(got, r)
+	}
+	is.NoErr(rows.Close())
+	is.Equal(len(got), 2)
+	is.Equal(got[0].S, NullFrom("a"))
+	is.Equal(got[0].N, NullFrom(1))
+	is.True(got[0].TS.V.Equal(now))
+	is.Equal(got[1], row{})
+
+	b, err := json.Marshal(got[0].S)
+	is.NoErr(err)
+	is.Equal(string(b), `"a"`)
+	b, err = json.Marshal(got[1])
+	is.NoErr(err)
+	is.Equal(string(b), `{"S":null,"N":null,"TS":null}`)
+
+	var r struct{ A, B, C Null[int] }
+	is.NoErr(json.Unmarshal([]byte(`{"A": 5, "B": null}`), &r))
+	is.Equal(r.A, NullFrom(5))
+	is.Equal(r.B, Null[int]{})
+	is.Equal(r.C, Null[int]{})
+	is.True(json.Unmarshal([]byte(`{"A": "x"}`), &r) != nil)
+
+	is.Equal(*NullFrom(3).Ptr(), 3)
+	is.True(Null[int]{}.Ptr() == nil)
+	x := 4
+	is.Equal(NullFromPtr(&x), NullFrom(4))
+	is.Equal(NullFromPtr[int](nil), Null[int]{})
+}
@@ -0,0 +1,181 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+
+	"github.com/pkg/errors"
+)
+
+// ConnectFunc sets up a new connection before it is added to the pool.
+type ConnectFunc func(ctx context.Context, conn *sql.Conn) error
+
+// WithOnConnect runs fn on every new connection before it is used, which is
+// the place for session setup like "SET search_path" or "SET ROLE" that
+// must survive connections being recycled. If fn fails the connection is
+// closed and the error is returned to the caller that needed a connection.
+//
+// The hook is installed when the pool is opened so it only applies to pools
+// opened by [Open], [Registry.Open] and [OpenSharded], and it is ignored by
+// [New] and [Registry.Add]. Use [WrapConnector] with [sql.OpenDB] for pools
+// that are opened elsewhere.
+func WithOnConnect(fn ConnectFunc) Option {
+	return func(d *dbOptions) { d.onConnect = append(d.onConnect, fn) }
+}
+
+// Open opens a connection pool for the config and wraps it with [New]
+// using the config's [Dialect].
+func Open(cfg *Config, opts ...Option) (*database, error) {
+	options := applyOptions(append([]Option{WithDialect(cfg.Dialect())}, opts...))
+	pool, err := cfg.openPool(&options)
+	if err != nil {
+		return nil, err
+	}
+	return newDatabase(pool, options), nil
+}
+
+// openPool opens the config's connection pool with the connection hooks
+// and pool settings from the options.
+func (db *Config) openPool(options *dbOptions) (*sql.DB, error) {
+	if len(options.onConnect) == 0 {
+		pool, err := db.Open()
+		if err != nil {
+			return nil, err
+		}
+		options.pool.apply(pool)
+		return pool, nil
+	}
+	connector, err := db.connector()
+	if err != nil {
+		return nil, err
+	}
+	hooks := options.onConnect
+	if setup := db.poolerSetup(); setup != nil {
+		hooks = append([]ConnectFunc{setup}, hooks...)
+	}
+	pool := sql.OpenDB(WrapConnector(connector, hooks...))
+	options.pool.apply(pool)
+	return pool, nil
+}
+
+// connector returns the driver's connector for the config.
+func (db *Config) connector() (driver.Connector, error) {
+	db = db.withAppName()
+	if err := checkDriver(db.Type); err != nil {
+		return nil, err
+	}
+	if len(db.hosts()) > 0 {
+		return db.failoverConnector()
+	}
+	if err := db.registerTLS(); err != nil {
+		return nil, err
+	}
+	// There is no way to look up a registered driver other than opening a
+	// pool, which does not connect.
+	pool, err := sql.Open(db.Type.DriverName(), db.DSN())
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	drv := pool.Driver()
+	pool.Close()
+	if db.customDial() {
+		return db.dialConnector()
+	}
+	if dc, ok := drv.(driver.DriverContext); ok {
+		return dc.OpenConnector(db.DSN())
+	}
+	return &dsnConnector{dsn: db.DSN(), driver: drv}, nil
+}
+
+type dsnConnector struct {
+	dsn    string
+	driver driver.Driver
+}
+
+func (c *dsnConnector) Connect(context.Context) (driver.Conn, error) { return c.driver.Open(c.dsn) }
+func (c *dsnConnector) Driver() driver.Driver                        { return c.driver }
+
+// WrapConnector returns a connector that runs each of the funcs on every new
+// connection.
+func WrapConnector(c driver.Connector, fns ...ConnectFunc) driver.Connector {
+	return &hookConnector{Connector: c, fns: fns}
+}
+
+type hookConnector struct {
+	driver.Connector
+	fns []ConnectFunc
+}
+
+func (c *hookConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err = runConnectFuncs(ctx, conn, c.fns); err != nil {
+		conn.Close()
+		return nil, errors.Wrap(err, "connection setup failed")
+	}
+	return conn, nil
+}
+
+// runConnectFuncs gives the hooks a [sql.Conn] by wrapping the new driver
+// connection in a single connection pool that does not close it.
+func runConnectFuncs(ctx context.Context, conn driver.Conn, fns []ConnectFunc) error {
+	pool := sql.OpenDB(&singleConnector{conn: setupConn{conn}})
+	defer pool.Close()
+	pool.SetMaxOpenConns(1)
+	c, err := pool.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	for _, fn := range fns {
+		if err = fn(ctx, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type singleConnector struct {
+	conn   driver.Conn
+	driver driver.Driver
+}
+
+func (c *singleConnector) Connect(context.Context) (driver.Conn, error) { return c.conn, nil }
+func (c *singleConnector) Driver() driver.Driver                        { return c.driver }
+
+// setupConn is a connection used by the connect hooks. Closing it does not
+// close the underlying connection which is handed to the real pool.
+type setupConn struct{ driver.Conn }
+
+func (setupConn) Close() error { return nil }
+
+func (c setupConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if e, ok := c.Conn.(driver.ExecerContext); ok {
+		return e.ExecContext(ctx, query, args)
+	}
+	return nil, driver.ErrSkip
+}
+
+func (c setupConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if q, ok := c.Conn.(driver.QueryerContext); ok {
+		return q.QueryContext(ctx, query, args)
+	}
+	return nil, driver.ErrSkip
+}
+
+func (c setupConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return p.PrepareContext(ctx, query)
+	}
+	return c.Conn.Prepare(query)
+}
+
+func (c setupConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin() //nolint:staticcheck
+}
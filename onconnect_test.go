@@ -0,0 +1,97 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"sync/atomic"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/pkg/errors"
+)
+
+func TestWithOnConnect(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	var calls atomic.Int32
+	cfg := Config{Type: SQLiteDBType, DBName: ":memory:"}
+	d, err := Open(&cfg, WithOnConnect(func(ctx context.Context, c *sql.Conn) error {
+		calls.Add(1)
+		_, err := c.ExecContext(ctx, "PRAGMA foreign_keys = ON")
+		return err
+	}))
+	is.NoErr(err)
+	defer d.Close()
+	is.Equal(d.Dialect().Type(), SQLiteDBType)
+
+	// Hold two connections at once so that the pool has to open both.
+	c1, err := d.Conn(ctx)
+	is.NoErr(err)
+	c2, err := d.Conn(ctx)
+	is.NoErr(err)
+	for _, c := range []*sql.Conn{c1, c2} {
+		var on int
+		is.NoErr(c.QueryRowContext(ctx, "PRAGMA foreign_keys").Scan(&on))
+		is.Equal(on, 1)
+		is.NoErr(c.Close())
+	}
+	is.Equal(calls.Load(), int32(2))
+
+	// Transactions and queries work on the hooked connections.
+	tx, err := d.BeginTx(ctx, nil)
+	is.NoErr(err)
+	rows, err := tx.QueryContext(ctx, "SELECT 1")
+	is.NoErr(err)
+	var n int
+	is.NoErr(ScanOne(rows, &n))
+	is.NoErr(tx.Rollback())
+}
+
+func TestWithOnConnect_Error(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	setupErr := errors.New("setup failed")
+	r := NewRegistry(WithOnConnect(func(context.Context, *sql.Conn) error { return setupErr }))
+	defer r.CloseAll()
+	d, err := r.Open("main", &Config{Type: SQLiteDBType, DBName: ":memory:"})
+	is.NoErr(err)
+	_, err = d.ExecContext(ctx, "SELECT 1")
+	is.True(errors.Is(err, setupErr))
+
+	_, err = Open(&Config{Type: "nope"}, WithOnConnect(func(context.Context, *sql.Conn) error { return nil }))
+	is.True(errors.Is(err, ErrDriverNotRegistered))
+
+	s, err := OpenSharded(NewHashRing([]string{"a"}, 0), map[string]*Config{
+		"a": {Type: SQLiteDBType, DBName: ":memory:"},
+	}, WithOnConnect(func(context.Context, *sql.Conn) error { return setupErr }))
+	is.NoErr(err)
+	defer s.Close()
+	_, err = s.Shard("x").ExecContext(ctx, "SELECT 1")
+	is.True(errors.Is(err, setupErr))
+}
+
+func TestOpen_AppliesOptionsOnce(t *testing.T) {
+	is := is.New(t)
+	var applied int
+	count := func(*dbOptions) { applied++ }
+	cfg := Config{Type: SQLiteDBType, DBName: ":memory:"}
+	hook := WithOnConnect(func(context.Context, *sql.Conn) error { return nil })
+
+	d, err := Open(&cfg, count, hook)
+	is.NoErr(err)
+	is.NoErr(d.Close())
+	is.Equal(applied, 1)
+
+	applied = 0
+	r := NewRegistry(count)
+	_, err = r.Open("app", &Config{Type: SQLiteDBType, DBName: ":memory:"}, hook)
+	is.NoErr(err)
+	is.NoErr(r.CloseAll())
+	is.Equal(applied, 1)
+
+	applied = 0
+	s, err := OpenSharded(&LookupTable{Default: "a"}, map[string]*Config{"a": &cfg}, count, hook)
+	is.NoErr(err)
+	is.NoErr(s.Close())
+	is.Equal(applied, 1)
+}
@@ -0,0 +1,83 @@
+package db
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Paged is one page of query results.
+type Paged[T any] struct {
+	Items []T `json:"items"`
+	// Total is the number of rows across all pages.
+	Total int64 `json:"total"`
+	// Page is the page number starting at 1.
+	Page int `json:"page"`
+	// Size is the maximum number of items on a page.
+	Size int `json:"size"`
+	// Pages is the number of pages.
+	Pages int `json:"pages"`
+}
+
+// HasNext reports whether there is a page after this one.
+func (p *Paged[T]) HasNext() bool { return p.Page < p.Pages }
+
+// HasPrev reports whether there is a page before this one.
+func (p *Paged[T]) HasPrev() bool { return p.Page > 1 }
+
+// Page runs a query for one page of results along with a query that counts
+// the total number of rows. The query has a LIMIT and OFFSET clause
+// appended and both queries are given the same arguments. Page numbers start
+// at 1. The two queries run concurrently unless db is a [Tx], in which case
+// they run one after the other in the transaction so the count is consistent
+// with the page.
+func Page[T any](
+	ctx context.Context,
+	db DB,
+	query, countQuery string,
+	page, size int,
+	scan func(Scanner) (T, error),
+	args ...any,
+) (*Paged[T], error) {
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 {
+		return nil, errors.Errorf("invalid page size %d", size)
+	}
+	p := Paged[T]{Page: page, Size: size}
+	query += " " + DialectOf(db).Limit(size, (page-1)*size)
+
+	var itemsErr, countErr error
+	items := func() { p.Items, itemsErr = queryAll(ctx, db, query, scan, args) }
+	count := func() {
+		var rows Rows
+		if rows, countErr = db.QueryContext(ctx, countQuery, args...); countErr == nil {
+			countErr = ScanOne(rows, &p.Total)
+		}
+	}
+	if _, ok := db.(Tx); ok {
+		count()
+		if countErr == nil {
+			items()
+		}
+	} else {
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() { defer wg.Done(); count() }()
+		items()
+		wg.Wait()
+	}
+	if countErr != nil {
+		return nil, errors.WithMessage(countErr, "failed to count rows")
+	}
+	if itemsErr != nil {
+		return nil, itemsErr
+	}
+	if p.Items == nil {
+		p.Items = []T{}
+	}
+	p.Pages = int((p.Total + int64(size) - 1) / int64(size))
+	return &p, nil
+}
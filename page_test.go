@@ -0,0 +1,56 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestPage(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	pool, err := sql.Open("sqlite3", "file:paging?mode=memory&cache=shared")
+	is.NoErr(err)
+	defer pool.Close()
+	d := New(pool, WithDialect(DialectFor(SQLiteDBType)))
+	_, err = d.ExecContext(ctx, "CREATE TABLE nums (n int)")
+	is.NoErr(err)
+	_, err = d.ExecContext(ctx, "INSERT INTO nums VALUES (1), (2), (3), (4), (5), (6), (7)")
+	is.NoErr(err)
+	scan := func(s Scanner) (n int, err error) { return n, s.Scan(&n) }
+
+	p, err := Page(ctx, d, "SELECT n FROM nums WHERE n > ? ORDER BY n", "SELECT count(*) FROM nums WHERE n > ?", 2, 2, scan, 1)
+	is.NoErr(err)
+	is.Equal(p.Items, []int{4, 5})
+	is.Equal(p.Total, int64(6))
+	is.Equal(p.Pages, 3)
+	is.True(p.HasNext())
+	is.True(p.HasPrev())
+
+	tx, err := d.BeginTx(ctx, nil)
+	is.NoErr(err)
+	p, err = Page(ctx, tx, "SELECT n FROM nums ORDER BY n", "SELECT count(*) FROM nums", 4, 2, scan)
+	is.NoErr(err)
+	is.NoErr(tx.Rollback())
+	is.Equal(p.Items, []int{7})
+	is.True(!p.HasNext())
+
+	p, err = Page(ctx, d, "SELECT n FROM nums ORDER BY n", "SELECT count(*) FROM nums", 10, 5, scan)
+	is.NoErr(err)
+	is.Equal(p.Items, []int{})
+	is.Equal(p.Page, 10)
+
+	p, err = Page(ctx, d, "SELECT n FROM nums", "SELECT count(*) FROM nums", 0, 100, scan)
+	is.NoErr(err)
+	is.Equal(p.Page, 1)
+	is.True(!p.HasPrev())
+
+	_, err = Page(ctx, d, "SELECT n FROM nums", "SELECT count(*) FROM missing", 1, 10, scan)
+	is.True(err != nil)
+	_, err = Page(ctx, d, "SELECT n FROM missing", "SELECT count(*) FROM nums", 1, 10, scan)
+	is.True(err != nil)
+	_, err = Page(ctx, d, "SELECT n FROM nums", "SELECT count(*) FROM nums", 1, 0, scan)
+	is.True(err != nil)
+}
@@ -0,0 +1,179 @@
+// Package pgnotify subscribes to postgres LISTEN/NOTIFY channels.
+package pgnotify
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+
+	"github.com/harrybrwn/db"
+)
+
+// Notification is a message sent with NOTIFY.
+type Notification struct {
+	Channel string
+	Payload string
+	// PID is the process id of the server backend that sent the
+	// notification.
+	PID int
+}
+
+// Option configures a [Listener].
+type Option func(*options)
+
+type options struct {
+	minReconnect time.Duration
+	maxReconnect time.Duration
+	pingInterval time.Duration
+	buffer       int
+	logger       *slog.Logger
+	onReconnect  func()
+}
+
+// WithReconnectInterval sets the minimum and maximum time to wait between
+// reconnection attempts. The wait doubles after each failed attempt.
+// Defaults to 1s and 30s.
+func WithReconnectInterval(minWait, maxWait time.Duration) Option {
+	return func(o *options) { o.minReconnect, o.maxReconnect = minWait, maxWait }
+}
+
+// WithPingInterval sets how often an idle connection is checked so that a
+// dead connection is noticed and replaced. Defaults to 90s.
+func WithPingInterval(d time.Duration) Option {
+	return func(o *options) { o.pingInterval = d }
+}
+
+// WithBuffer sets the size of the notification channel buffer. Defaults to
+// 64.
+func WithBuffer(n int) Option { return func(o *options) { o.buffer = n } }
+
+// WithLogger sets the logger used to report connection events.
+func WithLogger(l *slog.Logger) Option { return func(o *options) { o.logger = l } }
+
+// WithReconnectHook sets a function that is called after the connection was
+// lost and then re-established. Notifications sent while the connection was
+// down are lost so this is a good place to invalidate caches.
+func WithReconnectHook(fn func()) Option { return func(o *options) { o.onReconnect = fn } }
+
+// Listener receives notifications on a dedicated postgres connection and
+// reconnects automatically when the connection is lost.
+type Listener struct {
+	l    *pq.Listener
+	ch   chan Notification
+	opts options
+}
+
+// New creates a [Listener] that connects using the config's DSN.
+func New(cfg *db.Config, opts ...Option) *Listener {
+	return NewDSN(cfg.DSN(), opts...)
+}
+
+// NewDSN creates a [Listener] from a postgres connection string.
+func NewDSN(dsn string, opts ...Option) *Listener {
+	o := options{
+		minReconnect: time.Second,
+		maxReconnect: 30 * time.Second,
+		pingInterval: 90 * time.Second,
+		buffer:       64,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.logger == nil {
+		o.logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	l := &Listener{ch: make(chan Notification, o.buffer), opts: o}
+	l.l = pq.NewListener(dsn, o.minReconnect, o.maxReconnect, l.event)
+	return l
+}
+
+func (l *Listener) event(ev pq.ListenerEventType, err error) {
+	switch ev {
+	case pq.ListenerEventConnected:
+		l.opts.logger.Info("notification listener connected")
+	case pq.ListenerEventDisconnected:
+		l.opts.logger.Warn("notification listener disconnected", slog.Any("error", err))
+	case pq.ListenerEventReconnected:
+		l.opts.logger.Info("notification listener reconnected")
+	case pq.ListenerEventConnectionAttemptFailed:
+		l.opts.logger.Warn("notification listener failed to connect, retrying...", slog.Any("error", err))
+	}
+}
+
+// Listen starts listening on the channels. It blocks until the server
+// acknowledges each channel or the context is done.
+func (l *Listener) Listen(ctx context.Context, channels ...string) error {
+	for _, c := range channels {
+		if err := l.do(ctx, func() error { return l.l.Listen(c) }); err != nil {
+			return errors.Wrapf(err, "failed to listen on %q", c)
+		}
+	}
+	return nil
+}
+
+// Unlisten stops listening on a channel.
+func (l *Listener) Unlisten(ctx context.Context, channel string) error {
+	return l.do(ctx, func() error { return l.l.Unlisten(channel) })
+}
+
+// do runs a blocking listener operation and returns early if the context is
+// done. The operation keeps running in the background until it finishes or
+// the listener is closed.
+func (l *Listener) do(ctx context.Context, fn func() error) error {
+	done := make(chan error, 1)
+	go func() { done <- fn() }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Notifications returns the channel that notifications are delivered on.
+func (l *Listener) Notifications() <-chan Notification { return l.ch }
+
+// Run delivers notifications until the context is done or the listener is
+// closed. The notification channel is closed when Run returns.
+func (l *Listener) Run(ctx context.Context) error {
+	defer close(l.ch)
+	ping := time.NewTicker(l.opts.pingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case n, ok := <-l.l.Notify:
+			if !ok {
+				return nil
+			}
+			if n == nil {
+				// pq sends nil after reconnecting.
+				if l.opts.onReconnect != nil {
+					l.opts.onReconnect()
+				}
+				continue
+			}
+			select {
+			case l.ch <- Notification{Channel: n.Channel, Payload: n.Extra, PID: n.BePid}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		case <-ping.C:
+			go l.l.Ping()
+		}
+	}
+}
+
+// Close closes the connection.
+func (l *Listener) Close() error { return l.l.Close() }
+
+// Notify sends a notification on a channel.
+func Notify(ctx context.Context, d db.DB, channel, payload string) error {
+	_, err := d.ExecContext(ctx, "SELECT pg_notify($1, $2)", channel, payload)
+	return err
+}
@@ -0,0 +1,53 @@
+package pgnotify
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/mattn/go-sqlite3"
+	"github.com/pkg/errors"
+
+	"github.com/harrybrwn/db"
+)
+
+func TestListener_Down(t *testing.T) {
+	is := is.New(t)
+	l := New(&db.Config{
+		Type:           db.PostgresDBType,
+		Host:           "127.0.0.1",
+		Port:           "1",
+		SSLMode:        "disable",
+		ConnectTimeout: 1,
+	}, WithReconnectInterval(time.Millisecond, 10*time.Millisecond), WithBuffer(1))
+	defer l.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := l.Listen(ctx, "events")
+	is.True(errors.Is(err, context.DeadlineExceeded))
+	is.True(errors.Is(l.Run(ctx), context.DeadlineExceeded))
+	_, ok := <-l.Notifications()
+	is.True(!ok) // channel is closed after Run
+}
+
+func TestNotify(t *testing.T) {
+	is := is.New(t)
+	type call struct{ channel, payload string }
+	var calls []call
+	sql.Register("sqlite3_pgnotify", &sqlite3.SQLiteDriver{
+		ConnectHook: func(c *sqlite3.SQLiteConn) error {
+			return c.RegisterFunc("pg_notify", func(channel, payload string) int {
+				calls = append(calls, call{channel, payload})
+				return 0
+			}, false)
+		},
+	})
+	pool, err := sql.Open("sqlite3_pgnotify", ":memory:")
+	is.NoErr(err)
+	defer pool.Close()
+	is.NoErr(Notify(context.Background(), db.New(pool), "events", "hello"))
+	is.Equal(calls, []call{{"events", "hello"}})
+}
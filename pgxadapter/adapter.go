@@ -0,0 +1,273 @@
+// Package pgxadapter lets a pgx connection pool be used as a [db.DB] so the
+// helpers, mocks and config of the db package work with pgx.
+package pgxadapter
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pkg/errors"
+
+	"github.com/harrybrwn/db"
+)
+
+// querier is the part of [pgxpool.Pool] and [pgx.Tx] used by the adapters.
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+}
+
+type pool interface {
+	querier
+	BeginTx(ctx context.Context, opts pgx.TxOptions) (pgx.Tx, error)
+	Ping(ctx context.Context) error
+	Close()
+}
+
+// DB is a [db.DB] backed by a pgx pool.
+type DB struct{ pool pool }
+
+var (
+	_ db.DB          = (*DB)(nil)
+	_ db.Pingable    = (*DB)(nil)
+	_ db.BatchExecer = (*DB)(nil)
+)
+
+// Wrap returns a [db.DB] that runs statements on the pool.
+func Wrap(p *pgxpool.Pool) *DB { return &DB{pool: p} }
+
+// Open parses a postgres connection string, i.e. [db.Config.DSN], and opens
+// a pool with it.
+func Open(ctx context.Context, dsn string) (*DB, error) {
+	p, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return Wrap(p), nil
+}
+
+// Opener opens a pgx pool for the config. Register it to have
+// [db.Config.OpenDB] use pgx for postgres:
+//
+//	db.RegisterOpener(db.PostgresDBType, pgxadapter.Opener)
+//
+// When the config has a [db.Config.Pooler] the pool uses the simple
+// protocol, does not cache statements and applies the
+// [db.Config.PoolerSettings] after connecting.
+func Opener(cfg db.Config) (db.DB, error) {
+	pc, err := pgxpool.ParseConfig(cfg.DSN())
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(cfg.Pooler) > 0 {
+		// Only used by lib/pq.
+		delete(pc.ConnConfig.RuntimeParams, "binary_parameters")
+		pc.ConnConfig.DefaultQueryExecMode = pgx.QueryExecModeSimpleProtocol
+		pc.ConnConfig.StatementCacheCapacity = 0
+		pc.ConnConfig.DescriptionCacheCapacity = 0
+		if settings := cfg.PoolerSettings(); len(settings) > 0 {
+			pc.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+				for _, s := range settings {
+					if _, err := conn.Exec(ctx, "SELECT set_config($1, $2, false)", s.Name, s.Value); err != nil {
+						return errors.Wrapf(err, "could not set %s", s.Name)
+					}
+				}
+				return nil
+			}
+		}
+	}
+	p, err := pgxpool.NewWithConfig(context.Background(), pc)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return Wrap(p), nil
+}
+
+func (d *DB) QueryContext(ctx context.Context, query string, args ...any) (db.Rows, error) {
+	return queryContext(ctx, d.pool, query, args)
+}
+
+func (d *DB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return execContext(ctx, d.pool, query, args)
+}
+
+// ExecBatch sends the statements in one pgx batch. See [db.Batch].
+func (d *DB) ExecBatch(ctx context.Context, stmts []db.BatchStmt) ([]db.BatchResult, error) {
+	return execBatch(ctx, d.pool, stmts)
+}
+
+// BeginTx starts a transaction. The isolation level and read only flag of
+// opts are converted to their pgx equivalents.
+func (d *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (db.Tx, error) {
+	txOpts, err := TxOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	t, err := d.pool.BeginTx(ctx, txOpts)
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{tx: t}, nil
+}
+
+func (d *DB) Ping() error                           { return d.pool.Ping(context.Background()) }
+func (d *DB) PingContext(ctx context.Context) error { return d.pool.Ping(ctx) }
+
+// Close closes the pool.
+func (d *DB) Close() error {
+	d.pool.Close()
+	return nil
+}
+
+// Dialect returns the postgres dialect.
+func (d *DB) Dialect() db.Dialect { return db.DialectFor(db.PostgresDBType) }
+
+// Tx is a [db.Tx] backed by a pgx transaction.
+type Tx struct{ tx pgx.Tx }
+
+var (
+	_ db.Tx          = (*Tx)(nil)
+	_ db.BatchExecer = (*Tx)(nil)
+)
+
+func (t *Tx) QueryContext(ctx context.Context, query string, args ...any) (db.Rows, error) {
+	return queryContext(ctx, t.tx, query, args)
+}
+
+func (t *Tx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return execContext(ctx, t.tx, query, args)
+}
+
+// ExecBatch sends the statements in one pgx batch. See [db.Batch].
+func (t *Tx) ExecBatch(ctx context.Context, stmts []db.BatchStmt) ([]db.BatchResult, error) {
+	return execBatch(ctx, t.tx, stmts)
+}
+
+// BeginTx is a noop because this is already a transaction.
+func (t *Tx) BeginTx(context.Context, *sql.TxOptions) (db.Tx, error) { return t, nil }
+
+func (t *Tx) Commit() error   { return txErr(t.tx.Commit(context.Background())) }
+func (t *Tx) Rollback() error { return txErr(t.tx.Rollback(context.Background())) }
+
+// Close does nothing because transactions cannot be closed.
+func (t *Tx) Close() error { return db.ErrCannotCloseTx }
+
+// Dialect returns the postgres dialect.
+func (t *Tx) Dialect() db.Dialect { return db.DialectFor(db.PostgresDBType) }
+
+// txErr converts pgx's closed transaction error to the one returned by
+// [database/sql] so that helpers like [db.TxDo] can ignore it.
+func txErr(err error) error {
+	if errors.Is(err, pgx.ErrTxClosed) {
+		return sql.ErrTxDone
+	}
+	return err
+}
+
+// TxOptions converts [sql.TxOptions] to [pgx.TxOptions].
+func TxOptions(opts *sql.TxOptions) (pgx.TxOptions, error) {
+	var o pgx.TxOptions
+	if opts == nil {
+		return o, nil
+	}
+	switch opts.Isolation {
+	case sql.LevelDefault:
+	case sql.LevelReadUncommitted:
+		o.IsoLevel = pgx.ReadUncommitted
+	case sql.LevelReadCommitted:
+		o.IsoLevel = pgx.ReadCommitted
+	case sql.LevelRepeatableRead, sql.LevelSnapshot:
+		o.IsoLevel = pgx.RepeatableRead
+	case sql.LevelSerializable:
+		o.IsoLevel = pgx.Serializable
+	default:
+		return o, errors.Errorf("unsupported isolation level %s", opts.Isolation)
+	}
+	if opts.ReadOnly {
+		o.AccessMode = pgx.ReadOnly
+	}
+	return o, nil
+}
+
+func queryContext(ctx context.Context, q querier, query string, args []any) (db.Rows, error) {
+	r, err := q.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return &Rows{rows: r}, nil
+}
+
+func execContext(ctx context.Context, q querier, query string, args []any) (sql.Result, error) {
+	tag, err := q.Exec(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return Result(tag), nil
+}
+
+// execBatch runs the statements in a [pgx.Batch]. Outside of a transaction
+// postgres runs the batch in an implicit transaction so a failed statement
+// rolls back the ones before it.
+func execBatch(ctx context.Context, q querier, stmts []db.BatchStmt) (results []db.BatchResult, err error) {
+	var b pgx.Batch
+	for _, s := range stmts {
+		b.Queue(s.Query, s.Args...)
+	}
+	br := q.SendBatch(ctx, &b)
+	defer func() {
+		if e := br.Close(); e != nil && err == nil {
+			err = e
+		}
+	}()
+	results = make([]db.BatchResult, len(stmts))
+	for i := range results {
+		if err != nil {
+			results[i].Err = db.ErrBatchAborted
+			continue
+		}
+		var tag pgconn.CommandTag
+		if tag, err = br.Exec(); err != nil {
+			results[i].Err = err
+			continue
+		}
+		results[i].Result = Result(tag)
+	}
+	return results, err
+}
+
+// Rows is a [db.Rows] backed by [pgx.Rows].
+type Rows struct{ rows pgx.Rows }
+
+func (r *Rows) Next() bool             { return r.rows.Next() }
+func (r *Rows) Scan(dest ...any) error { return r.rows.Scan(dest...) }
+func (r *Rows) Err() error             { return r.rows.Err() }
+
+// Close closes the rows and returns any error that ended the iteration.
+func (r *Rows) Close() error {
+	r.rows.Close()
+	return r.rows.Err()
+}
+
+// Columns returns the column names of the result.
+func (r *Rows) Columns() ([]string, error) {
+	fields := r.rows.FieldDescriptions()
+	cols := make([]string, len(fields))
+	for i, f := range fields {
+		cols[i] = f.Name
+	}
+	return cols, nil
+}
+
+// Result is a [sql.Result] backed by a command tag.
+type Result pgconn.CommandTag
+
+// LastInsertId is not supported by postgres, use RETURNING instead.
+func (Result) LastInsertId() (int64, error) {
+	return 0, errors.New("LastInsertId is not supported by postgres")
+}
+
+func (r Result) RowsAffected() (int64, error) { return pgconn.CommandTag(r).RowsAffected(), nil }
@@ -0,0 +1,302 @@
+package pgxadapter
+
+import (
+	"context"
+	"database/sql"
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/matryer/is"
+	"github.com/pkg/errors"
+
+	"github.com/harrybrwn/db"
+)
+
+type fakeRows struct {
+	pgx.Rows
+	values [][]any
+	err    error
+	closed bool
+}
+
+func (r *fakeRows) Next() bool {
+	if len(r.values) == 0 {
+		return false
+	}
+	return true
+}
+
+func (r *fakeRows) Scan(dest ...any) error {
+	row := r.values[0]
+	r.values = r.values[1:]
+	for i, d := range dest {
+		*d.(*int) = row[i].(int)
+	}
+	return nil
+}
+
+func (r *fakeRows) Err() error { return r.err }
+func (r *fakeRows) Close()     { r.closed = true }
+func (r *fakeRows) FieldDescriptions() []pgconn.FieldDescription {
+	return []pgconn.FieldDescription{{Name: "id"}}
+}
+
+type fakeTx struct {
+	pgx.Tx
+	pool      *fakePool
+	committed bool
+	done      bool
+}
+
+func (t *fakeTx) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return t.pool.Query(ctx, sql, args...)
+}
+
+func (t *fakeTx) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	return t.pool.Exec(ctx, sql, args...)
+}
+
+func (t *fakeTx) SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults {
+	return t.pool.SendBatch(ctx, b)
+}
+
+func (t *fakeTx) Commit(context.Context) error {
+	if t.done {
+		return pgx.ErrTxClosed
+	}
+	t.done, t.committed = true, true
+	return nil
+}
+
+func (t *fakeTx) Rollback(context.Context) error {
+	if t.done {
+		return pgx.ErrTxClosed
+	}
+	t.done = true
+	return nil
+}
+
+type fakePool struct {
+	queries []string
+	rows    *fakeRows
+	opts    pgx.TxOptions
+	tx      *fakeTx
+	err     error
+	closed  bool
+}
+
+func (p *fakePool) Query(_ context.Context, sql string, _ ...any) (pgx.Rows, error) {
+	p.queries = append(p.queries, sql)
+	return p.rows, p.err
+}
+
+func (p *fakePool) Exec(_ context.Context, sql string, _ ...any) (pgconn.CommandTag, error) {
+	p.queries = append(p.queries, sql)
+	return pgconn.NewCommandTag("UPDATE 3"), p.err
+}
+
+// SendBatch fails the second statement when the pool has an error.
+func (p *fakePool) SendBatch(_ context.Context, b *pgx.Batch) pgx.BatchResults {
+	br := &fakeBatchResults{}
+	for i, q := range b.QueuedQueries {
+		p.queries = append(p.queries, q.SQL)
+		if i == 1 && p.err != nil {
+			br.errs = append(br.errs, p.err)
+		} else {
+			br.errs = append(br.errs, nil)
+		}
+	}
+	return br
+}
+
+type fakeBatchResults struct {
+	pgx.BatchResults
+	errs   []error
+	closed bool
+}
+
+func (br *fakeBatchResults) Exec() (pgconn.CommandTag, error) {
+	err := br.errs[0]
+	br.errs = br.errs[1:]
+	if err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	return pgconn.NewCommandTag("INSERT 0 1"), nil
+}
+
+func (br *fakeBatchResults) Close() error {
+	br.closed = true
+	return nil
+}
+
+func (p *fakePool) BeginTx(_ context.Context, opts pgx.TxOptions) (pgx.Tx, error) {
+	p.opts = opts
+	p.tx = &fakeTx{pool: p}
+	return p.tx, p.err
+}
+
+func (p *fakePool) Ping(context.Context) error { return p.err }
+func (p *fakePool) Close()                     { p.closed = true }
+
+func TestDB(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	p := &fakePool{rows: &fakeRows{values: [][]any{{1}, {2}}}}
+	d := &DB{pool: p}
+	is.Equal(d.Dialect().Type(), db.PostgresDBType)
+
+	rows, err := d.QueryContext(ctx, "SELECT id FROM t")
+	is.NoErr(err)
+	cols, err := rows.(*Rows).Columns()
+	is.NoErr(err)
+	is.Equal(cols, []string{"id"})
+	var ids []int
+	for rows.Next() {
+		var id int
+		is.NoErr(rows.Scan(&id))
+		ids = append(ids, id)
+	}
+	is.NoErr(rows.Err())
+	is.NoErr(rows.Close())
+	is.True(p.rows.closed)
+	is.Equal(ids, []int{1, 2})
+
+	res, err := d.ExecContext(ctx, "UPDATE t SET a = 1")
+	is.NoErr(err)
+	n, err := res.RowsAffected()
+	is.NoErr(err)
+	is.Equal(n, int64(3))
+	_, err = res.LastInsertId()
+	is.True(err != nil)
+
+	err = db.TxDo(ctx, must(d.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})), func(tx db.Tx) error {
+		_, err := tx.ExecContext(ctx, "DELETE FROM t")
+		return err
+	})
+	is.NoErr(err)
+	is.True(p.tx.committed)
+	is.Equal(p.opts.IsoLevel, pgx.Serializable)
+
+	tx, err := d.BeginTx(ctx, nil)
+	is.NoErr(err)
+	is.Equal(tx.Close(), db.ErrCannotCloseTx)
+	same, err := tx.BeginTx(ctx, nil)
+	is.NoErr(err)
+	is.Equal(same, tx)
+	p.rows = &fakeRows{values: [][]any{{7}}}
+	var id int
+	is.NoErr(db.ScanOne(must(tx.QueryContext(ctx, "SELECT 7")), &id))
+	is.Equal(id, 7)
+	is.NoErr(tx.Rollback())
+	is.True(errors.Is(tx.Rollback(), sql.ErrTxDone))
+	is.Equal(tx.(*Tx).Dialect().Type(), db.PostgresDBType)
+
+	p.err = errors.New("down")
+	_, err = d.QueryContext(ctx, "SELECT 1")
+	is.Equal(err, p.err)
+	_, err = d.ExecContext(ctx, "SELECT 1")
+	is.Equal(err, p.err)
+	_, err = d.BeginTx(ctx, nil)
+	is.Equal(err, p.err)
+	is.Equal(d.Ping(), p.err)
+	_, err = d.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelLinearizable})
+	is.True(err != nil)
+	is.NoErr(d.Close())
+	is.True(p.closed)
+}
+
+func TestExecBatch(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	p := &fakePool{}
+	d := &DB{pool: p}
+
+	var b db.Batch
+	b.Queue("INSERT INTO t VALUES ($1)", 1)
+	b.Queue("INSERT INTO t VALUES ($1)", 2)
+	b.Queue("INSERT INTO t VALUES ($1)", 3)
+	results, err := b.Run(ctx, d)
+	is.NoErr(err)
+	is.Equal(len(results), 3)
+	for _, r := range results {
+		is.NoErr(r.Err)
+		n, err := r.Result.RowsAffected()
+		is.NoErr(err)
+		is.Equal(n, int64(1))
+	}
+	is.Equal(len(p.queries), 3)
+
+	tx := must(d.BeginTx(ctx, nil))
+	p.err = errors.New("duplicate key")
+	results, err = b.Run(ctx, tx)
+	is.Equal(err, p.err)
+	is.NoErr(results[0].Err)
+	is.Equal(results[1].Err, p.err)
+	is.Equal(results[2].Err, db.ErrBatchAborted)
+}
+
+func TestTxOptions(t *testing.T) {
+	is := is.New(t)
+	for level, exp := range map[sql.IsolationLevel]pgx.TxIsoLevel{
+		sql.LevelDefault:         "",
+		sql.LevelReadUncommitted: pgx.ReadUncommitted,
+		sql.LevelReadCommitted:   pgx.ReadCommitted,
+		sql.LevelRepeatableRead:  pgx.RepeatableRead,
+		sql.LevelSnapshot:        pgx.RepeatableRead,
+		sql.LevelSerializable:    pgx.Serializable,
+	} {
+		o, err := TxOptions(&sql.TxOptions{Isolation: level, ReadOnly: true})
+		is.NoErr(err)
+		is.Equal(o.IsoLevel, exp)
+		is.Equal(o.AccessMode, pgx.ReadOnly)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	is := is.New(t)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	is.NoErr(err)
+	addr := ln.Addr().(*net.TCPAddr)
+	ln.Close()
+	cfg := db.Config{Type: db.PostgresDBType, Host: "127.0.0.1", Port: strconv.Itoa(addr.Port), User: "u", SSLMode: "disable"}
+	d, err := Open(context.Background(), cfg.DSN())
+	is.NoErr(err)
+	defer d.Close()
+	is.True(d.PingContext(context.Background()) != nil)
+	_, err = Open(context.Background(), "postgres://%zz")
+	is.True(err != nil)
+
+	db.RegisterOpener(db.PostgresDBType, Opener)
+	defer db.RegisterOpener(db.PostgresDBType, nil)
+	opened, err := cfg.OpenDB()
+	is.NoErr(err)
+	_, ok := opened.(*DB)
+	is.True(ok)
+	is.NoErr(opened.Close())
+
+	cfg.Pooler = db.PgBouncer
+	opened, err = Opener(cfg)
+	is.NoErr(err)
+	defer opened.Close()
+	conf := opened.(*DB).pool.(*pgxpool.Pool).Config().ConnConfig
+	is.Equal(conf.DefaultQueryExecMode, pgx.QueryExecModeSimpleProtocol)
+	is.Equal(conf.StatementCacheCapacity, 0)
+	is.Equal(conf.DescriptionCacheCapacity, 0)
+	_, ok = conf.RuntimeParams["binary_parameters"]
+	is.True(!ok)
+
+	cfg.Params = map[string]string{"pool_max_conns": "x"}
+	_, err = Opener(cfg)
+	is.True(err != nil)
+}
+
+func must[T any](v T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
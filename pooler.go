@@ -0,0 +1,117 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// PgBouncer is the [Config.Pooler] of a PgBouncer server running in
+// transaction pooling mode.
+const PgBouncer = "pgbouncer"
+
+// poolerStartupParams are server settings that poolers reject when they are
+// sent as startup parameters because they would leak into other clients'
+// sessions.
+var poolerStartupParams = []string{
+	"options",
+	"search_path",
+	"statement_timeout",
+	"lock_timeout",
+	"idle_in_transaction_session_timeout",
+	"extra_float_digits",
+	"default_transaction_isolation",
+	"default_transaction_read_only",
+}
+
+// behindPooler is true when connections go through a server side pooler.
+func (db *Config) behindPooler() bool {
+	return len(db.Pooler) > 0 && db.Type.postgresWire()
+}
+
+// poolerParams removes the startup parameters that the pooler does not
+// support from the query of a postgres URI.
+func poolerParams(q url.Values) {
+	for _, k := range poolerStartupParams {
+		q.Del(k)
+	}
+}
+
+// SessionSetting is a server setting applied to a connection after it is
+// opened.
+type SessionSetting struct {
+	Name, Value string
+}
+
+// PoolerSettings returns the session settings that a [Config.Pooler] rejects
+// as startup parameters, so they are applied with set_config after each
+// connection is opened instead. These are the search_path from the Schema,
+// the session timeouts and the settings in Params, including the
+// "-c name=value" pairs of "options". It is empty when there is no pooler.
+func (db *Config) PoolerSettings() []SessionSetting {
+	if !db.behindPooler() {
+		return nil
+	}
+	var settings []SessionSetting
+	if len(db.Schema) > 0 {
+		settings = append(settings, SessionSetting{"search_path", db.Schema})
+	}
+	settings = append(settings, db.pgTimeouts()...)
+	for _, k := range poolerStartupParams {
+		v, ok := db.Params[k]
+		if !ok {
+			continue
+		}
+		if k == "options" {
+			settings = append(settings, parsePgOptions(v)...)
+		} else {
+			settings = append(settings, SessionSetting{k, v})
+		}
+	}
+	return settings
+}
+
+// parsePgOptions parses the settings from a postgres "options" startup
+// parameter like "-c statement_timeout=5000 --lock_timeout=1000".
+func parsePgOptions(opts string) []SessionSetting {
+	var settings []SessionSetting
+	fields := strings.Fields(opts)
+	for i := 0; i < len(fields); i++ {
+		f := fields[i]
+		switch {
+		case f == "-c" && i+1 < len(fields):
+			i++
+			f = fields[i]
+		case strings.HasPrefix(f, "--"):
+			f = f[2:]
+		case strings.HasPrefix(f, "-c"):
+			f = f[2:]
+		default:
+			continue
+		}
+		if name, value, ok := strings.Cut(f, "="); ok {
+			settings = append(settings, SessionSetting{strings.ReplaceAll(name, "-", "_"), value})
+		}
+	}
+	return settings
+}
+
+// poolerSetup returns the hook that applies the [Config.PoolerSettings] to
+// new connections, or nil if there are none.
+func (db *Config) poolerSetup() ConnectFunc {
+	settings := db.PoolerSettings()
+	if len(settings) == 0 {
+		return nil
+	}
+	return func(ctx context.Context, conn *sql.Conn) error {
+		for _, s := range settings {
+			if _, err := conn.ExecContext(ctx, "SELECT set_config($1, $2, false)", s.Name, s.Value); err != nil {
+				return errors.Wrapf(err, "could not set %s", s.Name)
+			}
+		}
+		return nil
+	}
+}
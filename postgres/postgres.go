@@ -0,0 +1,24 @@
+// Package postgres registers the github.com/lib/pq driver for
+// [db.PostgresDBType] and [db.CockroachDBType]. The root package already
+// does this unless it is built with the "nopq" tag.
+//
+//	import _ "github.com/harrybrwn/db/postgres"
+package postgres
+
+import (
+	"database/sql/driver"
+
+	"github.com/harrybrwn/db"
+	"github.com/harrybrwn/db/internal/pqdial"
+)
+
+func init() {
+	db.RegisterDialConnector(db.PostgresDBType, DialConnector)
+	db.RegisterDialConnector(db.CockroachDBType, DialConnector)
+}
+
+// DialConnector returns a lib/pq connector that opens its connections with
+// dial.
+func DialConnector(dsn string, dial db.DialFunc) (driver.Connector, error) {
+	return pqdial.Connector(dsn, dial)
+}
@@ -0,0 +1,40 @@
+package postgres
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/pkg/errors"
+
+	"github.com/harrybrwn/db"
+)
+
+func TestDialer(t *testing.T) {
+	is := is.New(t)
+	for _, typ := range []db.Type{db.PostgresDBType, db.CockroachDBType} {
+		var addrs []string
+		cfg := db.Config{
+			Type: typ,
+			Host: "db.internal",
+			Port: "5432",
+			User: "u",
+			Dialer: func(_ context.Context, _, addr string) (net.Conn, error) {
+				addrs = append(addrs, addr)
+				return nil, errors.New("dial blocked")
+			},
+		}
+		pool, err := cfg.Open()
+		is.NoErr(err)
+		err = pool.Ping()
+		// The error comes straight from the dialer instead of a forwarded
+		// connection.
+		is.True(err != nil && strings.Contains(err.Error(), "dial blocked"))
+		is.Equal(addrs[0], "db.internal:5432")
+		is.NoErr(pool.Close())
+	}
+	_, err := DialConnector("postgres://%zz", nil)
+	is.True(err != nil)
+}
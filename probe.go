@@ -0,0 +1,235 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrNotReady is returned by [Prober.Ready] before the readiness probe has
+	// succeeded enough times.
+	ErrNotReady = errors.New("database not ready")
+	// ErrNotLive is returned by [Prober.Live] when the liveness probe has
+	// failed too many times in a row.
+	ErrNotLive = errors.New("database not live")
+)
+
+// ProbeConfig configures a probe in the same way as kubernetes probes.
+type ProbeConfig struct {
+	// Period is how often the probe runs.
+	Period time.Duration
+	// Timeout is how long each probe attempt can take.
+	Timeout time.Duration
+	// FailureThreshold is the number of consecutive failures needed to mark
+	// the probe as failing.
+	FailureThreshold int
+	// SuccessThreshold is the number of consecutive successes needed to mark
+	// the probe as passing after it has failed.
+	SuccessThreshold int
+	// MaxPeriod caps the wait between attempts while the probe is failing.
+	// The wait doubles after each consecutive failure, starting from
+	// Period, so a database that is down isn't hammered by probes. Defaults
+	// to 4 times the Period. Set it to the Period to turn the backoff off.
+	MaxPeriod time.Duration
+}
+
+func (pc *ProbeConfig) setDefaults() {
+	if pc.Period <= 0 {
+		pc.Period = 10 * time.Second
+	}
+	if pc.Timeout <= 0 {
+		pc.Timeout = time.Second
+	}
+	if pc.FailureThreshold <= 0 {
+		pc.FailureThreshold = 3
+	}
+	if pc.SuccessThreshold <= 0 {
+		pc.SuccessThreshold = 1
+	}
+	if pc.MaxPeriod < pc.Period {
+		pc.MaxPeriod = 4 * pc.Period
+	}
+}
+
+// ProberOpt is an option for [NewProber].
+type ProberOpt func(*Prober)
+
+// WithLiveness configures the liveness probe.
+func WithLiveness(pc ProbeConfig) ProberOpt { return func(p *Prober) { p.live.conf = pc } }
+
+// WithReadiness configures the readiness probe.
+func WithReadiness(pc ProbeConfig) ProberOpt { return func(p *Prober) { p.ready.conf = pc } }
+
+// WithReadinessQuery sets a query that the readiness probe runs after a
+// successful ping, i.e. "select 1 from migrations limit 1". The probe fails
+// if the query returns no rows. The database given to [NewProber] must be a
+// [DB] or have the QueryContext method of [sql.DB].
+func WithReadinessQuery(query string) ProberOpt {
+	return func(p *Prober) { p.readyQuery = query }
+}
+
+type sqlQueryer interface {
+	QueryContext(context.Context, string, ...any) (*sql.Rows, error)
+}
+
+// Prober runs liveness and readiness probes against a database so services
+// can serve probe endpoints without a sidecar.
+type Prober struct {
+	db         Pingable
+	readyQuery string
+	live       probe
+	ready      probe
+}
+
+// NewProber creates a new [Prober]. The liveness probe starts out passing and
+// the readiness probe starts out failing.
+func NewProber(db Pingable, opts ...ProberOpt) *Prober {
+	p := &Prober{db: db}
+	for _, o := range opts {
+		o(p)
+	}
+	p.live.conf.setDefaults()
+	p.ready.conf.setDefaults()
+	p.live.passing = true
+	p.live.err = ErrNotLive
+	p.ready.err = ErrNotReady
+	return p
+}
+
+// Live returns nil if the liveness probe is passing.
+func (p *Prober) Live(context.Context) error { return p.live.result() }
+
+// Ready returns nil if the readiness probe is passing.
+func (p *Prober) Ready(context.Context) error { return p.ready.result() }
+
+// Run runs both probes on their own cadence until the context is canceled.
+func (p *Prober) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() { defer wg.Done(); p.live.run(ctx, p.checkLive) }()
+	go func() { defer wg.Done(); p.ready.run(ctx, p.checkReady) }()
+	wg.Wait()
+	return nil
+}
+
+// CheckLive runs the liveness probe once.
+func (p *Prober) CheckLive(ctx context.Context) error { return p.live.check(ctx, p.checkLive) }
+
+// CheckReady runs the readiness probe once.
+func (p *Prober) CheckReady(ctx context.Context) error {
+	return p.ready.check(ctx, p.checkReady)
+}
+
+func (p *Prober) checkLive(ctx context.Context) error { return p.db.PingContext(ctx) }
+
+func (p *Prober) checkReady(ctx context.Context) error {
+	if err := p.db.PingContext(ctx); err != nil {
+		return err
+	}
+	if len(p.readyQuery) == 0 {
+		return nil
+	}
+	var (
+		rows Rows
+		err  error
+	)
+	switch q := p.db.(type) {
+	case DB:
+		rows, err = q.QueryContext(ctx, p.readyQuery)
+	case sqlQueryer:
+		rows, err = q.QueryContext(ctx, p.readyQuery)
+	default:
+		return errors.Errorf("cannot run readiness query with %T", p.db)
+	}
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return err
+		}
+		return errors.New("readiness query returned no rows")
+	}
+	return rows.Close()
+}
+
+type probe struct {
+	conf ProbeConfig
+
+	mu        sync.Mutex
+	passing   bool
+	successes int
+	failures  int
+	// err is the sentinel error returned while failing and lastErr is the
+	// cause of the last failure.
+	err     error
+	lastErr error
+}
+
+func (pr *probe) result() error {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+	if pr.passing {
+		return nil
+	}
+	if pr.lastErr != nil {
+		return fmt.Errorf("%w: %w", pr.err, pr.lastErr)
+	}
+	return pr.err
+}
+
+func (pr *probe) run(ctx context.Context, fn func(context.Context) error) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+		_ = pr.check(ctx, fn)
+		timer.Reset(pr.wait())
+	}
+}
+
+// wait is the time until the next attempt. It doubles with each consecutive
+// failure up to the MaxPeriod.
+func (pr *probe) wait() time.Duration {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+	d := pr.conf.Period
+	for i := 1; i < pr.failures && d < pr.conf.MaxPeriod; i++ {
+		d *= 2
+	}
+	return min(d, pr.conf.MaxPeriod)
+}
+
+func (pr *probe) check(ctx context.Context, fn func(context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, pr.conf.Timeout)
+	defer cancel()
+	err := fn(ctx)
+	pr.mu.Lock()
+	if err != nil {
+		pr.successes = 0
+		pr.failures++
+		pr.lastErr = err
+		if pr.failures >= pr.conf.FailureThreshold {
+			pr.passing = false
+		}
+	} else {
+		pr.failures = 0
+		pr.successes++
+		if pr.successes >= pr.conf.SuccessThreshold {
+			pr.passing = true
+			pr.lastErr = nil
+		}
+	}
+	pr.mu.Unlock()
+	return pr.result()
+}
@@ -0,0 +1,90 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestProber(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	errDown := errors.New("down")
+	var pingErr error
+	p := NewProber(
+		pingFunc(func(context.Context) error { return pingErr }),
+		WithLiveness(ProbeConfig{FailureThreshold: 2}),
+		WithReadiness(ProbeConfig{FailureThreshold: 1, SuccessThreshold: 2}),
+	)
+	is.NoErr(p.Live(ctx))
+	is.True(errors.Is(p.Ready(ctx), ErrNotReady))
+	is.True(errors.Is(p.CheckReady(ctx), ErrNotReady))
+	is.NoErr(p.CheckReady(ctx))
+
+	pingErr = errDown
+	is.NoErr(p.CheckLive(ctx))
+	err := p.CheckLive(ctx)
+	is.True(errors.Is(err, ErrNotLive))
+	is.Equal(err.Error(), "database not live: down")
+	is.True(errors.Is(err, errDown))
+	is.True(errors.Is(p.CheckReady(ctx), ErrNotReady))
+
+	pingErr = nil
+	is.NoErr(p.CheckLive(ctx))
+	is.True(p.CheckReady(ctx) != nil)
+	is.NoErr(p.CheckReady(ctx))
+
+	p = NewProber(pingFunc(func(context.Context) error { return nil }), WithReadinessQuery("select 1"))
+	is.True(p.CheckReady(ctx) != nil)
+
+	pool, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer pool.Close()
+	p = NewProber(pool, WithReadinessQuery("select * from migrations"))
+	is.True(errors.Is(p.CheckReady(ctx), ErrNotReady))
+	_, err = pool.ExecContext(ctx, "create table migrations (id int)")
+	is.NoErr(err)
+	err = p.CheckReady(ctx)
+	is.True(err != nil && strings.Contains(err.Error(), "no rows"))
+	_, err = pool.ExecContext(ctx, "insert into migrations values (1)")
+	is.NoErr(err)
+	is.NoErr(p.CheckReady(ctx))
+	// Wrapped databases are queried through QueryContext.
+	p = NewProber(New(pool), WithReadinessQuery("select id, 2 from migrations"))
+	is.NoErr(p.CheckReady(ctx))
+
+	runCtx, cancel := context.WithCancel(ctx)
+	p = NewProber(pool,
+		WithReadinessQuery("select 1"),
+		WithReadiness(ProbeConfig{Period: time.Millisecond}),
+		WithLiveness(ProbeConfig{Period: time.Millisecond}),
+	)
+	done := make(chan error)
+	go func() { done <- p.Run(runCtx) }()
+	for p.Ready(ctx) != nil {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	is.NoErr(<-done)
+	is.NoErr(p.Live(ctx))
+}
+
+func TestProbe_Backoff(t *testing.T) {
+	is := is.New(t)
+	pr := probe{conf: ProbeConfig{Period: time.Second}}
+	pr.conf.setDefaults()
+	is.Equal(pr.conf.MaxPeriod, 4*time.Second)
+	var waits []time.Duration
+	for range 5 {
+		waits = append(waits, pr.wait())
+		pr.failures++
+	}
+	is.Equal(waits, []time.Duration{time.Second, time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second})
+	pr.failures = 0
+	is.Equal(pr.wait(), time.Second)
+}
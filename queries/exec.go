@@ -0,0 +1,67 @@
+package queries
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/harrybrwn/db"
+	"github.com/pkg/errors"
+)
+
+// ErrUnknownQuery is returned when running a query name that was not loaded.
+var ErrUnknownQuery = errors.New("unknown query")
+
+func (q *Queries) lookup(name string) (Query, error) {
+	query, ok := q.Get(name)
+	if !ok {
+		return Query{}, errors.Wrapf(ErrUnknownQuery, "%q", name)
+	}
+	return query, nil
+}
+
+// tag adds the query's name and hash to the context as query tags, which a
+// database opened with [db.WithQueryTags] appends to the statement so that
+// it shows up in the server's logs and in the wrapper's error logs.
+func (query Query) tag(ctx context.Context) context.Context {
+	return db.AddQueryTags(ctx, map[string]string{"query_name": query.Name, "query_hash": query.Hash})
+}
+
+// wrap names the query and its hash in an error.
+func (query Query) wrap(err error) error {
+	if err == nil {
+		return nil
+	}
+	return errors.Wrapf(err, "query %s (%s)", query.Name, query.Hash)
+}
+
+// Query runs a named query and returns the rows. The query's name and hash
+// are added to its query tags, see [db.WithQueryTags], and to errors.
+func (q *Queries) Query(ctx context.Context, d db.DB, name string, args ...any) (db.Rows, error) {
+	query, err := q.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := d.QueryContext(query.tag(ctx), query.SQL, args...)
+	return rows, query.wrap(err)
+}
+
+// QueryRow runs a named query and scans the first row into dest. If there are
+// no rows then [db.ErrNotFound] is returned.
+func (q *Queries) QueryRow(ctx context.Context, d db.DB, name string, dest []any, args ...any) error {
+	rows, err := q.Query(ctx, d, name, args...)
+	if err != nil {
+		return err
+	}
+	return db.ScanOne(rows, dest...)
+}
+
+// Exec executes a named query. The query's name and hash are added to its
+// query tags, see [db.WithQueryTags], and to errors.
+func (q *Queries) Exec(ctx context.Context, d db.DB, name string, args ...any) (sql.Result, error) {
+	query, err := q.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	res, err := d.ExecContext(query.tag(ctx), query.SQL, args...)
+	return res, query.wrap(err)
+}
@@ -0,0 +1,80 @@
+package queries
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"log/slog"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/harrybrwn/db"
+	"github.com/matryer/is"
+	"github.com/pkg/errors"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestQueries_Exec(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	pool, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer pool.Close()
+	pool.SetMaxOpenConns(1)
+	d := db.New(pool)
+	q, err := Load(fstest.MapFS{"users.sql": {Data: []byte(`
+-- name: CreateUsers
+CREATE TABLE users (id INT, name TEXT);
+
+-- name: AddUser
+INSERT INTO users VALUES (?, ?);
+
+-- name: GetUser
+SELECT name FROM users WHERE id = ?;
+
+-- name: ListUsers
+SELECT id FROM users ORDER BY id;
+`)}})
+	is.NoErr(err)
+
+	_, err = q.Exec(ctx, d, "CreateUsers")
+	is.NoErr(err)
+	for i, name := range []string{"alice", "bob"} {
+		_, err = q.Exec(ctx, d, "AddUser", i+1, name)
+		is.NoErr(err)
+	}
+	var name string
+	is.NoErr(q.QueryRow(ctx, d, "GetUser", []any{&name}, 2))
+	is.Equal(name, "bob")
+	is.True(db.IsNotFound(q.QueryRow(ctx, d, "GetUser", []any{&name}, 3)))
+
+	rows, err := q.Query(ctx, d, "ListUsers")
+	is.NoErr(err)
+	var ids []int
+	for rows.Next() {
+		var id int
+		is.NoErr(rows.Scan(&id))
+		ids = append(ids, id)
+	}
+	is.NoErr(rows.Close())
+	is.Equal(ids, []int{1, 2})
+
+	// The name and hash are in the statement's tags and in errors.
+	var logs bytes.Buffer
+	tagged := db.New(pool, db.WithQueryTags(), db.WithLogger(slog.New(
+		slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))))
+	add, _ := q.Get("AddUser")
+	_, err = q.Exec(ctx, tagged, "AddUser")
+	is.True(err != nil)
+	is.True(strings.Contains(err.Error(), "query AddUser ("+add.Hash+")"))
+	is.True(strings.Contains(logs.String(), "query_hash='"+add.Hash+"'"))
+	is.True(strings.Contains(logs.String(), "query_name='AddUser'"))
+
+	_, err = q.Query(ctx, d, "Nope")
+	is.True(errors.Is(err, ErrUnknownQuery))
+	_, err = q.Exec(ctx, d, "Nope")
+	is.True(errors.Is(err, ErrUnknownQuery))
+	is.True(errors.Is(q.QueryRow(ctx, d, "Nope", nil), ErrUnknownQuery))
+}
@@ -0,0 +1,264 @@
+// Package queries loads named SQL queries from files.
+//
+// Each query in a file starts with a "-- name:" comment and runs until the
+// next one.
+//
+//	-- name: GetUser
+//	SELECT * FROM users WHERE id = $1;
+//
+//	-- name: ListUsers
+//	SELECT * FROM users;
+//
+// Loaded queries are run by name with [Queries.Query] and [Queries.Exec].
+package queries
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"log/slog"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Query is a named SQL query.
+type Query struct {
+	Name string
+	SQL  string
+	// File is the file that the query was loaded from.
+	File string
+	// Hash identifies the query text. [Queries.Query] and [Queries.Exec]
+	// add it to the statement's tags and to errors so that logs show
+	// exactly which version of a query was run.
+	Hash string
+}
+
+// LogValue implements [slog.LogValuer] so that logging a query includes its
+// hash instead of the full text.
+func (q Query) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("name", q.Name), slog.String("hash", q.Hash))
+}
+
+// Queries is a set of named queries loaded from an [fs.FS]. It can be
+// reloaded at runtime when the files change.
+type Queries struct {
+	fsys     fs.FS
+	patterns []string
+
+	mu      sync.RWMutex
+	queries map[string]Query
+	version string
+}
+
+// Load reads every query from files in fsys that match the glob patterns.
+// The pattern defaults to "*.sql".
+func Load(fsys fs.FS, patterns ...string) (*Queries, error) {
+	if len(patterns) == 0 {
+		patterns = []string{"*.sql"}
+	}
+	q := &Queries{fsys: fsys, patterns: patterns}
+	if _, err := q.Reload(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// Get returns a query by name.
+func (q *Queries) Get(name string) (Query, bool) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	query, ok := q.queries[name]
+	return query, ok
+}
+
+// Names returns the sorted names of all the queries.
+func (q *Queries) Names() []string {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	names := make([]string, 0, len(q.queries))
+	for n := range q.queries {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Version is a hash of every query. It changes whenever any query changes.
+func (q *Queries) Version() string {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.version
+}
+
+// Reload re-reads the query files and returns the names of the queries that
+// were added, changed or removed. If the files are invalid then the error is
+// returned and the previously loaded queries are kept.
+func (q *Queries) Reload() ([]string, error) {
+	queries, err := parseFS(q.fsys, q.patterns)
+	if err != nil {
+		return nil, err
+	}
+	version := versionOf(queries)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if version == q.version {
+		return nil, nil
+	}
+	var changed []string
+	for name, query := range queries {
+		if old, ok := q.queries[name]; !ok || old.Hash != query.Hash {
+			changed = append(changed, name)
+		}
+	}
+	for name := range q.queries {
+		if _, ok := queries[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	q.queries = queries
+	q.version = version
+	return changed, nil
+}
+
+// ReloadFunc is called by [Queries.Watch] after each reload that changed
+// something or failed.
+type ReloadFunc func(version string, changed []string, err error)
+
+// Watch polls the query files every interval and reloads them when they
+// change until the context is canceled. This is meant for development where
+// editing a .sql file should not require a restart.
+func (q *Queries) Watch(ctx context.Context, interval time.Duration, fn ReloadFunc) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			changed, err := q.Reload()
+			if fn != nil && (err != nil || len(changed) > 0) {
+				fn(q.Version(), changed, err)
+			}
+		}
+	}
+}
+
+func parseFS(fsys fs.FS, patterns []string) (map[string]Query, error) {
+	var files []string
+	for _, p := range patterns {
+		matches, err := fs.Glob(fsys, p)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		files = append(files, matches...)
+	}
+	slices.Sort(files)
+	files = slices.Compact(files)
+	queries := make(map[string]Query)
+	for _, f := range files {
+		b, err := fs.ReadFile(fsys, f)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		parsed, err := Parse(string(b))
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s", f)
+		}
+		for _, query := range parsed {
+			if prev, ok := queries[query.Name]; ok {
+				return nil, errors.Errorf("%s: query %q already defined in %s", f, query.Name, prev.File)
+			}
+			query.File = f
+			queries[query.Name] = query
+		}
+	}
+	return queries, nil
+}
+
+const namePrefix = "name:"
+
+// Parse parses queries from the contents of a SQL file.
+func Parse(src string) ([]Query, error) {
+	var (
+		queries []Query
+		cur     *Query
+		body    strings.Builder
+		line    int
+	)
+	finish := func() error {
+		if cur == nil {
+			return nil
+		}
+		cur.SQL = strings.TrimRight(strings.TrimSpace(body.String()), ";")
+		cur.SQL = strings.TrimSpace(cur.SQL)
+		if len(cur.SQL) == 0 {
+			return errors.Errorf("query %q is empty", cur.Name)
+		}
+		cur.Hash = hash(cur.SQL)
+		queries = append(queries, *cur)
+		body.Reset()
+		return nil
+	}
+	sc := bufio.NewScanner(strings.NewReader(src))
+	for sc.Scan() {
+		line++
+		text := sc.Text()
+		trimmed := strings.TrimSpace(text)
+		if c, ok := strings.CutPrefix(trimmed, "--"); ok {
+			if name, ok := strings.CutPrefix(strings.TrimSpace(c), namePrefix); ok {
+				if err := finish(); err != nil {
+					return nil, err
+				}
+				name = strings.TrimSpace(name)
+				if len(name) == 0 || strings.ContainsAny(name, " \t") {
+					return nil, errors.Errorf("line %d: invalid query name %q", line, name)
+				}
+				cur = &Query{Name: name}
+				continue
+			}
+		}
+		if cur == nil {
+			if len(trimmed) > 0 && !strings.HasPrefix(trimmed, "--") {
+				return nil, errors.Errorf("line %d: sql found before the first %q comment", line, "-- "+namePrefix)
+			}
+			continue
+		}
+		body.WriteString(text)
+		body.WriteByte('\n')
+	}
+	if err := sc.Err(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := finish(); err != nil {
+		return nil, err
+	}
+	return queries, nil
+}
+
+func hash(s string) string {
+	h := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(h[:6])
+}
+
+func versionOf(queries map[string]Query) string {
+	names := make([]string, 0, len(queries))
+	for n := range queries {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	h := sha256.New()
+	for _, n := range names {
+		h.Write([]byte(n))
+		h.Write([]byte{0})
+		h.Write([]byte(queries[n].Hash))
+	}
+	return hex.EncodeToString(h.Sum(nil)[:6])
+}
@@ -0,0 +1,123 @@
+package queries
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+const usersSQL = `-- Queries for the users table.
+
+-- name: GetUser
+SELECT *
+FROM users
+WHERE id = $1;
+
+-- name: ListUsers
+-- List every user.
+SELECT * FROM users;
+`
+
+func TestParse(t *testing.T) {
+	is := is.New(t)
+	qs, err := Parse(usersSQL)
+	is.NoErr(err)
+	is.Equal(len(qs), 2)
+	is.Equal(qs[0].Name, "GetUser")
+	is.Equal(qs[0].SQL, "SELECT *\nFROM users\nWHERE id = $1")
+	is.Equal(qs[1].Name, "ListUsers")
+	is.Equal(qs[1].SQL, "-- List every user.\nSELECT * FROM users")
+	is.Equal(len(qs[0].Hash), 12)
+
+	for _, src := range []string{
+		"select 1;\n-- name: A\nselect 1",
+		"-- name: A\n-- name: B\nselect 1",
+		"-- name: A B\nselect 1",
+		"-- name:\nselect 1",
+	} {
+		_, err = Parse(src)
+		is.True(err != nil)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	is := is.New(t)
+	fsys := fstest.MapFS{
+		"users.sql": {Data: []byte(usersSQL)},
+		"orgs.sql":  {Data: []byte("-- name: GetOrg\nselect * from orgs where id = $1")},
+		"notes.txt": {Data: []byte("not sql")},
+	}
+	q, err := Load(fsys)
+	is.NoErr(err)
+	is.Equal(q.Names(), []string{"GetOrg", "GetUser", "ListUsers"})
+	get, ok := q.Get("GetOrg")
+	is.True(ok)
+	is.Equal(get.File, "orgs.sql")
+	_, ok = q.Get("Nope")
+	is.True(!ok)
+
+	var buf bytes.Buffer
+	slog.New(slog.NewTextHandler(&buf, nil)).Info("slow query", "query", get)
+	is.True(strings.Contains(buf.String(), "query.name=GetOrg query.hash="+get.Hash))
+
+	version := q.Version()
+	changed, err := q.Reload()
+	is.NoErr(err)
+	is.Equal(len(changed), 0)
+	is.Equal(q.Version(), version)
+
+	fsys["orgs.sql"] = &fstest.MapFile{Data: []byte("-- name: GetOrg\nselect id from orgs where id = $1")}
+	delete(fsys, "users.sql")
+	fsys["more.sql"] = &fstest.MapFile{Data: []byte("-- name: Count\nselect count(*) from users")}
+	changed, err = q.Reload()
+	is.NoErr(err)
+	is.Equal(changed, []string{"Count", "GetOrg", "GetUser", "ListUsers"})
+	is.True(q.Version() != version)
+
+	// Invalid files keep the old queries.
+	fsys["dup.sql"] = &fstest.MapFile{Data: []byte("-- name: Count\nselect 1")}
+	_, err = q.Reload()
+	is.True(err != nil)
+	_, ok = q.Get("Count")
+	is.True(ok)
+	_, err = Load(fsys)
+	is.True(err != nil)
+	_, err = Load(fsys, "[")
+	is.True(err != nil)
+}
+
+func TestWatch(t *testing.T) {
+	is := is.New(t)
+	fsys := fstest.MapFS{"a.sql": {Data: []byte("-- name: A\nselect 1")}}
+	q, err := Load(fsys)
+	is.NoErr(err)
+	ctx, cancel := context.WithCancel(context.Background())
+	var (
+		mu      sync.Mutex
+		reloads [][]string
+	)
+	fsys["a.sql"] = &fstest.MapFile{Data: []byte("-- name: A\nselect 2")}
+	done := make(chan error)
+	ready := make(chan struct{})
+	go func() {
+		done <- q.Watch(ctx, time.Millisecond, func(version string, changed []string, err error) {
+			mu.Lock()
+			reloads = append(reloads, changed)
+			mu.Unlock()
+			ready <- struct{}{}
+		})
+	}()
+	<-ready
+	cancel()
+	is.NoErr(<-done)
+	is.Equal(reloads, [][]string{{"A"}})
+	a, _ := q.Get("A")
+	is.Equal(a.SQL, "select 2")
+}
@@ -0,0 +1,49 @@
+package db
+
+import (
+	"fmt"
+	"time"
+)
+
+// Operations reported by [QueryError].
+const (
+	opQuery    = "query"
+	opExec     = "exec"
+	opBegin    = "begin"
+	opCommit   = "commit"
+	opRollback = "rollback"
+)
+
+// maxErrorQueryLen is the number of bytes of a query kept in a [QueryError].
+const maxErrorQueryLen = 256
+
+// QueryError is returned by the wrapper from [New] when a statement fails.
+// It records which statement failed and can be unwrapped to get the
+// driver's error.
+type QueryError struct {
+	// Op is the operation that failed, i.e. "query" or "exec".
+	Op string
+	// Query is the statement, truncated if it is very long.
+	Query string
+	// Args is the number of arguments passed with the statement.
+	Args int
+	// Duration is how long the operation ran before it failed.
+	Duration time.Duration
+	Err      error
+}
+
+func newQueryError(op, query string, args []any, d time.Duration, err error) *QueryError {
+	if len(query) > maxErrorQueryLen {
+		query = query[:maxErrorQueryLen] + "..."
+	}
+	return &QueryError{Op: op, Query: query, Args: len(args), Duration: d, Err: err}
+}
+
+func (e *QueryError) Error() string {
+	if len(e.Query) == 0 {
+		return fmt.Sprintf("%s failed after %s: %v", e.Op, e.Duration, e.Err)
+	}
+	return fmt.Sprintf("%s %q with %d args failed after %s: %v", e.Op, e.Query, e.Args, e.Duration, e.Err)
+}
+
+func (e *QueryError) Unwrap() error { return e.Err }
@@ -0,0 +1,349 @@
+// Package queue is a database backed job queue. Jobs are claimed with
+// "FOR UPDATE SKIP LOCKED" so that many workers can poll the same table
+// without blocking each other.
+package queue
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"log/slog"
+	"math"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/harrybrwn/db"
+)
+
+// Job states.
+const (
+	StatePending = "pending"
+	StateDone    = "done"
+	StateDead    = "dead"
+)
+
+// DefaultTable is the name of the jobs table.
+const DefaultTable = "jobs"
+
+// Job is a unit of work in a queue.
+type Job struct {
+	ID    int64
+	Queue string
+	// Payload is the data the handler needs to do the job.
+	Payload []byte
+	// Attempts is the number of times the job has been claimed, including
+	// the current attempt.
+	Attempts int
+	// MaxAttempts is the number of attempts before the job is moved to the
+	// dead state. Defaults to 5.
+	MaxAttempts int
+	// RunAt is the earliest time the job can run. Defaults to now.
+	RunAt time.Time
+	// LastError is the error from the previous failed attempt.
+	LastError string
+}
+
+// Handler does a job. Returning an error schedules a retry, or kills the job
+// after it runs out of attempts.
+type Handler func(ctx context.Context, job *Job) error
+
+// Option configures the queue functions.
+type Option func(*options)
+
+type options struct {
+	table        string
+	poll         time.Duration
+	visibility   time.Duration
+	backoff      func(attempt int) time.Duration
+	logger       *slog.Logger
+	dialect      db.Dialect
+	deleteOnDone bool
+}
+
+// WithTable sets the name of the jobs table.
+func WithTable(name string) Option { return func(o *options) { o.table = name } }
+
+// WithPollInterval sets how long a worker waits before checking for jobs
+// when the queue is empty. Defaults to 1s.
+func WithPollInterval(d time.Duration) Option { return func(o *options) { o.poll = d } }
+
+// WithVisibilityTimeout sets how long a claimed job is hidden from other
+// workers. If the handler takes longer than this the job can be claimed
+// again. Defaults to 5m.
+func WithVisibilityTimeout(d time.Duration) Option {
+	return func(o *options) { o.visibility = d }
+}
+
+// WithBackoff sets the delay before a failed job is retried.
+func WithBackoff(fn func(attempt int) time.Duration) Option {
+	return func(o *options) { o.backoff = fn }
+}
+
+// WithLogger sets the worker's logger.
+func WithLogger(l *slog.Logger) Option { return func(o *options) { o.logger = l } }
+
+// WithDialect sets the dialect used to build queries. Defaults to the
+// dialect of the database.
+func WithDialect(d db.Dialect) Option { return func(o *options) { o.dialect = d } }
+
+// WithDeleteOnDone deletes jobs when they finish instead of keeping them in
+// the done state.
+func WithDeleteOnDone() Option { return func(o *options) { o.deleteOnDone = true } }
+
+// ExponentialBackoff returns base * 2^(attempt-1) capped at maxDelay.
+func ExponentialBackoff(base, maxDelay time.Duration) func(attempt int) time.Duration {
+	return func(attempt int) time.Duration {
+		d := float64(base) * math.Pow(2, float64(attempt-1))
+		if d > float64(maxDelay) {
+			return maxDelay
+		}
+		return time.Duration(d)
+	}
+}
+
+func newOptions(d any, opts []Option) options {
+	o := options{
+		table:      DefaultTable,
+		poll:       time.Second,
+		visibility: 5 * time.Minute,
+		backoff:    ExponentialBackoff(time.Second, time.Hour),
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.logger == nil {
+		o.logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	if o.dialect == nil {
+		o.dialect = db.DialectOf(d)
+	}
+	return o
+}
+
+func (o *options) rebind(query string) string { return db.Rebind(o.dialect.Type(), query) }
+
+var now = time.Now
+
+func timestamp() time.Time { return now().UTC().Truncate(time.Microsecond) }
+
+// CreateTable creates the jobs table if it doesn't exist.
+func CreateTable(ctx context.Context, d db.DB, opts ...Option) error {
+	o := newOptions(d, opts)
+	id, blob := "BIGSERIAL PRIMARY KEY", "BYTEA"
+	switch o.dialect.Type() {
+	case db.MySQLDBType:
+		id, blob = "BIGINT AUTO_INCREMENT PRIMARY KEY", "LONGBLOB"
+	case db.SQLiteDBType:
+		id, blob = "INTEGER PRIMARY KEY AUTOINCREMENT", "BLOB"
+	}
+	table := o.dialect.QuoteIdent(o.table)
+	_, err := d.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+table+` (
+		id           `+id+`,
+		queue        VARCHAR(255) NOT NULL,
+		payload      `+blob+`,
+		state        VARCHAR(16) NOT NULL DEFAULT 'pending',
+		attempts     INTEGER NOT NULL DEFAULT 0,
+		max_attempts INTEGER NOT NULL,
+		run_at       TIMESTAMP NOT NULL,
+		locked_until TIMESTAMP NULL,
+		last_error   TEXT
+	)`)
+	return err
+}
+
+// Enqueue adds a job to its queue.
+func Enqueue(ctx context.Context, d db.DB, job Job, opts ...Option) error {
+	o := newOptions(d, opts)
+	if len(job.Queue) == 0 {
+		return errors.New("queue: job has no queue name")
+	}
+	if job.MaxAttempts <= 0 {
+		job.MaxAttempts = 5
+	}
+	if job.RunAt.IsZero() {
+		job.RunAt = timestamp()
+	}
+	_, err := d.ExecContext(ctx, o.rebind(`INSERT INTO `+o.dialect.QuoteIdent(o.table)+
+		` (queue, payload, state, max_attempts, run_at) VALUES (?, ?, ?, ?, ?)`),
+		job.Queue, job.Payload, StatePending, job.MaxAttempts, job.RunAt.UTC())
+	return err
+}
+
+// Worker claims and runs jobs from a queue.
+type Worker struct {
+	db      db.DB
+	queue   string
+	handler Handler
+	opts    options
+}
+
+// NewWorker creates a worker for the named queue. Jobs are claimed with
+// "FOR UPDATE SKIP LOCKED", so only postgres, cockroachdb, mysql and sqlite
+// are supported.
+func NewWorker(d db.DB, queue string, h Handler, opts ...Option) (*Worker, error) {
+	o := newOptions(d, opts)
+	switch t := o.dialect.Type(); t {
+	case db.PostgresDBType, db.CockroachDBType, db.MySQLDBType, db.SQLiteDBType:
+	default:
+		return nil, errors.Errorf("queue: %q is not supported", t)
+	}
+	return &Worker{db: d, queue: queue, handler: h, opts: o}, nil
+}
+
+// Run works on jobs until the context is done.
+func (w *Worker) Run(ctx context.Context) error {
+	for {
+		ok, err := w.Work(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			w.opts.logger.Warn("failed to work on job", slog.Any("error", err))
+		}
+		if ok && err == nil {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(w.opts.poll):
+		}
+	}
+}
+
+// Work claims one job and runs it. It returns false if there were no jobs
+// ready to run. Errors returned by the handler are recorded on the job and
+// are not returned.
+func (w *Worker) Work(ctx context.Context) (bool, error) {
+	job, err := w.claim(ctx)
+	if err != nil || job == nil {
+		return false, err
+	}
+	// Record the outcome even if the handler's context was canceled.
+	bg := context.WithoutCancel(ctx)
+	if err = w.run(ctx, job); err != nil {
+		w.opts.logger.Warn("job failed",
+			slog.Int64("id", job.ID),
+			slog.String("queue", job.Queue),
+			slog.Int("attempt", job.Attempts),
+			slog.Any("error", err))
+		return true, w.fail(bg, job, err)
+	}
+	return true, w.finish(bg, job)
+}
+
+func (w *Worker) run(ctx context.Context, job *Job) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = errors.Errorf("job panicked: %v", p)
+		}
+	}()
+	return w.handler(ctx, job)
+}
+
+// errTimedOut is recorded on jobs that used their last attempt without
+// being finished, i.e. because the worker crashed.
+const errTimedOut = "queue: job was not finished before its visibility timeout"
+
+// claim locks the next ready job and hides it from other workers for the
+// visibility timeout. Jobs whose last attempt timed out are moved to the
+// dead state instead of being claimed again.
+func (w *Worker) claim(ctx context.Context) (*Job, error) {
+	o := &w.opts
+	table := o.dialect.QuoteIdent(o.table)
+	lock := " FOR UPDATE SKIP LOCKED"
+	if o.dialect.Type() == db.SQLiteDBType {
+		// sqlite locks the whole database for writes.
+		lock = ""
+	}
+	query := o.rebind(`SELECT id, queue, payload, attempts, max_attempts, last_error FROM ` + table +
+		` WHERE queue = ? AND state = ? AND run_at <= ? AND (locked_until IS NULL OR locked_until <= ?)` +
+		` AND attempts < max_attempts ORDER BY run_at, id ` + o.dialect.Limit(1, 0) + lock)
+	update := o.rebind(`UPDATE ` + table + ` SET attempts = attempts + 1, locked_until = ? WHERE id = ?`)
+	exhausted := o.rebind(`UPDATE ` + table + ` SET state = ?, locked_until = NULL, last_error = ?` +
+		` WHERE queue = ? AND state = ? AND attempts >= max_attempts AND locked_until <= ?`)
+
+	tx, err := db.Begin(ctx, nil, w.db)
+	if err != nil {
+		return nil, err
+	}
+	var job *Job
+	err = db.TxDo(ctx, tx, func(tx db.Tx) error {
+		ts := timestamp()
+		_, err := tx.ExecContext(ctx, exhausted, StateDead, errTimedOut, w.queue, StatePending, ts)
+		if err != nil {
+			return err
+		}
+		rows, err := tx.QueryContext(ctx, query, w.queue, StatePending, ts, ts)
+		if err != nil {
+			return err
+		}
+		var (
+			j       Job
+			lastErr sql.NullString
+		)
+		err = db.ScanOne(rows, &j.ID, &j.Queue, &j.Payload, &j.Attempts, &j.MaxAttempts, &lastErr)
+		if db.IsNotFound(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if _, err = tx.ExecContext(ctx, update, ts.Add(o.visibility), j.ID); err != nil {
+			return err
+		}
+		j.Attempts++
+		j.LastError = lastErr.String
+		job = &j
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// finish marks a job as done. The attempt number guards against finishing a
+// job that was claimed again after its visibility timeout.
+func (w *Worker) finish(ctx context.Context, job *Job) error {
+	o := &w.opts
+	table := o.dialect.QuoteIdent(o.table)
+	var err error
+	if o.deleteOnDone {
+		_, err = w.db.ExecContext(ctx, o.rebind(`DELETE FROM `+table+` WHERE id = ? AND attempts = ?`), job.ID, job.Attempts)
+	} else {
+		_, err = w.db.ExecContext(ctx, o.rebind(`UPDATE `+table+
+			` SET state = ?, locked_until = NULL WHERE id = ? AND attempts = ?`),
+			StateDone, job.ID, job.Attempts)
+	}
+	return err
+}
+
+// fail schedules a retry or moves the job to the dead state.
+func (w *Worker) fail(ctx context.Context, job *Job, jobErr error) error {
+	o := &w.opts
+	state, runAt := StatePending, timestamp().Add(o.backoff(job.Attempts))
+	if job.Attempts >= job.MaxAttempts {
+		state = StateDead
+	}
+	_, err := w.db.ExecContext(ctx, o.rebind(`UPDATE `+o.dialect.QuoteIdent(o.table)+
+		` SET state = ?, run_at = ?, locked_until = NULL, last_error = ? WHERE id = ? AND attempts = ?`),
+		state, runAt, jobErr.Error(), job.ID, job.Attempts)
+	return err
+}
+
+// Requeue moves a dead job back to the pending state with a fresh set of
+// attempts.
+func Requeue(ctx context.Context, d db.DB, id int64, opts ...Option) error {
+	o := newOptions(d, opts)
+	res, err := d.ExecContext(ctx, o.rebind(`UPDATE `+o.dialect.QuoteIdent(o.table)+
+		` SET state = ?, attempts = 0, run_at = ?, locked_until = NULL WHERE id = ? AND state = ?`),
+		StatePending, timestamp(), id, StateDead)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return db.ErrNotFound
+	}
+	return nil
+}
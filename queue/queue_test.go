@@ -0,0 +1,199 @@
+package queue
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/pkg/errors"
+
+	"github.com/harrybrwn/db"
+)
+
+func setup(t *testing.T) db.DB {
+	t.Helper()
+	pool, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.SetMaxOpenConns(1)
+	t.Cleanup(func() { pool.Close() })
+	d := db.New(pool, db.WithDialect(db.DialectFor(db.SQLiteDBType)))
+	if err = CreateTable(context.Background(), d); err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func withNow(tm time.Time) func() {
+	now = func() time.Time { return tm }
+	return func() { now = time.Now }
+}
+
+func state(t *testing.T, d db.DB, id int64) (st string, attempts int, lastErr sql.NullString) {
+	t.Helper()
+	rows, err := d.QueryContext(context.Background(), "SELECT state, attempts, last_error FROM jobs WHERE id = ?", id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.ScanOne(rows, &st, &attempts, &lastErr); err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func TestWorker(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	d := setup(t)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	defer withNow(start)()
+
+	is.NoErr(Enqueue(ctx, d, Job{Queue: "email", Payload: []byte("a")}))
+	is.NoErr(Enqueue(ctx, d, Job{Queue: "email", Payload: []byte("b"), MaxAttempts: 2}))
+	is.NoErr(Enqueue(ctx, d, Job{Queue: "email", Payload: []byte("later"), RunAt: start.Add(time.Hour)}))
+	is.NoErr(Enqueue(ctx, d, Job{Queue: "other"}))
+	is.True(Enqueue(ctx, d, Job{}) != nil)
+
+	var seen []string
+	w, err := NewWorker(d, "email", func(_ context.Context, j *Job) error {
+		seen = append(seen, string(j.Payload))
+		if string(j.Payload) == "b" {
+			return errors.New("smtp down")
+		}
+		return nil
+	}, WithBackoff(func(int) time.Duration { return time.Minute }))
+	is.NoErr(err)
+
+	ok, err := w.Work(ctx)
+	is.NoErr(err)
+	is.True(ok)
+	st, attempts, _ := state(t, d, 1)
+	is.Equal(st, StateDone)
+	is.Equal(attempts, 1)
+
+	ok, err = w.Work(ctx)
+	is.NoErr(err)
+	is.True(ok)
+	st, attempts, lastErr := state(t, d, 2)
+	is.Equal(st, StatePending)
+	is.Equal(attempts, 1)
+	is.Equal(lastErr.String, "smtp down")
+
+	// Nothing is ready until the backoff is over.
+	ok, err = w.Work(ctx)
+	is.NoErr(err)
+	is.True(!ok)
+
+	withNow(start.Add(2 * time.Minute))
+	ok, err = w.Work(ctx)
+	is.NoErr(err)
+	is.True(ok)
+	st, attempts, _ = state(t, d, 2)
+	is.Equal(st, StateDead)
+	is.Equal(attempts, 2)
+	is.Equal(seen, []string{"a", "b", "b"})
+
+	is.NoErr(Requeue(ctx, d, 2))
+	st, attempts, _ = state(t, d, 2)
+	is.Equal(st, StatePending)
+	is.Equal(attempts, 0)
+	is.True(errors.Is(Requeue(ctx, d, 2), db.ErrNotFound))
+}
+
+func TestWorker_VisibilityTimeout(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	d := setup(t)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	defer withNow(start)()
+	is.NoErr(Enqueue(ctx, d, Job{Queue: "q"}))
+
+	w, err := NewWorker(d, "q", func(context.Context, *Job) error { return nil },
+		WithVisibilityTimeout(time.Minute), WithDeleteOnDone())
+	is.NoErr(err)
+	job, err := w.claim(ctx)
+	is.NoErr(err)
+	is.True(job != nil)
+	// Hidden while claimed
+	again, err := w.claim(ctx)
+	is.NoErr(err)
+	is.True(again == nil)
+
+	withNow(start.Add(2 * time.Minute))
+	again, err = w.claim(ctx)
+	is.NoErr(err)
+	is.Equal(again.Attempts, 2)
+	// The first claim can no longer finish the job.
+	is.NoErr(w.finish(ctx, job))
+	st, _, _ := state(t, d, job.ID)
+	is.Equal(st, StatePending)
+	is.NoErr(w.finish(ctx, again))
+	rows, err := d.QueryContext(ctx, "SELECT id FROM jobs")
+	is.NoErr(err)
+	is.True(db.IsNotFound(db.ScanOne(rows, new(int64))))
+}
+
+func TestWorker_Run(t *testing.T) {
+	is := is.New(t)
+	d := setup(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	is.NoErr(Enqueue(ctx, d, Job{Queue: "q"}))
+	w, err := NewWorker(d, "q", func(context.Context, *Job) error {
+		cancel()
+		panic("boom")
+	}, WithPollInterval(time.Millisecond))
+	is.NoErr(err)
+	is.True(errors.Is(w.Run(ctx), context.Canceled))
+	st, _, lastErr := state(t, d, 1)
+	is.Equal(st, StatePending)
+	is.Equal(lastErr.String, "job panicked: boom")
+}
+
+func TestWorker_Exhausted(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	d := setup(t)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	defer withNow(start)()
+	is.NoErr(Enqueue(ctx, d, Job{Queue: "q", MaxAttempts: 2}))
+
+	w, err := NewWorker(d, "q", func(context.Context, *Job) error { return nil },
+		WithVisibilityTimeout(time.Minute))
+	is.NoErr(err)
+	// The worker crashes without finishing the job, twice.
+	for i := range 2 {
+		withNow(start.Add(time.Duration(i) * 2 * time.Minute))
+		job, err := w.claim(ctx)
+		is.NoErr(err)
+		is.Equal(job.Attempts, i+1)
+	}
+	withNow(start.Add(4 * time.Minute))
+	job, err := w.claim(ctx)
+	is.NoErr(err)
+	is.True(job == nil)
+	st, attempts, lastErr := state(t, d, 1)
+	is.Equal(st, StateDead)
+	is.Equal(attempts, 2)
+	is.Equal(lastErr.String, errTimedOut)
+}
+
+func TestNewWorker_Unsupported(t *testing.T) {
+	is := is.New(t)
+	d := setup(t)
+	for _, typ := range []db.Type{db.SQLServerDBType, db.ClickHouseDBType} {
+		_, err := NewWorker(d, "q", nil, WithDialect(db.DialectFor(typ)))
+		is.True(err != nil)
+	}
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	is := is.New(t)
+	b := ExponentialBackoff(time.Second, 10*time.Second)
+	is.Equal(b(1), time.Second)
+	is.Equal(b(3), 4*time.Second)
+	is.Equal(b(10), 10*time.Second)
+}
@@ -0,0 +1,76 @@
+package db
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Rebind converts the placeholders in a query to the style used by the
+// database type. For postgres "?" placeholders become "$1", "$2", etc. For
+// other databases "$n" placeholders become "?", which assumes that they
+// appear in order and are not reused. Use [RebindArgs] for queries where
+// they might not be.
+func Rebind(t Type, query string) string {
+	query, _, _ = rebind(t, query, nil, false)
+	return query
+}
+
+// RebindArgs is like [Rebind] but also returns the arguments for the new
+// placeholders. When "$n" placeholders become "?" the arguments are
+// reordered and repeated to match, so "$2 ... $1 ... $1" is run with the
+// second, first and first arguments. An error is returned when a
+// placeholder has no argument or when "?" and "$n" placeholders are mixed.
+func RebindArgs(t Type, query string, args []any) (string, []any, error) {
+	return rebind(t, query, args, true)
+}
+
+func rebind(t Type, query string, args []any, withArgs bool) (string, []any, error) {
+	var (
+		b        strings.Builder
+		n        int
+		out      []any
+		dollar   bool
+		question bool
+	)
+	b.Grow(len(query) + 8)
+	d := DialectFor(t)
+	numbered := usesNumberedParams(d)
+	for i := 0; i < len(query); {
+		if end, ok := skipQuoted(query, i); ok {
+			b.WriteString(query[i:end])
+			i = end
+			continue
+		}
+		c := query[i]
+		switch {
+		case numbered && c == '?':
+			n++
+			b.WriteString(d.Placeholder(n))
+			i++
+		case !numbered && c == '$' && i+1 < len(query) && isDigit(query[i+1]):
+			var p int
+			p, i = readNumber(query, i+1)
+			b.WriteByte('?')
+			dollar = true
+			if !withArgs {
+				continue
+			}
+			if p < 1 || p > len(args) {
+				return "", nil, errors.Errorf("placeholder $%d has no argument, got %d", p, len(args))
+			}
+			out = append(out, args[p-1])
+		default:
+			question = question || c == '?'
+			b.WriteByte(c)
+			i++
+		}
+	}
+	if !dollar {
+		return b.String(), args, nil
+	}
+	if withArgs && question {
+		return "", nil, errors.New("query mixes ? and $n placeholders")
+	}
+	return b.String(), out, nil
+}
@@ -0,0 +1,169 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	stderrors "errors"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrUnknownDatabase is returned when a name is not in a [Registry].
+	ErrUnknownDatabase = errors.New("unknown database")
+	// ErrDuplicateDatabase is returned when adding a name that is already in
+	// a [Registry].
+	ErrDuplicateDatabase = errors.New("database already registered")
+)
+
+// Registry holds named database handles for services that connect to more
+// than one database.
+type Registry struct {
+	mu   sync.RWMutex
+	dbs  map[string]*registryEntry
+	opts []Option
+}
+
+type registryEntry struct {
+	pool *sql.DB
+	db   *database
+}
+
+// NewRegistry creates an empty [Registry]. The options are used when
+// wrapping every database added to the registry.
+func NewRegistry(opts ...Option) *Registry {
+	return &Registry{dbs: make(map[string]*registryEntry), opts: opts}
+}
+
+// Open fills in the config from the environment and opens a database
+// under the given name. Environment variables are prefixed with the upper
+// case name so "analytics" reads ANALYTICS_POSTGRES_HOST and so on.
+func (r *Registry) Open(name string, cfg *Config, opts ...Option) (DB, error) {
+	cfg.init(strings.ToUpper(name) + "_")
+	opts = append(append(slices.Clone(r.opts), WithDialect(cfg.Dialect())), opts...)
+	options := applyOptions(opts)
+	pool, err := cfg.openPool(&options)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open database %q", name)
+	}
+	d, err := r.add(name, pool, options)
+	if err != nil {
+		pool.Close()
+		return nil, err
+	}
+	return d, nil
+}
+
+// Add adds an open connection pool to the registry. The registry takes
+// ownership of the pool and closes it in [Registry.CloseAll]. Like [New],
+// options that configure opening a pool are ignored.
+func (r *Registry) Add(name string, pool *sql.DB, opts ...Option) (DB, error) {
+	return r.add(name, pool, applyOptions(append(slices.Clone(r.opts), opts...)))
+}
+
+func (r *Registry) add(name string, pool *sql.DB, options dbOptions) (DB, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.dbs[name]; ok {
+		return nil, errors.Wrapf(ErrDuplicateDatabase, "%q", name)
+	}
+	d := newDatabase(pool, options)
+	r.dbs[name] = &registryEntry{pool: pool, db: d}
+	return d, nil
+}
+
+// Get returns the database with the given name.
+func (r *Registry) Get(name string) (DB, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	e, ok := r.dbs[name]
+	if !ok {
+		return nil, errors.Wrapf(ErrUnknownDatabase, "%q", name)
+	}
+	return e.db, nil
+}
+
+// Names returns the sorted names of the databases in the registry.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.dbs))
+	for name := range r.dbs {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// each calls fn for every database concurrently and joins the errors.
+func (r *Registry) each(fn func(name string, e *registryEntry) error) error {
+	r.mu.RLock()
+	entries := make(map[string]*registryEntry, len(r.dbs))
+	for name, e := range r.dbs {
+		entries[name] = e
+	}
+	r.mu.RUnlock()
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for name, e := range entries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := fn(name, e); err != nil {
+				mu.Lock()
+				errs = append(errs, errors.WithMessagef(err, "database %q", name))
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return stderrors.Join(errs...)
+}
+
+// WaitForAll calls [WaitFor] on every database concurrently.
+func (r *Registry) WaitForAll(ctx context.Context, opts ...WaitOpt) error {
+	return r.each(func(_ string, e *registryEntry) error {
+		return WaitFor(ctx, e.pool, opts...)
+	})
+}
+
+// Health pings every database and returns the errors by name. Healthy
+// databases have a nil error.
+func (r *Registry) Health(ctx context.Context) map[string]error {
+	var mu sync.Mutex
+	health := make(map[string]error)
+	r.each(func(name string, e *registryEntry) error {
+		err := e.pool.PingContext(ctx)
+		mu.Lock()
+		health[name] = err
+		mu.Unlock()
+		return nil
+	})
+	return health
+}
+
+// Ping pings every database and returns an error if any of them are down.
+func (r *Registry) Ping() error { return r.PingContext(context.Background()) }
+
+// PingContext pings every database and returns an error if any of them are
+// down.
+func (r *Registry) PingContext(ctx context.Context) error {
+	return r.each(func(_ string, e *registryEntry) error {
+		return e.pool.PingContext(ctx)
+	})
+}
+
+// CloseAll closes every database and its pool and empties the registry.
+func (r *Registry) CloseAll() error {
+	err := r.each(func(_ string, e *registryEntry) error { return e.db.Close() })
+	r.mu.Lock()
+	clear(r.dbs)
+	r.mu.Unlock()
+	return err
+}
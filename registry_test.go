@@ -0,0 +1,59 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/pkg/errors"
+)
+
+var _ Pingable = (*Registry)(nil)
+
+func TestRegistry(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	t.Setenv("ANALYTICS_SQLITE3_DB", "file:analytics?mode=memory&cache=shared")
+	r := NewRegistry(WithArgCoercion())
+
+	analytics, err := r.Open("analytics", &Config{Type: SQLiteDBType})
+	is.NoErr(err)
+	_, err = analytics.ExecContext(ctx, "CREATE TABLE events (id int)")
+	is.NoErr(err)
+	is.Equal(DialectOf(analytics).Type(), SQLiteDBType)
+
+	pool, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	main, err := r.Add("main", pool)
+	is.NoErr(err)
+	is.True(main.(*database).coerceArgs)
+	_, err = r.Add("main", pool)
+	is.True(errors.Is(err, ErrDuplicateDatabase))
+
+	got, err := r.Get("analytics")
+	is.NoErr(err)
+	is.Equal(got, analytics)
+	_, err = r.Get("billing")
+	is.True(errors.Is(err, ErrUnknownDatabase))
+	is.Equal(r.Names(), []string{"analytics", "main"})
+
+	is.NoErr(r.WaitForAll(ctx))
+	is.NoErr(r.Ping())
+	is.Equal(r.Health(ctx), map[string]error{"analytics": nil, "main": nil})
+
+	is.NoErr(pool.Close())
+	is.True(r.PingContext(ctx) != nil)
+	health := r.Health(ctx)
+	is.NoErr(health["analytics"])
+	is.True(health["main"] != nil)
+
+	_, err = r.Open("billing", &Config{Type: "nope"})
+	is.True(errors.Is(err, ErrDriverNotRegistered))
+
+	is.NoErr(r.CloseAll())
+	is.Equal(len(r.Names()), 0)
+	// The wrappers are closed, which stops their background goroutines.
+	_, open := <-analytics.(*database).stop
+	is.True(!open)
+}
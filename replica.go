@@ -0,0 +1,47 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"sync/atomic"
+)
+
+// WithReplicas routes read only queries that are run outside of a
+// transaction to the replicas in round robin order. Writes, transactions,
+// locking reads like SELECT ... FOR UPDATE and reads made with a context
+// from [AfterWrite] use the primary. The
+// caller is responsible for closing the replicas.
+func WithReplicas(replicas ...*sql.DB) Option {
+	return func(o *dbOptions) { o.replicas = append(o.replicas, replicas...) }
+}
+
+type primaryKey struct{}
+
+// AfterWrite returns a context that pins reads to the primary database when
+// using [WithReplicas]. Use it for the rest of a request after writing so
+// that the request reads its own writes instead of possibly stale data from
+// a replica.
+func AfterWrite(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// IsPinnedToPrimary reports whether the context was created with
+// [AfterWrite].
+func IsPinnedToPrimary(ctx context.Context) bool {
+	pinned, _ := ctx.Value(primaryKey{}).(bool)
+	return pinned
+}
+
+type replicaSet struct {
+	dbs  []*sql.DB
+	next atomic.Uint64
+}
+
+// reader returns the pool that should run a query.
+func (db *database) reader(ctx context.Context, query string) *sql.DB {
+	if db.replicas == nil || IsPinnedToPrimary(ctx) || classifyStatement(query) != stmtRead || isLockingRead(query) {
+		return db.DB
+	}
+	n := db.replicas.next.Add(1)
+	return db.replicas.dbs[n%uint64(len(db.replicas.dbs))]
+}
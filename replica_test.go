@@ -0,0 +1,92 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestWithReplicas(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	open := func(name string) *sql.DB {
+		pool, err := sql.Open("sqlite3", ":memory:")
+		is.NoErr(err)
+		pool.SetMaxOpenConns(1)
+		_, err = pool.Exec("CREATE TABLE src (name TEXT)")
+		is.NoErr(err)
+		_, err = pool.Exec("INSERT INTO src VALUES (?)", name)
+		is.NoErr(err)
+		return pool
+	}
+	primary, r1, r2 := open("primary"), open("r1"), open("r2")
+	defer primary.Close()
+	defer r1.Close()
+	defer r2.Close()
+	d := New(primary, WithReplicas(r1, r2))
+
+	read := func(ctx context.Context) string {
+		var name string
+		rows, err := d.QueryContext(ctx, "SELECT name FROM src")
+		is.NoErr(err)
+		is.NoErr(ScanOne(rows, &name))
+		return name
+	}
+	seen := map[string]int{}
+	for range 4 {
+		seen[read(ctx)]++
+	}
+	is.Equal(seen, map[string]int{"r1": 2, "r2": 2})
+
+	pinned := AfterWrite(ctx)
+	is.True(IsPinnedToPrimary(pinned))
+	is.True(!IsPinnedToPrimary(ctx))
+	is.Equal(read(pinned), "primary")
+
+	// writes always go to the primary
+	rows, err := d.QueryContext(ctx, "INSERT INTO src VALUES ('x') RETURNING name")
+	is.NoErr(err)
+	var name string
+	is.NoErr(ScanOne(rows, &name))
+	var n int
+	is.NoErr(primary.QueryRow("SELECT count(*) FROM src").Scan(&n))
+	is.Equal(n, 2)
+
+	tx, err := d.BeginTx(ctx, nil)
+	is.NoErr(err)
+	rows, err = tx.QueryContext(ctx, "SELECT count(*) FROM src")
+	is.NoErr(err)
+	is.NoErr(ScanOne(rows, &n))
+	is.Equal(n, 2)
+	is.NoErr(tx.Rollback())
+}
+
+func TestWithReplicas_LockingReads(t *testing.T) {
+	is := is.New(t)
+	primary, replica := &sql.DB{}, &sql.DB{}
+	d := New(primary, WithReplicas(replica))
+	ctx := context.Background()
+	for _, query := range []string{
+		"SELECT * FROM jobs WHERE id = $1 FOR UPDATE",
+		"select * from jobs for update skip locked",
+		"SELECT * FROM jobs FOR NO KEY UPDATE",
+		"SELECT * FROM jobs FOR SHARE",
+		"SELECT * FROM jobs FOR KEY SHARE NOWAIT",
+		"SELECT * FROM jobs LOCK IN SHARE MODE",
+		"WITH j AS (SELECT id FROM jobs) SELECT * FROM j FOR UPDATE",
+	} {
+		is.True(isLockingRead(query))
+		is.Equal(d.reader(ctx, query), primary)
+	}
+	for _, query := range []string{
+		"SELECT * FROM jobs",
+		"SELECT 'for update' FROM jobs",
+		"SELECT * FROM jobs -- for update",
+		"SELECT * FROM \"lock\" WHERE x IN (SELECT 1)",
+	} {
+		is.True(!isLockingRead(query))
+		is.Equal(d.reader(ctx, query), replica)
+	}
+}
@@ -0,0 +1,380 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Repo implements the common queries for a table whose rows map to the
+// struct T. Columns are mapped the same way as [Get] and the primary key is
+// made of the fields with the "pk" tag option, i.e. `db:"id,pk"`, or the
+// "id" column if no field has it. A primary key with the "auto" option, i.e.
+// `db:"id,pk,auto"`, is generated by the database and set by
+// [Repo.Insert]. A field with the "version" option, i.e.
+// `db:"version,version"`, is used for optimistic locking by [Repo.Update].
+//
+// Tables with a deleted_at column, or the column set with [WithSoftDelete],
+// are soft deleted: [Repo.Delete] sets the column to the current time and
+// the other methods ignore rows where it is not NULL unless the Repo comes
+// from [Repo.WithDeleted].
+//
+// Every method takes the [DB] to use so the same Repo works with a
+// transaction.
+type Repo[T any] struct {
+	table   string
+	fields  []*field
+	pk      []*field
+	auto    *field
+	version *field
+	// deletedAt is the soft delete column, empty if rows are deleted.
+	deletedAt   string
+	withDeleted bool
+}
+
+// RepoOpt is an option for [NewRepo].
+type RepoOpt func(*repoOpts)
+
+type repoOpts struct {
+	deletedAt *string
+}
+
+// WithSoftDelete sets the column that marks soft deleted rows. Defaults to
+// deleted_at if T has a deleted_at field. An empty column turns soft delete
+// off.
+func WithSoftDelete(column string) RepoOpt {
+	return func(o *repoOpts) { o.deletedAt = &column }
+}
+
+// NewRepo creates a [Repo] for a table. It panics if T is not a struct, has
+// no primary key or has a version field that is not an integer.
+func NewRepo[T any](table string, opts ...RepoOpt) *Repo[T] {
+	var o repoOpts
+	for _, opt := range opts {
+		opt(&o)
+	}
+	t := reflect.TypeFor[T]()
+	if t.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("db.NewRepo: %s is not a struct", t))
+	}
+	sf := fieldsOf(t)
+	r := &Repo[T]{table: table, fields: sf.list}
+	for _, f := range sf.list {
+		if f.hasOpt("pk") {
+			r.pk = append(r.pk, f)
+		}
+		if f.hasOpt("version") {
+			r.version = f
+		}
+	}
+	if len(r.pk) == 0 {
+		if f, ok := sf.byName["id"]; ok {
+			r.pk = []*field{f}
+		}
+	}
+	if len(r.pk) == 0 {
+		panic(fmt.Sprintf("db.NewRepo: %s has no primary key", t))
+	}
+	if r.version != nil {
+		if ft := t.FieldByIndex(r.version.index); ft.Type.Kind() < reflect.Int || ft.Type.Kind() > reflect.Uint64 {
+			panic(fmt.Sprintf("db.NewRepo: version field %s.%s is not an integer", t, ft.Name))
+		}
+	}
+	if len(r.pk) == 1 && r.pk[0].hasOpt("auto") {
+		r.auto = r.pk[0]
+	}
+	if o.deletedAt != nil {
+		r.deletedAt = *o.deletedAt
+	} else if _, ok := sf.byName["deleted_at"]; ok {
+		r.deletedAt = "deleted_at"
+	}
+	return r
+}
+
+// WithDeleted returns a copy of the Repo whose methods include soft deleted
+// rows.
+func (r *Repo[T]) WithDeleted() *Repo[T] {
+	c := *r
+	c.withDeleted = true
+	return &c
+}
+
+// Table returns the table name.
+func (r *Repo[T]) Table() string { return r.table }
+
+// Get returns the row with the primary key. The key values are in the order
+// of the key's fields. [ErrNotFound] is returned if there is no such row.
+func (r *Repo[T]) Get(ctx context.Context, d DB, key ...any) (*T, error) {
+	dialect := DialectOf(d)
+	where, err := r.keyWhere(dialect, key, 0)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := d.QueryContext(ctx, r.selectFrom(dialect)+" WHERE "+where+r.notDeleted(dialect), key...)
+	if err != nil {
+		return nil, err
+	}
+	var v T
+	if err = ScanOne(rows, r.scanDest(&v)...); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// List returns the rows matched by the clauses that follow "SELECT ... FROM
+// table", i.e. "WHERE a = ? ORDER BY b", written with the database's
+// placeholders. The clauses may be empty and can come from
+// [ListRequest.Clauses]. Soft deleted rows are filtered out in a subquery
+// that is aliased to the table name.
+func (r *Repo[T]) List(ctx context.Context, d DB, clauses string, args ...any) ([]T, error) {
+	dialect := DialectOf(d)
+	query := r.selectFrom(dialect)
+	if cond := r.notDeleted(dialect); len(cond) > 0 {
+		alias := r.table[strings.LastIndexByte(r.table, '.')+1:]
+		query = r.selectCols(dialect) + " FROM (SELECT * FROM " + dialect.QuoteIdent(r.table) +
+			" WHERE" + strings.TrimPrefix(cond, " AND") + ") " + dialect.QuoteIdent(alias)
+	}
+	if len(clauses) > 0 {
+		query += " " + clauses
+	}
+	rows, err := d.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []T
+	for rows.Next() {
+		var v T
+		if err = rows.Scan(r.scanDest(&v)...); err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return list, rows.Close()
+}
+
+// Insert inserts a row. An "auto" primary key is left out and set to the
+// key generated by the database.
+func (r *Repo[T]) Insert(ctx context.Context, d DB, v *T) error {
+	dialect := DialectOf(d)
+	rv := reflect.ValueOf(v).Elem()
+	var (
+		cols []string
+		args []any
+	)
+	for _, f := range r.fields {
+		if f == r.auto {
+			continue
+		}
+		cols = append(cols, dialect.QuoteIdent(f.name))
+		args = append(args, fieldValue(rv, f))
+	}
+	placeholders := make([]string, len(args))
+	for i := range placeholders {
+		placeholders[i] = dialect.Placeholder(i + 1)
+	}
+	query := "INSERT INTO " + dialect.QuoteIdent(r.table) + " (" + strings.Join(cols, ", ") +
+		") VALUES (" + strings.Join(placeholders, ", ") + ")"
+	if r.auto == nil {
+		_, err := d.ExecContext(ctx, query, args...)
+		return err
+	}
+	id, _ := allocFieldByIndex(rv, r.auto.index)
+	if dialect.Type() == MySQLDBType {
+		res, err := d.ExecContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		n, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		return assignValue(id, n)
+	}
+	rows, err := d.QueryContext(ctx, query+" RETURNING "+dialect.QuoteIdent(r.auto.name), args...)
+	if err != nil {
+		return err
+	}
+	return ScanOne(rows, id.Addr().Interface())
+}
+
+// Update sets every column of the row with the same primary key.
+// [ErrNotFound] is returned if no row was updated. Mysql only counts rows
+// that changed unless the connection uses the clientFoundRows parameter.
+//
+// If T has a version field then the row is only updated if its version is
+// the same as v's and the version is incremented in the row and in v.
+// [ErrStaleVersion] is returned if the row's version has changed or it was
+// deleted.
+func (r *Repo[T]) Update(ctx context.Context, d DB, v *T) error {
+	dialect := DialectOf(d)
+	rv := reflect.ValueOf(v).Elem()
+	var (
+		set  []string
+		args []any
+	)
+	for _, f := range r.fields {
+		if slices.Contains(r.pk, f) || f == r.version {
+			continue
+		}
+		args = append(args, fieldValue(rv, f))
+		set = append(set, dialect.QuoteIdent(f.name)+" = "+dialect.Placeholder(len(args)))
+	}
+	if r.version != nil {
+		set = append(set, versionSet(dialect, r.version.name))
+	}
+	if len(set) == 0 {
+		return errors.Errorf("%s has no columns to update", r.table)
+	}
+	key := r.keyOf(rv)
+	where, err := r.keyWhere(dialect, key, len(args))
+	if err != nil {
+		return err
+	}
+	args = append(args, key...)
+	if r.version != nil {
+		args = append(args, fieldValue(rv, r.version))
+		where += " AND " + dialect.QuoteIdent(r.version.name) + " = " + dialect.Placeholder(len(args))
+	}
+	where += r.notDeleted(dialect)
+	res, err := d.ExecContext(ctx, "UPDATE "+dialect.QuoteIdent(r.table)+" SET "+
+		strings.Join(set, ", ")+" WHERE "+where, args...)
+	if err != nil {
+		return err
+	}
+	if err = expectRows(res); err != nil {
+		if r.version != nil && IsNotFound(err) {
+			return ErrStaleVersion
+		}
+		return err
+	}
+	if r.version != nil {
+		fv, _ := allocFieldByIndex(rv, r.version.index)
+		if fv.CanInt() {
+			fv.SetInt(fv.Int() + 1)
+		} else {
+			fv.SetUint(fv.Uint() + 1)
+		}
+	}
+	return nil
+}
+
+// Delete deletes the row with the primary key, or soft deletes it if the
+// table uses soft delete. [ErrNotFound] is returned if there is no such row
+// or it was already soft deleted.
+func (r *Repo[T]) Delete(ctx context.Context, d DB, key ...any) error {
+	if len(r.deletedAt) == 0 {
+		return r.HardDelete(ctx, d, key...)
+	}
+	dialect := DialectOf(d)
+	where, err := r.keyWhere(dialect, key, 1)
+	if err != nil {
+		return err
+	}
+	col := dialect.QuoteIdent(r.deletedAt)
+	res, err := d.ExecContext(ctx, "UPDATE "+dialect.QuoteIdent(r.table)+" SET "+col+" = "+
+		dialect.Placeholder(1)+" WHERE "+where+" AND "+col+" IS NULL", append([]any{now().UTC()}, key...)...)
+	if err != nil {
+		return err
+	}
+	return expectRows(res)
+}
+
+// HardDelete removes the row with the primary key even if the table uses
+// soft delete. [ErrNotFound] is returned if there is no such row.
+func (r *Repo[T]) HardDelete(ctx context.Context, d DB, key ...any) error {
+	dialect := DialectOf(d)
+	where, err := r.keyWhere(dialect, key, 0)
+	if err != nil {
+		return err
+	}
+	res, err := d.ExecContext(ctx, "DELETE FROM "+dialect.QuoteIdent(r.table)+" WHERE "+where, key...)
+	if err != nil {
+		return err
+	}
+	return expectRows(res)
+}
+
+func (r *Repo[T]) selectCols(d Dialect) string {
+	cols := make([]string, len(r.fields))
+	for i, f := range r.fields {
+		cols[i] = d.QuoteIdent(f.name)
+	}
+	return "SELECT " + strings.Join(cols, ", ")
+}
+
+func (r *Repo[T]) selectFrom(d Dialect) string {
+	return r.selectCols(d) + " FROM " + d.QuoteIdent(r.table)
+}
+
+// notDeleted returns the condition that filters out soft deleted rows
+// prefixed with " AND ", or an empty string if they are included.
+func (r *Repo[T]) notDeleted(d Dialect) string {
+	if len(r.deletedAt) == 0 || r.withDeleted {
+		return ""
+	}
+	return " AND " + d.QuoteIdent(r.deletedAt) + " IS NULL"
+}
+
+// keyWhere returns the condition matching the primary key with placeholders
+// numbered from argStart+1.
+func (r *Repo[T]) keyWhere(d Dialect, key []any, argStart int) (string, error) {
+	if len(key) != len(r.pk) {
+		return "", errors.Errorf("%s has %d primary key columns, got %d values", r.table, len(r.pk), len(key))
+	}
+	conds := make([]string, len(r.pk))
+	for i, f := range r.pk {
+		conds[i] = d.QuoteIdent(f.name) + " = " + d.Placeholder(argStart+i+1)
+	}
+	return strings.Join(conds, " AND "), nil
+}
+
+func (r *Repo[T]) keyOf(rv reflect.Value) []any {
+	key := make([]any, len(r.pk))
+	for i, f := range r.pk {
+		key[i] = fieldValue(rv, f)
+	}
+	return key
+}
+
+// scanDest returns pointers to the fields of v in the order of the selected
+// columns.
+func (r *Repo[T]) scanDest(v *T) []any {
+	rv := reflect.ValueOf(v).Elem()
+	dest := make([]any, len(r.fields))
+	for i, f := range r.fields {
+		fv, _ := allocFieldByIndex(rv, f.index)
+		dest[i] = fv.Addr().Interface()
+	}
+	return dest
+}
+
+// fieldValue returns the value of a field or nil if it is in a nil embedded
+// struct.
+func fieldValue(v reflect.Value, f *field) any {
+	fv, ok := fieldByIndex(v, f.index)
+	if !ok {
+		return nil
+	}
+	return fv.Interface()
+}
+
+// expectRows returns [ErrNotFound] if a statement affected no rows.
+func expectRows(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+type repoUser struct {
+	ID    int64  `db:"id,pk,auto"`
+	Name  string `db:"name"`
+	Email string
+}
+
+func newRepoDB(t *testing.T) DB {
+	t.Helper()
+	pool, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.SetMaxOpenConns(1)
+	d := New(pool)
+	t.Cleanup(func() { d.Close() })
+	return d
+}
+
+func TestRepo(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	d := newRepoDB(t)
+	_, err := d.ExecContext(ctx, "CREATE TABLE users (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT, email TEXT)")
+	is.NoErr(err)
+	users := NewRepo[repoUser]("users")
+
+	a := repoUser{Name: "a", Email: "a@example.com"}
+	is.NoErr(users.Insert(ctx, d, &a))
+	is.Equal(a.ID, int64(1))
+	b := repoUser{Name: "b", Email: "b@example.com"}
+	is.NoErr(users.Insert(ctx, d, &b))
+	is.Equal(b.ID, int64(2))
+
+	u, err := users.Get(ctx, d, 1)
+	is.NoErr(err)
+	is.Equal(*u, a)
+	_, err = users.Get(ctx, d, 3)
+	is.True(IsNotFound(err))
+
+	b.Email = "bee@example.com"
+	is.NoErr(users.Update(ctx, d, &b))
+	list, err := users.List(ctx, d, "WHERE email LIKE ? ORDER BY id", "%example.com")
+	is.NoErr(err)
+	is.Equal(list, []repoUser{a, b})
+	is.True(IsNotFound(users.Update(ctx, d, &repoUser{ID: 3})))
+
+	tx, err := d.BeginTx(ctx, nil)
+	is.NoErr(err)
+	is.NoErr(users.Delete(ctx, tx, 1))
+	is.NoErr(tx.Commit())
+	list, err = users.List(ctx, d, "")
+	is.NoErr(err)
+	is.Equal(list, []repoUser{b})
+	is.True(IsNotFound(users.Delete(ctx, d, 1)))
+
+	_, err = users.Get(ctx, d, 1, 2)
+	is.True(err != nil)
+}
+
+func TestRepo_CompositeKey(t *testing.T) {
+	type member struct {
+		Org  string `db:"org,pk"`
+		User string `db:"user,pk"`
+		Role string `db:"role"`
+	}
+	is := is.New(t)
+	ctx := context.Background()
+	d := newRepoDB(t)
+	_, err := d.ExecContext(ctx, "CREATE TABLE members (org TEXT, user TEXT, role TEXT, PRIMARY KEY (org, user))")
+	is.NoErr(err)
+	members := NewRepo[member]("members")
+
+	m := member{Org: "o", User: "u", Role: "admin"}
+	is.NoErr(members.Insert(ctx, d, &m))
+	m.Role = "owner"
+	is.NoErr(members.Update(ctx, d, &m))
+	got, err := members.Get(ctx, d, "o", "u")
+	is.NoErr(err)
+	is.Equal(*got, m)
+}
+
+func TestNewRepo_NoKey(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a panic")
+		}
+	}()
+	NewRepo[struct{ Name string }]("t")
+}
+
+func TestRepo_SoftDelete(t *testing.T) {
+	type post struct {
+		ID        int        `db:"id,pk"`
+		Title     string     `db:"title"`
+		DeletedAt *time.Time `db:"deleted_at"`
+	}
+	is := is.New(t)
+	ctx := context.Background()
+	d := newRepoDB(t)
+	_, err := d.ExecContext(ctx, "CREATE TABLE posts (id int, title text, deleted_at timestamp)")
+	is.NoErr(err)
+	posts := NewRepo[post]("posts")
+	for i, title := range []string{"a", "b", "c"} {
+		is.NoErr(posts.Insert(ctx, d, &post{ID: i + 1, Title: title}))
+	}
+
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	now = func() time.Time { return ts }
+	defer func() { now = time.Now }()
+	is.NoErr(posts.Delete(ctx, d, 2))
+	is.True(IsNotFound(posts.Delete(ctx, d, 2)))
+
+	_, err = posts.Get(ctx, d, 2)
+	is.True(IsNotFound(err))
+	is.True(IsNotFound(posts.Update(ctx, d, &post{ID: 2, Title: "x"})))
+	list, err := posts.List(ctx, d, "WHERE posts.id > ? ORDER BY id", 0)
+	is.NoErr(err)
+	is.Equal(len(list), 2)
+	is.Equal(list[1].Title, "c")
+
+	deleted, err := posts.WithDeleted().Get(ctx, d, 2)
+	is.NoErr(err)
+	is.True(deleted.DeletedAt != nil)
+	is.True(deleted.DeletedAt.Equal(ts))
+	list, err = posts.WithDeleted().List(ctx, d, "ORDER BY id")
+	is.NoErr(err)
+	is.Equal(len(list), 3)
+
+	is.NoErr(posts.HardDelete(ctx, d, 2))
+	_, err = posts.WithDeleted().Get(ctx, d, 2)
+	is.True(IsNotFound(err))
+
+	// Soft delete can be turned off for tables with a deleted_at column.
+	is.NoErr(NewRepo[post]("posts", WithSoftDelete("")).Delete(ctx, d, 1))
+	list, err = posts.WithDeleted().List(ctx, d, "")
+	is.NoErr(err)
+	is.Equal(len(list), 1)
+}
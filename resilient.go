@@ -0,0 +1,189 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// ResilientDB is a database that can be wrapped by [NewResilient].
+type ResilientDB interface {
+	DB
+	Pingable
+}
+
+// ResilientOpt is an option for [NewResilient].
+type ResilientOpt func(*Resilient)
+
+// WithFailureThreshold sets the number of consecutive connection errors after
+// which the database is considered down. It defaults to 3.
+func WithFailureThreshold(n int) ResilientOpt {
+	return func(r *Resilient) {
+		if n > 0 {
+			r.threshold = n
+		}
+	}
+}
+
+// WithReconnectWait sets the options for the [WaitFor] loop that runs while
+// the database is down.
+func WithReconnectWait(opts ...WaitOpt) ResilientOpt {
+	return func(r *Resilient) { r.waitOpts = append(r.waitOpts, opts...) }
+}
+
+// Resilient is a [DB] that stops sending statements to a database that is
+// down. After a run of connection errors, like [driver.ErrBadConn] or
+// refused connections, statements wait until a [WaitFor] loop running in the
+// background can reach the database again, or until their context is done.
+type Resilient struct {
+	db        ResilientDB
+	threshold int
+	waitOpts  []WaitOpt
+	events    chan Event
+	ctx       context.Context
+	cancel    context.CancelFunc
+
+	mu       sync.Mutex
+	failures int
+	// up is closed when the database is reachable again. It is nil while
+	// the database is up.
+	up chan struct{}
+}
+
+var (
+	_ DB       = (*Resilient)(nil)
+	_ Pingable = (*Resilient)(nil)
+)
+
+// NewResilient wraps a database with a [Resilient].
+func NewResilient(d ResilientDB, opts ...ResilientOpt) *Resilient {
+	r := &Resilient{
+		db:        d,
+		threshold: 3,
+		events:    make(chan Event, 16),
+	}
+	for _, o := range opts {
+		o(r)
+	}
+	r.ctx, r.cancel = context.WithCancel(context.Background())
+	return r
+}
+
+// Events returns a channel that receives an [EventDown] when statements are
+// paused and an [EventReconnected] when they resume. Events are dropped if
+// the channel's buffer is full.
+func (r *Resilient) Events() <-chan Event { return r.events }
+
+// State returns [StateDown] while statements are paused and [StateUp]
+// otherwise.
+func (r *Resilient) State() State {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.up != nil {
+		return StateDown
+	}
+	return StateUp
+}
+
+// Dialect returns the wrapped database's [Dialect].
+func (r *Resilient) Dialect() Dialect { return DialectOf(r.db) }
+
+func (r *Resilient) QueryContext(ctx context.Context, query string, args ...any) (Rows, error) {
+	if err := r.wait(ctx); err != nil {
+		return nil, err
+	}
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	r.record(err)
+	return rows, err
+}
+
+func (r *Resilient) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	if err := r.wait(ctx); err != nil {
+		return nil, err
+	}
+	res, err := r.db.ExecContext(ctx, query, args...)
+	r.record(err)
+	return res, err
+}
+
+func (r *Resilient) BeginTx(ctx context.Context, opts *sql.TxOptions) (Tx, error) {
+	if err := r.wait(ctx); err != nil {
+		return nil, err
+	}
+	t, err := r.db.BeginTx(ctx, opts)
+	r.record(err)
+	return t, err
+}
+
+func (r *Resilient) Ping() error { return r.db.Ping() }
+
+func (r *Resilient) PingContext(ctx context.Context) error { return r.db.PingContext(ctx) }
+
+// Close stops the reconnect loop and closes the database.
+func (r *Resilient) Close() error {
+	r.cancel()
+	return r.db.Close()
+}
+
+// wait blocks while the database is down.
+func (r *Resilient) wait(ctx context.Context) error {
+	r.mu.Lock()
+	up := r.up
+	r.mu.Unlock()
+	if up == nil {
+		return nil
+	}
+	select {
+	case <-up:
+		return nil
+	case <-r.ctx.Done():
+		return sql.ErrConnDone
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err(), "waiting for the database to reconnect")
+	}
+}
+
+// record counts connection errors and pauses statements once there have
+// been too many in a row.
+func (r *Resilient) record(err error) {
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !IsConnectionError(err) {
+		r.failures = 0
+		return
+	}
+	r.failures++
+	if r.failures < r.threshold || r.up != nil {
+		return
+	}
+	r.up = make(chan struct{})
+	r.emit(Event{Type: EventDown, Time: now(), Err: err})
+	go r.reconnect()
+}
+
+// reconnect waits for the database to come back and resumes statements.
+func (r *Resilient) reconnect() {
+	for WaitFor(r.ctx, r.db, r.waitOpts...) != nil {
+		if r.ctx.Err() != nil {
+			return
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	close(r.up)
+	r.up = nil
+	r.failures = 0
+	r.emit(Event{Type: EventReconnected, Time: now()})
+}
+
+func (r *Resilient) emit(ev Event) {
+	select {
+	case r.events <- ev:
+	default:
+	}
+}
@@ -0,0 +1,111 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/pkg/errors"
+)
+
+type flakyDB struct {
+	down  atomic.Bool
+	calls atomic.Int32
+}
+
+func (f *flakyDB) err() error {
+	f.calls.Add(1)
+	if f.down.Load() {
+		return driver.ErrBadConn
+	}
+	return nil
+}
+
+func (f *flakyDB) QueryContext(context.Context, string, ...any) (Rows, error) {
+	return NewRowset(nil, nil), f.err()
+}
+
+func (f *flakyDB) ExecContext(context.Context, string, ...any) (sql.Result, error) {
+	return driver.RowsAffected(1), f.err()
+}
+
+func (f *flakyDB) BeginTx(context.Context, *sql.TxOptions) (Tx, error) { return nil, f.err() }
+func (f *flakyDB) Close() error                                        { return nil }
+func (f *flakyDB) Ping() error                                         { return f.PingContext(context.Background()) }
+
+func (f *flakyDB) PingContext(context.Context) error {
+	if f.down.Load() {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func TestResilient(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	f := &flakyDB{}
+	r := NewResilient(f, WithFailureThreshold(2), WithReconnectWait(WithInterval(time.Millisecond)))
+	defer r.Close()
+	_, err := r.ExecContext(ctx, "x")
+	is.NoErr(err)
+
+	f.down.Store(true)
+	_, err = r.QueryContext(ctx, "x")
+	is.True(errors.Is(err, driver.ErrBadConn))
+	is.Equal(r.State(), StateUp)
+	_, err = r.BeginTx(ctx, nil)
+	is.True(errors.Is(err, driver.ErrBadConn))
+	is.Equal(r.State(), StateDown)
+	ev := <-r.Events()
+	is.Equal(ev.Type, EventDown)
+	is.True(errors.Is(ev.Err, driver.ErrBadConn))
+
+	// Statements are held back while the database is down.
+	calls := f.calls.Load()
+	short, cancel := context.WithTimeout(ctx, 5*time.Millisecond)
+	defer cancel()
+	_, err = r.ExecContext(short, "x")
+	is.True(errors.Is(err, context.DeadlineExceeded))
+	is.Equal(f.calls.Load(), calls)
+
+	done := make(chan error)
+	go func() {
+		_, err := r.ExecContext(ctx, "x")
+		done <- err
+	}()
+	f.down.Store(false)
+	is.NoErr(<-done)
+	is.Equal((<-r.Events()).Type, EventReconnected)
+	is.Equal(r.State(), StateUp)
+	is.NoErr(r.Ping())
+	is.NoErr(r.PingContext(ctx))
+
+	// A canceled statement says nothing about the database.
+	r.record(context.Canceled)
+	r.record(driver.ErrBadConn)
+	r.record(errors.New("syntax error"))
+	is.Equal(r.failures, 0)
+}
+
+func TestResilient_Close(t *testing.T) {
+	is := is.New(t)
+	f := &flakyDB{}
+	f.down.Store(true)
+	r := NewResilient(f, WithFailureThreshold(1), WithReconnectWait(WithInterval(time.Millisecond)))
+	_, err := r.ExecContext(context.Background(), "x")
+	is.True(err != nil)
+	is.NoErr(r.Close())
+	_, err = r.ExecContext(context.Background(), "x")
+	is.Equal(err, sql.ErrConnDone)
+}
+
+func TestResilient_Dialect(t *testing.T) {
+	is := is.New(t)
+	d := New(nil, WithDialect(DialectFor(MySQLDBType)))
+	r := NewResilient(d)
+	is.Equal(DialectOf(r).Type(), MySQLDBType)
+}
@@ -0,0 +1,144 @@
+package db
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// RetryPolicy controls how the wrapper returned by [New] retries statements
+// that fail with transient connection errors.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of times a statement is run. Defaults
+	// to 3.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry. Defaults to 50ms.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between retries. Defaults to 2s.
+	MaxBackoff time.Duration
+	// Multiplier grows the backoff after each retry. Defaults to 2.
+	Multiplier float64
+	// RetryExec enables retries for exec statements and for queries that
+	// write or lock rows. Only enable this when all writes are idempotent,
+	// otherwise use [Idempotent] to opt in per call.
+	RetryExec bool
+	// Retryable reports whether an error should be retried. Defaults to
+	// [IsConnectionError].
+	Retryable func(error) bool
+}
+
+// WithRetry retries read only queries and transaction begins that fail with
+// a transient connection error such as [driver.ErrBadConn]. Exec statements,
+// and queries that write or lock rows like "INSERT ... RETURNING" or
+// "SELECT ... FOR UPDATE", are only retried when [RetryPolicy.RetryExec] is
+// set or the context was created with [Idempotent]. Statements run inside a
+// transaction are never retried.
+func WithRetry(policy RetryPolicy) Option {
+	return func(o *dbOptions) {
+		policy.setDefaults()
+		if policy.Retryable == nil {
+			policy.Retryable = IsConnectionError
+		}
+		o.retry = &policy
+	}
+}
+
+func (p *RetryPolicy) setDefaults() {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = 3
+	}
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = 50 * time.Millisecond
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = 2 * time.Second
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = 2
+	}
+}
+
+type retryKey struct{}
+
+type retryMode int
+
+const (
+	retryDisabled retryMode = iota + 1
+	retryIdempotent
+)
+
+// NoRetry returns a context that disables retries for statements run with it.
+func NoRetry(ctx context.Context) context.Context {
+	return context.WithValue(ctx, retryKey{}, retryDisabled)
+}
+
+// Idempotent returns a context that marks exec statements run with it as
+// safe to retry.
+func Idempotent(ctx context.Context) context.Context {
+	return context.WithValue(ctx, retryKey{}, retryIdempotent)
+}
+
+func (p *RetryPolicy) allowed(ctx context.Context, op, query string) bool {
+	mode, _ := ctx.Value(retryKey{}).(retryMode)
+	switch {
+	case mode == retryDisabled:
+		return false
+	case op == opBegin:
+		return true
+	case op == opQuery && classifyStatement(query) == stmtRead && !isLockingRead(query):
+		return true
+	case op == opQuery, op == opExec:
+		return p.RetryExec || mode == retryIdempotent
+	}
+	return false
+}
+
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	d := float64(p.InitialBackoff)
+	for i := 1; i < attempt; i++ {
+		d *= p.Multiplier
+		if d >= float64(p.MaxBackoff) {
+			return p.MaxBackoff
+		}
+	}
+	return time.Duration(d)
+}
+
+// retry calls fn until it succeeds, returns an error that can't be retried,
+// or runs out of attempts.
+func (p *RetryPolicy) retry(ctx context.Context, logger *slog.Logger, op, query string, fn func() error) error {
+	if !p.allowed(ctx, op, query) {
+		return fn()
+	}
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = fn()
+		if err == nil || attempt >= p.MaxAttempts || !p.retryable(ctx, err) {
+			return err
+		}
+		wait := p.backoff(attempt)
+		logger.Debug("retrying statement",
+			slog.String("op", op),
+			slog.Int("attempt", attempt),
+			slog.Duration("backoff", wait),
+			slog.Any("error", err))
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		case <-t.C:
+		}
+	}
+}
+
+func (p *RetryPolicy) retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil ||
+		errors.Is(err, context.Canceled) ||
+		errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	return p.Retryable(err)
+}
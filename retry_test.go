@@ -0,0 +1,103 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/pkg/errors"
+)
+
+func TestWithRetry(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	pool, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer pool.Close()
+	d := New(pool, WithRetry(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Microsecond}))
+
+	failing := func(n int, err error) (queryFunc, *int) {
+		calls := 0
+		return func(context.Context, string, []any) error {
+			calls++
+			if calls <= n {
+				return err
+			}
+			return nil
+		}, &calls
+	}
+
+	fn, calls := failing(2, driver.ErrBadConn)
+	is.NoErr(d.run(ctx, opQuery, "select 1", nil, fn))
+	is.Equal(*calls, 3)
+
+	fn, calls = failing(5, driver.ErrBadConn)
+	err = d.run(ctx, opQuery, "select 1", nil, fn)
+	is.True(errors.Is(err, driver.ErrBadConn))
+	is.Equal(*calls, 3)
+
+	// exec statements are not retried by default
+	fn, calls = failing(1, driver.ErrBadConn)
+	is.True(d.run(ctx, opExec, "insert", nil, fn) != nil)
+	is.Equal(*calls, 1)
+	fn, calls = failing(1, driver.ErrBadConn)
+	is.NoErr(d.run(Idempotent(ctx), opExec, "insert", nil, fn))
+	is.Equal(*calls, 2)
+
+	// queries that write or lock rows are treated like exec statements
+	for _, query := range []string{
+		"INSERT INTO t VALUES (1) RETURNING id",
+		"UPDATE t SET n = n + 1 RETURNING n",
+		"SELECT * FROM t FOR UPDATE",
+	} {
+		fn, calls = failing(1, driver.ErrBadConn)
+		is.True(d.run(ctx, opQuery, query, nil, fn) != nil)
+		is.Equal(*calls, 1)
+		fn, calls = failing(1, driver.ErrBadConn)
+		is.NoErr(d.run(Idempotent(ctx), opQuery, query, nil, fn))
+		is.Equal(*calls, 2)
+	}
+
+	// opt out
+	fn, calls = failing(1, driver.ErrBadConn)
+	is.True(d.run(NoRetry(ctx), opQuery, "select 1", nil, fn) != nil)
+	is.Equal(*calls, 1)
+
+	// non transient errors
+	fn, calls = failing(1, errors.New("syntax error"))
+	is.True(d.run(ctx, opQuery, "select 1", nil, fn) != nil)
+	is.Equal(*calls, 1)
+	fn, calls = failing(1, context.DeadlineExceeded)
+	is.True(d.run(ctx, opQuery, "select 1", nil, fn) != nil)
+	is.Equal(*calls, 1)
+
+	// canceled while waiting
+	d = New(pool, WithRetry(RetryPolicy{InitialBackoff: time.Hour, RetryExec: true}))
+	cctx, cancel := context.WithCancel(ctx)
+	fn, calls = failing(1, driver.ErrBadConn)
+	go cancel()
+	is.True(errors.Is(d.run(cctx, opExec, "insert", nil, fn), driver.ErrBadConn))
+	is.Equal(*calls, 1)
+
+	// queries still work end to end
+	rows, err := d.QueryContext(ctx, "select 1")
+	is.NoErr(err)
+	var one int
+	is.NoErr(ScanOne(rows, &one))
+	is.Equal(one, 1)
+}
+
+func TestRetryPolicy_backoff(t *testing.T) {
+	is := is.New(t)
+	var o dbOptions
+	WithRetry(RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second})(&o)
+	p := o.retry
+	is.Equal(p.MaxAttempts, 3)
+	is.Equal(p.backoff(1), time.Second)
+	is.Equal(p.backoff(2), 2*time.Second)
+	is.Equal(p.backoff(3), 4*time.Second)
+	is.Equal(p.backoff(4), 5*time.Second)
+}
@@ -0,0 +1,125 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+// ErrPermissionDenied is returned when a statement is not allowed by the
+// [Role] of a database wrapper.
+var ErrPermissionDenied = errors.New("statement not permitted for role")
+
+// Role is a client side statement policy. It complements least privilege
+// database users by rejecting statements before they are sent to the server.
+type Role struct {
+	// Name is used in error messages.
+	Name string
+	// Write allows data modifying statements like INSERT and UPDATE.
+	Write bool
+	// DDL allows schema changes like CREATE, ALTER, and DROP.
+	DDL bool
+	// Other allows statements that can't be classified such as SET, LOCK,
+	// or PRAGMA.
+	Other bool
+}
+
+var (
+	// RoleReporting can only read data. Transactions are started read only.
+	RoleReporting = Role{Name: "reporting"}
+	// RoleApp can read and write data but can't change the schema.
+	RoleApp = Role{Name: "app", Write: true, Other: true}
+	// RoleAdmin can run any statement.
+	RoleAdmin = Role{Name: "admin", Write: true, DDL: true, Other: true}
+)
+
+// WithRole sets the statement policy enforced by the wrapper returned by
+// [New].
+func WithRole(r Role) Option { return func(o *dbOptions) { o.role = &r } }
+
+// WithSetRole issues a "SET LOCAL ROLE" for the given database role at the
+// start of every transaction. Statements run outside of a transaction are run
+// in an implicit transaction so that they also use the role. This is
+// supported by postgres and compatible databases.
+func WithSetRole(name string) Option { return func(o *dbOptions) { o.setRole = name } }
+
+// NewWithRole is the same as [New] with the [WithRole] option.
+func NewWithRole(pool *sql.DB, r Role, opts ...Option) *database {
+	return New(pool, append(opts, WithRole(r))...)
+}
+
+// check returns an error if the role does not allow the statement.
+func (r *Role) check(op, query string) error {
+	if r == nil || (op != opQuery && op != opExec) {
+		return nil
+	}
+	class := classifyStatement(query)
+	switch {
+	case class&stmtWrite != 0 && !r.Write:
+		return errors.Wrapf(ErrPermissionDenied, "role %q cannot write", r.Name)
+	case class&stmtDDL != 0 && !r.DDL:
+		return errors.Wrapf(ErrPermissionDenied, "role %q cannot change the schema", r.Name)
+	case class&stmtOther != 0 && !r.Other:
+		return errors.Wrapf(ErrPermissionDenied, "role %q cannot run statement", r.Name)
+	}
+	return nil
+}
+
+// txOptions adjusts transaction options for the role.
+func (r *Role) txOptions(opts *sql.TxOptions) *sql.TxOptions {
+	if r == nil || r.Write || r.DDL || (opts != nil && opts.ReadOnly) {
+		return opts
+	}
+	o := sql.TxOptions{ReadOnly: true}
+	if opts != nil {
+		o.Isolation = opts.Isolation
+	}
+	return &o
+}
+
+func (db *database) queryAsRole(ctx context.Context, query string, args []any) (Rows, error) {
+	t, err := db.begin(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := t.QueryContext(ctx, query, args...)
+	if err != nil {
+		t.Rollback()
+		return nil, err
+	}
+	return &txRows{Rows: rows, tx: t}, nil
+}
+
+func (db *database) execAsRole(ctx context.Context, query string, args []any) (sql.Result, error) {
+	t, err := db.begin(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := t.ExecContext(ctx, query, args...)
+	if err != nil {
+		t.Rollback()
+		return nil, err
+	}
+	return res, t.Commit()
+}
+
+// txRows commits its transaction when closed.
+type txRows struct {
+	*sql.Rows
+	tx     *sql.Tx
+	closed bool
+}
+
+func (r *txRows) Close() error {
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	err := r.Rows.Close()
+	if err != nil {
+		r.tx.Rollback()
+		return err
+	}
+	return r.tx.Commit()
+}
@@ -0,0 +1,111 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/pkg/errors"
+)
+
+func TestClassifyStatement(t *testing.T) {
+	is := is.New(t)
+	for query, want := range map[string]stmtClass{
+		"SELECT * FROM t":                                              stmtRead,
+		"  -- comment\n select 'delete'":                               stmtRead,
+		"WITH x AS (SELECT 1) SELECT * FROM x":                         stmtRead,
+		"WITH x AS (DELETE FROM t RETURNING *) TABLE x":                stmtRead | stmtWrite,
+		"insert into t values (1)":                                     stmtWrite,
+		"/* drop */ UPDATE t SET \"drop\" = 1":                         stmtWrite,
+		"CREATE TABLE t (id int)":                                      stmtDDL,
+		"select 1; drop table t":                                       stmtRead | stmtDDL,
+		"SET statement_timeout = 0":                                    stmtOther,
+		"EXPLAIN SELECT 1":                                             stmtRead,
+		"EXPLAIN (FORMAT JSON) DELETE FROM t":                          stmtRead,
+		"EXPLAIN ANALYZE DELETE FROM t":                                stmtWrite,
+		"explain (analyze, buffers) update t set a = 1":                stmtWrite,
+		"EXPLAIN ANALYZE WITH x AS (DELETE FROM t) SELECT 1; SELECT 2": stmtWrite | stmtRead,
+		"": 0,
+	} {
+		is.Equal(classifyStatement(query), want)
+	}
+}
+
+func TestNewWithRole(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	pool, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer pool.Close()
+	pool.SetMaxOpenConns(1)
+
+	admin := NewWithRole(pool, RoleAdmin)
+	_, err = admin.ExecContext(ctx, "CREATE TABLE t (id int)")
+	is.NoErr(err)
+
+	app := NewWithRole(pool, RoleApp)
+	_, err = app.ExecContext(ctx, "INSERT INTO t VALUES (1)")
+	is.NoErr(err)
+	_, err = app.ExecContext(ctx, "DROP TABLE t")
+	is.True(errors.Is(err, ErrPermissionDenied))
+
+	reporting := NewWithRole(pool, RoleReporting)
+	var n int
+	rows, err := reporting.QueryContext(ctx, "SELECT count(*) FROM t")
+	is.NoErr(err)
+	is.NoErr(ScanOne(rows, &n))
+	is.Equal(n, 1)
+	_, err = reporting.ExecContext(ctx, "DELETE FROM t")
+	is.True(errors.Is(err, ErrPermissionDenied))
+	_, err = reporting.QueryContext(ctx, "EXPLAIN ANALYZE DELETE FROM t")
+	is.True(errors.Is(err, ErrPermissionDenied))
+	_, err = reporting.QueryContext(ctx, "SELECT 1; PRAGMA writable_schema = ON")
+	is.True(errors.Is(err, ErrPermissionDenied))
+
+	tx, err := reporting.BeginTx(ctx, nil)
+	is.NoErr(err)
+	_, err = tx.ExecContext(ctx, "UPDATE t SET id = 2")
+	is.True(errors.Is(err, ErrPermissionDenied))
+	is.NoErr(tx.Rollback())
+
+	is.Equal(RoleReporting.txOptions(nil), &sql.TxOptions{ReadOnly: true})
+	is.Equal(RoleReporting.txOptions(&sql.TxOptions{Isolation: sql.LevelSerializable}),
+		&sql.TxOptions{ReadOnly: true, Isolation: sql.LevelSerializable})
+	is.Equal(RoleApp.txOptions(nil), (*sql.TxOptions)(nil))
+}
+
+func TestWithSetRole(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	pool, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer pool.Close()
+	pool.SetMaxOpenConns(1)
+
+	// sqlite doesn't have roles so switching roles will fail, but the
+	// implicit transaction must not leak the connection.
+	d := New(pool, WithSetRole("reporting"))
+	_, err = d.QueryContext(ctx, "SELECT 1")
+	is.True(err != nil)
+	_, err = d.ExecContext(ctx, "SELECT 1")
+	is.True(err != nil)
+	_, err = d.BeginTx(ctx, nil)
+	is.True(err != nil)
+
+	// Check that the connection was released.
+	rows, err := New(pool).QueryContext(ctx, "SELECT 1")
+	is.NoErr(err)
+	is.NoErr(rows.Close())
+
+	t2, err := pool.BeginTx(ctx, nil)
+	is.NoErr(err)
+	r, err := t2.QueryContext(ctx, "SELECT 1")
+	is.NoErr(err)
+	rows = &txRows{Rows: r, tx: t2}
+	var one int
+	is.NoErr(ScanOne(rows, &one))
+	is.Equal(one, 1)
+	is.NoErr(rows.Close())
+	is.True(errors.Is(t2.Rollback(), sql.ErrTxDone)) // committed on close
+}
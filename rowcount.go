@@ -0,0 +1,79 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// ErrUnexpectedRowCount is matched by any [RowCountError] when using
+// [errors.Is].
+var ErrUnexpectedRowCount = errors.New("unexpected number of rows affected")
+
+// RowCountError is returned by [ExecExpectRows] and [ExecAtLeastOne] when a
+// statement affected the wrong number of rows. If no rows were affected it
+// also matches [ErrNotFound].
+type RowCountError struct {
+	// Expected is the number of rows the statement should have affected.
+	Expected int64
+	// Affected is the number of rows the statement did affect.
+	Affected int64
+	// AtLeast is true when Expected is a lower bound.
+	AtLeast bool
+}
+
+func (e *RowCountError) Error() string {
+	if e.AtLeast {
+		return fmt.Sprintf("%v: expected at least %d, got %d", ErrUnexpectedRowCount, e.Expected, e.Affected)
+	}
+	return fmt.Sprintf("%v: expected %d, got %d", ErrUnexpectedRowCount, e.Expected, e.Affected)
+}
+
+func (e *RowCountError) Is(target error) bool { return target == ErrUnexpectedRowCount }
+
+// Unwrap lets zero row errors match [ErrNotFound] and [sql.ErrNoRows].
+func (e *RowCountError) Unwrap() error {
+	if e.Affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ExecExpectRows executes a statement and returns a [RowCountError] if it did
+// not affect exactly n rows.
+func ExecExpectRows(ctx context.Context, db DB, n int64, query string, args ...any) error {
+	affected, err := execAffected(ctx, db, query, args)
+	if err != nil {
+		return err
+	}
+	if affected != n {
+		return &RowCountError{Expected: n, Affected: affected}
+	}
+	return nil
+}
+
+// ExecAtLeastOne executes a statement and returns a [RowCountError] if it did
+// not affect any rows.
+func ExecAtLeastOne(ctx context.Context, db DB, query string, args ...any) error {
+	affected, err := execAffected(ctx, db, query, args)
+	if err != nil {
+		return err
+	}
+	if affected < 1 {
+		return &RowCountError{Expected: 1, Affected: affected, AtLeast: true}
+	}
+	return nil
+}
+
+func execAffected(ctx context.Context, db DB, query string, args []any) (int64, error) {
+	res, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to get rows affected")
+	}
+	return n, nil
+}
@@ -0,0 +1,44 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/pkg/errors"
+)
+
+func TestExecExpectRows(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	pool, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer pool.Close()
+	pool.SetMaxOpenConns(1)
+	d := New(pool)
+	_, err = d.ExecContext(ctx, "CREATE TABLE t (id INT, name TEXT); INSERT INTO t VALUES (1, 'a'), (2, 'b'), (3, 'b')")
+	is.NoErr(err)
+
+	is.NoErr(ExecExpectRows(ctx, d, 1, "UPDATE t SET name = ? WHERE id = ?", "x", 1))
+	err = ExecExpectRows(ctx, d, 1, "UPDATE t SET name = ? WHERE name = ?", "y", "b")
+	is.True(errors.Is(err, ErrUnexpectedRowCount))
+	is.True(!IsNotFound(err))
+	var rce *RowCountError
+	is.True(errors.As(err, &rce))
+	is.Equal(*rce, RowCountError{Expected: 1, Affected: 2})
+	is.Equal(err.Error(), "unexpected number of rows affected: expected 1, got 2")
+
+	err = ExecExpectRows(ctx, d, 1, "DELETE FROM t WHERE id = ?", 99)
+	is.True(errors.Is(err, ErrUnexpectedRowCount))
+	is.True(errors.Is(err, ErrNotFound))
+	is.True(IsNotFound(err))
+
+	is.NoErr(ExecAtLeastOne(ctx, d, "DELETE FROM t WHERE name = ?", "y"))
+	err = ExecAtLeastOne(ctx, d, "DELETE FROM t WHERE name = ?", "y")
+	is.True(IsNotFound(err))
+	is.Equal(err.Error(), "unexpected number of rows affected: expected at least 1, got 0")
+
+	is.True(ExecAtLeastOne(ctx, d, "DELETE FROM missing") != nil)
+	is.True(ExecExpectRows(ctx, d, 0, "DELETE FROM missing") != nil)
+}
@@ -0,0 +1,239 @@
+package db
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Rowset is a result set held in memory. It can be read after the
+// connection that ran the query has gone back to the pool, read again after
+// [Rowset.Rewind] and shared between goroutines with [Rowset.Clone].
+type Rowset struct {
+	cols []string
+	// vals is never modified so it can be shared by clones.
+	vals [][]any
+
+	mu     sync.Mutex
+	pos    int
+	closed bool
+}
+
+var _ Rows = (*Rowset)(nil)
+
+// Materialize reads every row into a [Rowset] and closes the rows. The rows
+// must have a Columns method like [sql.Rows].
+func Materialize(r Rows) (*Rowset, error) {
+	defer r.Close()
+	c, ok := r.(columner)
+	if !ok {
+		return nil, errors.New("rows do not have column names")
+	}
+	cols, err := c.Columns()
+	if err != nil {
+		return nil, err
+	}
+	rs := &Rowset{cols: cols}
+	for r.Next() {
+		row := make([]any, len(cols))
+		dest := make([]any, len(cols))
+		for i := range row {
+			dest[i] = &row[i]
+		}
+		if err = r.Scan(dest...); err != nil {
+			return nil, err
+		}
+		rs.vals = append(rs.vals, row)
+	}
+	if err = r.Err(); err != nil {
+		return nil, err
+	}
+	return rs, r.Close()
+}
+
+// NewRowset creates a [Rowset] from column names and rows of driver values.
+func NewRowset(cols []string, rows [][]any) *Rowset {
+	return &Rowset{cols: cols, vals: rows}
+}
+
+// Len returns the number of rows.
+func (rs *Rowset) Len() int { return len(rs.vals) }
+
+// Columns returns the column names.
+func (rs *Rowset) Columns() ([]string, error) { return rs.cols, nil }
+
+// Err always returns nil, errors are returned by [Materialize].
+func (rs *Rowset) Err() error { return nil }
+
+// Close stops iteration until [Rowset.Rewind] is called.
+func (rs *Rowset) Close() error {
+	rs.mu.Lock()
+	rs.closed = true
+	rs.mu.Unlock()
+	return nil
+}
+
+// Rewind moves back to before the first row.
+func (rs *Rowset) Rewind() {
+	rs.mu.Lock()
+	rs.pos, rs.closed = 0, false
+	rs.mu.Unlock()
+}
+
+// Clone returns a [Rowset] with the same rows that starts before the first
+// row. The rows are shared and not copied.
+func (rs *Rowset) Clone() *Rowset { return &Rowset{cols: rs.cols, vals: rs.vals} }
+
+func (rs *Rowset) Next() bool {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if rs.closed || rs.pos >= len(rs.vals) {
+		return false
+	}
+	rs.pos++
+	return true
+}
+
+func (rs *Rowset) Scan(dest ...any) error {
+	rs.mu.Lock()
+	pos, closed := rs.pos, rs.closed
+	rs.mu.Unlock()
+	if closed {
+		return errors.New("rows are closed")
+	}
+	if pos == 0 {
+		return errors.New("Scan called without calling Next")
+	}
+	row := rs.vals[pos-1]
+	if len(dest) != len(row) {
+		return errors.Errorf("expected %d destination arguments in Scan, not %d", len(row), len(dest))
+	}
+	for i, d := range dest {
+		if err := assign(d, row[i]); err != nil {
+			return errors.Wrapf(err, "converting column %q", rs.cols[i])
+		}
+	}
+	return nil
+}
+
+// assign copies a driver value into a scan destination with roughly the
+// same conversions as [sql.Rows.Scan].
+func assign(dest, src any) error {
+	switch d := dest.(type) {
+	case *any:
+		if b, ok := src.([]byte); ok {
+			src = append([]byte(nil), b...)
+		}
+		*d = src
+		return nil
+	case sql.Scanner:
+		return d.Scan(src)
+	}
+	dv := reflect.ValueOf(dest)
+	if dv.Kind() != reflect.Pointer || dv.IsNil() {
+		return errors.Errorf("destination not a pointer: %T", dest)
+	}
+	return assignValue(dv.Elem(), src)
+}
+
+func assignValue(dv reflect.Value, src any) error {
+	if src == nil {
+		switch dv.Kind() {
+		case reflect.Pointer, reflect.Interface, reflect.Slice, reflect.Map:
+			dv.Set(reflect.Zero(dv.Type()))
+			return nil
+		}
+		return errors.Errorf("converting NULL to %s is unsupported", dv.Type())
+	}
+	if dv.Kind() == reflect.Pointer {
+		v := reflect.New(dv.Type().Elem())
+		if err := assignValue(v.Elem(), src); err != nil {
+			return err
+		}
+		dv.Set(v)
+		return nil
+	}
+	if s, ok := dv.Addr().Interface().(sql.Scanner); ok {
+		return s.Scan(src)
+	}
+	sv := reflect.ValueOf(src)
+	if b, ok := src.([]byte); ok && dv.Kind() == reflect.Slice && dv.Type().Elem().Kind() == reflect.Uint8 {
+		dv.SetBytes(append([]byte(nil), b...))
+		return nil
+	}
+	if sv.Type().AssignableTo(dv.Type()) {
+		dv.Set(sv)
+		return nil
+	}
+	text, isText := asText(src)
+	switch dv.Kind() {
+	case reflect.String:
+		dv.SetString(text)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(text, 10, dv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		dv.SetInt(n)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(text, 10, dv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		dv.SetUint(n)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(text, dv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		dv.SetFloat(n)
+		return nil
+	case reflect.Bool:
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return err
+		}
+		dv.SetBool(b)
+		return nil
+	}
+	if isText && dv.Type() == reflect.TypeOf(time.Time{}) {
+		t, err := time.Parse(time.RFC3339Nano, text)
+		if err != nil {
+			return err
+		}
+		dv.Set(reflect.ValueOf(t))
+		return nil
+	}
+	if sv.Type().ConvertibleTo(dv.Type()) {
+		dv.Set(sv.Convert(dv.Type()))
+		return nil
+	}
+	return errors.Errorf("unsupported scan, storing driver.Value type %T into type %s", src, dv.Type())
+}
+
+// asText formats a driver value as text. The bool is true if the value was
+// already text.
+func asText(src any) (string, bool) {
+	switch v := src.(type) {
+	case string:
+		return v, true
+	case []byte:
+		return string(v), true
+	case time.Time:
+		return v.Format(time.RFC3339Nano), false
+	case driver.Valuer:
+		if val, err := v.Value(); err == nil {
+			return asText(val)
+		}
+	}
+	return fmt.Sprint(src), false
+}
@@ -0,0 +1,129 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestAssign(t *testing.T) {
+	is := is.New(t)
+	var (
+		s   string
+		i   int32
+		u   uint8
+		f   float64
+		b   bool
+		raw []byte
+		ptr *string
+		ns  sql.NullString
+		tm  time.Time
+		v   any
+	)
+	is.NoErr(assign(&s, int64(4)))
+	is.Equal(s, "4")
+	is.NoErr(assign(&i, []byte("12")))
+	is.Equal(i, int32(12))
+	is.NoErr(assign(&u, "7"))
+	is.Equal(u, uint8(7))
+	is.NoErr(assign(&f, []byte("1.5")))
+	is.Equal(f, 1.5)
+	is.NoErr(assign(&b, int64(1)))
+	is.True(b)
+	is.NoErr(assign(&raw, []byte("x")))
+	is.Equal(raw, []byte("x"))
+	is.NoErr(assign(&ptr, "p"))
+	is.Equal(*ptr, "p")
+	is.NoErr(assign(&ptr, nil))
+	is.True(ptr == nil)
+	is.NoErr(assign(&ns, "n"))
+	is.Equal(ns.String, "n")
+	is.NoErr(assign(&tm, "2024-01-02T03:04:05Z"))
+	is.Equal(tm, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+	is.NoErr(assign(&v, []byte("y")))
+	is.Equal(v, []byte("y"))
+	is.NoErr(assign(&f, int64(2)))
+	is.Equal(f, 2.0)
+
+	is.True(assign(s, "x") != nil)
+	is.True(assign(&s, nil) != nil)
+	is.True(assign(&i, "x") != nil)
+	is.True(assign(&u, "-1") != nil)
+	is.True(assign(&f, "x") != nil)
+	is.True(assign(&b, "x") != nil)
+	is.True(assign(&tm, "x") != nil)
+	is.True(assign(&tm, int64(1)) != nil)
+
+	rs := NewRowset([]string{"a"}, [][]any{{"x"}})
+	is.True(rs.Scan(&s) != nil)
+	is.True(rs.Next())
+	is.True(rs.Scan(&s, &s) != nil)
+	is.True(rs.Scan(&i) != nil)
+	is.NoErr(rs.Scan(&s))
+	is.True(!rs.Next())
+}
+
+func TestMaterialize(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	pool, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer pool.Close()
+	rows, err := pool.QueryContext(ctx, "SELECT 1 AS a, 'x' AS b UNION ALL SELECT 2, NULL")
+	is.NoErr(err)
+	rs, err := Materialize(rows)
+	is.NoErr(err)
+	is.Equal(rs.Len(), 2)
+	cols, err := rs.Columns()
+	is.NoErr(err)
+	is.Equal(cols, []string{"a", "b"})
+	// The connection was released.
+	is.Equal(pool.Stats().InUse, 0)
+
+	read := func(rs *Rowset) []int {
+		var ids []int
+		for rs.Next() {
+			var (
+				id int
+				b  *string
+			)
+			is.NoErr(rs.Scan(&id, &b))
+			ids = append(ids, id)
+		}
+		is.NoErr(rs.Err())
+		return ids
+	}
+	is.Equal(read(rs), []int{1, 2})
+	is.Equal(read(rs), []int(nil))
+	rs.Rewind()
+	is.True(rs.Next())
+	is.NoErr(rs.Close())
+	is.True(!rs.Next())
+	var id int
+	is.True(rs.Scan(&id) != nil)
+	rs.Rewind()
+	is.Equal(read(rs), []int{1, 2})
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			is.Equal(read(rs.Clone()), []int{1, 2})
+		}()
+	}
+	wg.Wait()
+
+	_, err = Materialize(NewRowset(nil, nil))
+	is.NoErr(err)
+	rows, err = pool.QueryContext(ctx, "SELECT 1")
+	is.NoErr(err)
+	_, err = Materialize(&utcRows{rows})
+	is.NoErr(err)
+	_, err = Materialize(struct{ Rows }{rs})
+	is.True(err != nil)
+}
@@ -0,0 +1,159 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Diff is the difference between the schemas of two databases found by
+// [DiffSchemas]. Missing items are in the first database but not the second
+// and extra items are in the second but not the first. Columns are named
+// "table.column" and indexes "table(col1,col2)".
+type Diff struct {
+	MissingTables  []string
+	ExtraTables    []string
+	MissingColumns []string
+	ExtraColumns   []string
+	MissingIndexes []string
+	ExtraIndexes   []string
+	// Mismatches are columns in both databases with different types or
+	// nullability.
+	Mismatches []ColumnMismatch
+}
+
+// ColumnMismatch is a column that is different in two databases.
+type ColumnMismatch struct {
+	Table string
+	A, B  ColumnInfo
+}
+
+func (m ColumnMismatch) String() string {
+	return fmt.Sprintf("%s.%s: %s != %s", m.Table, m.A.Name, describeColumn(m.A), describeColumn(m.B))
+}
+
+func describeColumn(c ColumnInfo) string {
+	if c.Nullable {
+		return c.Type + " null"
+	}
+	return c.Type + " not null"
+}
+
+// Empty is true when the schemas are the same.
+func (d *Diff) Empty() bool {
+	return len(d.MissingTables)+len(d.ExtraTables)+
+		len(d.MissingColumns)+len(d.ExtraColumns)+
+		len(d.MissingIndexes)+len(d.ExtraIndexes)+
+		len(d.Mismatches) == 0
+}
+
+// String lists the differences one per line.
+func (d *Diff) String() string {
+	var b strings.Builder
+	list := func(prefix string, items []string) {
+		for _, it := range items {
+			fmt.Fprintf(&b, "%s %s\n", prefix, it)
+		}
+	}
+	list("missing table", d.MissingTables)
+	list("extra table", d.ExtraTables)
+	list("missing column", d.MissingColumns)
+	list("extra column", d.ExtraColumns)
+	list("missing index", d.MissingIndexes)
+	list("extra index", d.ExtraIndexes)
+	for _, m := range d.Mismatches {
+		fmt.Fprintf(&b, "mismatched column %s\n", m)
+	}
+	return b.String()
+}
+
+// DiffSchemas compares the tables, columns and indexes of two databases, i.e.
+// a database built from the migrations and a staging database. Indexes are
+// compared by their columns and uniqueness, not their names. Column types
+// are compared case insensitively as reported by each database so both
+// databases should be of the same [Type].
+func DiffSchemas(ctx context.Context, a, b DB) (Diff, error) {
+	at, err := Tables(ctx, a)
+	if err != nil {
+		return Diff{}, errors.Wrap(err, "failed to read first schema")
+	}
+	bt, err := Tables(ctx, b)
+	if err != nil {
+		return Diff{}, errors.Wrap(err, "failed to read second schema")
+	}
+	return diffTables(at, bt), nil
+}
+
+func diffTables(a, b []TableInfo) Diff {
+	var d Diff
+	bTables := make(map[string]*TableInfo, len(b))
+	for i := range b {
+		bTables[b[i].Name] = &b[i]
+	}
+	seen := make(map[string]bool, len(a))
+	for i := range a {
+		at := &a[i]
+		seen[at.Name] = true
+		bt, ok := bTables[at.Name]
+		if !ok {
+			d.MissingTables = append(d.MissingTables, at.Name)
+			continue
+		}
+		for _, ac := range at.Columns {
+			bc, ok := bt.Column(ac.Name)
+			switch {
+			case !ok:
+				d.MissingColumns = append(d.MissingColumns, at.Name+"."+ac.Name)
+			case !strings.EqualFold(ac.Type, bc.Type) || ac.Nullable != bc.Nullable:
+				d.Mismatches = append(d.Mismatches, ColumnMismatch{Table: at.Name, A: ac, B: bc})
+			}
+		}
+		for _, bc := range bt.Columns {
+			if _, ok := at.Column(bc.Name); !ok {
+				d.ExtraColumns = append(d.ExtraColumns, at.Name+"."+bc.Name)
+			}
+		}
+		missing, extra := diffIndexes(at, bt)
+		d.MissingIndexes = append(d.MissingIndexes, missing...)
+		d.ExtraIndexes = append(d.ExtraIndexes, extra...)
+	}
+	for _, bt := range b {
+		if !seen[bt.Name] {
+			d.ExtraTables = append(d.ExtraTables, bt.Name)
+		}
+	}
+	return d
+}
+
+// diffIndexes compares the indexes of a table by their columns and
+// uniqueness.
+func diffIndexes(a, b *TableInfo) (missing, extra []string) {
+	key := func(ix IndexInfo) string {
+		k := a.Name + "(" + strings.Join(ix.Columns, ",") + ")"
+		if ix.Unique {
+			k = "unique " + k
+		}
+		return k
+	}
+	count := make(map[string]int)
+	for _, ix := range a.Indexes {
+		count[key(ix)]++
+	}
+	for _, ix := range b.Indexes {
+		count[key(ix)]--
+	}
+	for k, n := range count {
+		for ; n > 0; n-- {
+			missing = append(missing, k)
+		}
+		for ; n < 0; n++ {
+			extra = append(extra, k)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(extra)
+	return missing, extra
+}
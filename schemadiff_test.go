@@ -0,0 +1,71 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestDiffSchemas(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	open := func(ddl string) DB {
+		pool, err := sql.Open("sqlite3", ":memory:")
+		is.NoErr(err)
+		pool.SetMaxOpenConns(1)
+		t.Cleanup(func() { pool.Close() })
+		d := New(pool, WithDialect(DialectFor(SQLiteDBType)))
+		is.NoErr(ExecScript(ctx, d, ddl))
+		return d
+	}
+	a := open(`
+CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT NOT NULL UNIQUE, name TEXT);
+CREATE INDEX users_name ON users (name, email);
+CREATE TABLE posts (id INTEGER PRIMARY KEY, body TEXT);
+CREATE VIEW names AS SELECT name FROM users;`)
+	b := open(`
+CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT, age INT);
+CREATE INDEX users_age ON users (age);
+CREATE TABLE tags (id INTEGER PRIMARY KEY);`)
+
+	tables, err := Tables(ctx, a)
+	is.NoErr(err)
+	is.Equal(len(tables), 2)
+	is.Equal(tables[1].Name, "users")
+	is.Equal(tables[1].Columns[1], ColumnInfo{Name: "email", Type: "TEXT", Nullable: false})
+	is.Equal(len(tables[1].Indexes), 2)
+
+	d, err := DiffSchemas(ctx, a, b)
+	is.NoErr(err)
+	is.True(!d.Empty())
+	is.Equal(d.MissingTables, []string{"posts"})
+	is.Equal(d.ExtraTables, []string{"tags"})
+	is.Equal(d.MissingColumns, []string{"users.name"})
+	is.Equal(d.ExtraColumns, []string{"users.age"})
+	is.Equal(d.MissingIndexes, []string{"unique users(email)", "users(name,email)"})
+	is.Equal(d.ExtraIndexes, []string{"users(age)"})
+	is.Equal(len(d.Mismatches), 1)
+	is.Equal(d.String(), `missing table posts
+extra table tags
+missing column users.name
+extra column users.age
+missing index unique users(email)
+missing index users(name,email)
+extra index users(age)
+mismatched column users.email: TEXT not null != TEXT null
+`)
+
+	d, err = DiffSchemas(ctx, a, a)
+	is.NoErr(err)
+	is.True(d.Empty())
+
+	pool, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer pool.Close()
+	_, err = DiffSchemas(ctx, New(pool, WithDialect(DialectFor(ClickHouseDBType))), b)
+	is.True(err != nil)
+	_, err = DiffSchemas(ctx, a, New(pool))
+	is.True(err != nil)
+}
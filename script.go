@@ -0,0 +1,69 @@
+package db
+
+import (
+	"context"
+	"io/fs"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ExecScript splits a script into statements and executes them one at a time.
+// Statements are separated by semicolons outside of string literals, quoted
+// identifiers, comments and postgres dollar quoted strings. Use this for
+// scripts that the driver will not execute as a single multi-statement query.
+func ExecScript(ctx context.Context, db DB, script string) error {
+	for i, stmt := range splitStatements(script) {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return errors.Wrapf(err, "script statement %d failed", i+1)
+		}
+	}
+	return nil
+}
+
+// ExecScriptFS reads a script from a file and executes it with [ExecScript].
+func ExecScriptFS(ctx context.Context, db DB, fsys fs.FS, path string) error {
+	b, err := fs.ReadFile(fsys, path)
+	if err != nil {
+		return errors.Wrap(err, "failed to read script")
+	}
+	if err = ExecScript(ctx, db, string(b)); err != nil {
+		return errors.Wrap(err, path)
+	}
+	return nil
+}
+
+// splitStatements splits a script on semicolons. Statements that only contain
+// whitespace and comments are dropped.
+func splitStatements(script string) []string {
+	var (
+		stmts []string
+		start int
+		code  bool // true when the current statement has more than comments
+	)
+	add := func(end int) {
+		if code {
+			stmts = append(stmts, strings.TrimSpace(script[start:end]))
+		}
+		start, code = end+1, false
+	}
+	for i := 0; i < len(script); {
+		if j, ok := skipQuoted(script, i); ok {
+			// Comments alone do not make a statement.
+			if c := script[i]; c != '-' && c != '/' {
+				code = true
+			}
+			i = j
+			continue
+		}
+		switch c := script[i]; {
+		case c == ';':
+			add(i)
+		case c != ' ' && c != '\t' && c != '\n' && c != '\r':
+			code = true
+		}
+		i++
+	}
+	add(len(script))
+	return stmts
+}
@@ -0,0 +1,67 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/matryer/is"
+)
+
+func TestSplitStatements(t *testing.T) {
+	is := is.New(t)
+	for _, tt := range []struct {
+		script string
+		want   []string
+	}{
+		{"", nil},
+		{"-- only a comment\n/* and another; */\n;;", nil},
+		{"select 1; select 2", []string{"select 1", "select 2"}},
+		{"select 'a;b'; select \"x;\"", []string{"select 'a;b'", "select \"x;\""}},
+		{"select 1 -- one; two\n;\nselect 2;\n", []string{"select 1 -- one; two", "select 2"}},
+		{
+			"CREATE FUNCTION f() RETURNS int AS $$ BEGIN RETURN 1; END; $$ LANGUAGE plpgsql;\nselect f();",
+			[]string{"CREATE FUNCTION f() RETURNS int AS $$ BEGIN RETURN 1; END; $$ LANGUAGE plpgsql", "select f()"},
+		},
+		{"select $body$;$body$ ;", []string{"select $body$;$body$"}},
+		{"/* header */\ncreate table t (id int);", []string{"/* header */\ncreate table t (id int)"}},
+	} {
+		is.Equal(splitStatements(tt.script), tt.want)
+	}
+}
+
+func TestExecScript(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	pool, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer pool.Close()
+	pool.SetMaxOpenConns(1)
+	d := New(pool)
+	fsys := fstest.MapFS{
+		"schema.sql": {Data: []byte(`-- bootstrap
+CREATE TABLE t (id INT, name TEXT);
+INSERT INTO t VALUES (1, 'a;b');
+INSERT INTO t VALUES (2, 'it''s');
+`)},
+		"bad.sql": {Data: []byte("INSERT INTO t VALUES (3, 'c');\nINSERT INTO missing VALUES (1);")},
+	}
+	is.NoErr(ExecScriptFS(ctx, d, fsys, "schema.sql"))
+	var names []string
+	rows, err := d.QueryContext(ctx, "SELECT name FROM t ORDER BY id")
+	is.NoErr(err)
+	for rows.Next() {
+		var s string
+		is.NoErr(rows.Scan(&s))
+		names = append(names, s)
+	}
+	is.NoErr(rows.Close())
+	is.Equal(names, []string{"a;b", "it's"})
+
+	err = ExecScriptFS(ctx, d, fsys, "bad.sql")
+	is.True(err != nil)
+	is.True(strings.HasPrefix(err.Error(), "bad.sql: script statement 2 failed"))
+	is.True(ExecScriptFS(ctx, d, fsys, "missing.sql") != nil)
+}
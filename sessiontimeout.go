@@ -0,0 +1,73 @@
+package db
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// pgTimeoutOptions returns the value of the postgres "options" startup
+// parameter that sets the config's session timeouts, i.e.
+// "-c statement_timeout=30000 -c lock_timeout=5000".
+func (db *Config) pgTimeoutOptions() string {
+	var opts []string
+	for _, s := range db.pgTimeouts() {
+		opts = append(opts, "-c "+s.Name+"="+s.Value)
+	}
+	return strings.Join(opts, " ")
+}
+
+// pgTimeouts returns the postgres settings for the config's session
+// timeouts in milliseconds.
+func (db *Config) pgTimeouts() []SessionSetting {
+	var settings []SessionSetting
+	for _, s := range []struct {
+		name string
+		d    time.Duration
+	}{
+		{"statement_timeout", db.StatementTimeout},
+		{"lock_timeout", db.LockTimeout},
+		{"idle_in_transaction_session_timeout", db.IdleInTxTimeout},
+	} {
+		if s.d > 0 {
+			settings = append(settings, SessionSetting{s.name, strconv.FormatInt(millis(s.d), 10)})
+		}
+	}
+	return settings
+}
+
+// mysqlTimeoutParams adds the system variables for the config's session
+// timeouts to a mysql DSN query. The driver sets them on each new connection.
+// Mysql has no idle in transaction timeout.
+func (db *Config) mysqlTimeoutParams(q url.Values) {
+	if db.StatementTimeout > 0 {
+		// Only applies to SELECT statements.
+		q.Set("max_execution_time", strconv.FormatInt(millis(db.StatementTimeout), 10))
+	}
+	if db.LockTimeout > 0 {
+		secs := (db.LockTimeout + time.Second - 1) / time.Second
+		q.Set("innodb_lock_wait_timeout", strconv.FormatInt(int64(secs), 10))
+	}
+}
+
+// millis converts a positive duration to milliseconds, rounding up so that a
+// timeout is never turned into zero which disables it.
+func millis(d time.Duration) int64 {
+	return int64((d + time.Millisecond - 1) / time.Millisecond)
+}
+
+// parseTimeout parses a duration like "30s" or "1m30s". A plain number is in
+// milliseconds like postgres' timeout settings.
+func parseTimeout(s string) (time.Duration, error) {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Duration(n) * time.Millisecond, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, errors.Errorf("invalid timeout %q", s)
+	}
+	return d, nil
+}
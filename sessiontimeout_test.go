@@ -0,0 +1,73 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/matryer/is"
+)
+
+func TestConfig_SessionTimeouts(t *testing.T) {
+	is := is.New(t)
+	c := Config{
+		Type:             PostgresDBType,
+		Host:             "h",
+		Port:             "5432",
+		StatementTimeout: 30 * time.Second,
+		LockTimeout:      1500 * time.Microsecond,
+		IdleInTxTimeout:  time.Minute,
+	}
+	is.Equal(c.URI().Query().Get("options"),
+		"-c statement_timeout=30000 -c lock_timeout=2 -c idle_in_transaction_session_timeout=60000")
+	c.Params = map[string]string{"options": "-c jit=off"}
+	is.Equal(c.URI().Query().Get("options"),
+		"-c statement_timeout=30000 -c lock_timeout=2 -c idle_in_transaction_session_timeout=60000 -c jit=off")
+	c.Pooler = PgBouncer
+	is.Equal(c.URI().Query().Get("options"), "")
+	is.Equal(c.PoolerSettings(), []SessionSetting{
+		{"statement_timeout", "30000"},
+		{"lock_timeout", "2"},
+		{"idle_in_transaction_session_timeout", "60000"},
+		{"jit", "off"},
+	})
+
+	c = Config{Type: MySQLDBType, Host: "h", Port: "3306", StatementTimeout: 10 * time.Second, LockTimeout: 1500 * time.Millisecond, IdleInTxTimeout: time.Minute}
+	mc, err := mysql.ParseDSN(c.DSN())
+	is.NoErr(err)
+	is.Equal(mc.Params, map[string]string{"max_execution_time": "10000", "innodb_lock_wait_timeout": "2"})
+
+	c = Config{Type: SQLiteDBType, DBName: ":memory:", StatementTimeout: time.Second}
+	is.Equal(c.DSN(), ":memory:")
+}
+
+func TestConfig_SessionTimeoutsEnv(t *testing.T) {
+	is := is.New(t)
+	clearEnv()
+	t.Setenv("POSTGRES_STATEMENT_TIMEOUT", "30s")
+	t.Setenv("POSTGRES_LOCK_TIMEOUT", "500")
+	var c Config
+	is.NoErr(c.InitStrict())
+	is.Equal(c.StatementTimeout, 30*time.Second)
+	is.Equal(c.LockTimeout, 500*time.Millisecond)
+
+	t.Setenv("POSTGRES_STATEMENT_TIMEOUT", "1m")
+	c.EnvOverride()
+	is.Equal(c.StatementTimeout, time.Minute)
+
+	t.Setenv("POSTGRES_IDLE_IN_TX_TIMEOUT", "5 minutes")
+	c = Config{}
+	err := c.InitStrict()
+	is.True(err != nil && strings.Contains(err.Error(), "POSTGRES_IDLE_IN_TX_TIMEOUT"))
+
+	clearEnv()
+	path := filepath.Join(t.TempDir(), "db.json")
+	is.NoErr(os.WriteFile(path, []byte(`{"statement_timeout": "45s", "lock_timeout": 250}`), 0o600))
+	cfg, err := LoadConfig(path)
+	is.NoErr(err)
+	is.Equal(cfg.StatementTimeout, 45*time.Second)
+	is.Equal(cfg.LockTimeout, 250*time.Millisecond)
+}
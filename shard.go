@@ -0,0 +1,208 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	stderrors "errors"
+	"hash/fnv"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// ErrUnknownShard is returned when a key is routed to a shard that doesn't
+// exist.
+var ErrUnknownShard = errors.New("unknown shard")
+
+// ShardRouter maps a shard key such as a customer id to the name of a shard.
+type ShardRouter interface {
+	Route(key string) (string, error)
+}
+
+// HashRing is a [ShardRouter] that uses consistent hashing so that adding or
+// removing a shard only moves a small fraction of the keys.
+type HashRing struct {
+	points []uint32
+	owners map[uint32]string
+}
+
+// NewHashRing creates a consistent hash ring over the shard names. Each shard
+// is placed on the ring vnodes times, defaulting to 128.
+func NewHashRing(shards []string, vnodes int) *HashRing {
+	if vnodes <= 0 {
+		vnodes = 128
+	}
+	r := &HashRing{owners: make(map[uint32]string, len(shards)*vnodes)}
+	for _, s := range shards {
+		for i := 0; i < vnodes; i++ {
+			p := hashKey(s + "#" + strconv.Itoa(i))
+			if _, ok := r.owners[p]; ok {
+				continue
+			}
+			r.owners[p] = s
+			r.points = append(r.points, p)
+		}
+	}
+	slices.Sort(r.points)
+	return r
+}
+
+// Route returns the shard that owns the key.
+func (r *HashRing) Route(key string) (string, error) {
+	if len(r.points) == 0 {
+		return "", errors.Wrap(ErrUnknownShard, "hash ring is empty")
+	}
+	h := hashKey(key)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= h })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.owners[r.points[i]], nil
+}
+
+func hashKey(s string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(s))
+	return h.Sum32()
+}
+
+// LookupTable is a [ShardRouter] with an explicit mapping of keys to shards.
+// Keys that are not in the table go to the Default shard.
+type LookupTable struct {
+	Keys    map[string]string
+	Default string
+}
+
+// Route returns the shard for the key.
+func (t *LookupTable) Route(key string) (string, error) {
+	if s, ok := t.Keys[key]; ok {
+		return s, nil
+	}
+	if len(t.Default) == 0 {
+		return "", errors.Wrapf(ErrUnknownShard, "no shard for key %q", key)
+	}
+	return t.Default, nil
+}
+
+// Sharded routes database access to one of several databases by a shard
+// key.
+type Sharded struct {
+	router ShardRouter
+	shards map[string]DB
+}
+
+// NewSharded creates a [Sharded] database from a set of named shards.
+func NewSharded(router ShardRouter, shards map[string]DB) *Sharded {
+	return &Sharded{router: router, shards: shards}
+}
+
+// OpenSharded opens a database for each config and wraps it with [New].
+// Shards with a type that has an [Opener] are opened with it instead and
+// the options are not applied to them.
+func OpenSharded(router ShardRouter, configs map[string]*Config, opts ...Option) (*Sharded, error) {
+	s := &Sharded{router: router, shards: make(map[string]DB, len(configs))}
+	for name, cfg := range configs {
+		if fn, ok := openerFor(cfg.Type); ok {
+			d, err := fn(*cfg)
+			if err != nil {
+				s.Close()
+				return nil, errors.Wrapf(err, "failed to open shard %q", name)
+			}
+			s.shards[name] = d
+			continue
+		}
+		options := applyOptions(append([]Option{WithDialect(cfg.Dialect())}, opts...))
+		pool, err := cfg.openPool(&options)
+		if err != nil {
+			s.Close()
+			return nil, errors.Wrapf(err, "failed to open shard %q", name)
+		}
+		s.shards[name] = newDatabase(pool, options)
+	}
+	return s, nil
+}
+
+// Shard returns the database for the shard key. If the key can't be routed
+// then every operation on the returned [DB] fails.
+func (s *Sharded) Shard(key string) DB {
+	d, err := s.ShardFor(key)
+	if err != nil {
+		return errDB{err}
+	}
+	return d
+}
+
+// ShardFor returns the database for the shard key or an error if the key
+// can't be routed.
+func (s *Sharded) ShardFor(key string) (DB, error) {
+	name, err := s.router.Route(key)
+	if err != nil {
+		return nil, err
+	}
+	d, ok := s.shards[name]
+	if !ok {
+		return nil, errors.Wrapf(ErrUnknownShard, "%q", name)
+	}
+	return d, nil
+}
+
+// Names returns the sorted shard names.
+func (s *Sharded) Names() []string {
+	names := make([]string, 0, len(s.shards))
+	for name := range s.shards {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// ForEachShard calls fn for every shard concurrently. The context passed to
+// fn is canceled when any call fails and the errors are joined.
+func (s *Sharded) ForEachShard(ctx context.Context, fn func(ctx context.Context, name string, d DB) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for name, d := range s.shards {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := fn(ctx, name, d); err != nil {
+				mu.Lock()
+				errs = append(errs, errors.WithMessagef(err, "shard %q", name))
+				mu.Unlock()
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+	return stderrors.Join(errs...)
+}
+
+// Close closes every shard.
+func (s *Sharded) Close() error {
+	var errs []error
+	for _, d := range s.shards {
+		if err := d.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return stderrors.Join(errs...)
+}
+
+// errDB is a [DB] that always fails.
+type errDB struct{ err error }
+
+func (d errDB) Close() error { return nil }
+
+func (d errDB) QueryContext(context.Context, string, ...any) (Rows, error) { return nil, d.err }
+
+func (d errDB) ExecContext(context.Context, string, ...any) (sql.Result, error) { return nil, d.err }
+
+func (d errDB) BeginTx(context.Context, *sql.TxOptions) (Tx, error) { return nil, d.err }
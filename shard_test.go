@@ -0,0 +1,96 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/pkg/errors"
+)
+
+func TestHashRing(t *testing.T) {
+	is := is.New(t)
+	ring := NewHashRing([]string{"a", "b", "c"}, 0)
+	counts := map[string]int{}
+	before := map[string]string{}
+	for i := range 3000 {
+		key := fmt.Sprintf("customer-%d", i)
+		s, err := ring.Route(key)
+		is.NoErr(err)
+		counts[s]++
+		before[key] = s
+	}
+	for _, n := range counts {
+		is.True(n > 600) // keys are spread over the shards
+	}
+	// Adding a shard only moves keys to the new shard.
+	ring = NewHashRing([]string{"a", "b", "c", "d"}, 0)
+	for key, old := range before {
+		s, _ := ring.Route(key)
+		is.True(s == old || s == "d")
+	}
+	_, err := NewHashRing(nil, 1).Route("x")
+	is.True(errors.Is(err, ErrUnknownShard))
+}
+
+func TestSharded(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	configs := map[string]*Config{
+		"eu": {Type: SQLiteDBType, DBName: "file:shard_eu?mode=memory&cache=shared"},
+		"us": {Type: SQLiteDBType, DBName: "file:shard_us?mode=memory&cache=shared"},
+	}
+	s, err := OpenSharded(&LookupTable{Keys: map[string]string{"acme": "eu", "bad": "asia"}, Default: "us"}, configs)
+	is.NoErr(err)
+	defer s.Close()
+	is.Equal(s.Names(), []string{"eu", "us"})
+
+	is.NoErr(s.ForEachShard(ctx, func(ctx context.Context, name string, d DB) error {
+		_, err := d.ExecContext(ctx, "CREATE TABLE region (name text)")
+		if err != nil {
+			return err
+		}
+		_, err = d.ExecContext(ctx, "INSERT INTO region VALUES (?)", name)
+		return err
+	}))
+
+	region := func(d DB) string {
+		var name string
+		rows, err := d.QueryContext(ctx, "SELECT name FROM region")
+		is.NoErr(err)
+		is.NoErr(ScanOne(rows, &name))
+		return name
+	}
+	is.Equal(region(s.Shard("acme")), "eu")
+	is.Equal(region(s.Shard("globex")), "us")
+
+	bad := s.Shard("bad")
+	_, err = bad.QueryContext(ctx, "SELECT 1")
+	is.True(errors.Is(err, ErrUnknownShard))
+	_, err = bad.ExecContext(ctx, "SELECT 1")
+	is.True(errors.Is(err, ErrUnknownShard))
+	_, err = bad.BeginTx(ctx, nil)
+	is.True(errors.Is(err, ErrUnknownShard))
+	is.NoErr(bad.Close())
+	_, err = NewSharded(&LookupTable{}, nil).ShardFor("x")
+	is.True(errors.Is(err, ErrUnknownShard))
+
+	var mu sync.Mutex
+	var visited []string
+	err = s.ForEachShard(ctx, func(ctx context.Context, name string, d DB) error {
+		mu.Lock()
+		visited = append(visited, name)
+		mu.Unlock()
+		if name == "eu" {
+			return errors.New("boom")
+		}
+		return nil
+	})
+	is.True(err != nil)
+	is.Equal(len(visited), 2)
+
+	_, err = OpenSharded(&LookupTable{}, map[string]*Config{"x": {Type: "nope"}})
+	is.True(errors.Is(err, ErrDriverNotRegistered))
+}
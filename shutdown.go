@@ -0,0 +1,73 @@
+package db
+
+import (
+	"context"
+	stderrors "errors"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// ErrShutdown is returned for statements started after
+// [database.Shutdown] was called.
+var ErrShutdown = errors.New("database is shutting down")
+
+// drainer tracks the statements, open rows and transactions that are using
+// the database so shutdown can wait for them.
+type drainer struct {
+	mu      sync.Mutex
+	closing bool
+	active  int
+	drained chan struct{}
+}
+
+// enter registers a new user of the database. The returned func must be
+// called once it is done and is safe to call more than once.
+func (d *drainer) enter() (func(), error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closing {
+		return nil, ErrShutdown
+	}
+	d.active++
+	var once sync.Once
+	return func() { once.Do(d.leave) }, nil
+}
+
+func (d *drainer) leave() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.active--
+	if d.closing && d.active == 0 {
+		close(d.drained)
+	}
+}
+
+// close stops new users from entering and returns a channel that is closed
+// once every active user has left.
+func (d *drainer) close() <-chan struct{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.closing {
+		d.closing = true
+		d.drained = make(chan struct{})
+		if d.active == 0 {
+			close(d.drained)
+		}
+	}
+	return d.drained
+}
+
+// Shutdown stops new statements and transactions from starting, waits for
+// running statements, open rows and transactions to finish and then closes
+// the database. If ctx is done first the database is closed anyway and the
+// context's error is returned.
+func (db *database) Shutdown(ctx context.Context) error {
+	var err error
+	select {
+	case <-db.drain.close():
+	case <-ctx.Done():
+		err = errors.Wrap(ctx.Err(), "waiting for statements to finish")
+	}
+	return stderrors.Join(err, db.Close())
+}
@@ -0,0 +1,69 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/pkg/errors"
+)
+
+func TestShutdown(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	pool, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	d := New(pool)
+	rows, err := d.QueryContext(ctx, "SELECT 1")
+	is.NoErr(err)
+	tx, err := d.BeginTx(ctx, nil)
+	is.NoErr(err)
+
+	done := make(chan error)
+	go func() { done <- d.Shutdown(ctx) }()
+	for {
+		_, err = d.ExecContext(ctx, "SELECT 1")
+		if err != nil {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	is.True(errors.Is(err, ErrShutdown))
+	_, err = d.QueryContext(ctx, "SELECT 1")
+	is.True(errors.Is(err, ErrShutdown))
+	_, err = d.BeginTx(ctx, nil)
+	is.True(errors.Is(err, ErrShutdown))
+
+	// Work that started before the shutdown can finish.
+	_, err = tx.ExecContext(ctx, "SELECT 2")
+	is.NoErr(err)
+	is.NoErr(tx.Commit())
+	select {
+	case <-done:
+		t.Fatal("shutdown should wait for the open rows")
+	case <-time.After(10 * time.Millisecond):
+	}
+	var n int
+	is.True(rows.Next())
+	is.NoErr(rows.Scan(&n))
+	is.NoErr(rows.Close())
+	is.NoErr(<-done)
+	is.True(pool.PingContext(ctx) != nil)
+}
+
+func TestShutdown_Timeout(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	pool, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	d := New(pool)
+	_, err = d.BeginTx(ctx, nil)
+	is.NoErr(err)
+	ctx, cancel := context.WithTimeout(ctx, time.Millisecond)
+	defer cancel()
+	err = d.Shutdown(ctx)
+	is.True(errors.Is(err, context.DeadlineExceeded))
+	is.True(pool.PingContext(context.Background()) != nil)
+}
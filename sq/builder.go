@@ -0,0 +1,363 @@
+package sq
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"strings"
+
+	"github.com/harrybrwn/db"
+	"github.com/pkg/errors"
+)
+
+// SelectBuilder builds a SELECT statement.
+type SelectBuilder struct {
+	columns []string
+	from    string
+	joins   []Sqlizer
+	where   whereClause
+	groupBy []string
+	having  whereClause
+	orderBy []string
+	limit   int
+	offset  int
+}
+
+// Select starts a SELECT statement.
+func Select(columns ...string) *SelectBuilder {
+	return &SelectBuilder{columns: columns, limit: -1}
+}
+
+// From sets the table to select from.
+func (s *SelectBuilder) From(table string) *SelectBuilder { s.from = table; return s }
+
+// Join adds a JOIN clause, i.e. Join("orgs o ON o.id = u.org_id").
+func (s *SelectBuilder) Join(join string, args ...any) *SelectBuilder {
+	s.joins = append(s.joins, Expr("JOIN "+join, args...))
+	return s
+}
+
+// LeftJoin adds a LEFT JOIN clause.
+func (s *SelectBuilder) LeftJoin(join string, args ...any) *SelectBuilder {
+	s.joins = append(s.joins, Expr("LEFT JOIN "+join, args...))
+	return s
+}
+
+// Where adds conditions to the WHERE clause. All conditions are joined with
+// AND.
+func (s *SelectBuilder) Where(conds ...Sqlizer) *SelectBuilder {
+	s.where = append(s.where, conds...)
+	return s
+}
+
+// GroupBy adds GROUP BY expressions.
+func (s *SelectBuilder) GroupBy(exprs ...string) *SelectBuilder {
+	s.groupBy = append(s.groupBy, exprs...)
+	return s
+}
+
+// Having adds conditions to the HAVING clause.
+func (s *SelectBuilder) Having(conds ...Sqlizer) *SelectBuilder {
+	s.having = append(s.having, conds...)
+	return s
+}
+
+// OrderBy adds ORDER BY expressions, i.e. OrderBy("created_at DESC").
+func (s *SelectBuilder) OrderBy(exprs ...string) *SelectBuilder {
+	s.orderBy = append(s.orderBy, exprs...)
+	return s
+}
+
+// Limit sets the maximum number of rows returned.
+func (s *SelectBuilder) Limit(n int) *SelectBuilder { s.limit = n; return s }
+
+// Offset sets the number of rows to skip.
+func (s *SelectBuilder) Offset(n int) *SelectBuilder { s.offset = n; return s }
+
+// SQL implements [Sqlizer]. Select statements can be used as sub-queries
+// with [Expr] or in other conditions.
+func (s *SelectBuilder) SQL(d db.Dialect) (string, []any, error) {
+	if len(s.columns) == 0 {
+		return "", nil, errors.New("select statement has no columns")
+	}
+	var (
+		b    strings.Builder
+		args []any
+	)
+	b.WriteString("SELECT ")
+	b.WriteString(strings.Join(s.columns, ", "))
+	if len(s.from) > 0 {
+		b.WriteString(" FROM ")
+		b.WriteString(s.from)
+	}
+	for _, j := range s.joins {
+		q, a, err := j.SQL(d)
+		if err != nil {
+			return "", nil, err
+		}
+		b.WriteByte(' ')
+		b.WriteString(q)
+		args = append(args, a...)
+	}
+	if err := s.where.write(d, &b, &args, "WHERE", false); err != nil {
+		return "", nil, err
+	}
+	if len(s.groupBy) > 0 {
+		b.WriteString(" GROUP BY ")
+		b.WriteString(strings.Join(s.groupBy, ", "))
+	}
+	if err := s.having.write(d, &b, &args, "HAVING", false); err != nil {
+		return "", nil, err
+	}
+	if len(s.orderBy) > 0 {
+		b.WriteString(" ORDER BY ")
+		b.WriteString(strings.Join(s.orderBy, ", "))
+	}
+	if limit := d.Limit(s.limit, s.offset); len(limit) > 0 {
+		b.WriteByte(' ')
+		b.WriteString(limit)
+	}
+	return b.String(), args, nil
+}
+
+// Query runs the statement using the dialect of the database.
+func (s *SelectBuilder) Query(ctx context.Context, d db.DB) (db.Rows, error) {
+	return query(ctx, d, s)
+}
+
+// InsertBuilder builds an INSERT statement.
+type InsertBuilder struct {
+	table     string
+	columns   []string
+	rows      [][]any
+	returning []string
+}
+
+// Insert starts an INSERT statement.
+func Insert(table string) *InsertBuilder { return &InsertBuilder{table: table} }
+
+// Columns sets the columns being inserted.
+func (i *InsertBuilder) Columns(columns ...string) *InsertBuilder {
+	i.columns = append(i.columns, columns...)
+	return i
+}
+
+// Values adds a row of values. Call it more than once to insert many rows.
+func (i *InsertBuilder) Values(values ...any) *InsertBuilder {
+	i.rows = append(i.rows, values)
+	return i
+}
+
+// SetMap sets the columns and values of a single row from a map.
+func (i *InsertBuilder) SetMap(m map[string]any) *InsertBuilder {
+	cols := sortedKeys(m)
+	row := make([]any, len(cols))
+	for j, c := range cols {
+		row[j] = m[c]
+	}
+	i.columns, i.rows = cols, [][]any{row}
+	return i
+}
+
+// Returning adds a RETURNING clause. This is not supported by mysql.
+func (i *InsertBuilder) Returning(columns ...string) *InsertBuilder {
+	i.returning = append(i.returning, columns...)
+	return i
+}
+
+// SQL implements [Sqlizer].
+func (i *InsertBuilder) SQL(d db.Dialect) (string, []any, error) {
+	if len(i.rows) == 0 {
+		return "", nil, errors.New("insert statement has no values")
+	}
+	var (
+		b    strings.Builder
+		args []any
+	)
+	b.WriteString("INSERT INTO ")
+	b.WriteString(i.table)
+	if len(i.columns) > 0 {
+		b.WriteString(" (")
+		b.WriteString(strings.Join(i.columns, ", "))
+		b.WriteByte(')')
+	}
+	b.WriteString(" VALUES ")
+	for n, row := range i.rows {
+		if len(i.columns) > 0 && len(row) != len(i.columns) {
+			return "", nil, errors.Errorf("insert row %d has %d values for %d columns", n, len(row), len(i.columns))
+		}
+		if n > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteByte('(')
+		for j, v := range row {
+			if j > 0 {
+				b.WriteString(", ")
+			}
+			if e, ok := v.(Sqlizer); ok {
+				q, a, err := e.SQL(d)
+				if err != nil {
+					return "", nil, err
+				}
+				b.WriteString(q)
+				args = append(args, a...)
+				continue
+			}
+			b.WriteByte('?')
+			args = append(args, v)
+		}
+		b.WriteByte(')')
+	}
+	writeReturning(&b, i.returning)
+	return b.String(), args, nil
+}
+
+// Exec executes the statement using the dialect of the database.
+func (i *InsertBuilder) Exec(ctx context.Context, d db.DB) (sql.Result, error) {
+	return exec(ctx, d, i)
+}
+
+// Query executes the statement and returns the rows from the RETURNING
+// clause.
+func (i *InsertBuilder) Query(ctx context.Context, d db.DB) (db.Rows, error) {
+	return query(ctx, d, i)
+}
+
+// UpdateBuilder builds an UPDATE statement.
+type UpdateBuilder struct {
+	table     string
+	columns   []string
+	values    []any
+	where     whereClause
+	returning []string
+}
+
+// Update starts an UPDATE statement.
+func Update(table string) *UpdateBuilder { return &UpdateBuilder{table: table} }
+
+// Set sets a column to a value. The value can be an [Expr] such as
+// Expr("count + ?", 1).
+func (u *UpdateBuilder) Set(column string, value any) *UpdateBuilder {
+	u.columns = append(u.columns, column)
+	u.values = append(u.values, value)
+	return u
+}
+
+// SetMap sets every column in the map. Columns are set in sorted order.
+func (u *UpdateBuilder) SetMap(m map[string]any) *UpdateBuilder {
+	for _, c := range sortedKeys(m) {
+		u.Set(c, m[c])
+	}
+	return u
+}
+
+// Where adds conditions to the WHERE clause.
+func (u *UpdateBuilder) Where(conds ...Sqlizer) *UpdateBuilder {
+	u.where = append(u.where, conds...)
+	return u
+}
+
+// Returning adds a RETURNING clause. This is not supported by mysql.
+func (u *UpdateBuilder) Returning(columns ...string) *UpdateBuilder {
+	u.returning = append(u.returning, columns...)
+	return u
+}
+
+// SQL implements [Sqlizer].
+func (u *UpdateBuilder) SQL(d db.Dialect) (string, []any, error) {
+	if len(u.columns) == 0 {
+		return "", nil, errors.New("update statement has no columns to set")
+	}
+	var (
+		b    strings.Builder
+		args []any
+	)
+	b.WriteString("UPDATE ")
+	b.WriteString(u.table)
+	b.WriteString(" SET ")
+	for i, c := range u.columns {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(c)
+		b.WriteString(" = ")
+		if e, ok := u.values[i].(Sqlizer); ok {
+			q, a, err := e.SQL(d)
+			if err != nil {
+				return "", nil, err
+			}
+			b.WriteString(q)
+			args = append(args, a...)
+			continue
+		}
+		b.WriteByte('?')
+		args = append(args, u.values[i])
+	}
+	if err := u.where.write(d, &b, &args, "WHERE", true); err != nil {
+		return "", nil, err
+	}
+	writeReturning(&b, u.returning)
+	return b.String(), args, nil
+}
+
+// Exec executes the statement using the dialect of the database.
+func (u *UpdateBuilder) Exec(ctx context.Context, d db.DB) (sql.Result, error) {
+	return exec(ctx, d, u)
+}
+
+// Query executes the statement and returns the rows from the RETURNING
+// clause.
+func (u *UpdateBuilder) Query(ctx context.Context, d db.DB) (db.Rows, error) {
+	return query(ctx, d, u)
+}
+
+// DeleteBuilder builds a DELETE statement.
+type DeleteBuilder struct {
+	table string
+	where whereClause
+}
+
+// Delete starts a DELETE statement.
+func Delete(table string) *DeleteBuilder { return &DeleteBuilder{table: table} }
+
+// Where adds conditions to the WHERE clause.
+func (del *DeleteBuilder) Where(conds ...Sqlizer) *DeleteBuilder {
+	del.where = append(del.where, conds...)
+	return del
+}
+
+// SQL implements [Sqlizer].
+func (del *DeleteBuilder) SQL(d db.Dialect) (string, []any, error) {
+	var (
+		b    strings.Builder
+		args []any
+	)
+	b.WriteString("DELETE FROM ")
+	b.WriteString(del.table)
+	if err := del.where.write(d, &b, &args, "WHERE", true); err != nil {
+		return "", nil, err
+	}
+	return b.String(), args, nil
+}
+
+// Exec executes the statement using the dialect of the database.
+func (del *DeleteBuilder) Exec(ctx context.Context, d db.DB) (sql.Result, error) {
+	return exec(ctx, d, del)
+}
+
+func writeReturning(b *strings.Builder, cols []string) {
+	if len(cols) == 0 {
+		return
+	}
+	b.WriteString(" RETURNING ")
+	b.WriteString(strings.Join(cols, ", "))
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
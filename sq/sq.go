@@ -0,0 +1,240 @@
+// Package sq is a small query builder that renders statements for a
+// [db.Dialect] and runs them against a [db.DB].
+//
+//	rows, err := sq.Select("id", "name").
+//		From("users").
+//		Where(sq.Eq{"org_id": orgID}).
+//		OrderBy("name").
+//		Limit(10).
+//		Query(ctx, database)
+//
+// Table and column names are written as given so they can contain
+// expressions like "count(*)" or "u.id". Never build them from user input.
+package sq
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"strings"
+
+	"github.com/harrybrwn/db"
+	"github.com/pkg/errors"
+)
+
+// Sqlizer is anything that can be rendered into SQL. Placeholders in the
+// returned SQL are always "?" and are converted for the dialect by [ToSQL].
+type Sqlizer interface {
+	SQL(d db.Dialect) (string, []any, error)
+}
+
+// ToSQL renders a statement for a dialect.
+func ToSQL(d db.Dialect, s Sqlizer) (string, []any, error) {
+	query, args, err := s.SQL(d)
+	if err != nil {
+		return "", nil, err
+	}
+	return db.Rebind(d.Type(), query), args, nil
+}
+
+// Expr is a raw SQL expression using "?" placeholders.
+func Expr(sql string, args ...any) Sqlizer { return expr{sql, args} }
+
+type expr struct {
+	sql  string
+	args []any
+}
+
+func (e expr) SQL(db.Dialect) (string, []any, error) { return e.sql, e.args, nil }
+
+// Eq renders "col = ?" for each key. Nil values become "IS NULL" and slices
+// become "IN (...)".
+type Eq map[string]any
+
+func (eq Eq) SQL(db.Dialect) (string, []any, error) { return cmpMap(eq, "=", false) }
+
+// NotEq renders "col <> ?" for each key. Nil values become "IS NOT NULL" and
+// slices become "NOT IN (...)".
+type NotEq map[string]any
+
+func (eq NotEq) SQL(db.Dialect) (string, []any, error) { return cmpMap(eq, "<>", true) }
+
+// Lt renders "col < ?" for each key.
+type Lt map[string]any
+
+func (m Lt) SQL(db.Dialect) (string, []any, error) { return cmpMap(m, "<", false) }
+
+// LtOrEq renders "col <= ?" for each key.
+type LtOrEq map[string]any
+
+func (m LtOrEq) SQL(db.Dialect) (string, []any, error) { return cmpMap(m, "<=", false) }
+
+// Gt renders "col > ?" for each key.
+type Gt map[string]any
+
+func (m Gt) SQL(db.Dialect) (string, []any, error) { return cmpMap(m, ">", false) }
+
+// GtOrEq renders "col >= ?" for each key.
+type GtOrEq map[string]any
+
+func (m GtOrEq) SQL(db.Dialect) (string, []any, error) { return cmpMap(m, ">=", false) }
+
+// Like renders "col LIKE ?" for each key.
+type Like map[string]any
+
+func (m Like) SQL(db.Dialect) (string, []any, error) { return cmpMap(m, "LIKE", false) }
+
+// And joins conditions with AND.
+type And []Sqlizer
+
+func (a And) SQL(d db.Dialect) (string, []any, error) { return join(d, a, " AND ") }
+
+// Or joins conditions with OR.
+type Or []Sqlizer
+
+func (o Or) SQL(d db.Dialect) (string, []any, error) { return join(d, o, " OR ") }
+
+func join(d db.Dialect, parts []Sqlizer, sep string) (string, []any, error) {
+	if len(parts) == 0 {
+		return "", nil, nil
+	}
+	var (
+		b    strings.Builder
+		args []any
+	)
+	b.WriteByte('(')
+	for _, p := range parts {
+		s, a, err := p.SQL(d)
+		if err != nil {
+			return "", nil, err
+		}
+		if len(s) == 0 {
+			// Empty conditions like Eq{} are skipped.
+			continue
+		}
+		if b.Len() > 1 {
+			b.WriteString(sep)
+		}
+		b.WriteString(s)
+		args = append(args, a...)
+	}
+	if b.Len() == 1 {
+		return "", nil, nil
+	}
+	b.WriteByte(')')
+	return b.String(), args, nil
+}
+
+func cmpMap(m map[string]any, op string, not bool) (string, []any, error) {
+	keys := sortedKeys(m)
+	var (
+		parts = make([]string, 0, len(keys))
+		args  []any
+	)
+	for _, k := range keys {
+		v := m[k]
+		if v == nil {
+			if op != "=" && op != "<>" {
+				return "", nil, errors.Errorf("cannot use NULL with %s for %q", op, k)
+			}
+			if not {
+				parts = append(parts, k+" IS NOT NULL")
+			} else {
+				parts = append(parts, k+" IS NULL")
+			}
+			continue
+		}
+		if list, ok := asList(v); ok {
+			if op != "=" && op != "<>" {
+				return "", nil, errors.Errorf("cannot use a list with %s for %q", op, k)
+			}
+			if len(list) == 0 {
+				// Nothing is in an empty list.
+				if not {
+					parts = append(parts, "(1=1)")
+				} else {
+					parts = append(parts, "(1=0)")
+				}
+				continue
+			}
+			in := " IN ("
+			if not {
+				in = " NOT IN ("
+			}
+			parts = append(parts, k+in+strings.TrimSuffix(strings.Repeat("?, ", len(list)), ", ")+")")
+			args = append(args, list...)
+			continue
+		}
+		parts = append(parts, k+" "+op+" ?")
+		args = append(args, v)
+	}
+	switch len(parts) {
+	case 0:
+		return "", nil, nil
+	case 1:
+		return parts[0], args, nil
+	}
+	return "(" + strings.Join(parts, " AND ") + ")", args, nil
+}
+
+// asList returns the elements of slices and arrays other than []byte.
+func asList(v any) ([]any, bool) {
+	if _, ok := v.([]byte); ok {
+		return nil, false
+	}
+	rv := reflect.ValueOf(v)
+	if k := rv.Kind(); k != reflect.Slice && k != reflect.Array {
+		return nil, false
+	}
+	list := make([]any, rv.Len())
+	for i := range list {
+		list[i] = rv.Index(i).Interface()
+	}
+	return list, true
+}
+
+// whereClause renders the conditions joined by AND. The clause is left out
+// when every condition is empty, unless the statement modifies rows where
+// conditions that render to nothing most likely come from an empty filter
+// and would change every row.
+type whereClause []Sqlizer
+
+func (w whereClause) write(d db.Dialect, b *strings.Builder, args *[]any, keyword string, modifies bool) error {
+	if len(w) == 0 {
+		return nil
+	}
+	s, a, err := And(w).SQL(d)
+	if err != nil {
+		return err
+	}
+	if len(s) == 0 {
+		if modifies {
+			return errors.New("every WHERE condition is empty, leave out Where to change every row")
+		}
+		return nil
+	}
+	if len(w) == 1 {
+		// Drop the parens added by And.
+		s = s[1 : len(s)-1]
+	}
+	b.WriteString(" " + keyword + " ")
+	b.WriteString(s)
+	*args = append(*args, a...)
+	return nil
+}
+
+func query(ctx context.Context, d db.DB, s Sqlizer) (db.Rows, error) {
+	q, args, err := ToSQL(db.DialectOf(d), s)
+	if err != nil {
+		return nil, err
+	}
+	return d.QueryContext(ctx, q, args...)
+}
+
+func exec(ctx context.Context, d db.DB, s Sqlizer) (sql.Result, error) {
+	q, args, err := ToSQL(db.DialectOf(d), s)
+	if err != nil {
+		return nil, err
+	}
+	return d.ExecContext(ctx, q, args...)
+}
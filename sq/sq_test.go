@@ -0,0 +1,151 @@
+package sq
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/harrybrwn/db"
+	"github.com/matryer/is"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestSelect(t *testing.T) {
+	is := is.New(t)
+	pg := db.DialectFor(db.PostgresDBType)
+	my := db.DialectFor(db.MySQLDBType)
+	s := Select("id", "name").
+		From("users u").
+		Join("orgs o ON o.id = u.org_id AND o.kind = ?", "team").
+		Where(Eq{"u.org_id": 5, "u.deleted_at": nil}, Or{Like{"name": "a%"}, Gt{"age": 30}}).
+		Where(Expr("u.name <> '?'")).
+		OrderBy("name", "id DESC").
+		Limit(10).
+		Offset(20)
+	q, args, err := ToSQL(pg, s)
+	is.NoErr(err)
+	is.Equal(q, "SELECT id, name FROM users u JOIN orgs o ON o.id = u.org_id AND o.kind = $1 "+
+		"WHERE ((u.deleted_at IS NULL AND u.org_id = $2) AND (name LIKE $3 OR age > $4) AND u.name <> '?') "+
+		"ORDER BY name, id DESC LIMIT 10 OFFSET 20")
+	is.Equal(args, []any{"team", 5, "a%", 30})
+
+	q, args, err = ToSQL(my, Select("org_id", "count(*)").From("users").
+		Where(Eq{"id": []int{1, 2, 3}}, NotEq{"role": []string{}}).
+		GroupBy("org_id").
+		Having(GtOrEq{"count(*)": 2}, LtOrEq{"count(*)": 9}, Lt{"max(age)": 50}).
+		Offset(5))
+	is.NoErr(err)
+	is.Equal(q, "SELECT org_id, count(*) FROM users WHERE (id IN (?, ?, ?) AND (1=1)) "+
+		"GROUP BY org_id HAVING (count(*) >= ? AND count(*) <= ? AND max(age) < ?) "+
+		"LIMIT 18446744073709551615 OFFSET 5")
+	is.Equal(args, []any{1, 2, 3, 2, 9, 50})
+
+	q, _, err = ToSQL(pg, Select("1").Where(Eq{"a": []int{}}, NotEq{"b": nil, "c": []int{1}}))
+	is.NoErr(err)
+	is.Equal(q, "SELECT 1 WHERE ((1=0) AND (b IS NOT NULL AND c NOT IN ($1)))")
+
+	// Empty conditions are skipped so dynamic filters can be empty.
+	q, args, err = ToSQL(pg, Select("1").From("t").Where(Eq{}, Or{}, And{Eq{}, Or{Like{}}}))
+	is.NoErr(err)
+	is.Equal(q, "SELECT 1 FROM t")
+	is.Equal(len(args), 0)
+	q, args, err = ToSQL(pg, Select("1").From("t").Where(Eq{}, Or{Gt{}, Eq{"a": 1}}).Having(Lt{}))
+	is.NoErr(err)
+	is.Equal(q, "SELECT 1 FROM t WHERE ((a = $1))")
+	is.Equal(args, []any{1})
+	// Unless that would change every row.
+	_, _, err = ToSQL(pg, Delete("t").Where(NotEq{}))
+	is.True(err != nil)
+	_, _, err = ToSQL(pg, Update("t").Set("a", 1).Where(Or{}))
+	is.True(err != nil)
+
+	// Placeholders in comments and dollar quoted strings are left alone.
+	q, _, err = ToSQL(pg, Select("1").Where(Expr("a = $$?$$ /* ? */ AND b = ?", 1)))
+	is.NoErr(err)
+	is.Equal(q, "SELECT 1 WHERE a = $$?$$ /* ? */ AND b = $1")
+
+	for _, s := range []Sqlizer{
+		Select(),
+		Select("1").Where(Gt{"a": nil}),
+		Select("1").Where(Lt{"a": []int{1}}),
+		Select("1").LeftJoin("x ON true").Where(Or{Gt{"a": nil}}),
+		Select("1").Having(Gt{"a": nil}),
+		Insert("t"),
+		Insert("t").Columns("a", "b").Values(1),
+		Insert("t").Values(Select()),
+		Update("t"),
+		Update("t").Set("a", Select()),
+		Update("t").Set("a", 1).Where(Gt{"a": nil}),
+		Delete("t").Where(Gt{"a": nil}),
+	} {
+		_, _, err = ToSQL(pg, s)
+		is.True(err != nil)
+	}
+}
+
+func TestStatements(t *testing.T) {
+	is := is.New(t)
+	pg := db.DialectFor(db.PostgresDBType)
+	q, args, err := ToSQL(pg, Insert("users").Columns("id", "name").Values(1, "a").Values(2, Expr("upper(?)", "b")).Returning("id"))
+	is.NoErr(err)
+	is.Equal(q, "INSERT INTO users (id, name) VALUES ($1, $2), ($3, upper($4)) RETURNING id")
+	is.Equal(args, []any{1, "a", 2, "b"})
+
+	q, args, err = ToSQL(pg, Update("users").SetMap(map[string]any{"name": "x", "n": Expr("n + ?", 1)}).Where(Eq{"id": 3}).Returning("n"))
+	is.NoErr(err)
+	is.Equal(q, "UPDATE users SET n = n + $1, name = $2 WHERE id = $3 RETURNING n")
+	is.Equal(args, []any{1, "x", 3})
+
+	q, args, err = ToSQL(pg, Delete("users").Where(Eq{"id": 3}))
+	is.NoErr(err)
+	is.Equal(q, "DELETE FROM users WHERE id = $1")
+	is.Equal(args, []any{3})
+}
+
+func TestExec(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	pool, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer pool.Close()
+	pool.SetMaxOpenConns(1)
+	d := db.New(pool, db.WithDialect(db.DialectFor(db.SQLiteDBType)))
+	_, err = d.ExecContext(ctx, "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT, org_id INT)")
+	is.NoErr(err)
+
+	_, err = Insert("users").Columns("name", "org_id").Values("a", 1).Values("b", 1).Values("c", 2).Exec(ctx, d)
+	is.NoErr(err)
+	rows, err := Insert("users").SetMap(map[string]any{"name": "d", "org_id": 2}).Returning("id").Query(ctx, d)
+	is.NoErr(err)
+	var id int
+	is.NoErr(db.ScanOne(rows, &id))
+	is.Equal(id, 4)
+
+	res, err := Update("users").Set("org_id", 3).Where(Eq{"name": []string{"c", "d"}}).Exec(ctx, d)
+	is.NoErr(err)
+	n, _ := res.RowsAffected()
+	is.Equal(n, int64(2))
+	rows, err = Update("users").Set("name", "z").Where(Eq{"id": 1}).Returning("name").Query(ctx, d)
+	is.NoErr(err)
+	var name string
+	is.NoErr(db.ScanOne(rows, &name))
+	is.Equal(name, "z")
+	_, err = Delete("users").Where(Eq{"name": "b"}).Exec(ctx, d)
+	is.NoErr(err)
+
+	rows, err = Select("name").From("users").Where(GtOrEq{"org_id": 1}).OrderBy("id").Limit(2).Offset(1).Query(ctx, d)
+	is.NoErr(err)
+	var names []string
+	for rows.Next() {
+		is.NoErr(rows.Scan(&name))
+		names = append(names, name)
+	}
+	is.NoErr(rows.Close())
+	is.Equal(names, []string{"c", "d"})
+
+	_, err = Select().Query(ctx, d)
+	is.True(err != nil)
+	_, err = Insert("users").Exec(ctx, d)
+	is.True(err != nil)
+}
@@ -0,0 +1,9 @@
+// Package sqlite registers the github.com/mattn/go-sqlite3 driver for
+// [db.SQLiteDBType]. The driver uses cgo.
+//
+//	import _ "github.com/harrybrwn/db/sqlite"
+package sqlite
+
+import (
+	_ "github.com/mattn/go-sqlite3"
+)
@@ -0,0 +1,18 @@
+package sqlite
+
+import (
+	"testing"
+
+	"github.com/matryer/is"
+
+	"github.com/harrybrwn/db"
+)
+
+func TestOpen(t *testing.T) {
+	is := is.New(t)
+	cfg := db.Config{Type: db.SQLiteDBType, DBName: ":memory:"}
+	pool, err := cfg.Open()
+	is.NoErr(err)
+	defer pool.Close()
+	is.NoErr(pool.Ping())
+}
@@ -0,0 +1,143 @@
+// Package sshtunnel opens the ssh tunnels for configs with an
+// [db.Config.SSHHost]. Connections are dialed through the ssh client
+// directly, so the driver's package must register a [db.DialConnector].
+//
+//	import _ "github.com/harrybrwn/db/sshtunnel"
+package sshtunnel
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/harrybrwn/db"
+)
+
+func init() {
+	db.RegisterSSHTunnel(func(cfg *db.Config) (db.DialFunc, io.Closer, error) {
+		t, err := New(cfg)
+		if err != nil {
+			return nil, nil, err
+		}
+		return t.Dial, t, nil
+	})
+}
+
+// Tunnel dials through an ssh server. The ssh connection is opened on the
+// first dial and reopened if it fails.
+type Tunnel struct {
+	addr   string
+	config *ssh.ClientConfig
+	dialer db.DialFunc
+
+	mu     sync.Mutex
+	client *ssh.Client
+}
+
+// New creates a tunnel through the config's ssh host. The key defaults to
+// ~/.ssh/id_ed25519, the known hosts to ~/.ssh/known_hosts and the user to
+// $USER. The config's Dialer is used to reach the ssh server.
+func New(cfg *db.Config) (*Tunnel, error) {
+	home, _ := os.UserHomeDir()
+	keyFile := cfg.SSHKeyFile
+	if len(keyFile) == 0 {
+		keyFile = filepath.Join(home, ".ssh", "id_ed25519")
+	}
+	key, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read ssh key")
+	}
+	signer, err := ssh.ParsePrivateKey(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse ssh key")
+	}
+	knownHosts := cfg.SSHKnownHosts
+	if len(knownHosts) == 0 {
+		knownHosts = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	hostKeys, err := knownhosts.New(knownHosts)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read ssh known hosts")
+	}
+	user := cfg.SSHUser
+	if len(user) == 0 {
+		user = os.Getenv("USER")
+	}
+	addr := cfg.SSHHost
+	if _, _, err = net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, strconv.Itoa(22))
+	}
+	dialer := cfg.Dialer
+	if dialer == nil {
+		var d net.Dialer
+		dialer = d.DialContext
+	}
+	return &Tunnel{
+		addr:   addr,
+		dialer: dialer,
+		config: &ssh.ClientConfig{
+			User:            user,
+			Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+			HostKeyCallback: hostKeys,
+		},
+	}, nil
+}
+
+func (t *Tunnel) connect(ctx context.Context) (*ssh.Client, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.client != nil {
+		return t.client, nil
+	}
+	conn, err := t.dialer(ctx, "tcp", t.addr)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to dial ssh host")
+	}
+	c, chans, reqs, err := ssh.NewClientConn(conn, t.addr, t.config)
+	if err != nil {
+		conn.Close()
+		return nil, errors.Wrap(err, "ssh handshake failed")
+	}
+	t.client = ssh.NewClient(c, chans, reqs)
+	return t.client, nil
+}
+
+// Dial opens a connection to addr from the ssh server.
+func (t *Tunnel) Dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	client, err := t.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := client.DialContext(ctx, network, addr)
+	if err != nil {
+		// The ssh connection may be broken so start over next time.
+		t.mu.Lock()
+		if t.client == client {
+			t.client = nil
+			client.Close()
+		}
+		t.mu.Unlock()
+		return nil, errors.Wrap(err, "failed to dial through ssh")
+	}
+	return conn, nil
+}
+
+// Close closes the ssh connection.
+func (t *Tunnel) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.client == nil {
+		return nil
+	}
+	err := t.client.Close()
+	t.client = nil
+	return err
+}
@@ -0,0 +1,211 @@
+package sshtunnel
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/harrybrwn/db"
+	_ "github.com/harrybrwn/db/mysql"
+)
+
+// echoServer accepts connections and writes back everything it reads.
+func echoServer(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() { io.Copy(c, c); c.Close() }()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// sshServer starts an ssh server that allows port forwarding for the client
+// key. It returns the server address.
+func sshServer(t *testing.T, hostKey ssh.Signer, clientKey ssh.PublicKey) string {
+	t.Helper()
+	conf := &ssh.ServerConfig{
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if string(key.Marshal()) != string(clientKey.Marshal()) {
+				return nil, errors.New("unknown key")
+			}
+			return nil, nil
+		},
+	}
+	conf.AddHostKey(hostKey)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_, chans, reqs, err := ssh.NewServerConn(c, conf)
+				if err != nil {
+					return
+				}
+				go ssh.DiscardRequests(reqs)
+				for nc := range chans {
+					var payload struct {
+						Host       string
+						Port       uint32
+						OriginHost string
+						OriginPort uint32
+					}
+					if nc.ChannelType() != "direct-tcpip" || ssh.Unmarshal(nc.ExtraData(), &payload) != nil {
+						nc.Reject(ssh.UnknownChannelType, "no")
+						continue
+					}
+					remote, err := net.Dial("tcp", net.JoinHostPort(payload.Host, strconv.Itoa(int(payload.Port))))
+					if err != nil {
+						nc.Reject(ssh.ConnectionFailed, err.Error())
+						continue
+					}
+					ch, creqs, err := nc.Accept()
+					if err != nil {
+						remote.Close()
+						continue
+					}
+					go ssh.DiscardRequests(creqs)
+					go func() {
+						defer ch.Close()
+						defer remote.Close()
+						go io.Copy(remote, ch)
+						io.Copy(ch, remote)
+					}()
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestTunnel(t *testing.T) {
+	is := is.New(t)
+	dir := t.TempDir()
+	_, hostPriv, err := ed25519.GenerateKey(rand.Reader)
+	is.NoErr(err)
+	hostKey, err := ssh.NewSignerFromKey(hostPriv)
+	is.NoErr(err)
+	clientPub, clientPriv, err := ed25519.GenerateKey(rand.Reader)
+	is.NoErr(err)
+	sshClientPub, err := ssh.NewPublicKey(clientPub)
+	is.NoErr(err)
+	block, err := ssh.MarshalPrivateKey(clientPriv, "")
+	is.NoErr(err)
+	keyFile := filepath.Join(dir, "id")
+	is.NoErr(os.WriteFile(keyFile, pem.EncodeToMemory(block), 0o600))
+
+	sshAddr := sshServer(t, hostKey, sshClientPub)
+	knownHosts := filepath.Join(dir, "known_hosts")
+	is.NoErr(os.WriteFile(knownHosts, []byte(knownhosts.Line([]string{sshAddr}, hostKey.PublicKey())+"\n"), 0o600))
+	echo := echoServer(t)
+	host, port, _ := net.SplitHostPort(echo)
+
+	cfg := db.Config{
+		Type:          db.MySQLDBType,
+		Host:          host,
+		Port:          port,
+		SSHHost:       sshAddr,
+		SSHUser:       "tester",
+		SSHKeyFile:    keyFile,
+		SSHKnownHosts: knownHosts,
+	}
+	tun, err := New(&cfg)
+	is.NoErr(err)
+	for range 2 {
+		conn, err := tun.Dial(context.Background(), "tcp", echo)
+		is.NoErr(err)
+		_, err = conn.Write([]byte("ping"))
+		is.NoErr(err)
+		buf := make([]byte, 4)
+		_, err = io.ReadFull(conn, buf)
+		is.NoErr(err)
+		is.Equal(string(buf), "ping")
+		is.NoErr(conn.Close())
+	}
+	_, err = tun.Dial(context.Background(), "tcp", "127.0.0.1:1")
+	is.True(err != nil)
+	is.NoErr(tun.Close())
+
+	// The host key must be known.
+	cfg.SSHKnownHosts = filepath.Join(dir, "empty")
+	is.NoErr(os.WriteFile(cfg.SSHKnownHosts, nil, 0o600))
+	tun, err = New(&cfg)
+	is.NoErr(err)
+	_, err = tun.Dial(context.Background(), "tcp", echo)
+	is.True(err != nil)
+	is.NoErr(tun.Close())
+
+	for _, bad := range []db.Config{
+		{SSHHost: sshAddr, SSHKeyFile: filepath.Join(dir, "missing")},
+		{SSHHost: sshAddr, SSHKeyFile: knownHosts},
+		{SSHHost: sshAddr, SSHKeyFile: keyFile, SSHKnownHosts: filepath.Join(dir, "missing")},
+	} {
+		_, err = New(&bad)
+		is.True(err != nil)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	is := is.New(t)
+	dir := t.TempDir()
+	_, clientPriv, err := ed25519.GenerateKey(rand.Reader)
+	is.NoErr(err)
+	block, err := ssh.MarshalPrivateKey(clientPriv, "")
+	is.NoErr(err)
+	keyFile := filepath.Join(dir, "id")
+	is.NoErr(os.WriteFile(keyFile, pem.EncodeToMemory(block), 0o600))
+	knownHosts := filepath.Join(dir, "known_hosts")
+	is.NoErr(os.WriteFile(knownHosts, nil, 0o600))
+
+	// Connections are dialed through the tunnel, which reaches the ssh
+	// server with the config's dialer.
+	var addrs []string
+	cfg := db.Config{
+		Type:          db.MySQLDBType,
+		Host:          "db.internal",
+		Port:          "3306",
+		User:          "u",
+		SSHHost:       "bastion",
+		SSHKeyFile:    keyFile,
+		SSHKnownHosts: knownHosts,
+		Dialer: func(_ context.Context, _, addr string) (net.Conn, error) {
+			addrs = append(addrs, addr)
+			return nil, errors.New("dial blocked")
+		},
+	}
+	pool, err := cfg.Open()
+	is.NoErr(err)
+	err = pool.Ping()
+	is.True(err != nil && strings.Contains(err.Error(), "dial blocked"))
+	is.Equal(addrs[0], "bastion:22")
+	is.NoErr(pool.Close())
+}
@@ -0,0 +1,340 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// StatsFormat is an output format for a [Snapshot].
+type StatsFormat string
+
+const (
+	StatsText        StatsFormat = "text"
+	StatsJSON        StatsFormat = "json"
+	StatsOpenMetrics StatsFormat = "openmetrics"
+)
+
+// ErrUnknownStatsFormat is returned when writing stats in an unsupported
+// format.
+var ErrUnknownStatsFormat = errors.New("unknown stats format")
+
+// Snapshot is the internal state of the wrapper returned by [New] at a point
+// in time.
+type Snapshot struct {
+	// Pool is the connection pool statistics.
+	Pool PoolStats `json:"pool"`
+	// Circuit is the state of the circuit breaker.
+	Circuit string `json:"circuit"`
+	// InFlight is the number of statements currently running.
+	InFlight int64 `json:"in_flight"`
+	// Statements is the number of statements that have been run.
+	Statements uint64 `json:"statements"`
+	// Errors is the number of statements that failed.
+	Errors uint64 `json:"errors"`
+	// Canceled is the number of statements that failed because their
+	// context was canceled or timed out.
+	Canceled uint64 `json:"canceled"`
+	// QueryCount and ExecCount are the number of queries and execs run.
+	QueryCount uint64 `json:"query_count"`
+	ExecCount  uint64 `json:"exec_count"`
+	// Commits and Rollbacks count the transactions started by the wrapper
+	// that were committed or rolled back.
+	Commits   uint64 `json:"commits"`
+	Rollbacks uint64 `json:"rollbacks"`
+	// CacheHits and CacheMisses count the queries cached by [WithCache].
+	CacheHits   uint64 `json:"cache_hits"`
+	CacheMisses uint64 `json:"cache_misses"`
+	// Queries are the per fingerprint statistics when the wrapper was
+	// created with [WithQueryStats].
+	Queries []StatementStats `json:"queries,omitempty"`
+}
+
+// PoolStats mirrors [sql.DBStats] with stable field names.
+type PoolStats struct {
+	MaxOpen           int           `json:"max_open"`
+	Open              int           `json:"open"`
+	InUse             int           `json:"in_use"`
+	Idle              int           `json:"idle"`
+	WaitCount         int64         `json:"wait_count"`
+	WaitDuration      time.Duration `json:"wait_duration"`
+	MaxIdleClosed     int64         `json:"max_idle_closed"`
+	MaxIdleTimeClosed int64         `json:"max_idle_time_closed"`
+	MaxLifetimeClosed int64         `json:"max_lifetime_closed"`
+}
+
+type counters struct {
+	inFlight   atomic.Int64
+	statements atomic.Uint64
+	errors     atomic.Uint64
+	queries    atomic.Uint64
+	execs      atomic.Uint64
+	commits    atomic.Uint64
+	rollbacks  atomic.Uint64
+	canceled   atomic.Uint64
+}
+
+func (c *counters) start() { c.inFlight.Add(1) }
+
+func (c *counters) done(op string, err error) {
+	c.inFlight.Add(-1)
+	c.statements.Add(1)
+	if err != nil {
+		c.errors.Add(1)
+	}
+	switch op {
+	case opQuery:
+		c.queries.Add(1)
+	case opExec:
+		c.execs.Add(1)
+	}
+}
+
+// txDone counts a transaction that was committed or rolled back.
+func (c *counters) txDone(op string) {
+	switch op {
+	case opCommit:
+		c.commits.Add(1)
+	case opRollback:
+		c.rollbacks.Add(1)
+	}
+}
+
+// Snapshot returns a snapshot of the wrapper's internal state.
+func (db *database) Snapshot() Snapshot {
+	s := db.DB.Stats()
+	snap := Snapshot{
+		Pool: PoolStats{
+			MaxOpen:           s.MaxOpenConnections,
+			Open:              s.OpenConnections,
+			InUse:             s.InUse,
+			Idle:              s.Idle,
+			WaitCount:         s.WaitCount,
+			WaitDuration:      s.WaitDuration,
+			MaxIdleClosed:     s.MaxIdleClosed,
+			MaxIdleTimeClosed: s.MaxIdleTimeClosed,
+			MaxLifetimeClosed: s.MaxLifetimeClosed,
+		},
+		Circuit:    db.CircuitState().String(),
+		InFlight:   db.counters.inFlight.Load(),
+		Statements: db.counters.statements.Load(),
+		Errors:     db.counters.errors.Load(),
+		Canceled:   db.counters.canceled.Load(),
+		QueryCount: db.counters.queries.Load(),
+		ExecCount:  db.counters.execs.Load(),
+		Commits:    db.counters.commits.Load(),
+		Rollbacks:  db.counters.rollbacks.Load(),
+		Queries:    db.QueryStats(),
+	}
+	if db.cache != nil {
+		snap.CacheHits = db.cache.hits.Load()
+		snap.CacheMisses = db.cache.misses.Load()
+	}
+	return snap
+}
+
+// WrapperStats is the connection pool statistics of [sql.DB.Stats] together
+// with the wrapper's counters.
+type WrapperStats struct {
+	sql.DBStats
+	// Queries and Execs are the number of queries and execs run.
+	Queries uint64
+	Execs   uint64
+	// Errors is the number of statements that failed.
+	Errors uint64
+	// Commits and Rollbacks count the transactions started by the wrapper
+	// that were committed or rolled back.
+	Commits   uint64
+	Rollbacks uint64
+	// CacheHits and CacheMisses count the queries cached by [WithCache].
+	CacheHits   uint64
+	CacheMisses uint64
+}
+
+// WrapperStats returns the pool statistics and the wrapper's counters. Stats
+// is left as the [sql.DB] method so that it keeps returning [sql.DBStats].
+func (db *database) WrapperStats() WrapperStats {
+	s := WrapperStats{
+		DBStats:   db.DB.Stats(),
+		Queries:   db.counters.queries.Load(),
+		Execs:     db.counters.execs.Load(),
+		Errors:    db.counters.errors.Load(),
+		Commits:   db.counters.commits.Load(),
+		Rollbacks: db.counters.rollbacks.Load(),
+	}
+	if db.cache != nil {
+		s.CacheHits = db.cache.hits.Load()
+		s.CacheMisses = db.cache.misses.Load()
+	}
+	return s
+}
+
+// LogValue groups the counters and pool statistics for logging. The per
+// fingerprint statistics are left out.
+func (s Snapshot) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("circuit", s.Circuit),
+		slog.Int64("in_flight", s.InFlight),
+		slog.Uint64("statements", s.Statements),
+		slog.Uint64("errors", s.Errors),
+		slog.Uint64("canceled", s.Canceled),
+		slog.Uint64("queries", s.QueryCount),
+		slog.Uint64("execs", s.ExecCount),
+		slog.Uint64("commits", s.Commits),
+		slog.Uint64("rollbacks", s.Rollbacks),
+		slog.Uint64("cache_hits", s.CacheHits),
+		slog.Uint64("cache_misses", s.CacheMisses),
+		slog.Group("pool",
+			slog.Int("max_open", s.Pool.MaxOpen),
+			slog.Int("open", s.Pool.Open),
+			slog.Int("in_use", s.Pool.InUse),
+			slog.Int("idle", s.Pool.Idle),
+			slog.Int64("wait_count", s.Pool.WaitCount),
+			slog.Duration("wait_duration", s.Pool.WaitDuration),
+		),
+	)
+}
+
+// WithStatsLogging logs a [Snapshot] at the info level every interval until
+// the database is closed.
+func WithStatsLogging(interval time.Duration) Option {
+	return func(o *dbOptions) { o.statsInterval = interval }
+}
+
+// logStats logs a snapshot every interval until stop is closed.
+func (db *database) logStats(interval time.Duration, stop <-chan struct{}) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+			db.logger.Info("database stats", slog.Any("stats", db.Snapshot()))
+		}
+	}
+}
+
+// WriteStats writes a snapshot of the wrapper's internal state to w. This
+// is meant for debug handlers and scripts that don't have a metrics stack.
+func (db *database) WriteStats(w io.Writer, format StatsFormat) error {
+	return db.Snapshot().Write(w, format)
+}
+
+// Write writes the snapshot to w in the given format.
+func (s Snapshot) Write(w io.Writer, format StatsFormat) error {
+	switch format {
+	case StatsJSON:
+		return json.NewEncoder(w).Encode(s)
+	case StatsText, "":
+		return s.writeText(w)
+	case StatsOpenMetrics:
+		return s.writeOpenMetrics(w)
+	}
+	return errors.Wrapf(ErrUnknownStatsFormat, "%q", format)
+}
+
+func (s Snapshot) writeText(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "circuit:              %s\n", s.Circuit)
+	fmt.Fprintf(&b, "in_flight:            %d\n", s.InFlight)
+	fmt.Fprintf(&b, "statements:           %d\n", s.Statements)
+	fmt.Fprintf(&b, "errors:               %d\n", s.Errors)
+	fmt.Fprintf(&b, "canceled:             %d\n", s.Canceled)
+	fmt.Fprintf(&b, "queries:              %d\n", s.QueryCount)
+	fmt.Fprintf(&b, "execs:                %d\n", s.ExecCount)
+	fmt.Fprintf(&b, "commits:              %d\n", s.Commits)
+	fmt.Fprintf(&b, "rollbacks:            %d\n", s.Rollbacks)
+	fmt.Fprintf(&b, "cache_hits:           %d\n", s.CacheHits)
+	fmt.Fprintf(&b, "cache_misses:         %d\n", s.CacheMisses)
+	fmt.Fprintf(&b, "pool.max_open:        %d\n", s.Pool.MaxOpen)
+	fmt.Fprintf(&b, "pool.open:            %d\n", s.Pool.Open)
+	fmt.Fprintf(&b, "pool.in_use:          %d\n", s.Pool.InUse)
+	fmt.Fprintf(&b, "pool.idle:            %d\n", s.Pool.Idle)
+	fmt.Fprintf(&b, "pool.wait_count:      %d\n", s.Pool.WaitCount)
+	fmt.Fprintf(&b, "pool.wait_duration:   %s\n", s.Pool.WaitDuration)
+	fmt.Fprintf(&b, "pool.closed_max_idle: %d\n", s.Pool.MaxIdleClosed)
+	fmt.Fprintf(&b, "pool.closed_idle:     %d\n", s.Pool.MaxIdleTimeClosed)
+	fmt.Fprintf(&b, "pool.closed_lifetime: %d\n", s.Pool.MaxLifetimeClosed)
+	for _, q := range s.Queries {
+		fmt.Fprintf(&b, "query: count=%d errors=%d total=%s max=%s p50=%s p95=%s p99=%s %s\n",
+			q.Count, q.Errors, q.Total, q.Max, q.P50, q.P95, q.P99, q.Fingerprint)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (s Snapshot) writeOpenMetrics(w io.Writer) error {
+	var b strings.Builder
+	metric := func(name, typ, help string, value any, labels ...string) {
+		fmt.Fprintf(&b, "# TYPE db_%s %s\n# HELP db_%s %s\n", name, typ, name, help)
+		if typ == "counter" {
+			name += "_total"
+		}
+		fmt.Fprintf(&b, "db_%s", name)
+		if len(labels) > 0 {
+			fmt.Fprintf(&b, "{%s}", strings.Join(labels, ","))
+		}
+		fmt.Fprintf(&b, " %v\n", value)
+	}
+	metric("statements", "counter", "Statements run.", s.Statements)
+	metric("statement_errors", "counter", "Statements that failed.", s.Errors)
+	metric("statements_canceled", "counter", "Statements stopped by their context.", s.Canceled)
+	metric("statements_in_flight", "gauge", "Statements currently running.", s.InFlight)
+	metric("queries", "counter", "Queries run.", s.QueryCount)
+	metric("execs", "counter", "Execs run.", s.ExecCount)
+	metric("transactions", "counter", "Transactions finished.", s.Commits, `result="commit"`)
+	fmt.Fprintf(&b, "db_transactions_total{result=\"rollback\"} %d\n", s.Rollbacks)
+	metric("cache_requests", "counter", "Cached queries.", s.CacheHits, `result="hit"`)
+	fmt.Fprintf(&b, "db_cache_requests_total{result=\"miss\"} %d\n", s.CacheMisses)
+	fmt.Fprintf(&b, "# TYPE db_circuit_state stateset\n# HELP db_circuit_state Circuit breaker state.\n")
+	for _, st := range []CircuitState{CircuitClosed, CircuitOpen, CircuitHalfOpen} {
+		v := 0
+		if st.String() == s.Circuit {
+			v = 1
+		}
+		fmt.Fprintf(&b, "db_circuit_state{db_circuit_state=%q} %d\n", st.String(), v)
+	}
+	metric("pool_max_open_connections", "gauge", "Maximum number of open connections.", s.Pool.MaxOpen)
+	metric("pool_open_connections", "gauge", "Open connections.", s.Pool.Open)
+	metric("pool_in_use_connections", "gauge", "Connections in use.", s.Pool.InUse)
+	metric("pool_idle_connections", "gauge", "Idle connections.", s.Pool.Idle)
+	metric("pool_waits", "counter", "Times a connection was waited for.", s.Pool.WaitCount)
+	metric("pool_wait_seconds", "counter", "Total time spent waiting for a connection.", s.Pool.WaitDuration.Seconds())
+	metric("pool_closed_connections", "counter", "Connections closed by the pool.", s.Pool.MaxIdleClosed, `reason="max_idle"`)
+	fmt.Fprintf(&b, "db_pool_closed_connections_total{reason=\"max_idle_time\"} %d\n", s.Pool.MaxIdleTimeClosed)
+	fmt.Fprintf(&b, "db_pool_closed_connections_total{reason=\"max_lifetime\"} %d\n", s.Pool.MaxLifetimeClosed)
+	if len(s.Queries) > 0 {
+		b.WriteString("# TYPE db_query_errors counter\n# HELP db_query_errors Failed statements by fingerprint.\n")
+		for _, q := range s.Queries {
+			fmt.Fprintf(&b, "db_query_errors_total{fingerprint=\"%s\"} %d\n", labelEscape(q.Fingerprint), q.Errors)
+		}
+		b.WriteString("# TYPE db_query_duration_seconds summary\n# HELP db_query_duration_seconds Statement latency by fingerprint.\n")
+		for _, q := range s.Queries {
+			fp := labelEscape(q.Fingerprint)
+			for _, p := range []struct {
+				quantile string
+				d        time.Duration
+			}{{"0.5", q.P50}, {"0.95", q.P95}, {"0.99", q.P99}} {
+				fmt.Fprintf(&b, "db_query_duration_seconds{fingerprint=\"%s\",quantile=\"%s\"} %v\n", fp, p.quantile, p.d.Seconds())
+			}
+			fmt.Fprintf(&b, "db_query_duration_seconds_sum{fingerprint=\"%s\"} %v\n", fp, q.Total.Seconds())
+			fmt.Fprintf(&b, "db_query_duration_seconds_count{fingerprint=\"%s\"} %d\n", fp, q.Count)
+		}
+	}
+	b.WriteString("# EOF\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// labelEscape escapes an OpenMetrics label value.
+func labelEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
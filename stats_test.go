@@ -0,0 +1,115 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/pkg/errors"
+)
+
+func TestWriteStats(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	pool, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer pool.Close()
+	pool.SetMaxOpenConns(3)
+	d := New(pool)
+	_, err = d.ExecContext(ctx, "select 1")
+	is.NoErr(err)
+	_, err = d.ExecContext(ctx, "not sql")
+	is.True(err != nil)
+
+	s := d.Snapshot()
+	is.Equal(s.Statements, uint64(2))
+	is.Equal(s.Errors, uint64(1))
+	is.Equal(s.InFlight, int64(0))
+	is.Equal(s.Circuit, "closed")
+	is.Equal(s.Pool.MaxOpen, 3)
+
+	var b bytes.Buffer
+	is.NoErr(d.WriteStats(&b, StatsJSON))
+	var decoded Snapshot
+	is.NoErr(json.Unmarshal(b.Bytes(), &decoded))
+	is.Equal(decoded, s)
+
+	b.Reset()
+	is.NoErr(d.WriteStats(&b, StatsText))
+	is.True(strings.Contains(b.String(), "statements:           2\n"))
+	is.True(strings.Contains(b.String(), "pool.max_open:        3\n"))
+
+	b.Reset()
+	is.NoErr(d.WriteStats(&b, StatsOpenMetrics))
+	out := b.String()
+	is.True(strings.Contains(out, "# TYPE db_statements counter\n"))
+	is.True(strings.Contains(out, "db_statements_total 2\n"))
+	is.True(strings.Contains(out, "db_statement_errors_total 1\n"))
+	is.True(strings.Contains(out, `db_circuit_state{db_circuit_state="closed"} 1`))
+	is.True(strings.Contains(out, `db_pool_closed_connections_total{reason="max_idle"} 0`))
+	is.True(strings.HasSuffix(out, "# EOF\n"))
+
+	is.True(errors.Is(d.WriteStats(&b, "xml"), ErrUnknownStatsFormat))
+}
+
+func TestSnapshotCounters(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	pool, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	pool.SetMaxOpenConns(1)
+	var logs syncBuffer
+	d := New(pool,
+		WithLogger(slog.New(slog.NewTextHandler(&logs, nil))),
+		WithCache(NewLRUCache(10), time.Minute, nil),
+		WithStatsLogging(time.Millisecond),
+	)
+	_, err = d.ExecContext(ctx, "CREATE TABLE t (id INTEGER)")
+	is.NoErr(err)
+	for range 2 {
+		rows, err := d.QueryContext(CacheQuery(ctx, "t"), "SELECT id FROM t")
+		is.NoErr(err)
+		is.NoErr(rows.Close())
+	}
+	tx, err := d.BeginTx(ctx, nil)
+	is.NoErr(err)
+	is.NoErr(tx.Commit())
+	tx, err = d.BeginTx(ctx, nil)
+	is.NoErr(err)
+	is.NoErr(tx.Rollback())
+
+	s := d.Snapshot()
+	is.Equal(s.QueryCount, uint64(1))
+	is.Equal(s.ExecCount, uint64(1))
+	is.Equal(s.Commits, uint64(1))
+	is.Equal(s.Rollbacks, uint64(1))
+	is.Equal(s.CacheHits, uint64(1))
+	is.Equal(s.CacheMisses, uint64(1))
+	ws := d.WrapperStats()
+	is.Equal(ws.MaxOpenConnections, 1)
+	is.Equal(ws.Queries, uint64(1))
+	is.Equal(ws.Execs, uint64(1))
+	is.Equal(ws.Commits, uint64(1))
+	is.Equal(ws.Rollbacks, uint64(1))
+	is.Equal(ws.CacheHits, uint64(1))
+	is.Equal(ws.CacheMisses, uint64(1))
+
+	var b bytes.Buffer
+	is.NoErr(s.Write(&b, StatsOpenMetrics))
+	is.True(strings.Contains(b.String(), `db_transactions_total{result="rollback"} 1`))
+	is.True(strings.Contains(b.String(), `db_cache_requests_total{result="hit"} 1`))
+
+	for !strings.Contains(logs.String(), "database stats") {
+		time.Sleep(time.Millisecond)
+	}
+	is.True(strings.Contains(logs.String(), "stats.commits=1 stats.rollbacks=1"))
+	is.True(strings.Contains(logs.String(), "stats.pool.max_open=1"))
+	is.NoErr(d.Close())
+	is.NoErr(d.Close())
+}
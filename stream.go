@@ -0,0 +1,76 @@
+package db
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+var cursorID atomic.Uint64
+
+// Stream calls fn for every row of a query while holding at most fetchSize
+// rows in memory. On postgres and CockroachDB the query is run with a server
+// side cursor using DECLARE and FETCH inside a transaction, starting one if
+// db is not already a [Tx]. Other databases iterate over the rows of a
+// normal query.
+func Stream(ctx context.Context, db DB, query string, fetchSize int, fn func(Scanner) error, args ...any) error {
+	if !DialectOf(db).Type().postgresWire() {
+		return streamRows(ctx, db, query, fn, args)
+	}
+	if fetchSize <= 0 {
+		fetchSize = 1000
+	}
+	if tx, ok := db.(Tx); ok {
+		return streamCursor(ctx, tx, query, fetchSize, fn, args)
+	}
+	tx, err := Begin(ctx, nil, db)
+	if err != nil {
+		return err
+	}
+	return TxDo(ctx, tx, func(tx Tx) error {
+		return streamCursor(ctx, tx, query, fetchSize, fn, args)
+	})
+}
+
+func streamRows(ctx context.Context, db DB, query string, fn func(Scanner) error, args []any) (err error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if e := rows.Close(); e != nil && err == nil {
+			err = e
+		}
+	}()
+	for rows.Next() {
+		if err = fn(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func streamCursor(ctx context.Context, tx Tx, query string, fetchSize int, fn func(Scanner) error, args []any) error {
+	cursor := "db_stream_" + strconv.FormatUint(cursorID.Add(1), 10)
+	if _, err := tx.ExecContext(ctx, "DECLARE "+cursor+" NO SCROLL CURSOR FOR "+query, args...); err != nil {
+		return errors.Wrap(err, "failed to declare cursor")
+	}
+	fetch := "FETCH FORWARD " + strconv.Itoa(fetchSize) + " FROM " + cursor
+	for {
+		n := 0
+		err := streamRows(ctx, tx, fetch, func(s Scanner) error {
+			n++
+			return fn(s)
+		}, nil)
+		if err != nil {
+			return err
+		}
+		if n < fetchSize {
+			break
+		}
+	}
+	_, err := tx.ExecContext(ctx, "CLOSE "+cursor)
+	return err
+}
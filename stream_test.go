@@ -0,0 +1,51 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/pkg/errors"
+)
+
+func TestStream(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	pool, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer pool.Close()
+	pool.SetMaxOpenConns(1)
+	lite := New(pool, WithDialect(DialectFor(SQLiteDBType)))
+	_, err = lite.ExecContext(ctx, "CREATE TABLE nums (n int)")
+	is.NoErr(err)
+	_, err = lite.ExecContext(ctx, "INSERT INTO nums VALUES (1), (2), (3)")
+	is.NoErr(err)
+
+	var sum int
+	err = Stream(ctx, lite, "SELECT n FROM nums WHERE n > ?", 2, func(s Scanner) error {
+		var n int
+		err := s.Scan(&n)
+		sum += n
+		return err
+	}, 1)
+	is.NoErr(err)
+	is.Equal(sum, 5)
+
+	errStop := errors.New("stop")
+	err = Stream(ctx, lite, "SELECT n FROM nums", 2, func(Scanner) error { return errStop })
+	is.True(errors.Is(err, errStop))
+
+	// sqlite doesn't have cursors so the transaction is rolled back.
+	err = Stream(ctx, New(pool), "SELECT n FROM nums", 0, func(Scanner) error { return nil })
+	is.True(err != nil)
+	tx, err := New(pool).BeginTx(ctx, nil)
+	is.NoErr(err)
+	is.True(Stream(ctx, tx, "SELECT n FROM nums", 0, func(Scanner) error { return nil }) != nil)
+	is.NoErr(tx.Rollback())
+
+	// CockroachDB also uses a cursor.
+	err = Stream(ctx, New(pool, WithDialect(DialectFor(CockroachDBType))), "SELECT n FROM nums", 0, func(Scanner) error { return nil })
+	is.True(err != nil && strings.Contains(err.Error(), "failed to declare cursor"))
+}
@@ -0,0 +1,220 @@
+// Package tablecache keeps small reference tables in memory.
+package tablecache
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/harrybrwn/db"
+)
+
+// Option configures a [Cache].
+type Option func(*options)
+
+type options struct {
+	interval     time.Duration
+	versionQuery string
+	trigger      <-chan struct{}
+	logger       *slog.Logger
+}
+
+// WithInterval sets how often the cache checks for changes. Defaults to one
+// minute.
+func WithInterval(d time.Duration) Option { return func(o *options) { o.interval = d } }
+
+// WithVersionQuery sets a query that returns a single value that changes
+// whenever the table changes, i.e. "select max(updated_at) from plans". The
+// table is only reloaded when the version changes.
+func WithVersionQuery(query string) Option {
+	return func(o *options) { o.versionQuery = query }
+}
+
+// WithTrigger sets a channel that forces a reload whenever it receives a
+// value. This can be hooked up to LISTEN/NOTIFY for instant updates.
+func WithTrigger(ch <-chan struct{}) Option { return func(o *options) { o.trigger = ch } }
+
+// WithLogger sets the logger used for background refresh errors.
+func WithLogger(l *slog.Logger) Option { return func(o *options) { o.logger = l } }
+
+// ScanFunc scans one row into a key and value.
+type ScanFunc[K comparable, V any] func(db.Scanner) (K, V, error)
+
+// Cache is an in-memory copy of a database table.
+type Cache[K comparable, V any] struct {
+	db    db.DB
+	query string
+	scan  ScanFunc[K, V]
+	opts  options
+
+	mu      sync.RWMutex
+	items   map[K]V
+	version string
+	loaded  time.Time
+}
+
+// New creates a new [Cache] that is filled with the rows returned by the query.
+// The cache is empty until [Cache.Load] or [Cache.Run] is called.
+func New[K comparable, V any](d db.DB, query string, scan ScanFunc[K, V], opts ...Option) *Cache[K, V] {
+	o := options{interval: time.Minute}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.logger == nil {
+		o.logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	return &Cache[K, V]{
+		db:    d,
+		query: query,
+		scan:  scan,
+		opts:  o,
+		items: make(map[K]V),
+	}
+}
+
+// Get looks up a value by key.
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	c.mu.RLock()
+	v, ok := c.items[key]
+	c.mu.RUnlock()
+	return v, ok
+}
+
+// All returns a copy of every item in the cache.
+func (c *Cache[K, V]) All() map[K]V {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return maps.Clone(c.items)
+}
+
+// Len returns the number of items in the cache.
+func (c *Cache[K, V]) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.items)
+}
+
+// LoadedAt returns the time the cache was last loaded.
+func (c *Cache[K, V]) LoadedAt() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.loaded
+}
+
+// Load reloads the whole table.
+func (c *Cache[K, V]) Load(ctx context.Context) error {
+	var version string
+	if len(c.opts.versionQuery) > 0 {
+		var err error
+		if version, err = c.currentVersion(ctx); err != nil {
+			return err
+		}
+	}
+	return c.load(ctx, version)
+}
+
+// Refresh reloads the table if the version has changed. If no version query
+// was set then the table is always reloaded.
+func (c *Cache[K, V]) Refresh(ctx context.Context) error {
+	if len(c.opts.versionQuery) == 0 {
+		return c.load(ctx, "")
+	}
+	version, err := c.currentVersion(ctx)
+	if err != nil {
+		return err
+	}
+	c.mu.RLock()
+	same := !c.loaded.IsZero() && version == c.version
+	c.mu.RUnlock()
+	if same {
+		return nil
+	}
+	return c.load(ctx, version)
+}
+
+// Run loads the table and then keeps it fresh until the context is canceled.
+// Errors from background refreshes are logged and the last good copy of the
+// table is kept.
+func (c *Cache[K, V]) Run(ctx context.Context) error {
+	if err := c.Load(ctx); err != nil {
+		return err
+	}
+	ticker := time.NewTicker(c.opts.interval)
+	defer ticker.Stop()
+	for {
+		var err error
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			err = c.Refresh(ctx)
+		case <-c.opts.trigger:
+			err = c.Load(ctx)
+		}
+		if err != nil && ctx.Err() == nil {
+			c.opts.logger.Warn("failed to refresh table cache", slog.Any("error", err))
+		}
+	}
+}
+
+// currentVersion runs the version query. The version is compared as a
+// string because drivers return different types for the same column, and
+// some of them, like byte slices, can't be compared with ==.
+func (c *Cache[K, V]) currentVersion(ctx context.Context) (string, error) {
+	rows, err := c.db.QueryContext(ctx, c.opts.versionQuery)
+	if err != nil {
+		return "", errors.Wrap(err, "could not query table version")
+	}
+	var version any
+	if err = db.ScanOne(rows, &version); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", errors.Wrap(err, "could not scan table version")
+	}
+	switch v := version.(type) {
+	case nil:
+		return "", nil
+	case []byte:
+		return string(v), nil
+	case string:
+		return v, nil
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano), nil
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
+func (c *Cache[K, V]) load(ctx context.Context, version string) (err error) {
+	rows, err := c.db.QueryContext(ctx, c.query)
+	if err != nil {
+		return errors.Wrap(err, "could not load table")
+	}
+	defer func() {
+		if e := rows.Close(); e != nil && err == nil {
+			err = e
+		}
+	}()
+	items := make(map[K]V)
+	for rows.Next() {
+		k, v, err := c.scan(rows)
+		if err != nil {
+			return err
+		}
+		items[k] = v
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.items = items
+	c.version = version
+	c.loaded = time.Now()
+	c.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,109 @@
+package tablecache
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/harrybrwn/db"
+)
+
+type plan struct {
+	Name  string
+	Price int
+}
+
+func scanPlan(s db.Scanner) (string, plan, error) {
+	var p plan
+	err := s.Scan(&p.Name, &p.Price)
+	return p.Name, p, err
+}
+
+func testDB(t *testing.T) db.DB {
+	t.Helper()
+	pool, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.SetMaxOpenConns(1)
+	t.Cleanup(func() { pool.Close() })
+	d := db.Simple(pool)
+	_, err = d.ExecContext(context.Background(), `
+		create table plans (name text, price int);
+		create table plans_version (v int);
+		insert into plans values ('free', 0), ('pro', 10);
+		insert into plans_version values (1);`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestCache(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	d := testDB(t)
+	c := New(d, "select name, price from plans", scanPlan,
+		WithVersionQuery("select v from plans_version"))
+	_, ok := c.Get("pro")
+	is.True(!ok)
+	is.NoErr(c.Load(ctx))
+	p, ok := c.Get("pro")
+	is.True(ok)
+	is.Equal(p, plan{"pro", 10})
+	is.Equal(c.Len(), 2)
+	is.True(!c.LoadedAt().IsZero())
+
+	// No version change means no reload.
+	_, err := d.ExecContext(ctx, "insert into plans values ('team', 20)")
+	is.NoErr(err)
+	is.NoErr(c.Refresh(ctx))
+	is.Equal(c.Len(), 2)
+	_, err = d.ExecContext(ctx, "update plans_version set v = 2")
+	is.NoErr(err)
+	is.NoErr(c.Refresh(ctx))
+	is.Equal(c.Len(), 3)
+	is.Equal(c.All()["team"], plan{"team", 20})
+
+	// Byte slice versions are compared by value.
+	blob := New(d, "select name, price from plans", scanPlan,
+		WithVersionQuery("select cast(v as blob) from plans_version"))
+	is.NoErr(blob.Load(ctx))
+	_, err = d.ExecContext(ctx, "delete from plans where name = 'team'")
+	is.NoErr(err)
+	is.NoErr(blob.Refresh(ctx))
+	is.Equal(blob.Len(), 3)
+
+	bad := New(d, "select name, price from nope", scanPlan)
+	is.True(bad.Load(ctx) != nil)
+	is.True(bad.Refresh(ctx) != nil)
+	bad = New(d, "select name, price from plans", scanPlan, WithVersionQuery("select nope"))
+	is.True(bad.Load(ctx) != nil)
+	is.True(bad.Refresh(ctx) != nil)
+}
+
+func TestCache_Run(t *testing.T) {
+	is := is.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	d := testDB(t)
+	trigger := make(chan struct{})
+	c := New(d, "select name, price from plans", scanPlan,
+		WithTrigger(trigger), WithInterval(time.Hour))
+	done := make(chan error)
+	go func() { done <- c.Run(ctx) }()
+	for c.Len() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	_, err := d.ExecContext(context.Background(), "delete from plans where name = 'free'")
+	is.NoErr(err)
+	trigger <- struct{}{}
+	for c.Len() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	is.NoErr(<-done)
+}
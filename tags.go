@@ -0,0 +1,106 @@
+package db
+
+import (
+	"context"
+	"maps"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// TagFunc returns tags for a statement from the statement's context.
+type TagFunc func(ctx context.Context) map[string]string
+
+// WithQueryTags appends a sqlcommenter comment (i.e.
+// /*route='%2Fusers',traceparent='00-...'*/) to every statement. Tags are
+// collected from the context with [AddQueryTags] and from each of the tag
+// funcs, which is how tracing libraries can add a traceparent. Statements
+// that already end with a comment are left alone.
+//
+// See https://google.github.io/sqlcommenter/spec/.
+func WithQueryTags(fns ...TagFunc) Option {
+	return func(d *dbOptions) {
+		d.queryTags = true
+		d.tagFuncs = append(d.tagFuncs, fns...)
+	}
+}
+
+type queryTagsKey struct{}
+
+// AddQueryTags returns a context that will tag statements with the key value
+// pairs when the database was created with [WithQueryTags]. Tags from any
+// parent context are kept unless overwritten.
+func AddQueryTags(ctx context.Context, tags map[string]string) context.Context {
+	merged := maps.Clone(QueryTags(ctx))
+	if merged == nil {
+		merged = make(map[string]string, len(tags))
+	}
+	maps.Copy(merged, tags)
+	return context.WithValue(ctx, queryTagsKey{}, merged)
+}
+
+// QueryTags returns the tags added to the context with [AddQueryTags].
+func QueryTags(ctx context.Context) map[string]string {
+	tags, _ := ctx.Value(queryTagsKey{}).(map[string]string)
+	return tags
+}
+
+// tagQuery appends the sqlcommenter comment to a query.
+func (db *database) tagQuery(ctx context.Context, query string) string {
+	tags := QueryTags(ctx)
+	if len(db.tagFuncs) > 0 {
+		tags = maps.Clone(tags)
+		if tags == nil {
+			tags = make(map[string]string)
+		}
+		for _, fn := range db.tagFuncs {
+			maps.Copy(tags, fn(ctx))
+		}
+	}
+	if len(tags) == 0 {
+		return query
+	}
+	trimmed := strings.TrimRight(query, " \t\r\n")
+	semi := strings.HasSuffix(trimmed, ";")
+	trimmed = strings.TrimRight(strings.TrimSuffix(trimmed, ";"), " \t\r\n")
+	if strings.HasSuffix(trimmed, "*/") {
+		return query
+	}
+	var b strings.Builder
+	b.Grow(len(trimmed) + 64)
+	b.WriteString(trimmed)
+	b.WriteString(" ")
+	b.WriteString(sqlComment(tags))
+	if semi {
+		b.WriteByte(';')
+	}
+	return b.String()
+}
+
+// sqlComment formats tags as a sqlcommenter comment. Keys are sorted and
+// both keys and values are url encoded.
+func sqlComment(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteString("/*")
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(commentEscape(k))
+		b.WriteString("='")
+		b.WriteString(strings.ReplaceAll(commentEscape(tags[k]), "'", `\'`))
+		b.WriteByte('\'')
+	}
+	b.WriteString("*/")
+	return b.String()
+}
+
+func commentEscape(s string) string {
+	// QueryEscape encodes spaces as "+" but the spec uses "%20".
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
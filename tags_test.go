@@ -0,0 +1,70 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/pkg/errors"
+)
+
+func TestSQLComment(t *testing.T) {
+	is := is.New(t)
+	is.Equal(sqlComment(map[string]string{
+		"route":       "/users/{id}",
+		"traceparent": "00-5bd66ef5095369c7b0d1f8f4bd33716a-c532cb4098ac3dd2-01",
+		"action":      "it's here",
+	}), `/*action='it%27s%20here',route='%2Fusers%2F%7Bid%7D',traceparent='00-5bd66ef5095369c7b0d1f8f4bd33716a-c532cb4098ac3dd2-01'*/`)
+	is.Equal(sqlComment(map[string]string{"a b": "c'd"}), `/*a%20b='c%27d'*/`)
+}
+
+func TestWithQueryTags(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	d := New(nil, WithQueryTags(func(context.Context) map[string]string {
+		return map[string]string{"traceparent": "00-abc-def-01"}
+	}))
+	ctx = AddQueryTags(ctx, map[string]string{"route": "/a", "app": "api"})
+	ctx = AddQueryTags(ctx, map[string]string{"route": "/b"})
+	is.Equal(QueryTags(ctx), map[string]string{"route": "/b", "app": "api"})
+
+	for _, tt := range []struct{ in, want string }{
+		{"select 1", "select 1 /*app='api',route='%2Fb',traceparent='00-abc-def-01'*/"},
+		{"select 1;\n", "select 1 /*app='api',route='%2Fb',traceparent='00-abc-def-01'*/;"},
+		{"select 1 /*x='y'*/", "select 1 /*x='y'*/"},
+	} {
+		is.Equal(d.tagQuery(ctx, tt.in), tt.want)
+	}
+	d = New(nil, WithQueryTags())
+	is.Equal(d.tagQuery(context.Background(), "select 1"), "select 1")
+	is.Equal(d.tagQuery(AddQueryTags(context.Background(), map[string]string{"a": "b"}), "select 1"), "select 1 /*a='b'*/")
+}
+
+func TestWithQueryTags_Statements(t *testing.T) {
+	is := is.New(t)
+	ctx := AddQueryTags(context.Background(), map[string]string{"route": "/a"})
+	pool, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer pool.Close()
+	pool.SetMaxOpenConns(1)
+	d := New(pool, WithQueryTags())
+	_, err = d.ExecContext(ctx, "CREATE TABLE t (id INT)")
+	is.NoErr(err)
+	tx, err := d.BeginTx(ctx, nil)
+	is.NoErr(err)
+	_, err = tx.ExecContext(ctx, "INSERT INTO t VALUES (?)", 1)
+	is.NoErr(err)
+	is.NoErr(tx.Commit())
+	rows, err := d.QueryContext(ctx, "SELECT id FROM t")
+	is.NoErr(err)
+	var id int
+	is.NoErr(ScanOne(rows, &id))
+	is.Equal(id, 1)
+
+	// The tagged statement is the one sent to the driver.
+	_, err = d.ExecContext(ctx, "INSERT INTO missing VALUES (1)")
+	var qe *QueryError
+	is.True(errors.As(err, &qe))
+	is.Equal(qe.Query, "INSERT INTO missing VALUES (1) /*route='%2Fa'*/")
+}
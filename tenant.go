@@ -0,0 +1,137 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"regexp"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// ErrInvalidTenant is returned when a tenant name is not a valid identifier.
+var ErrInvalidTenant = errors.New("invalid tenant name")
+
+var tenantRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,62}$`)
+
+type tenantKey struct{}
+
+// Tenant returns a context that runs statements against the tenant's schema
+// when the database was created with [WithTenants]. Tenant names must be
+// identifiers made of letters, digits and underscores.
+func Tenant(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, name)
+}
+
+// TenantFrom returns the tenant set with [Tenant].
+func TenantFrom(ctx context.Context) (string, bool) {
+	name, ok := ctx.Value(tenantKey{}).(string)
+	return name, ok
+}
+
+// WithTenants switches every statement and transaction to the schema of the
+// tenant in the context, see [Tenant]. On postgres the statements run in a
+// transaction with "SET LOCAL search_path". On mysql the connection is
+// switched with "USE" and switched back when the statement or transaction is
+// done.
+func WithTenants() Option { return func(o *dbOptions) { o.tenants = true } }
+
+// tenant returns the validated tenant for the context if tenants are
+// enabled.
+func (db *database) tenant(ctx context.Context) (string, error) {
+	if !db.tenants {
+		return "", nil
+	}
+	name, ok := TenantFrom(ctx)
+	if !ok {
+		return "", nil
+	}
+	if !tenantRe.MatchString(name) {
+		return "", errors.Wrapf(ErrInvalidTenant, "%q", name)
+	}
+	return name, nil
+}
+
+// useConn is true when tenants are switched per connection instead of per
+// transaction.
+func (db *database) useConn() bool { return db.dialect.Type() == MySQLDBType }
+
+// implicitTx is true when statements outside of a transaction need to be
+// run in one for the transaction setup to apply.
+func (db *database) implicitTx(ctx context.Context) bool {
+	if len(db.setRole) > 0 {
+		return true
+	}
+	name, _ := db.tenant(ctx)
+	return len(name) > 0 && !db.useConn()
+}
+
+// tenantConn checks out a connection and switches it to the tenant's
+// database. The release func switches it back and returns it to the pool.
+func (db *database) tenantConn(ctx context.Context, name string) (*sql.Conn, func(), error) {
+	conn, err := db.DB.Conn(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	var prev sql.NullString
+	if err = conn.QueryRowContext(ctx, "SELECT DATABASE()").Scan(&prev); err != nil {
+		conn.Close()
+		return nil, nil, errors.Wrap(err, "failed to get current database")
+	}
+	if _, err = conn.ExecContext(ctx, "USE "+db.dialect.QuoteIdent(name)); err != nil {
+		conn.Close()
+		return nil, nil, errors.Wrap(err, "failed to switch tenant")
+	}
+	release := func() {
+		var err error
+		if prev.Valid {
+			_, err = conn.ExecContext(context.WithoutCancel(ctx), "USE "+db.dialect.QuoteIdent(prev.String))
+		}
+		if !prev.Valid || err != nil {
+			// The connection can't be switched back so it must not be
+			// reused.
+			conn.Raw(func(any) error { return driver.ErrBadConn })
+		}
+		conn.Close()
+	}
+	return conn, release, nil
+}
+
+// tenantQuery runs a query on a connection switched to the tenant.
+func (db *database) tenantQuery(ctx context.Context, name, query string, args []any) (Rows, error) {
+	conn, release, err := db.tenantConn(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	return &releaseRows{Rows: rows, release: sync.OnceFunc(release)}, nil
+}
+
+// tenantExec runs a statement on a connection switched to the tenant.
+func (db *database) tenantExec(ctx context.Context, name, query string, args []any) (sql.Result, error) {
+	conn, release, err := db.tenantConn(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return conn.ExecContext(ctx, query, args...)
+}
+
+// tenantBegin starts a transaction on a connection switched to the tenant.
+func (db *database) tenantBegin(ctx context.Context, name string, opts *sql.TxOptions) (*sql.Tx, func(), error) {
+	conn, release, err := db.tenantConn(ctx, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	t, err := conn.BeginTx(ctx, db.role.txOptions(opts))
+	if err != nil {
+		release()
+		return nil, nil, err
+	}
+	return t, release, nil
+}
@@ -0,0 +1,190 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/pkg/errors"
+)
+
+// recorder is a fake driver connection that records every statement.
+type recorder struct {
+	mu    sync.Mutex
+	stmts []string
+	// current is returned by "SELECT DATABASE()".
+	current any
+	fail    string
+	// results are returned by the matching queries.
+	results map[string]driver.Value
+}
+
+func (r *recorder) log(query string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stmts = append(r.stmts, query)
+	if len(r.fail) > 0 && strings.HasPrefix(query, r.fail) {
+		return errors.New("recorder: failed " + query)
+	}
+	return nil
+}
+
+func (r *recorder) take() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.stmts
+	r.stmts = nil
+	return s
+}
+
+func (r *recorder) Connect(context.Context) (driver.Conn, error) { return &recorderConn{r}, nil }
+func (r *recorder) Driver() driver.Driver                        { return nil }
+
+type recorderConn struct{ r *recorder }
+
+func (c *recorderConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c *recorderConn) Close() error                        { return nil }
+func (c *recorderConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *recorderConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	return c, c.r.log("BEGIN")
+}
+func (c *recorderConn) Commit() error   { return c.r.log("COMMIT") }
+func (c *recorderConn) Rollback() error { return c.r.log("ROLLBACK") }
+
+func (c *recorderConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	return driver.RowsAffected(0), c.r.log(query)
+}
+
+func (c *recorderConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	if err := c.r.log(query); err != nil {
+		return nil, err
+	}
+	if query == "SELECT DATABASE()" {
+		return &recorderRows{values: []driver.Value{c.r.current}}, nil
+	}
+	if v, ok := c.r.results[query]; ok {
+		return &recorderRows{values: []driver.Value{v}}, nil
+	}
+	return &recorderRows{}, nil
+}
+
+type recorderRows struct{ values []driver.Value }
+
+func (r *recorderRows) Columns() []string { return []string{"x"} }
+func (r *recorderRows) Close() error      { return nil }
+func (r *recorderRows) Next(dest []driver.Value) error {
+	if r.values == nil {
+		return io.EOF
+	}
+	copy(dest, r.values)
+	r.values = nil
+	return nil
+}
+
+func TestTenant_Postgres(t *testing.T) {
+	is := is.New(t)
+	rec := &recorder{}
+	pool := sql.OpenDB(rec)
+	defer pool.Close()
+	d := New(pool, WithTenants())
+	ctx := Tenant(context.Background(), "acme")
+
+	_, err := d.ExecContext(ctx, "DELETE FROM users")
+	is.NoErr(err)
+	is.Equal(rec.take(), []string{"BEGIN", `SET LOCAL search_path TO "acme"`, "DELETE FROM users", "COMMIT"})
+
+	rows, err := d.QueryContext(ctx, "SELECT 1")
+	is.NoErr(err)
+	is.NoErr(rows.Close())
+	is.Equal(rec.take(), []string{"BEGIN", `SET LOCAL search_path TO "acme"`, "SELECT 1", "COMMIT"})
+
+	tx, err := d.BeginTx(ctx, nil)
+	is.NoErr(err)
+	_, err = tx.ExecContext(ctx, "DELETE FROM users")
+	is.NoErr(err)
+	is.NoErr(tx.Commit())
+	is.Equal(rec.take(), []string{"BEGIN", `SET LOCAL search_path TO "acme"`, "DELETE FROM users", "COMMIT"})
+
+	// No tenant, no switching.
+	_, err = d.ExecContext(context.Background(), "DELETE FROM users")
+	is.NoErr(err)
+	is.Equal(rec.take(), []string{"DELETE FROM users"})
+	// Tenants are ignored unless enabled.
+	_, err = New(pool).ExecContext(ctx, "DELETE FROM users")
+	is.NoErr(err)
+	is.Equal(rec.take(), []string{"DELETE FROM users"})
+
+	for _, name := range []string{"", "a b", `x"; drop table users; --`, "1abc", strings.Repeat("a", 64)} {
+		_, err = d.ExecContext(Tenant(context.Background(), name), "DELETE FROM users")
+		is.True(errors.Is(err, ErrInvalidTenant))
+		_, err = d.QueryContext(Tenant(context.Background(), name), "SELECT 1")
+		is.True(errors.Is(err, ErrInvalidTenant))
+		_, err = d.BeginTx(Tenant(context.Background(), name), nil)
+		is.True(errors.Is(err, ErrInvalidTenant))
+	}
+	is.Equal(len(rec.take()), 0)
+
+	rec.fail = "SET LOCAL search_path"
+	_, err = d.BeginTx(ctx, nil)
+	is.True(err != nil)
+	is.Equal(rec.take(), []string{"BEGIN", `SET LOCAL search_path TO "acme"`, "ROLLBACK"})
+}
+
+func TestTenant_MySQL(t *testing.T) {
+	is := is.New(t)
+	rec := &recorder{current: "app"}
+	pool := sql.OpenDB(rec)
+	defer pool.Close()
+	pool.SetMaxOpenConns(1)
+	d := New(pool, WithTenants(), WithDialect(DialectFor(MySQLDBType)))
+	ctx := Tenant(context.Background(), "acme")
+
+	_, err := d.ExecContext(ctx, "DELETE FROM users")
+	is.NoErr(err)
+	is.Equal(rec.take(), []string{"SELECT DATABASE()", "USE `acme`", "DELETE FROM users", "USE `app`"})
+
+	rows, err := d.QueryContext(ctx, "SELECT 1")
+	is.NoErr(err)
+	for rows.Next() {
+	}
+	is.NoErr(rows.Close())
+	is.Equal(rec.take(), []string{"SELECT DATABASE()", "USE `acme`", "SELECT 1", "USE `app`"})
+
+	tx, err := d.BeginTx(ctx, nil)
+	is.NoErr(err)
+	_, err = tx.ExecContext(ctx, "DELETE FROM users")
+	is.NoErr(err)
+	is.NoErr(tx.Rollback())
+	is.Equal(rec.take(), []string{"SELECT DATABASE()", "USE `acme`", "BEGIN", "DELETE FROM users", "ROLLBACK", "USE `app`"})
+
+	// Connections without a default database can't be switched back.
+	rec.current = nil
+	_, err = d.ExecContext(ctx, "DELETE FROM users")
+	is.NoErr(err)
+	is.Equal(rec.take(), []string{"SELECT DATABASE()", "USE `acme`", "DELETE FROM users"})
+
+	rec.current = "app"
+	rec.fail = "USE `acme`"
+	_, err = d.ExecContext(ctx, "DELETE FROM users")
+	is.True(err != nil)
+	_, err = d.QueryContext(ctx, "SELECT 1")
+	is.True(err != nil)
+	_, err = d.BeginTx(ctx, nil)
+	is.True(err != nil)
+	rec.fail = "SELECT DATABASE()"
+	_, err = d.ExecContext(ctx, "DELETE FROM users")
+	is.True(err != nil)
+	rec.fail = "SELECT 1"
+	rec.take()
+	_, err = d.QueryContext(ctx, "SELECT 1")
+	is.True(err != nil)
+	is.Equal(rec.take(), []string{"SELECT DATABASE()", "USE `acme`", "SELECT 1", "USE `app`"})
+}
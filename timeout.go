@@ -0,0 +1,78 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type timeoutKey struct{}
+
+// WithQueryTimeout returns a context that sets the timeout of each statement
+// run with it by the wrapper from [New]. Unlike [context.WithTimeout] the
+// timeout starts when the statement starts, and a timeout of zero disables
+// the default set by [WithDefaultQueryTimeout].
+func WithQueryTimeout(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, timeoutKey{}, d)
+}
+
+// WithDefaultQueryTimeout sets a timeout for queries and execs run with a
+// context that has no deadline. The timeout includes the time spent reading
+// rows.
+func WithDefaultQueryTimeout(d time.Duration) Option {
+	return func(o *dbOptions) { o.queryTimeout = d }
+}
+
+// WithStatementTimeout sets the statement_timeout of postgres and
+// CockroachDB to the default query timeout at the start of each transaction
+// so the server also stops statements that run too long. It does nothing
+// for other databases.
+func WithStatementTimeout() Option {
+	return func(o *dbOptions) { o.statementTimeout = true }
+}
+
+// statementContext applies the statement timeout to a context. The cancel
+// function is nil if the context was not changed.
+func (db *database) statementContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	d, ok := ctx.Value(timeoutKey{}).(time.Duration)
+	if !ok {
+		if _, hasDeadline := ctx.Deadline(); hasDeadline {
+			return ctx, nil
+		}
+		d = db.queryTimeout
+	}
+	if d <= 0 {
+		return ctx, nil
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+// acquire prepares the context for a statement and waits for a concurrency
+// slot. The done function must be called once the statement has finished and
+// is nil if there is nothing to clean up.
+func (db *database) acquire(ctx context.Context) (context.Context, func(), error) {
+	ctx, cancel := db.statementContext(ctx)
+	if db.limiter == nil {
+		return ctx, cancel, nil
+	}
+	release, err := db.limiter.acquire(ctx)
+	if err != nil {
+		if cancel != nil {
+			cancel()
+		}
+		return ctx, nil, err
+	}
+	if cancel == nil {
+		return ctx, release, nil
+	}
+	return ctx, func() { release(); cancel() }, nil
+}
+
+// statementTimeoutQuery returns the statement that sets the server side
+// statement timeout for a transaction.
+func (db *database) statementTimeoutQuery() string {
+	if !db.statementTimeout || db.queryTimeout <= 0 || !db.dialect.Type().postgresWire() {
+		return ""
+	}
+	return fmt.Sprintf("SET LOCAL statement_timeout = %d", db.queryTimeout.Milliseconds())
+}
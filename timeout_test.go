@@ -0,0 +1,78 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/pkg/errors"
+)
+
+const slowQuery = `WITH RECURSIVE c(x) AS (SELECT 1 UNION ALL SELECT x+1 FROM c WHERE x < 100000000) SELECT max(x) FROM c`
+
+func TestWithDefaultQueryTimeout(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	pool, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer pool.Close()
+	d := New(pool, WithDefaultQueryTimeout(10*time.Millisecond))
+
+	_, err = d.ExecContext(ctx, slowQuery)
+	is.True(err != nil)
+	rows, err := d.QueryContext(ctx, slowQuery)
+	if err == nil {
+		var n int
+		err = ScanOne(rows, &n)
+	}
+	is.True(errors.Is(err, context.DeadlineExceeded) || err.Error() == "interrupted")
+
+	// The timeout covers reading the rows.
+	rows, err = d.QueryContext(ctx, "SELECT 1 UNION ALL SELECT 2")
+	is.NoErr(err)
+	n := 0
+	is.True(rows.Next())
+	time.Sleep(20 * time.Millisecond)
+	for rows.Next() {
+	}
+	is.True(errors.Is(rows.Err(), context.DeadlineExceeded))
+	is.NoErr(rows.Close())
+
+	// Per call timeouts
+	tx, err := d.BeginTx(ctx, nil)
+	is.NoErr(err)
+	_, err = tx.ExecContext(WithQueryTimeout(ctx, time.Millisecond), slowQuery)
+	is.True(err != nil)
+	rows, err = tx.QueryContext(WithQueryTimeout(ctx, 0), "SELECT 1")
+	is.NoErr(err)
+	is.NoErr(ScanOne(rows, &n))
+	is.NoErr(tx.Rollback())
+
+	ctx2, cancel := d.statementContext(WithQueryTimeout(ctx, 0))
+	is.True(cancel == nil)
+	_, hasDeadline := ctx2.Deadline()
+	is.True(!hasDeadline)
+	deadline, cancel := context.WithTimeout(ctx, time.Hour)
+	defer cancel()
+	ctx2, cancel = d.statementContext(deadline)
+	is.True(cancel == nil)
+	is.Equal(ctx2, deadline)
+}
+
+func TestWithStatementTimeout(t *testing.T) {
+	is := is.New(t)
+	pool, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer pool.Close()
+	d := New(pool, WithDefaultQueryTimeout(1500*time.Millisecond), WithStatementTimeout())
+	is.Equal(d.statementTimeoutQuery(), "SET LOCAL statement_timeout = 1500")
+	d = New(pool, WithDefaultQueryTimeout(time.Second), WithStatementTimeout(), WithDialect(DialectFor(CockroachDBType)))
+	is.Equal(d.statementTimeoutQuery(), "SET LOCAL statement_timeout = 1000")
+	d = New(pool, WithDefaultQueryTimeout(time.Second), WithStatementTimeout(), WithDialect(DialectFor(SQLiteDBType)))
+	is.Equal(d.statementTimeoutQuery(), "")
+	tx, err := d.BeginTx(context.Background(), nil)
+	is.NoErr(err)
+	is.NoErr(tx.Rollback())
+}
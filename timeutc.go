@@ -0,0 +1,88 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// WithUTC will convert all scanned time values to UTC. Supported scan
+// destinations are *[time.Time], **[time.Time], *[sql.NullTime] and
+// *[Null][time.Time].
+func WithUTC() Option { return func(d *dbOptions) { d.utc = true } }
+
+// WithZeroTimeAsNull will bind zero [time.Time] arguments as NULL.
+func WithZeroTimeAsNull() Option { return func(d *dbOptions) { d.zeroTimeNull = true } }
+
+// zeroTimesToNull replaces any zero time arguments with nil. The args slice is
+// only copied if a replacement is made.
+func zeroTimesToNull(args []any) []any {
+	var out []any
+	for i, a := range args {
+		var zero bool
+		switch t := a.(type) {
+		case time.Time:
+			zero = t.IsZero()
+		case *time.Time:
+			zero = t != nil && t.IsZero()
+		}
+		if !zero {
+			continue
+		}
+		if out == nil {
+			out = make([]any, len(args))
+			copy(out, args)
+		}
+		out[i] = nil
+	}
+	if out == nil {
+		return args
+	}
+	return out
+}
+
+// utcRows converts scanned time values to UTC.
+type utcRows struct{ Rows }
+
+func (r *utcRows) Scan(dest ...any) error {
+	if err := r.Rows.Scan(dest...); err != nil {
+		return err
+	}
+	for _, d := range dest {
+		toUTC(d)
+	}
+	return nil
+}
+
+func (r *utcRows) Columns() ([]string, error) {
+	c, ok := r.Rows.(columner)
+	if !ok {
+		return nil, errors.New("rows do not have column names")
+	}
+	return c.Columns()
+}
+
+func toUTC(dest any) {
+	switch t := dest.(type) {
+	case *time.Time:
+		*t = t.UTC()
+	case **time.Time:
+		if *t != nil {
+			u := (*t).UTC()
+			*t = &u
+		}
+	case *sql.NullTime:
+		if t.Valid {
+			t.Time = t.Time.UTC()
+		}
+	case *Null[time.Time]:
+		if t.Valid {
+			t.V = t.V.UTC()
+		}
+	case *any:
+		if tm, ok := (*t).(time.Time); ok {
+			*t = tm.UTC()
+		}
+	}
+}
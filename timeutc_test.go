@@ -0,0 +1,58 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestWithUTC(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	pool, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer pool.Close()
+	pool.SetMaxOpenConns(1)
+	d := New(pool, WithUTC(), WithZeroTimeAsNull())
+	_, err = d.ExecContext(ctx, "CREATE TABLE t (id INT, ts DATETIME)")
+	is.NoErr(err)
+	est := time.FixedZone("EST", -5*60*60)
+	now := time.Date(2024, 5, 6, 7, 8, 9, 0, est)
+	_, err = d.ExecContext(ctx, "INSERT INTO t VALUES (1, ?), (2, ?), (3, ?)", now, time.Time{}, &time.Time{})
+	is.NoErr(err)
+
+	var (
+		ts  time.Time
+		pts *time.Time
+		nt  sql.NullTime
+		n   Null[time.Time]
+	)
+	rows, err := d.QueryContext(ctx, "SELECT ts, ts, ts, ts FROM t WHERE id = 1")
+	is.NoErr(err)
+	is.NoErr(ScanOne(rows, &ts, &pts, &nt, &n))
+	for _, tm := range []time.Time{ts, *pts, nt.Time, n.V} {
+		is.Equal(tm.Location(), time.UTC)
+		is.True(tm.Equal(now))
+	}
+
+	var nulls int
+	is.NoErr(d.QueryRowContext(ctx, "SELECT COUNT(*) FROM t WHERE ts IS NULL").Scan(&nulls))
+	is.Equal(nulls, 2)
+
+	tx, err := d.BeginTx(ctx, nil)
+	is.NoErr(err)
+	defer tx.Rollback()
+	rows, err = tx.QueryContext(ctx, "SELECT ts FROM t WHERE id = 1")
+	is.NoErr(err)
+	is.NoErr(ScanOne(rows, &ts))
+	is.Equal(ts.Location(), time.UTC)
+	_, err = tx.ExecContext(ctx, "UPDATE t SET ts = ? WHERE id = 1", time.Time{})
+	is.NoErr(err)
+	rows, err = tx.QueryContext(ctx, "SELECT COUNT(*) FROM t WHERE ts IS NULL")
+	is.NoErr(err)
+	is.NoErr(ScanOne(rows, &nulls))
+	is.Equal(nulls, 3)
+}
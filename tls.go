@@ -0,0 +1,251 @@
+package db
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// TLSConfig builds a [tls.Config] from the ssl options in the Config. The
+// in-memory PEM fields take precedence over their file path counterparts. A
+// nil config is returned when ssl is disabled or no ssl options are set.
+func (db *Config) TLSConfig() (*tls.Config, error) {
+	mode := strings.ToLower(db.SSLMode)
+	if sslDisabled(mode) || (len(mode) == 0 && !db.hasTLSMaterial()) {
+		return nil, nil
+	}
+	ca, err := pemOrFile(db.SSLCAPEM, db.SSLCA)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read ssl ca")
+	}
+	cert, err := pemOrFile(db.SSLCertPEM, db.SSLCert)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read ssl cert")
+	}
+	key, err := pemOrFile(db.SSLKeyPEM, db.SSLKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read ssl key")
+	}
+
+	conf := tls.Config{ServerName: db.SSLSNI}
+	if len(conf.ServerName) == 0 && !db.isSocket() {
+		conf.ServerName = db.Host
+	}
+	if len(ca) > 0 {
+		conf.RootCAs = x509.NewCertPool()
+		if !conf.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, errors.New("no valid certificates found in ssl ca")
+		}
+	}
+	if len(cert) > 0 || len(key) > 0 {
+		pair, err := tls.X509KeyPair(cert, key)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid ssl client certificate")
+		}
+		conf.Certificates = []tls.Certificate{pair}
+	}
+
+	switch mode {
+	case "verify-full", "verify_identity", "true":
+	case "verify-ca", "verify_ca":
+		// Verify the chain but not the host name.
+		conf.InsecureSkipVerify = true
+		conf.VerifyConnection = verifyChain(conf.RootCAs)
+	default:
+		// Modes like "require" only verify the server when given a CA.
+		conf.InsecureSkipVerify = true
+		if conf.RootCAs != nil {
+			conf.VerifyConnection = verifyChain(conf.RootCAs)
+		}
+	}
+	return &conf, nil
+}
+
+func (db *Config) hasTLSMaterial() bool {
+	return len(db.SSLCA) > 0 || len(db.SSLCert) > 0 || len(db.SSLKey) > 0 ||
+		len(db.SSLCAPEM) > 0 || len(db.SSLCertPEM) > 0 || len(db.SSLKeyPEM) > 0
+}
+
+func (db *Config) hasInlinePEM() bool {
+	return len(db.SSLCAPEM) > 0 || len(db.SSLCertPEM) > 0 || len(db.SSLKeyPEM) > 0
+}
+
+func verifyChain(roots *x509.CertPool) func(tls.ConnectionState) error {
+	return func(cs tls.ConnectionState) error {
+		if len(cs.PeerCertificates) == 0 {
+			return errors.New("server did not present a certificate")
+		}
+		opts := x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: x509.NewCertPool(),
+		}
+		for _, c := range cs.PeerCertificates[1:] {
+			opts.Intermediates.AddCert(c)
+		}
+		_, err := cs.PeerCertificates[0].Verify(opts)
+		return err
+	}
+}
+
+func pemOrFile(pem, file string) ([]byte, error) {
+	if len(pem) > 0 {
+		return []byte(pem), nil
+	}
+	if len(file) == 0 {
+		return nil, nil
+	}
+	return os.ReadFile(file)
+}
+
+var (
+	tlsMu         sync.Mutex
+	tlsRegistrars = map[Type]func(name string, conf *tls.Config) error{}
+	// tlsNames holds the names of the tls configs that were registered.
+	tlsNames = map[string]struct{}{}
+)
+
+// RegisterTLSFunc sets the func used to register a [tls.Config] with the
+// driver for a database type. Drivers like github.com/go-sql-driver/mysql
+// can only use custom tls configs that are registered by name, so this is
+// required for [Config.TLS] and the ssl options to work with mysql. It is
+// done by importing github.com/harrybrwn/db/mysql.
+func RegisterTLSFunc(t Type, fn func(name string, conf *tls.Config) error) {
+	tlsMu.Lock()
+	defer tlsMu.Unlock()
+	tlsRegistrars[t] = fn
+}
+
+// tlsName is the name the config's tls config is registered under. It
+// hashes everything the tls config is built from so that two configs only
+// share a name when they would register the same tls config.
+func (db *Config) tlsName() string {
+	h := fnv.New64a()
+	for _, s := range []string{
+		db.Host, db.Port, db.User, db.DBName, strings.ToLower(db.SSLMode), db.SSLSNI,
+		db.SSLCA, db.SSLCert, db.SSLKey, db.SSLCAPEM, db.SSLCertPEM, db.SSLKeyPEM,
+		fmt.Sprintf("%p", db.TLS),
+	} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	return "db-" + strconv.FormatUint(h.Sum64(), 36)
+}
+
+// tlsRegistered is true when a tls config was registered under name.
+func tlsRegistered(name string) bool {
+	tlsMu.Lock()
+	defer tlsMu.Unlock()
+	_, ok := tlsNames[name]
+	return ok
+}
+
+func sslDisabled(mode string) bool {
+	switch strings.ToLower(mode) {
+	case "disable", "disabled", "off", "false":
+		return true
+	}
+	return false
+}
+
+// mysqlCustomTLS is true when the mysql driver needs a registered tls
+// config.
+func (db *Config) mysqlCustomTLS() bool {
+	return db.Type == MySQLDBType && !sslDisabled(db.SSLMode) &&
+		(db.TLS != nil || db.hasTLSMaterial())
+}
+
+// pgCustomTLS is true when tls is negotiated by the dialer because the
+// postgres driver can't be given a tls config.
+func (db *Config) pgCustomTLS() bool {
+	return db.Type.postgresWire() && db.TLS != nil && !sslDisabled(db.SSLMode)
+}
+
+// registerTLS registers the tls config with the driver if it needs one. The
+// dsn only names the registered config once this succeeded. Without a
+// registration func the ssl options fall back to the driver's own tls
+// param, but an explicit [Config.TLS] can't be used and is an error.
+func (db *Config) registerTLS() error {
+	if !db.mysqlCustomTLS() {
+		return nil
+	}
+	tlsMu.Lock()
+	fn, ok := tlsRegistrars[db.Type]
+	tlsMu.Unlock()
+	if !ok {
+		if db.TLS == nil {
+			return nil
+		}
+		return errors.Errorf("no tls registration func for %q, see RegisterTLSFunc", db.Type)
+	}
+	conf := db.TLS
+	if conf == nil {
+		var err error
+		if conf, err = db.TLSConfig(); err != nil {
+			return err
+		}
+	}
+	name := db.tlsName()
+	if err := fn(name, conf); err != nil {
+		return errors.Wrap(err, "failed to register tls config")
+	}
+	tlsMu.Lock()
+	tlsNames[name] = struct{}{}
+	tlsMu.Unlock()
+	return nil
+}
+
+// pgSSLRequest is the message that asks a postgres server to start tls.
+var pgSSLRequest = []byte{0, 0, 0, 8, 0x04, 0xd2, 0x16, 0x2f}
+
+// pgTLSDial returns a dialer that negotiates tls with a postgres server
+// before handing the connection to the driver.
+func pgTLSDial(dial DialFunc, conf *tls.Config) DialFunc {
+	if dial == nil {
+		var d net.Dialer
+		dial = d.DialContext
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		if deadline, ok := ctx.Deadline(); ok {
+			conn.SetDeadline(deadline)
+			defer conn.SetDeadline(time.Time{})
+		}
+		resp := make([]byte, 1)
+		if _, err = conn.Write(pgSSLRequest); err == nil {
+			_, err = io.ReadFull(conn, resp)
+		}
+		if err != nil {
+			conn.Close()
+			return nil, errors.Wrap(err, "failed to request ssl")
+		}
+		if resp[0] != 'S' {
+			conn.Close()
+			return nil, errors.New("server does not support ssl")
+		}
+		c := conf
+		if len(c.ServerName) == 0 && !c.InsecureSkipVerify {
+			c = conf.Clone()
+			c.ServerName, _, _ = net.SplitHostPort(addr)
+		}
+		tc := tls.Client(conn, c)
+		if err = tc.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, errors.Wrap(err, "tls handshake failed")
+		}
+		return tc, nil
+	}
+}
@@ -0,0 +1,14 @@
+// Package tomlconfig lets [db.LoadConfig] read ".toml" files.
+//
+//	import _ "github.com/harrybrwn/db/tomlconfig"
+package tomlconfig
+
+import (
+	"github.com/BurntSushi/toml"
+
+	"github.com/harrybrwn/db"
+)
+
+func init() {
+	db.RegisterConfigDecoder(".toml", toml.Unmarshal)
+}
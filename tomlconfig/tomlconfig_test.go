@@ -0,0 +1,46 @@
+package tomlconfig
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/matryer/is"
+
+	"github.com/harrybrwn/db"
+)
+
+func TestLoadConfig(t *testing.T) {
+	is := is.New(t)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "db.toml")
+	is.NoErr(os.WriteFile(path, []byte(`
+type = "postgres"
+host = "db.internal"
+port = "6432"
+user = "app"
+dbname = "billing"
+sslmode = "verify-full"
+connect_timeout = 5
+
+[params]
+search_path = "app"
+statement_cache_capacity = 0
+`), 0o600))
+	cfg, err := db.LoadConfig(path)
+	is.NoErr(err)
+	is.Equal(*cfg, db.Config{
+		Type:           db.PostgresDBType,
+		Host:           "db.internal",
+		Port:           "6432",
+		User:           "app",
+		DBName:         "billing",
+		SSLMode:        "verify-full",
+		ConnectTimeout: 5,
+		Params:         map[string]string{"search_path": "app", "statement_cache_capacity": "0"},
+	})
+
+	is.NoErr(os.WriteFile(path, []byte("port = [1]"), 0o600))
+	_, err = db.LoadConfig(path)
+	is.True(err != nil)
+}
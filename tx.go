@@ -4,6 +4,9 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -14,19 +17,14 @@ type Tx interface {
 	Rollback() error
 }
 
-// Begin will begin a transaction.
+// Begin will begin a transaction. The database can be a [DB], or anything that
+// implements [TxBeginor] such as [*sql.DB], [*sql.Conn] or a [StdDB].
 func Begin(ctx context.Context, opts *sql.TxOptions, database any) (Tx, error) {
 	switch db := database.(type) {
 	case Tx:
 		return nil, errors.New("cannot start a transaction from a transaction")
 	case DB:
 		return db.BeginTx(ctx, opts)
-	case *sql.DB:
-		t, err := db.BeginTx(ctx, opts)
-		if err != nil {
-			return nil, err
-		}
-		return &tx{Tx: t}, nil
 	case TxBeginor:
 		t, err := db.BeginTx(ctx, opts)
 		if err != nil {
@@ -37,29 +35,275 @@ func Begin(ctx context.Context, opts *sql.TxOptions, database any) (Tx, error) {
 	return nil, fmt.Errorf("cannot start a transaction using %T", database)
 }
 
-func TxDo(ctx context.Context, tx Tx, fn func(tx Tx) error) (err error) {
+// TxOpt is an option for the transaction helpers [TxDo] and [WithTx].
+type TxOpt func(*txOpts)
+
+type txOpts struct {
+	recoverPanics bool
+	verifier      *CommitVerifier
+	retry         *RetryPolicy
+}
+
+func (o *txOpts) commit(ctx context.Context, tx committer) error {
+	if o.verifier != nil {
+		return o.verifier.Commit(ctx, tx)
+	}
+	return tx.Commit()
+}
+
+// WithPanicRecovery will make the transaction helpers return a [*PanicError]
+// when the callback panics instead of re-panicking after the rollback.
+func WithPanicRecovery() TxOpt { return func(o *txOpts) { o.recoverPanics = true } }
+
+// WithTxRetry sets the policy used by [TxDoRetry]. The policy's Retryable
+// func defaults to retrying serialization failures and deadlocks.
+func WithTxRetry(policy RetryPolicy) TxOpt {
+	return func(o *txOpts) { o.retry = &policy }
+}
+
+func newTxOpts(opts []TxOpt) txOpts {
+	var o txOpts
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// PanicError is returned by the transaction helpers when the callback panics
+// and [WithPanicRecovery] is used.
+type PanicError struct {
+	// Value is the value that was passed to panic.
+	Value any
+	// Stack is the stack trace of the goroutine that panicked.
+	Stack []byte
+}
+
+func (pe *PanicError) Error() string {
+	return fmt.Sprintf("panic in transaction: %v", pe.Value)
+}
+
+// Unwrap returns the panic value if it was an error.
+func (pe *PanicError) Unwrap() error {
+	err, _ := pe.Value.(error)
+	return err
+}
+
+// recoverTx rolls back the transaction if fn panicked. The panic is then
+// either re-raised or stored in err depending on the options.
+func recoverTx(o *txOpts, rollback func() error, err *error) {
+	p := recover()
+	if p == nil {
+		return
+	}
+	_ = rollback()
+	if !o.recoverPanics {
+		panic(p)
+	}
+	*err = &PanicError{Value: p, Stack: debug.Stack()}
+}
+
+// TxDo runs fn inside the transaction. The transaction is committed if fn
+// returns nil and rolled back if fn returns an error or panics.
+func TxDo(ctx context.Context, tx Tx, fn func(tx Tx) error, opts ...TxOpt) (err error) {
+	o := newTxOpts(opts)
 	defer func() {
 		e := tx.Rollback()
 		if e != nil && err == nil && !errors.Is(e, sql.ErrTxDone) {
 			err = errors.WithStack(e)
 		}
 	}()
+	defer recoverTx(&o, tx.Rollback, &err)
 	err = fn(tx)
 	if err != nil {
 		return errors.WithStack(err)
 	}
-	err = errors.WithStack(tx.Commit())
+	err = errors.WithStack(o.commit(ctx, tx))
 	return
 }
 
+// TxDoRetry begins a transaction and runs fn in it like [TxDo]. When the
+// transaction fails with a serialization failure or a deadlock, as classified
+// by the database's [Dialect], it is rolled back and the whole transaction is
+// run again in a new one. This makes it safe to use SERIALIZABLE isolation
+// and databases like CockroachDB that ask clients to restart transactions.
+// fn may be called more than once so it must not have side effects outside
+// of the transaction.
+//
+// The database can be anything accepted by [Begin]. Retries are controlled
+// with [WithTxRetry] and default to 3 attempts.
+func TxDoRetry(ctx context.Context, database any, txOpts *sql.TxOptions, fn func(tx Tx) error, opts ...TxOpt) error {
+	o := newTxOpts(opts)
+	var policy RetryPolicy
+	if o.retry != nil {
+		policy = *o.retry
+	}
+	policy.setDefaults()
+	if policy.Retryable == nil {
+		d := DialectOf(database)
+		policy.Retryable = func(err error) bool {
+			switch d.ClassifyError(err) {
+			case ErrorKindSerializationFailure, ErrorKindDeadlock:
+				return true
+			}
+			return false
+		}
+	}
+	var err error
+	for attempt := 1; ; attempt++ {
+		var tx Tx
+		tx, err = Begin(ctx, txOpts, database)
+		if err == nil {
+			err = TxDo(ctx, tx, fn, opts...)
+		}
+		if err == nil || attempt >= policy.MaxAttempts || !policy.retryable(ctx, err) {
+			return err
+		}
+		t := time.NewTimer(policy.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		case <-t.C:
+		}
+	}
+}
+
 // NewTx creates a wrapper around the standard library [sql.Tx] and returns a
 // wrapper type that implements [DB].
 func NewTx(tr *sql.Tx) *tx { return &tx{Tx: tr} }
 
-type tx struct{ *sql.Tx }
+type tx struct {
+	*sql.Tx
+	// db is the wrapper that started the transaction, nil if the transaction
+	// was not started with [New].
+	db *database
+	// release is called once the transaction is done.
+	release func()
+	// written holds the tables written to by the transaction so their
+	// cached results can be dropped once it commits.
+	written []string
+	// audit collects the statements to audit once the transaction commits.
+	audit *txAudit
+}
 
 func (tx *tx) QueryContext(ctx context.Context, query string, v ...any) (Rows, error) {
-	return tx.Tx.QueryContext(ctx, query, v...)
+	var cancel context.CancelFunc
+	if tx.db != nil {
+		ctx, cancel = tx.db.statementContext(ctx)
+	}
+	var rows *sql.Rows
+	err := tx.run(ctx, opQuery, query, v, func(ctx context.Context, query string, args []any) (err error) {
+		rows, err = tx.Tx.QueryContext(ctx, query, args...)
+		return err
+	})
+	if err != nil {
+		if cancel != nil {
+			cancel()
+		}
+		return nil, err
+	}
+	if tx.db != nil && tx.db.cache != nil && classifyStatement(query)&^stmtRead != 0 {
+		tx.written = append(tx.written, writtenTables(query)...)
+	}
+	var r Rows = rows
+	if tx.db != nil && tx.db.utc {
+		r = &utcRows{r}
+	}
+	if tx.db != nil && tx.db.leaks != nil {
+		r = tx.db.leaks.track(r)
+	}
+	if cancel != nil {
+		return &releaseRows{Rows: r, release: cancel}, nil
+	}
+	return r, nil
+}
+
+func (tx *tx) ExecContext(ctx context.Context, query string, v ...any) (sql.Result, error) {
+	if tx.db != nil {
+		var cancel context.CancelFunc
+		if ctx, cancel = tx.db.statementContext(ctx); cancel != nil {
+			defer cancel()
+		}
+	}
+	var res sql.Result
+	err := tx.run(ctx, opExec, query, v, func(ctx context.Context, query string, args []any) (err error) {
+		res, err = tx.Tx.ExecContext(ctx, query, args...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if tx.db != nil && tx.db.cache != nil {
+		tx.written = append(tx.written, writtenTables(query)...)
+	}
+	if tx.audit != nil {
+		tx.audit.add(ctx, query, res)
+	}
+	return res, nil
+}
+
+func (tx *tx) Commit() error {
+	defer tx.done()
+	err := tx.run(context.Background(), opCommit, "", nil, func(context.Context, string, []any) error {
+		return tx.Tx.Commit()
+	})
+	if err == nil && tx.db != nil {
+		tx.db.counters.txDone(opCommit)
+		tx.db.invalidateWrites(context.Background(), tx.written)
+		if tx.audit != nil && len(tx.audit.event.Statements) > 0 {
+			tx.db.sendAudit(tx.audit.ctx, tx.audit.event)
+		}
+	}
+	return err
+}
+
+func (tx *tx) Rollback() error {
+	defer tx.done()
+	err := tx.run(context.Background(), opRollback, "", nil, func(context.Context, string, []any) error {
+		return tx.Tx.Rollback()
+	})
+	if err == nil && tx.db != nil {
+		tx.db.counters.txDone(opRollback)
+	}
+	return err
+}
+
+func (tx *tx) done() {
+	if tx.release != nil {
+		tx.release()
+		tx.release = nil
+	}
+}
+
+// Dialect returns the [Dialect] of the database that started the
+// transaction.
+func (tx *tx) Dialect() Dialect {
+	if tx.db == nil {
+		return nil
+	}
+	return tx.db.dialect
+}
+
+// run runs a statement in the transaction. Transactions started with [New]
+// get the same argument processing and error wrapping as the database.
+func (tx *tx) run(ctx context.Context, op, query string, args []any, fn queryFunc) error {
+	if tx.db == nil {
+		return fn(ctx, query, args)
+	}
+	start := now()
+	raw := query
+	query, args, err := tx.db.prepare(ctx, op, query, args)
+	if err == nil {
+		err = fn(ctx, query, args)
+	}
+	if tx.db.queryStats != nil && len(raw) > 0 {
+		tx.db.queryStats.record(raw, now().Sub(start), err)
+	}
+	if err != nil {
+		tx.db.logger.Debug(query, slog.String("op", op), slog.Any("error", err))
+		return newQueryError(op, query, args, now().Sub(start), err)
+	}
+	return nil
 }
 
 // BeginTx is a noop because this is already a transaction. Should be used with caution.
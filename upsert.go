@@ -0,0 +1,58 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+// UpsertStmt builds INSERT statements that update rows that already exist.
+// It generates "ON CONFLICT ... DO UPDATE" for postgres and sqlite and
+// "ON DUPLICATE KEY UPDATE" for mysql. Other databases fail with
+// [ErrNoConflictClause].
+type UpsertStmt struct {
+	dialect Dialect
+	table   string
+	opts    bulkOpts
+}
+
+// Upsert creates an [UpsertStmt] for a table. Rows that conflict on the
+// conflict columns have their update columns set to the new values. If there
+// are no update columns then every inserted column except the conflict
+// columns is updated. Mysql ignores the conflict columns and uses whichever
+// unique key was violated.
+func Upsert(d Dialect, table string, conflictCols, updateCols []string) *UpsertStmt {
+	u := &UpsertStmt{dialect: d, table: table}
+	WithUpsert(conflictCols, updateCols)(&u.opts)
+	return u
+}
+
+// SQL returns the statement for inserting rows with the given columns. It
+// fails with [ErrNoConflictClause] if the dialect can't express the upsert.
+func (u *UpsertStmt) SQL(cols []string, rows int) (string, error) {
+	values := make([][]any, rows)
+	for i := range values {
+		values[i] = make([]any, len(cols))
+	}
+	query, _, err := bulkInsertQuery(u.dialect, u.table, cols, values, &u.opts)
+	return query, err
+}
+
+// Exec upserts one row.
+func (u *UpsertStmt) Exec(ctx context.Context, db DB, cols []string, values ...any) (sql.Result, error) {
+	if len(cols) != len(values) {
+		return nil, errors.Errorf("got %d values for %d columns", len(values), len(cols))
+	}
+	query, args, err := bulkInsertQuery(u.dialect, u.table, cols, [][]any{values}, &u.opts)
+	if err != nil {
+		return nil, err
+	}
+	return db.ExecContext(ctx, query, args...)
+}
+
+// ExecRows upserts many rows in the same way as [BulkInsert].
+func (u *UpsertStmt) ExecRows(ctx context.Context, db DB, cols []string, rows [][]any, opts ...BulkOpt) (int64, error) {
+	opts = append(opts, WithUpsert(u.opts.conflictCols, u.opts.updateCols))
+	return bulkInsert(ctx, db, u.dialect, u.table, cols, rows, opts)
+}
@@ -0,0 +1,72 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/pkg/errors"
+)
+
+func TestUpsert(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	pool, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer pool.Close()
+	pool.SetMaxOpenConns(1)
+	d := New(pool)
+	_, err = d.ExecContext(ctx, "CREATE TABLE kv (k TEXT PRIMARY KEY, v TEXT, n INT)")
+	is.NoErr(err)
+
+	u := Upsert(DialectFor(SQLiteDBType), "kv", []string{"k"}, nil)
+	cols := []string{"k", "v", "n"}
+	_, err = u.Exec(ctx, d, cols, "a", "one", 1)
+	is.NoErr(err)
+	_, err = u.Exec(ctx, d, cols, "a", "uno", 2)
+	is.NoErr(err)
+	n, err := u.ExecRows(ctx, d, cols, [][]any{{"a", "eins", 3}, {"b", "two", 1}})
+	is.NoErr(err)
+	is.Equal(n, int64(2))
+	_, err = u.Exec(ctx, d, cols, "a")
+	is.True(err != nil)
+
+	only := Upsert(DialectFor(SQLiteDBType), "kv", []string{"k"}, []string{"n"})
+	_, err = only.Exec(ctx, d, cols, "b", "ignored", 5)
+	is.NoErr(err)
+
+	got := map[string]string{}
+	rows, err := d.QueryContext(ctx, "SELECT k, v || n FROM kv")
+	is.NoErr(err)
+	for rows.Next() {
+		var k, v string
+		is.NoErr(rows.Scan(&k, &v))
+		got[k] = v
+	}
+	is.NoErr(rows.Close())
+	is.Equal(got, map[string]string{"a": "eins3", "b": "two5"})
+}
+
+func TestUpsert_SQL(t *testing.T) {
+	is := is.New(t)
+	cols := []string{"id", "name", "email"}
+	query, err := Upsert(DialectFor(PostgresDBType), "users", []string{"id"}, nil).SQL(cols, 1)
+	is.NoErr(err)
+	is.Equal(query, `INSERT INTO "users" ("id", "name", "email") VALUES ($1, $2, $3) ON CONFLICT ("id") DO UPDATE SET "name" = EXCLUDED."name", "email" = EXCLUDED."email"`)
+	query, err = Upsert(DialectFor(MySQLDBType), "users", []string{"id"}, []string{"email"}).SQL(cols, 2)
+	is.NoErr(err)
+	is.Equal(query, "INSERT INTO `users` (`id`, `name`, `email`) VALUES (?, ?, ?), (?, ?, ?) ON DUPLICATE KEY UPDATE `email` = VALUES(`email`)")
+
+	// Upserts that the dialect can't express are errors instead of plain
+	// inserts.
+	_, err = Upsert(DialectFor(SQLServerDBType), "users", []string{"id"}, nil).SQL(cols, 1)
+	is.True(errors.Is(err, ErrNoConflictClause))
+	_, err = Upsert(DialectFor(MySQLDBType), "users", nil, []string{}).SQL(cols, 1)
+	is.True(errors.Is(err, ErrNoConflictClause))
+	_, err = Upsert(DialectFor(SQLServerDBType), "users", []string{"id"}, nil).Exec(context.Background(), nil, cols, 1, "a", "b")
+	is.True(errors.Is(err, ErrNoConflictClause))
+	_, err = BulkInsert(context.Background(), New(nil, WithDialect(DialectFor(SQLServerDBType))), "users", cols,
+		[][]any{{1, "a", "b"}}, WithIgnoreConflicts("id"))
+	is.True(errors.Is(err, ErrNoConflictClause))
+}
@@ -37,7 +37,9 @@ func WithTx(
 	db TxBeginor,
 	txOpts *sql.TxOptions,
 	fn func(tx *sql.Tx) error,
+	opts ...TxOpt,
 ) (err error) {
+	o := newTxOpts(opts)
 	if txOpts == nil {
 		txOpts = new(sql.TxOptions)
 	}
@@ -52,11 +54,12 @@ func WithTx(
 			err = errors.WithStack(e)
 		}
 	}()
+	defer recoverTx(&o, tx.Rollback, &err)
 	err = fn(tx)
 	if err != nil {
 		return errors.WithStack(err)
 	}
-	err = errors.WithStack(tx.Commit())
+	err = errors.WithStack(o.commit(ctx, tx))
 	return
 }
 
@@ -66,8 +69,27 @@ func WithTxStmt(
 	txOpts *sql.TxOptions,
 	query string,
 	fn func(stmt *sql.Stmt) error,
+	opts ...TxOpt,
 ) (err error) {
 	return WithTx(ctx, db, txOpts, func(tx *sql.Tx) error {
 		return WithStmt(ctx, tx, query, fn)
-	})
+	}, opts...)
+}
+
+// WithConn checks out a dedicated connection from the pool, runs fn with it,
+// and returns it to the pool. Use this for session scoped work like temporary
+// tables, advisory locks and session variables which need every statement to
+// run on the same connection.
+func WithConn(ctx context.Context, db *sql.DB, fn func(conn *sql.Conn) error) (err error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer func() {
+		e := conn.Close()
+		if e != nil && err == nil && !errors.Is(e, sql.ErrConnDone) {
+			err = errors.WithStack(e)
+		}
+	}()
+	return fn(conn)
 }
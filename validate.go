@@ -0,0 +1,98 @@
+package db
+
+import (
+	stderrors "errors"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Validate checks the config for missing and contradicting options so that
+// mistakes are found at startup instead of on the first query. Every
+// problem is returned, joined with [errors.Join].
+func (db *Config) Validate() error {
+	var errs []error
+	add := func(format string, args ...any) { errs = append(errs, errors.Errorf(format, args...)) }
+	keyPre := db.envKeyPrefix("")
+
+	if !knownType(db.Type) {
+		add("unknown database type %q, register a dialect or opener for it", db.Type)
+	}
+	if len(db.DBName) == 0 && db.Type != SQLiteDBType && (db.Type != MySQLDBType || len(db.Schema) == 0) {
+		add("database name is empty, set DBName or %sDB", keyPre)
+	}
+	if db.Type == MySQLDBType && len(db.DBName) > 0 && len(db.Schema) > 0 && db.DBName != db.Schema {
+		add("mysql schemas are databases but DBName %q and Schema %q are different", db.DBName, db.Schema)
+	}
+	if db.Type == SQLiteDBType {
+		return stderrors.Join(errs...)
+	}
+	if len(db.Host) == 0 && len(db.Hosts) == 0 {
+		add("host is empty, set Host or %sHOST", keyPre)
+	}
+	if len(db.Port) > 0 && !validPort(db.Port) {
+		add("invalid port %q, set %sPORT to a number from 1 to 65535", db.Port, keyPre)
+	}
+	for _, h := range db.Hosts {
+		if _, port, err := net.SplitHostPort(strings.TrimSpace(h)); err == nil && !validPort(port) {
+			add("invalid port in host %q", h)
+		}
+	}
+
+	mode := strings.ToLower(db.SSLMode)
+	switch {
+	case sslDisabled(mode) && (db.hasTLSMaterial() || db.TLS != nil):
+		add("sslmode %q disables ssl but ssl certificates or a TLS config are set", db.SSLMode)
+	case sslVerifies(mode) && db.TLS == nil && len(db.SSLCA) == 0 && len(db.SSLCAPEM) == 0:
+		add("sslmode %q verifies the server but there is no CA, set SSLCA or %sSSLCA_PEM", db.SSLMode, keyPre)
+	}
+	hasCert := len(db.SSLCert) > 0 || len(db.SSLCertPEM) > 0
+	hasKey := len(db.SSLKey) > 0 || len(db.SSLKeyPEM) > 0
+	if hasCert != hasKey {
+		add("ssl client certificates need both a cert and a key")
+	}
+	if len(db.Location) > 0 {
+		if loc, err := time.LoadLocation(db.Location); err != nil {
+			add("invalid time zone %q, set Location or %sLOC to a name like UTC or Europe/Berlin", db.Location, keyPre)
+		} else if db.UTC && loc != time.UTC {
+			add("UTC conflicts with the time zone %q, unset UTC or Location", db.Location)
+		}
+	}
+	if db.Type == MySQLDBType && strings.ContainsAny(db.AppName, ",:") {
+		add("mysql app name %q cannot contain a comma or a colon", db.AppName)
+	}
+	if len(db.Pooler) > 0 && !db.Type.postgresWire() {
+		add("pooler %q is only supported for postgres", db.Pooler)
+	}
+	return stderrors.Join(errs...)
+}
+
+// knownType is true for the built in types and types with a registered
+// [Dialect] or [Opener].
+func knownType(t Type) bool {
+	dialectsMu.RLock()
+	_, ok := dialects[t]
+	dialectsMu.RUnlock()
+	if ok {
+		return true
+	}
+	_, ok = openerFor(t)
+	return ok
+}
+
+func validPort(port string) bool {
+	n, err := strconv.ParseUint(port, 10, 16)
+	return err == nil && n > 0
+}
+
+// sslVerifies is true for ssl modes that verify the server's certificate.
+func sslVerifies(mode string) bool {
+	switch mode {
+	case "verify-full", "verify-ca", "verify_identity", "verify_ca":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+package db
+
+import (
+	"crypto/tls"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	is := is.New(t)
+	for _, cfg := range []Config{
+		{Type: PostgresDBType, Host: "localhost", Port: "5432", DBName: "app"},
+		{Type: PostgresDBType, Host: "db", DBName: "app", SSLMode: "verify-full", SSLCA: "/etc/ca.pem"},
+		{Type: MySQLDBType, Hosts: []string{"a:3306", "b"}, DBName: "app", SSLMode: "VERIFY_IDENTITY", TLS: &tls.Config{}},
+		{Type: SQLiteDBType, DBName: ":memory:"},
+		{Type: SQLiteDBType},
+	} {
+		is.NoErr(cfg.Validate())
+	}
+
+	for _, tt := range []struct {
+		cfg  Config
+		errs []string
+	}{
+		{
+			cfg:  Config{Type: "oracle", Host: "db", DBName: "app"},
+			errs: []string{`unknown database type "oracle"`},
+		},
+		{
+			cfg: Config{Type: PostgresDBType, Host: "db", Port: "99999", SSLMode: "verify-full"},
+			errs: []string{
+				"database name is empty, set DBName or POSTGRES_DB",
+				`invalid port "99999"`,
+				`sslmode "verify-full" verifies the server but there is no CA`,
+			},
+		},
+		{
+			cfg: Config{Type: MySQLDBType, Hosts: []string{"a:0"}, DBName: "app", SSLMode: "disabled", SSLCert: "c.pem", Pooler: PgBouncer, AppName: "api,role:admin"},
+			errs: []string{
+				`invalid port in host "a:0"`,
+				`sslmode "disabled" disables ssl`,
+				"need both a cert and a key",
+				`mysql app name "api,role:admin" cannot contain a comma or a colon`,
+				`pooler "pgbouncer" is only supported for postgres`,
+			},
+		},
+	} {
+		err := tt.cfg.Validate()
+		is.True(err != nil)
+		msgs := strings.Split(err.Error(), "\n")
+		is.Equal(len(msgs), len(tt.errs))
+		for i, exp := range tt.errs {
+			is.True(strings.Contains(msgs[i], exp))
+		}
+	}
+}
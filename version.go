@@ -0,0 +1,49 @@
+package db
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrStaleVersion is returned by versioned updates when the row's version
+// has changed since it was read, or the row was deleted.
+var ErrStaleVersion = errors.New("stale version")
+
+// UpdateVersioned sets columns of the row with the id only if its version
+// column still has the version that was read, and increments the version.
+// It returns the new version or [ErrStaleVersion] if no row was updated.
+// Mysql only counts rows that changed so the version is always changed by
+// the update.
+func UpdateVersioned(ctx context.Context, d DB, table, idCol, versionCol string, id any, version int64, cols []string, values ...any) (int64, error) {
+	if len(cols) != len(values) {
+		return 0, errors.Errorf("got %d values for %d columns", len(values), len(cols))
+	}
+	dialect := DialectOf(d)
+	set := make([]string, 0, len(cols)+1)
+	for i, c := range cols {
+		set = append(set, dialect.QuoteIdent(c)+" = "+dialect.Placeholder(i+1))
+	}
+	set = append(set, versionSet(dialect, versionCol))
+	args := append(values[:len(values):len(values)], id, version)
+	res, err := d.ExecContext(ctx, "UPDATE "+dialect.QuoteIdent(table)+" SET "+strings.Join(set, ", ")+
+		" WHERE "+dialect.QuoteIdent(idCol)+" = "+dialect.Placeholder(len(cols)+1)+
+		" AND "+dialect.QuoteIdent(versionCol)+" = "+dialect.Placeholder(len(cols)+2), args...)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		return 0, ErrStaleVersion
+	}
+	return version + 1, nil
+}
+
+func versionSet(d Dialect, col string) string {
+	col = d.QuoteIdent(col)
+	return col + " = " + col + " + 1"
+}
@@ -0,0 +1,59 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/pkg/errors"
+)
+
+func TestUpdateVersioned(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	d := newRepoDB(t)
+	_, err := d.ExecContext(ctx, "CREATE TABLE docs (id int, body text, version int)")
+	is.NoErr(err)
+	_, err = d.ExecContext(ctx, "INSERT INTO docs VALUES (1, 'a', 1)")
+	is.NoErr(err)
+
+	v, err := UpdateVersioned(ctx, d, "docs", "id", "version", 1, 1, []string{"body"}, "b")
+	is.NoErr(err)
+	is.Equal(v, int64(2))
+	_, err = UpdateVersioned(ctx, d, "docs", "id", "version", 1, 1, []string{"body"}, "c")
+	is.True(errors.Is(err, ErrStaleVersion))
+	_, err = UpdateVersioned(ctx, d, "docs", "id", "version", 2, 2, []string{"body"}, "c")
+	is.True(errors.Is(err, ErrStaleVersion))
+
+	var body string
+	is.NoErr(Get(ctx, d, &body, "SELECT body FROM docs WHERE id = 1"))
+	is.Equal(body, "b")
+}
+
+func TestRepo_Version(t *testing.T) {
+	type doc struct {
+		ID      int    `db:"id,pk"`
+		Body    string `db:"body"`
+		Version int    `db:"version,version"`
+	}
+	is := is.New(t)
+	ctx := context.Background()
+	d := newRepoDB(t)
+	_, err := d.ExecContext(ctx, "CREATE TABLE docs (id int, body text, version int)")
+	is.NoErr(err)
+	docs := NewRepo[doc]("docs")
+	is.NoErr(docs.Insert(ctx, d, &doc{ID: 1, Body: "a", Version: 1}))
+
+	a, err := docs.Get(ctx, d, 1)
+	is.NoErr(err)
+	b := *a
+	a.Body = "from a"
+	is.NoErr(docs.Update(ctx, d, a))
+	is.Equal(a.Version, 2)
+	b.Body = "from b"
+	is.True(errors.Is(docs.Update(ctx, d, &b), ErrStaleVersion))
+
+	got, err := docs.Get(ctx, d, 1)
+	is.NoErr(err)
+	is.Equal(*got, *a)
+}
@@ -0,0 +1,77 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Warmup opens n connections in parallel and pings them so the first
+// requests after a deploy don't wait for the pool to fill. The connections
+// are held until all of them are open and then returned to the pool, so n
+// should not be more than the pool's max idle connections, 2 by default,
+// or the extra connections are closed again. n is capped at the pool's max
+// open connections.
+//
+// Each statement is prepared and closed on every connection, which warms
+// the server's caches and fails at startup when a statement is invalid. The
+// first error is returned. Nothing is done when n is zero and a negative n
+// is an error.
+func Warmup(ctx context.Context, db *sql.DB, n int, statements ...string) error {
+	if n < 0 {
+		return errors.Errorf("cannot warm up %d connections", n)
+	}
+	if n == 0 {
+		return nil
+	}
+	if limit := db.Stats().MaxOpenConnections; limit > 0 && n > limit {
+		n = limit
+	}
+	var (
+		wg    sync.WaitGroup
+		conns = make([]*sql.Conn, n)
+		errs  = make([]error, n)
+	)
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conns[i], errs[i] = warmConn(ctx, db, statements)
+		}()
+	}
+	wg.Wait()
+	for _, c := range conns {
+		if c != nil {
+			c.Close()
+		}
+	}
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// warmConn takes a connection from the pool and prepares the statements on
+// it. The connection is returned even if there was an error so that the
+// caller can release it.
+func warmConn(ctx context.Context, db *sql.DB, statements []string) (*sql.Conn, error) {
+	c, err := db.Conn(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not open connection")
+	}
+	if err = c.PingContext(ctx); err != nil {
+		return c, errors.Wrap(err, "could not ping connection")
+	}
+	for _, query := range statements {
+		stmt, err := c.PrepareContext(ctx, query)
+		if err != nil {
+			return c, errors.Wrapf(err, "could not prepare %q", query)
+		}
+		stmt.Close()
+	}
+	return c, nil
+}
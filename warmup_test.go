@@ -0,0 +1,31 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestWarmup(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+	pool, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer pool.Close()
+	pool.SetMaxIdleConns(4)
+	pool.SetMaxOpenConns(3)
+
+	is.NoErr(Warmup(ctx, pool, 4, "SELECT 1", "SELECT ?"))
+	stats := pool.Stats()
+	is.Equal(stats.OpenConnections, 3)
+	is.Equal(stats.Idle, 3)
+
+	is.True(Warmup(ctx, pool, 2, "SELEC 1") != nil)
+	is.Equal(pool.Stats().InUse, 0)
+
+	is.True(Warmup(ctx, pool, -1) != nil)
+	// Nothing is prepared when there are no connections to warm up.
+	is.NoErr(Warmup(ctx, pool, 0, "SELEC 1"))
+}
@@ -0,0 +1,297 @@
+// Package watch polls a table for changed rows using an updated at column.
+// It is a lightweight alternative to logical replication that works with
+// any database, at the cost of missing deletes and rows whose timestamps are
+// older than the last change seen.
+package watch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/harrybrwn/db"
+)
+
+// DefaultTable is the name of the checkpoint table.
+const DefaultTable = "watch_checkpoints"
+
+// Change is a row that was inserted or updated since the last checkpoint.
+type Change struct {
+	// Key is the value of the key column.
+	Key any
+	// UpdatedAt is the value of the updated at column.
+	UpdatedAt time.Time
+	// Row holds every column of the row by name.
+	Row map[string]any
+
+	ack func()
+}
+
+// Ack marks the change as handled. The checkpoint only moves past a batch
+// of changes once all of them have been acked and the next batch is not
+// polled until then. Calling Ack more than once is a no-op.
+func (c *Change) Ack() {
+	if c.ack != nil {
+		c.ack()
+	}
+}
+
+// Option configures [Watch].
+type Option func(*options)
+
+type options struct {
+	table     string
+	name      string
+	batchSize int
+	logger    *slog.Logger
+	dialect   db.Dialect
+}
+
+// WithTable sets the name of the checkpoint table.
+func WithTable(name string) Option { return func(o *options) { o.table = name } }
+
+// WithName sets the name the checkpoint is saved under. Defaults to the
+// watched table's name. Use different names for watchers of the same table
+// that should each see every change.
+func WithName(name string) Option { return func(o *options) { o.name = name } }
+
+// WithBatchSize sets the most rows read per poll. Defaults to 100.
+func WithBatchSize(n int) Option { return func(o *options) { o.batchSize = n } }
+
+// WithLogger sets the logger used for polling errors.
+func WithLogger(l *slog.Logger) Option { return func(o *options) { o.logger = l } }
+
+// WithDialect sets the dialect used to build queries. Defaults to the
+// dialect of the database.
+func WithDialect(d db.Dialect) Option { return func(o *options) { o.dialect = d } }
+
+func newOptions(d any, opts []Option) options {
+	o := options{table: DefaultTable, batchSize: 100}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.logger == nil {
+		o.logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	if o.dialect == nil {
+		o.dialect = db.DialectOf(d)
+	}
+	return o
+}
+
+func (o *options) rebind(query string) string { return db.Rebind(o.dialect.Type(), query) }
+
+// CreateTable creates the checkpoint table if it doesn't exist.
+func CreateTable(ctx context.Context, d db.DB, opts ...Option) error {
+	o := newOptions(d, opts)
+	_, err := d.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+o.dialect.QuoteIdent(o.table)+` (
+		name       VARCHAR(255) NOT NULL PRIMARY KEY,
+		updated_at TIMESTAMP NOT NULL,
+		last_key   TEXT NOT NULL
+	)`)
+	return err
+}
+
+// Watch polls a table every interval for rows with a newer updated at
+// column than the last checkpoint and sends them on the returned channel in
+// order of updated at and key. Rows with the same updated at are told apart
+// by the key column so none are skipped between batches.
+//
+// Delivery is at least once. The checkpoint is saved in the checkpoint table
+// after every change in a batch has been acked with [Change.Ack], so changes
+// that were not acked before a crash are sent again on the next start. The
+// channel is closed when the context is done.
+//
+// The updated at column must scan into a [time.Time], which needs
+// parseTime=true on mysql. Rows committed with an updated at older than the
+// checkpoint, like ones from long running transactions, are not seen.
+func Watch(
+	ctx context.Context,
+	d db.DB,
+	table, keyColumn, updatedAtColumn string,
+	interval time.Duration,
+	opts ...Option,
+) (<-chan Change, error) {
+	o := newOptions(d, opts)
+	if len(o.name) == 0 {
+		o.name = table
+	}
+	w := watcher{db: d, opts: o, table: table, key: keyColumn, updatedAt: updatedAtColumn, interval: interval}
+	if err := w.loadCheckpoint(ctx); err != nil {
+		return nil, errors.Wrap(err, "failed to load checkpoint")
+	}
+	ch := make(chan Change)
+	go w.run(ctx, ch)
+	return ch, nil
+}
+
+type watcher struct {
+	db                    db.DB
+	opts                  options
+	table, key, updatedAt string
+	interval              time.Duration
+
+	// The high-water mark. started is false until there is a checkpoint.
+	started bool
+	lastAt  time.Time
+	lastKey string
+}
+
+func (w *watcher) run(ctx context.Context, ch chan<- Change) {
+	defer close(ch)
+	for {
+		n, err := w.poll(ctx, ch)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			w.opts.logger.Warn("failed to poll for changes",
+				slog.String("table", w.table),
+				slog.Any("error", err))
+		} else if n == w.opts.batchSize {
+			// There are probably more changes waiting.
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(w.interval):
+		}
+	}
+}
+
+// poll sends the next batch of changes, waits for them to be acked and saves
+// the checkpoint.
+func (w *watcher) poll(ctx context.Context, ch chan<- Change) (int, error) {
+	batch, err := w.read(ctx)
+	if err != nil || len(batch) == 0 {
+		return 0, err
+	}
+	acks := make(chan struct{}, len(batch))
+	for i := range batch {
+		batch[i].ack = sync.OnceFunc(func() { acks <- struct{}{} })
+		select {
+		case ch <- batch[i]:
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+	for range batch {
+		select {
+		case <-acks:
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+	last := batch[len(batch)-1]
+	if err = w.saveCheckpoint(ctx, last.UpdatedAt, keyString(last.Key)); err != nil {
+		return 0, errors.Wrap(err, "failed to save checkpoint")
+	}
+	return len(batch), nil
+}
+
+// read reads the changes after the high-water mark.
+func (w *watcher) read(ctx context.Context) ([]Change, error) {
+	d := w.opts.dialect
+	key, updatedAt := d.QuoteIdent(w.key), d.QuoteIdent(w.updatedAt)
+	query := `SELECT * FROM ` + d.QuoteIdent(w.table)
+	var args []any
+	if w.started {
+		query += ` WHERE ` + updatedAt + ` > ? OR (` + updatedAt + ` = ? AND ` + key + ` > ?)`
+		args = []any{w.lastAt, w.lastAt, w.lastKey}
+	}
+	query += ` ORDER BY ` + updatedAt + `, ` + key + ` ` + d.Limit(w.opts.batchSize, 0)
+	rows, err := w.db.QueryContext(ctx, w.opts.rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	r, ok := rows.(interface{ Columns() ([]string, error) })
+	if !ok {
+		return nil, errors.New("rows do not have column names")
+	}
+	cols, err := r.Columns()
+	if err != nil {
+		return nil, err
+	}
+	var batch []Change
+	for rows.Next() {
+		vals := make([]any, len(cols))
+		dest := make([]any, len(cols))
+		for i := range vals {
+			dest[i] = &vals[i]
+		}
+		if err = rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		c := Change{Row: make(map[string]any, len(cols))}
+		for i, col := range cols {
+			if b, ok := vals[i].([]byte); ok {
+				vals[i] = append([]byte(nil), b...)
+			}
+			c.Row[col] = vals[i]
+		}
+		c.Key = c.Row[w.key]
+		if c.UpdatedAt, ok = c.Row[w.updatedAt].(time.Time); !ok {
+			return nil, errors.Errorf("column %q is a %T, not a time", w.updatedAt, c.Row[w.updatedAt])
+		}
+		batch = append(batch, c)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return batch, rows.Close()
+}
+
+func (w *watcher) loadCheckpoint(ctx context.Context) error {
+	rows, err := w.db.QueryContext(ctx, w.opts.rebind(`SELECT updated_at, last_key FROM `+
+		w.opts.dialect.QuoteIdent(w.opts.table)+` WHERE name = ?`), w.opts.name)
+	if err != nil {
+		return err
+	}
+	err = db.ScanOne(rows, &w.lastAt, &w.lastKey)
+	if db.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	w.started = true
+	return nil
+}
+
+func (w *watcher) saveCheckpoint(ctx context.Context, at time.Time, key string) error {
+	table := w.opts.dialect.QuoteIdent(w.opts.table)
+	res, err := w.db.ExecContext(ctx, w.opts.rebind(`UPDATE `+table+
+		` SET updated_at = ?, last_key = ? WHERE name = ?`), at, key, w.opts.name)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		_, err = w.db.ExecContext(ctx, w.opts.rebind(`INSERT INTO `+table+
+			` (name, updated_at, last_key) VALUES (?, ?, ?)`), w.opts.name, at, key)
+		if err != nil {
+			return err
+		}
+	}
+	w.started, w.lastAt, w.lastKey = true, at, key
+	return nil
+}
+
+// keyString formats a key for the checkpoint table. Keys are compared
+// against the key column as a parameter so the database converts them back.
+func keyString(k any) string {
+	switch k := k.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(k)
+	}
+	return fmt.Sprint(k)
+}
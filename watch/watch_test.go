@@ -0,0 +1,115 @@
+package watch
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/harrybrwn/db"
+)
+
+func setup(t *testing.T) db.DB {
+	t.Helper()
+	pool, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.SetMaxOpenConns(1)
+	t.Cleanup(func() { pool.Close() })
+	d := db.New(pool, db.WithDialect(db.DialectFor(db.SQLiteDBType)))
+	ctx := context.Background()
+	if err = CreateTable(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = d.ExecContext(ctx, `CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT, updated_at TIMESTAMP NOT NULL)`); err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func recv(t *testing.T, ch <-chan Change) Change {
+	t.Helper()
+	select {
+	case c, ok := <-ch:
+		if !ok {
+			t.Fatal("channel closed")
+		}
+		return c
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for change")
+	}
+	return Change{}
+}
+
+func TestWatch(t *testing.T) {
+	is := is.New(t)
+	d := setup(t)
+	ts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	insert := func(id int, name string, at time.Time) {
+		t.Helper()
+		_, err := d.ExecContext(context.Background(),
+			`INSERT OR REPLACE INTO items (id, name, updated_at) VALUES (?, ?, ?)`, id, name, at)
+		is.NoErr(err)
+	}
+	insert(1, "a", ts)
+	insert(2, "b", ts)
+	insert(3, "c", ts.Add(time.Second))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, err := Watch(ctx, d, "items", "id", "updated_at", time.Millisecond, WithBatchSize(2))
+	is.NoErr(err)
+	c := recv(t, ch)
+	is.Equal(c.Key, int64(1))
+	is.Equal(c.Row["name"], "a")
+	is.True(c.UpdatedAt.Equal(ts))
+	c.Ack()
+	c.Ack()
+	c = recv(t, ch)
+	is.Equal(c.Key, int64(2))
+	c.Ack()
+	c = recv(t, ch)
+	is.Equal(c.Key, int64(3))
+	// Not acked so the checkpoint stays after the first batch.
+	cancel()
+	for range ch {
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	ch, err = Watch(ctx, d, "items", "id", "updated_at", time.Millisecond)
+	is.NoErr(err)
+	c = recv(t, ch)
+	is.Equal(c.Key, int64(3))
+	c.Ack()
+	insert(1, "a2", ts.Add(time.Minute))
+	c = recv(t, ch)
+	is.Equal(c.Key, int64(1))
+	is.Equal(c.Row["name"], "a2")
+	c.Ack()
+
+	// Separate checkpoints by name.
+	other, err := Watch(ctx, d, "items", "id", "updated_at", time.Millisecond, WithName("other"))
+	is.NoErr(err)
+	is.Equal(recv(t, other).Key, int64(2))
+}
+
+func TestWatch_Errors(t *testing.T) {
+	is := is.New(t)
+	d := setup(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, err := Watch(ctx, d, "items", "id", "updated_at", time.Millisecond, WithTable("missing"))
+	is.True(err != nil)
+
+	w := watcher{db: d, opts: newOptions(d, nil), table: "items", key: "id", updatedAt: "name"}
+	_, err = d.ExecContext(ctx, `INSERT INTO items VALUES (1, 'a', CURRENT_TIMESTAMP)`)
+	is.NoErr(err)
+	_, err = w.read(ctx)
+	is.True(err != nil) // name is not a time
+	is.Equal(keyString(nil), "")
+	is.Equal(keyString([]byte("k")), "k")
+}
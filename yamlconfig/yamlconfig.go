@@ -0,0 +1,15 @@
+// Package yamlconfig lets [db.LoadConfig] read ".yaml" and ".yml" files.
+//
+//	import _ "github.com/harrybrwn/db/yamlconfig"
+package yamlconfig
+
+import (
+	"gopkg.in/yaml.v3"
+
+	"github.com/harrybrwn/db"
+)
+
+func init() {
+	db.RegisterConfigDecoder(".yaml", yaml.Unmarshal)
+	db.RegisterConfigDecoder(".yml", yaml.Unmarshal)
+}